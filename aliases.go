@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// An alias file names synonyms, one "alias -> canonical" pair per line (or
+// tab-separated), with # comments:
+//
+//	K8s -> Kubernetes
+//	k8s -> Kubernetes
+//
+// Nodes named by an alias are renamed, and all nodes that end up with the
+// same canonical name are merged into one, so synonyms don't split the
+// graph.
+
+// readAliases parses an alias file into a map from lower-cased alias to
+// canonical name.
+func readAliases(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	aliases := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		alias, canonical, ok := strings.Cut(text, "\t")
+		if !ok {
+			alias, canonical, ok = strings.Cut(text, " -> ")
+		}
+		alias, canonical = strings.TrimSpace(alias), strings.TrimSpace(canonical)
+		if !ok || alias == "" || canonical == "" {
+			return nil, fmt.Errorf("%s:%d: want \"alias -> canonical\"", path, line)
+		}
+		aliases[strings.ToLower(alias)] = canonical
+	}
+	return aliases, sc.Err()
+}
+
+// applyAliases renames aliased nodes to their canonical name, matching
+// names case-insensitively, and merges the nodes sharing a canonical name
+// into the first of them. The names merged away are listed in its aliases
+// attribute. Groups are left alone.
+func applyAliases(g *graph, aliases map[string]string) {
+	canonical := map[string]string{} // lower-cased -> as the file spells it
+	for _, c := range aliases {
+		canonical[strings.ToLower(c)] = c
+	}
+	addAlias := func(i int, name string) {
+		merged := splitList(g.Nodes[i].Attrs["aliases"])
+		if !slices.Contains(merged, name) {
+			g.setAttr(i, "aliases", strings.Join(append(merged, name), ","))
+		}
+	}
+	first := map[string]string{} // canonical name -> surviving node ID
+	into := map[string]string{}
+	for i, n := range g.Nodes {
+		if n.Type == "group" {
+			continue
+		}
+		name := n.Name
+		if c, ok := aliases[strings.ToLower(name)]; ok {
+			name = c
+		}
+		key := strings.ToLower(name)
+		name, ok := canonical[key]
+		if !ok {
+			continue
+		}
+		g.Nodes[i].Name = name
+		keep := i
+		if id, ok := first[key]; ok {
+			into[n.ID] = id
+			keep = g.byID[id]
+		} else {
+			first[key] = n.ID
+		}
+		if n.Name != name {
+			addAlias(keep, n.Name)
+		}
+	}
+	g.mergeNodes(into)
+}
+
+// mergeCaseInsensitive merges the nodes whose names differ only in case
+// into the first of them, for -merge-case-insensitive, listing the other
+// spellings in its aliases attribute. Groups are left alone. It returns
+// how many nodes were merged away.
+func mergeCaseInsensitive(g *graph) int {
+	first := map[string]int{} // lower-cased name -> surviving node index
+	into := map[string]string{}
+	for i, n := range g.Nodes {
+		if n.Type == "group" || n.Name == "" {
+			continue
+		}
+		key := strings.ToLower(n.Name)
+		keep, ok := first[key]
+		if !ok {
+			first[key] = i
+			continue
+		}
+		into[n.ID] = g.Nodes[keep].ID
+		merged := splitList(g.Nodes[keep].Attrs["aliases"])
+		if n.Name != g.Nodes[keep].Name && !slices.Contains(merged, n.Name) {
+			g.setAttr(keep, "aliases", strings.Join(append(merged, n.Name), ","))
+		}
+	}
+	g.mergeNodes(into)
+	return len(into)
+}
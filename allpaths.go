@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// allpaths lists every simple path from one set of nodes to another, e.g.
+// to trace which requirements lead to which implementations. A path ends at
+// the first target it reaches; parallel edges with the same label count
+// once.
+
+type pathStep struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Label string `json:"label,omitempty"` // of the edge leading here
+}
+
+type pathFinder struct {
+	g          *graph
+	isTarget   map[string]bool
+	maxLen     int
+	undirected bool
+	limit      int
+
+	paths   [][]pathStep
+	onPath  map[string]bool
+	stack   []pathStep
+	limited bool
+}
+
+// walk extends the current path from id, recording it at each target.
+func (f *pathFinder) walk(id string) {
+	if f.limited {
+		return
+	}
+	if len(f.stack) > 1 && f.isTarget[id] {
+		if len(f.paths) == f.limit {
+			f.limited = true
+			return
+		}
+		f.paths = append(f.paths, append([]pathStep(nil), f.stack...))
+		return
+	}
+	if len(f.stack)-1 >= f.maxLen {
+		return
+	}
+	type hop struct{ to, label string }
+	seen := map[hop]bool{}
+	follow := func(to, label string) {
+		h := hop{to, label}
+		if f.onPath[to] || seen[h] {
+			return
+		}
+		if _, ok := f.g.node(to); !ok {
+			return // dangling edge
+		}
+		seen[h] = true
+		f.onPath[to] = true
+		f.stack = append(f.stack, pathStep{ID: to, Name: f.g.name(to), Label: label})
+		f.walk(to)
+		f.stack = f.stack[:len(f.stack)-1]
+		f.onPath[to] = false
+	}
+	for _, ei := range f.g.out[id] {
+		follow(f.g.Edges[ei].To, f.g.Edges[ei].Label)
+	}
+	if f.undirected {
+		for _, ei := range f.g.in[id] {
+			follow(f.g.Edges[ei].From, f.g.Edges[ei].Label)
+		}
+	}
+}
+
+func runAllPaths(args []string) {
+	fs := flag.NewFlagSet("allpaths", flag.ExitOnError)
+	var from, to stringsFlag
+	fs.Var(&from, "from", "start `node`: an ID, name, /regexp/ or @file of them (repeatable)")
+	fs.Var(&to, "to", "end `node`, as for -from (repeatable)")
+	maxLen := fs.Int("max-length", 6, "longest path to list, in edges")
+	undirected := fs.Bool("undirected", false, "follow edges in both directions")
+	limit := fs.Int("limit", 10000, "stop after this many paths")
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	asJSON := fs.Bool("json", false, "print paths as JSON lines (lists of steps)")
+	fs.Parse(args)
+	if fs.NArg() != 1 || len(from) == 0 || len(to) == 0 {
+		fatalf("allpaths: usage: canvas_tool allpaths -from node -to node [flags] file.canvas")
+	}
+	sources, err := nodeMatcherFrom(from)
+	if err != nil {
+		fatalf("allpaths: -from: %v", err)
+	}
+	targets, err := nodeMatcherFrom(to)
+	if err != nil {
+		fatalf("allpaths: -to: %v", err)
+	}
+	c, err := loadCanvas(fs.Arg(0))
+	if err != nil {
+		fatalf("allpaths: %v", err)
+	}
+	g := buildGraph(c, *keepPath)
+
+	f := &pathFinder{g: g, isTarget: map[string]bool{}, maxLen: *maxLen, undirected: *undirected, limit: *limit, onPath: map[string]bool{}}
+	var starts []string
+	for _, n := range g.Nodes {
+		if targets.match(n) {
+			f.isTarget[n.ID] = true
+		}
+		if sources.match(n) {
+			starts = append(starts, n.ID)
+		}
+	}
+	if len(starts) == 0 {
+		fatalf("allpaths: -from matches no node")
+	}
+	if len(f.isTarget) == 0 {
+		fatalf("allpaths: -to matches no node")
+	}
+	for _, id := range starts {
+		f.onPath[id] = true
+		f.stack = []pathStep{{ID: id, Name: g.name(id)}}
+		f.walk(id)
+		f.onPath[id] = false
+	}
+	if f.limited {
+		slog.Warn(fmt.Sprintf("allpaths: stopped after %d paths (-limit)", *limit))
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	for _, p := range f.paths {
+		if *asJSON {
+			enc.Encode(p)
+			continue
+		}
+		var sb strings.Builder
+		for i, s := range p {
+			switch {
+			case i == 0:
+			case s.Label != "":
+				sb.WriteString(" -" + s.Label + "-> ")
+			default:
+				sb.WriteString(" -> ")
+			}
+			sb.WriteString(s.Name)
+		}
+		fmt.Fprintln(w, sb.String())
+	}
+	if len(f.paths) == 0 {
+		w.Flush()
+		os.Exit(1) // like grep
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Minimal Arrow IPC file writer (Feather v2): a schema of nullable or
+// non-nullable Utf8 columns and a single record batch.
+
+const (
+	arrowV5          = 4 // MetadataVersion
+	arrowHdrSchema   = 1 // MessageHeader union
+	arrowHdrBatch    = 3
+	arrowTypeUtf8    = 5 // Type union
+	arrowMagic       = "ARROW1"
+	arrowContinueTag = 0xFFFFFFFF
+)
+
+type arrowBlock struct {
+	offset  int64
+	metaLen int32
+	bodyLen int64
+}
+
+func writeArrow(out io.Writer, g *graph, opts exportOptions) error {
+	t, err := selectTable(g, opts.opt("arrow", "table", "edges"))
+	if err != nil {
+		return err
+	}
+
+	var file bytes.Buffer
+	file.WriteString(arrowMagic + "\x00\x00")
+
+	schema := arrowSchema(t)
+	writeArrowMessage(&file, arrowHdrSchema, schema, nil)
+
+	body, nodes, buffers := arrowBatchBody(t)
+	batch := &fbTable{}
+	batch.set(0, fbScalar{8, uint64(len(t.rows))})
+	batch.set(1, nodes)
+	batch.set(2, buffers)
+	block := writeArrowMessage(&file, arrowHdrBatch, batch, body)
+
+	binary.Write(&file, binary.LittleEndian, uint32(arrowContinueTag))
+	binary.Write(&file, binary.LittleEndian, uint32(0)) // end of stream
+
+	blocks := fbStructs{align: 8}
+	blocks.add(block.offset, int64(block.metaLen), block.bodyLen) // int32 + 4 bytes padding packed as one int64 slot
+	footer := &fbTable{}
+	footer.set(0, fbScalar{2, arrowV5})
+	footer.set(1, schema)
+	footer.set(2, fbStructs{align: 8})
+	footer.set(3, blocks)
+	fb := fbEncode(footer)
+	file.Write(fb)
+	binary.Write(&file, binary.LittleEndian, uint32(len(fb)))
+	file.WriteString(arrowMagic)
+
+	_, err = out.Write(file.Bytes())
+	return err
+}
+
+func arrowSchema(t table) *fbTable {
+	var fields fbTables
+	for _, col := range t.columns {
+		f := &fbTable{}
+		f.set(0, fbString(col.name))
+		f.set(1, fbBool(!col.required))
+		f.set(2, fbScalar{1, arrowTypeUtf8})
+		f.set(3, &fbTable{}) // Utf8 has no fields
+		f.set(5, fbTables{}) // readers expect children to be present
+		fields = append(fields, f)
+	}
+	s := &fbTable{}
+	s.set(0, fbScalar{2, 0}) // little endian
+	s.set(1, fields)
+	return s
+}
+
+// arrowBatchBody lays out validity, offset and data buffers for every column,
+// each padded to 8 bytes.
+func arrowBatchBody(t table) (body []byte, nodes, buffers fbStructs) {
+	nodes = fbStructs{align: 8}
+	buffers = fbStructs{align: 8}
+	var buf bytes.Buffer
+	addBuffer := func(b []byte) {
+		start := buf.Len()
+		buf.Write(b)
+		for buf.Len()%8 != 0 {
+			buf.WriteByte(0)
+		}
+		buffers.add(int64(start), int64(len(b)))
+	}
+	for ci := range t.columns {
+		n := len(t.rows)
+		valid := make([]byte, (n+7)/8)
+		offsets := make([]byte, 0, 4*(n+1))
+		offsets = binary.LittleEndian.AppendUint32(offsets, 0)
+		var data []byte
+		nulls := 0
+		for ri, row := range t.rows {
+			if v := row[ci]; v != nil {
+				valid[ri/8] |= 1 << (ri % 8)
+				data = append(data, *v...)
+			} else {
+				nulls++
+			}
+			offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+		}
+		nodes.add(int64(n), int64(nulls))
+		if nulls == 0 {
+			valid = nil // all valid; the bitmap may be omitted
+		}
+		addBuffer(valid)
+		addBuffer(offsets)
+		addBuffer(data)
+	}
+	return buf.Bytes(), nodes, buffers
+}
+
+// writeArrowMessage writes an encapsulated IPC message and returns its block.
+func writeArrowMessage(w *bytes.Buffer, hdrType byte, hdr *fbTable, body []byte) arrowBlock {
+	msg := &fbTable{}
+	msg.set(0, fbScalar{2, arrowV5})
+	msg.set(1, fbScalar{1, uint64(hdrType)})
+	msg.set(2, hdr)
+	msg.set(3, fbScalar{8, uint64(len(body))})
+	fb := fbEncode(msg)
+
+	start := int64(w.Len())
+	padded := len(fb)
+	for (8+padded)%8 != 0 {
+		padded++
+	}
+	binary.Write(w, binary.LittleEndian, uint32(arrowContinueTag))
+	binary.Write(w, binary.LittleEndian, uint32(padded))
+	w.Write(fb)
+	w.Write(make([]byte, padded-len(fb)))
+	w.Write(body)
+	return arrowBlock{offset: start, metaLen: int32(8 + padded), bodyLen: int64(len(body))}
+}
+
+// FlatBuffers encoding. Objects are written top-down so every uoffset points
+// forward, which keeps the encoder a single pass plus back-patching.
+
+type fbTable struct{ fields []any }
+
+type fbScalar struct {
+	size int
+	bits uint64
+}
+
+type fbString string
+
+type fbTables []*fbTable
+
+// fbStructs is a vector of fixed-size structs, built from 8-byte words.
+type fbStructs struct {
+	align int
+	words []int64
+	n     int
+}
+
+func fbBool(b bool) fbScalar {
+	if b {
+		return fbScalar{1, 1}
+	}
+	return fbScalar{1, 0}
+}
+
+func (t *fbTable) set(slot int, v any) {
+	for len(t.fields) <= slot {
+		t.fields = append(t.fields, nil)
+	}
+	t.fields[slot] = v
+}
+
+// add appends one struct made of the given 8-byte words.
+func (s *fbStructs) add(words ...int64) {
+	s.words = append(s.words, words...)
+	s.n++
+}
+
+func fbEncode(root *fbTable) []byte {
+	e := &fbEncoder{buf: make([]byte, 4)}
+	pos := e.table(root)
+	binary.LittleEndian.PutUint32(e.buf[0:], uint32(pos))
+	e.pad(8)
+	return e.buf
+}
+
+type fbEncoder struct{ buf []byte }
+
+func (e *fbEncoder) pad(align int) {
+	for len(e.buf)%align != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *fbEncoder) patch(at, target int) {
+	binary.LittleEndian.PutUint32(e.buf[at:], uint32(target-at))
+}
+
+func fbInlineSize(v any) int {
+	if s, ok := v.(fbScalar); ok {
+		return s.size
+	}
+	return 4 // uoffset
+}
+
+func (e *fbEncoder) table(t *fbTable) int {
+	voff := make([]uint16, len(t.fields))
+	off := 4 // soffset to the vtable
+	for _, size := range []int{8, 4, 2, 1} {
+		for slot, v := range t.fields {
+			if v == nil || fbInlineSize(v) != size {
+				continue
+			}
+			for off%size != 0 {
+				off++
+			}
+			voff[slot] = uint16(off)
+			off += size
+		}
+	}
+
+	for off%4 != 0 {
+		off++
+	}
+
+	e.pad(2)
+	vtPos := len(e.buf)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, uint16(4+2*len(voff)))
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, uint16(off))
+	for _, o := range voff {
+		e.buf = binary.LittleEndian.AppendUint16(e.buf, o)
+	}
+
+	e.pad(8)
+	pos := len(e.buf)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, uint32(int32(pos-vtPos)))
+	e.buf = append(e.buf, make([]byte, off-4)...)
+	for slot, v := range t.fields {
+		s, ok := v.(fbScalar)
+		if !ok {
+			continue
+		}
+		at := pos + int(voff[slot])
+		switch s.size {
+		case 1:
+			e.buf[at] = byte(s.bits)
+		case 2:
+			binary.LittleEndian.PutUint16(e.buf[at:], uint16(s.bits))
+		case 4:
+			binary.LittleEndian.PutUint32(e.buf[at:], uint32(s.bits))
+		case 8:
+			binary.LittleEndian.PutUint64(e.buf[at:], s.bits)
+		}
+	}
+	for slot, v := range t.fields {
+		if _, ok := v.(fbScalar); v == nil || ok {
+			continue
+		}
+		e.patch(pos+int(voff[slot]), e.value(v))
+	}
+	return pos
+}
+
+func (e *fbEncoder) value(v any) int {
+	switch v := v.(type) {
+	case *fbTable:
+		return e.table(v)
+	case fbString:
+		e.pad(4)
+		pos := len(e.buf)
+		e.buf = binary.LittleEndian.AppendUint32(e.buf, uint32(len(v)))
+		e.buf = append(e.buf, v...)
+		e.buf = append(e.buf, 0)
+		return pos
+	case fbTables:
+		e.pad(4)
+		pos := len(e.buf)
+		e.buf = binary.LittleEndian.AppendUint32(e.buf, uint32(len(v)))
+		e.buf = append(e.buf, make([]byte, 4*len(v))...)
+		for i, t := range v {
+			e.patch(pos+4+4*i, e.table(t))
+		}
+		return pos
+	case fbStructs:
+		for (len(e.buf)+4)%max(v.align, 4) != 0 {
+			e.buf = append(e.buf, 0)
+		}
+		pos := len(e.buf)
+		e.buf = binary.LittleEndian.AppendUint32(e.buf, uint32(v.n))
+		for _, w := range v.words {
+			e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(w))
+		}
+		return pos
+	}
+	panic("flatbuffers: unsupported value")
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// fbReader reads FlatBuffers tables by position, independently of
+// fbEncoder.
+type fbReader []byte
+
+func (b fbReader) u16(at int) int { return int(binary.LittleEndian.Uint16(b[at:])) }
+func (b fbReader) u32(at int) int { return int(binary.LittleEndian.Uint32(b[at:])) }
+func (b fbReader) i64(at int) int { return int(int64(binary.LittleEndian.Uint64(b[at:]))) }
+
+// field returns where slot of the table at pos is stored, or ok=false
+// when it is absent.
+func (b fbReader) field(pos, slot int) (int, bool) {
+	vt := pos - int(int32(b.u32(pos)))
+	if 4+2*slot >= b.u16(vt) {
+		return 0, false
+	}
+	off := b.u16(vt + 4 + 2*slot)
+	return pos + off, off != 0
+}
+
+// ref follows the uoffset stored in slot.
+func (b fbReader) ref(pos, slot int) int {
+	at, ok := b.field(pos, slot)
+	if !ok {
+		panic(fmt.Sprintf("flatbuffers: table at %d has no field %d", pos, slot))
+	}
+	return at + b.u32(at)
+}
+
+func (b fbReader) scalar(pos, slot, size int) int {
+	at, ok := b.field(pos, slot)
+	if !ok {
+		return 0 // the default
+	}
+	switch size {
+	case 1:
+		return int(b[at])
+	case 2:
+		return b.u16(at)
+	}
+	return b.i64(at)
+}
+
+func (b fbReader) str(pos, slot int) string {
+	at := b.ref(pos, slot)
+	return string(b[at+4 : at+4+b.u32(at)])
+}
+
+// vector returns the position of the first element and the length.
+func (b fbReader) vector(pos, slot int) (int, int) {
+	at := b.ref(pos, slot)
+	return at + 4, b.u32(at)
+}
+
+// readArrow decodes a file written by writeArrow back into column names,
+// their nullability, and the rows of its one record batch.
+func readArrow(data []byte) (names []string, nullable []bool, rows [][]*string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	if !bytes.HasPrefix(data, []byte(arrowMagic+"\x00\x00")) || !bytes.HasSuffix(data, []byte(arrowMagic)) {
+		return nil, nil, nil, fmt.Errorf("missing %s magic", arrowMagic)
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+	footer := fbReader(data[len(data)-10-footerLen : len(data)-10])
+	root := footer.u32(0)
+	if v := footer.scalar(root, 0, 2); v != arrowV5 {
+		return nil, nil, nil, fmt.Errorf("metadata version %d, want %d", v, arrowV5)
+	}
+
+	schema := footer.ref(root, 1)
+	start, n := footer.vector(schema, 1)
+	for i := 0; i < n; i++ {
+		f := start + 4*i + footer.u32(start+4*i)
+		if typ := footer.scalar(f, 2, 1); typ != arrowTypeUtf8 {
+			return nil, nil, nil, fmt.Errorf("field %d has type %d, want Utf8", i, typ)
+		}
+		names = append(names, footer.str(f, 0))
+		nullable = append(nullable, footer.scalar(f, 1, 1) == 1)
+	}
+
+	blocks, nb := footer.vector(root, 3)
+	if nb != 1 {
+		return nil, nil, nil, fmt.Errorf("%d record batches, want 1", nb)
+	}
+	offset, metaLen, bodyLen := footer.i64(blocks), int(int32(footer.u32(blocks+8))), footer.i64(blocks+16)
+	if binary.LittleEndian.Uint32(data[offset:]) != arrowContinueTag {
+		return nil, nil, nil, fmt.Errorf("record batch at %d lacks the continuation marker", offset)
+	}
+	if 8+int(binary.LittleEndian.Uint32(data[offset+4:])) != metaLen || metaLen%8 != 0 {
+		return nil, nil, nil, fmt.Errorf("record batch metadata is %d bytes, the block says %d", 8+binary.LittleEndian.Uint32(data[offset+4:]), metaLen)
+	}
+	msg := fbReader(data[offset+8 : offset+metaLen])
+	mroot := msg.u32(0)
+	if typ := msg.scalar(mroot, 1, 1); typ != arrowHdrBatch {
+		return nil, nil, nil, fmt.Errorf("message type %d, want a record batch", typ)
+	}
+	if msg.scalar(mroot, 3, 8) != bodyLen {
+		return nil, nil, nil, fmt.Errorf("message body is %d bytes, the block says %d", msg.scalar(mroot, 3, 8), bodyLen)
+	}
+	batch := msg.ref(mroot, 2)
+	length := msg.scalar(batch, 0, 8)
+	nodes, nn := msg.vector(batch, 1)
+	buffers, nbuf := msg.vector(batch, 2)
+	if nn != len(names) || nbuf != 3*len(names) {
+		return nil, nil, nil, fmt.Errorf("%d field nodes and %d buffers for %d columns", nn, nbuf, len(names))
+	}
+	body := data[offset+metaLen : offset+metaLen+bodyLen]
+	buffer := func(i int) []byte {
+		at, size := msg.i64(buffers+16*i), msg.i64(buffers+16*i+8)
+		if at%8 != 0 {
+			panic(fmt.Sprintf("buffer %d at %d is not 8-byte aligned", i, at))
+		}
+		return body[at : at+size]
+	}
+	rows = make([][]*string, length)
+	for i := range rows {
+		rows[i] = make([]*string, len(names))
+	}
+	for ci := range names {
+		if msg.i64(nodes+16*ci) != length {
+			return nil, nil, nil, fmt.Errorf("column %s: %d values, want %d", names[ci], msg.i64(nodes+16*ci), length)
+		}
+		nulls, valid, offsets, values := msg.i64(nodes+16*ci+8), buffer(3*ci), buffer(3*ci+1), buffer(3*ci+2)
+		if nulls > 0 && !nullable[ci] {
+			return nil, nil, nil, fmt.Errorf("column %s: %d nulls in a non-nullable column", names[ci], nulls)
+		}
+		seen := 0
+		for ri := range rows {
+			if len(valid) > 0 && valid[ri/8]&(1<<(ri%8)) == 0 {
+				seen++
+				continue
+			}
+			lo, hi := binary.LittleEndian.Uint32(offsets[4*ri:]), binary.LittleEndian.Uint32(offsets[4*ri+4:])
+			s := string(values[lo:hi])
+			rows[ri][ci] = &s
+		}
+		if seen != nulls {
+			return nil, nil, nil, fmt.Errorf("column %s: %d nulls in the bitmap, field node says %d", names[ci], seen, nulls)
+		}
+	}
+	return names, nullable, rows, nil
+}
+
+func TestArrowRoundTrip(t *testing.T) {
+	for _, tc := range exportCorpus {
+		for _, name := range []string{"edges", "nodes"} {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				g := corpusGraph(t, tc.name)
+				var buf bytes.Buffer
+				opts := exportOptions{format: formatOpts{"arrow": {"table": name}}}
+				if err := writeArrow(&buf, g, opts); err != nil {
+					t.Fatal(err)
+				}
+				names, nullable, rows, err := readArrow(buf.Bytes())
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := selectTable(g, name)
+				if len(names) != len(want.columns) {
+					t.Fatalf("%d columns, want %d", len(names), len(want.columns))
+				}
+				for i, col := range want.columns {
+					if names[i] != col.name || nullable[i] == col.required {
+						t.Fatalf("columns %v (nullable %v), want %+v", names, nullable, want.columns)
+					}
+				}
+				if !slices.EqualFunc(rows, want.rows, func(a, b []*string) bool { return slices.EqualFunc(a, b, sameCell) }) {
+					t.Errorf("rows %s, want %s", formatRows(rows), formatRows(want.rows))
+				}
+			})
+		}
+	}
+}
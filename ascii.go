@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// -format ascii draws the graph as boxes and arrows for a terminal, left to
+// right in the layers of the layered layout. Edges spanning several layers
+// run through the layers in between, back edges of cycles point leftwards,
+// and self-loops mark their box with ↻. Edge labels would crowd the
+// drawing, so they are listed under it. Meant for small graphs: the
+// drawing grows with the longest layer and the edges between layers.
+
+const asciiMaxName = 30 // longer names are cut short with …
+
+// Line directions at a cell; a cell's lines are joined into one character.
+const (
+	lineUp = 1 << iota
+	lineDown
+	lineLeft
+	lineRight
+)
+
+type asciiChars struct {
+	lines                    [16]rune // by direction bits
+	tl, tr, bl, br, h, v     rune     // box corners and sides
+	tee, teeLeft             rune     // box sides where lines leave or arrive
+	arrowRight, arrowLeft    rune
+	loop, ellipsis, labelArr string
+}
+
+var unicodeChars = asciiChars{
+	lines: [16]rune{' ', '│', '│', '│', '─', '┘', '┐', '┤', '─', '└', '┌', '├', '─', '┴', '┬', '┼'},
+	tl:    '┌', tr: '┐', bl: '└', br: '┘', h: '─', v: '│',
+	tee: '├', teeLeft: '┤',
+	arrowRight: '▶', arrowLeft: '◀',
+	loop: " ↻", ellipsis: "…", labelArr: "→",
+}
+
+var plainChars = asciiChars{
+	lines: [16]rune{' ', '|', '|', '|', '-', '+', '+', '+', '-', '+', '+', '+', '-', '+', '+', '+'},
+	tl:    '+', tr: '+', bl: '+', br: '+', h: '-', v: '|',
+	tee: '+', teeLeft: '+',
+	arrowRight: '>', arrowLeft: '<',
+	loop: " (loop)", ellipsis: "...", labelArr: "->",
+}
+
+// asciiSlot is a node, or a point an edge passes through (node < 0), at a
+// row of its layer.
+type asciiSlot struct {
+	node       int
+	prev, next []int // slot indexes in the neighbouring layers
+}
+
+// asciiSegment joins a slot to one in the next layer.
+type asciiSegment struct {
+	from, to  int // slot indexes in layers l and l+1
+	arrowTo   bool
+	arrowFrom bool
+}
+
+func writeASCII(out io.Writer, g *graph, opts exportOptions) error {
+	chars := unicodeChars
+	switch charset := opts.opt("ascii", "charset", "unicode"); charset {
+	case "", "unicode":
+	case "ascii":
+		chars = plainChars
+	default:
+		return fmt.Errorf("unknown -opt ascii.charset %q (want unicode or ascii)", charset)
+	}
+	w := bufio.NewWriter(out)
+	if len(g.Nodes) == 0 {
+		return w.Flush()
+	}
+
+	nodes := make([]Node, len(g.Nodes))
+	for i, n := range g.Nodes {
+		nodes[i] = Node{ID: n.ID}
+	}
+	var edges []Edge
+	loops := map[int]bool{}
+	for _, e := range g.Edges {
+		if e.From == e.To {
+			loops[g.byID[e.From]] = true
+		} else {
+			edges = append(edges, Edge{FromNode: e.From, ToNode: e.To})
+		}
+	}
+	layer, columns, _ := layerNodes(nodes, edges)
+
+	// One chain of slots per pair of nodes, from the lower layer up,
+	// whichever way its edges point.
+	layers := make([][]asciiSlot, len(columns))
+	slotOf := make([]int, len(nodes))
+	for l, col := range columns {
+		for _, v := range col {
+			slotOf[v] = len(layers[l])
+			layers[l] = append(layers[l], asciiSlot{node: v})
+		}
+	}
+	type pair struct{ lo, hi int }
+	arrows := map[pair][2]bool{} // arrow at hi, at lo
+	var pairs []pair
+	for _, e := range edges {
+		f, t := g.byID[e.FromNode], g.byID[e.ToNode]
+		p, at := pair{f, t}, 0
+		if layer[f] > layer[t] {
+			p, at = pair{t, f}, 1
+		}
+		a, ok := arrows[p]
+		if !ok {
+			pairs = append(pairs, p)
+		}
+		a[at] = true
+		arrows[p] = a
+	}
+	segments := make([][]asciiSegment, len(columns))
+	for _, p := range pairs {
+		prev := slotOf[p.lo]
+		for l := layer[p.lo] + 1; l <= layer[p.hi]; l++ {
+			next := slotOf[p.hi]
+			if l < layer[p.hi] {
+				next = len(layers[l])
+				layers[l] = append(layers[l], asciiSlot{node: -1})
+			}
+			layers[l-1][prev].next = append(layers[l-1][prev].next, next)
+			layers[l][next].prev = append(layers[l][next].prev, prev)
+			segments[l-1] = append(segments[l-1], asciiSegment{
+				from: prev, to: next,
+				arrowTo:   l == layer[p.hi] && arrows[p][0],
+				arrowFrom: l == layer[p.lo]+1 && arrows[p][1],
+			})
+			prev = next
+		}
+	}
+	row := asciiOrder(layers)
+
+	label := func(v int) string {
+		s := g.Nodes[v].Name
+		if utf8.RuneCountInString(s) > asciiMaxName {
+			s = string([]rune(s)[:asciiMaxName-utf8.RuneCountInString(chars.ellipsis)]) + chars.ellipsis
+		}
+		if loops[v] {
+			s += chars.loop
+		}
+		return s
+	}
+
+	// Columns: each layer as wide as its widest box, then a gap with a
+	// vertical track per segment that changes row.
+	x := make([]int, len(layers))
+	width := make([]int, len(layers))
+	tracks := make([]map[int]int, len(layers)) // segment -> track
+	right, height := 0, 0
+	for l, slots := range layers {
+		x[l] = right
+		width[l] = 5
+		for i, s := range slots {
+			if s.node >= 0 {
+				width[l] = max(width[l], utf8.RuneCountInString(label(s.node))+4)
+			}
+			height = max(height, row[l][i]*4+3)
+		}
+		right += width[l]
+		if l < len(layers)-1 {
+			tracks[l] = map[int]int{}
+			for k, seg := range segments[l] {
+				if row[l][seg.from] != row[l+1][seg.to] {
+					tracks[l][k] = len(tracks[l])
+				}
+			}
+			right += 2*len(tracks[l]) + 3
+		}
+	}
+
+	grid := make([][]rune, height)
+	dirs := make([][]uint8, height)
+	for y := range grid {
+		grid[y] = make([]rune, right)
+		dirs[y] = make([]uint8, right)
+	}
+	hline := func(y, x0, x1 int) {
+		if x0 > x1 {
+			x0, x1 = x1, x0
+		}
+		for i := x0; i <= x1; i++ {
+			if i > x0 {
+				dirs[y][i] |= lineLeft
+			}
+			if i < x1 {
+				dirs[y][i] |= lineRight
+			}
+		}
+	}
+	vline := func(x, y0, y1 int) {
+		if y0 > y1 {
+			y0, y1 = y1, y0
+		}
+		for i := y0; i <= y1; i++ {
+			if i > y0 {
+				dirs[i][x] |= lineUp
+			}
+			if i < y1 {
+				dirs[i][x] |= lineDown
+			}
+		}
+	}
+	put := func(y, x int, s string) {
+		for _, r := range s {
+			grid[y][x] = r
+			x++
+		}
+	}
+
+	for l, slots := range layers {
+		for i, s := range slots {
+			top, mid := row[l][i]*4, row[l][i]*4+1
+			if s.node < 0 {
+				hline(mid, x[l], x[l]+width[l]-1)
+				continue
+			}
+			bar := strings.Repeat(string(chars.h), width[l]-2)
+			put(top, x[l], string(chars.tl)+bar+string(chars.tr))
+			put(top+2, x[l], string(chars.bl)+bar+string(chars.br))
+			name := label(s.node)
+			put(mid, x[l], string(chars.v)+" "+name+strings.Repeat(" ", width[l]-3-utf8.RuneCountInString(name))+string(chars.v))
+			if len(s.prev) > 0 {
+				grid[mid][x[l]] = chars.teeLeft
+			}
+			if len(s.next) > 0 {
+				grid[mid][x[l]+width[l]-1] = chars.tee
+			}
+		}
+		if l == len(layers)-1 {
+			continue
+		}
+		start, end := x[l]+width[l], x[l+1]-1 // the gap
+		for k, seg := range segments[l] {
+			y0, y1 := row[l][seg.from]*4+1, row[l+1][seg.to]*4+1
+			if t, ok := tracks[l][k]; ok {
+				tx := start + 1 + 2*t
+				hline(y0, start, tx)
+				vline(tx, y0, y1)
+				hline(y1, tx, end)
+			} else {
+				hline(y0, start, end)
+			}
+			if seg.arrowTo {
+				grid[y1][end] = chars.arrowRight
+			}
+			if seg.arrowFrom {
+				grid[y0][start] = chars.arrowLeft
+			}
+		}
+	}
+
+	for y := range grid {
+		line := make([]rune, right)
+		for i := range line {
+			line[i] = grid[y][i]
+			if line[i] == 0 {
+				line[i] = chars.lines[dirs[y][i]]
+			}
+		}
+		w.WriteString(strings.TrimRight(string(line), " ") + "\n")
+	}
+	first := true
+	for _, e := range g.Edges {
+		if e.Label == "" {
+			continue
+		}
+		if first {
+			w.WriteString("\n")
+			first = false
+		}
+		fmt.Fprintf(w, "%s %s %s: %s\n", g.name(e.From), chars.labelArr, g.name(e.To), e.Label)
+	}
+	return w.Flush()
+}
+
+// asciiOrder orders the slots of each layer by the average row of their
+// neighbours, sweeping forwards and back a few times to untangle edges. It
+// returns each slot's row.
+func asciiOrder(layers [][]asciiSlot) [][]int {
+	row := make([][]int, len(layers))
+	for l, slots := range layers {
+		row[l] = make([]int, len(slots))
+		for i := range slots {
+			row[l][i] = i
+		}
+	}
+	sweep := func(l int, neighbours func(asciiSlot) []int, other int) {
+		order := make([]int, len(layers[l]))
+		bary := make([]float64, len(layers[l]))
+		for i, s := range layers[l] {
+			order[row[l][i]] = i
+			bary[i] = float64(row[l][i])
+			if ns := neighbours(s); len(ns) > 0 {
+				sum := 0.0
+				for _, n := range ns {
+					sum += float64(row[other][n])
+				}
+				bary[i] = sum / float64(len(ns))
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return bary[order[a]] < bary[order[b]]
+		})
+		for r, i := range order {
+			row[l][i] = r
+		}
+	}
+	for range 4 {
+		for l := 1; l < len(layers); l++ {
+			sweep(l, func(s asciiSlot) []int { return s.prev }, l-1)
+		}
+		for l := len(layers) - 2; l >= 0; l-- {
+			sweep(l, func(s asciiSlot) []int { return s.next }, l+1)
+		}
+	}
+	return row
+}
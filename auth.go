@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serve can be opened up beyond localhost with -tokens and -rate. A token
+// file holds one client per line, a name and its secret, with # comments:
+//
+//	ci        3f9a0c...
+//	notebook  77b2e1...
+//
+// Every request must then carry "Authorization: Bearer <secret>" (gRPC
+// clients send it as authorization metadata). -rate limits each client,
+// by token name or else by remote address, to that many requests a second
+// on average, with -burst allowed at once. Refusals are 401 and 429 for
+// HTTP, with the same error object /convert sends, and UNAUTHENTICATED and
+// RESOURCE_EXHAUSTED for gRPC, which keeps HTTP 200 and reports the status
+// in its trailers.
+//
+// Neither bounds what one request costs, so the handlers behind the guard
+// do that for every client alike: bodies are held to -max-bytes, canvases
+// to the other -max flags, and GraphQL queries to the depth and result
+// size executeGraphQL allows.
+
+type serveToken struct {
+	name, secret string
+}
+
+func readTokens(path string) ([]serveToken, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tokens []serveToken
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 0:
+			continue
+		case 2:
+			tokens = append(tokens, serveToken{name: fields[0], secret: fields[1]})
+		default:
+			return nil, fmt.Errorf("%s:%d: want \"name secret\"", path, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	return tokens, nil
+}
+
+// tokenBucket allows rate requests a second, up to burst at once.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	clients map[string]*tokenBucket
+}
+
+// rateLimiterSweep is how many clients are tracked before idle ones are
+// forgotten.
+const rateLimiterSweep = 10000
+
+// allow takes a request from client's bucket, returning how long to wait
+// when it is empty.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.clients) >= rateLimiterSweep {
+		for k, b := range l.clients {
+			if now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.clients, k) // full again, so nothing to remember
+			}
+		}
+	}
+	b, ok := l.clients[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// guard authenticates and rate-limits requests before next sees them.
+type guard struct {
+	tokens  []serveToken // nil: no authentication
+	limiter *rateLimiter // nil: no limit
+	metrics *serveMetrics
+}
+
+// client returns the name of the token r carries, or ok=false.
+func (gd *guard) client(r *http.Request) (string, bool) {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	secret = strings.TrimSpace(secret)
+	found := ""
+	for _, t := range gd.tokens { // compare with every token, in constant time
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(t.secret)) == 1 {
+			found = t.name
+		}
+	}
+	return found, found != ""
+}
+
+func (gd *guard) wrap(next http.Handler) http.Handler {
+	if gd.tokens == nil && gd.limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		// limited charges client's bucket, refusing the request when it
+		// is empty.
+		limited := func(client string) bool {
+			if gd.limiter == nil {
+				return false
+			}
+			ok, wait := gd.limiter.allow(client, time.Now())
+			if !ok {
+				gd.metrics.rejected.inc("rate_limited")
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				gd.refuse(w, r, http.StatusTooManyRequests, grpcResourceLimit, "rate_limited", "rate limit exceeded for "+client)
+			}
+			return !ok
+		}
+		if gd.tokens != nil {
+			name, ok := gd.client(r)
+			if !ok {
+				// Failed attempts count against the remote address, so
+				// tokens can't be guessed faster than the limit.
+				if !limited(client) {
+					gd.metrics.rejected.inc("unauthenticated")
+					gd.refuse(w, r, http.StatusUnauthorized, grpcUnauthenticated, "unauthenticated", "missing or unknown bearer token")
+				}
+				return
+			}
+			client = name
+		}
+		if limited(client) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (gd *guard) refuse(w http.ResponseWriter, r *http.Request, status, grpcCode int, code, msg string) {
+	slog.Debug("refused", "path", r.URL.Path, "remote", r.RemoteAddr, "reason", msg)
+	if isGRPC(r) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		writeGRPCStatus(w, grpcErrorf(grpcCode, "%s", msg))
+		return
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="canvas_tool"`)
+	}
+	writeAPIError(w, status, code, msg)
+}
+
+// isLoopback reports whether addr listens on localhost only.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestGuardGraphQL sends /graphql requests through the guard: a valid
+// token gets a request past it, but not past the limits of the handler.
+func TestGuardGraphQL(t *testing.T) {
+	s := &server{metrics: newServeMetrics(), limits: parseLimits{maxBytes: 1 << 10}, store: newGraphStore(nil, false, "nfc", serveLimits)}
+	gd := &guard{
+		tokens:  []serveToken{{name: "ci", secret: "s3cret"}},
+		limiter: &rateLimiter{rate: 0.001, burst: 3, clients: map[string]*tokenBucket{}},
+		metrics: s.metrics,
+	}
+	h := gd.wrap(http.HandlerFunc(s.handleGraphQL))
+	post := func(remote, token, query string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		r.RemoteAddr = remote
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+	deep := nestQuery("node", 100)
+	for _, tc := range []struct {
+		name, remote, token, query string
+		status                     int
+	}{
+		{"no token", "192.0.2.1:1000", "", deep, http.StatusUnauthorized},
+		{"wrong token", "192.0.2.1:1001", "guess", deep, http.StatusUnauthorized},
+		{"another wrong token", "192.0.2.1:1002", "guess2", deep, http.StatusUnauthorized},
+		{"guessing from the same address", "192.0.2.1:1003", "guess3", deep, http.StatusTooManyRequests},
+		{"valid token", "192.0.2.2:1000", "s3cret", "{canvases}", http.StatusOK},
+		{"valid token, too deep", "192.0.2.2:1000", "s3cret", deep, http.StatusBadRequest},
+		{"valid token, too large", "192.0.2.2:1000", "s3cret", "{" + strings.Repeat("canvases ", 200) + "}", http.StatusRequestEntityTooLarge},
+		{"valid token, over its rate", "192.0.2.3:1000", "s3cret", "{canvases}", http.StatusTooManyRequests},
+	} {
+		if rec := post(tc.remote, tc.token, tc.query); rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, rec.Code, tc.status, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A note is referenced by a canvas through a file node or through a
+// [[wikilink]] in a text node.
+
+type backlink struct {
+	Note   string `json:"note"` // vault-relative path
+	Canvas string `json:"canvas"`
+	NodeID string `json:"node"`
+	Kind   string `json:"kind"` // file or wikilink
+}
+
+var wikilinkPattern = regexp.MustCompile(`\[\[([^\[\]|#^]+)(?:[#^][^\[\]|]*)?(?:\|[^\[\]]*)?\]\]`)
+
+// noteIndex resolves wikilink targets the way Obsidian does: an exact
+// vault-relative path first, otherwise the note with that name closest to
+// the vault root.
+type noteIndex struct {
+	paths  map[string]string   // lower-cased vault-relative path -> path
+	byName map[string][]string // lower-cased base name without .md -> paths
+}
+
+func indexNotes(vault string) (*noteIndex, error) {
+	idx := &noteIndex{paths: map[string]string{}, byName: map[string][]string{}}
+	err := filepath.WalkDir(vault, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != vault && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(vault, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		idx.paths[strings.ToLower(rel)] = rel
+		name := strings.ToLower(strings.TrimSuffix(path.Base(rel), path.Ext(rel)))
+		idx.byName[name] = append(idx.byName[name], rel)
+		return nil
+	})
+	for _, ps := range idx.byName {
+		sort.Slice(ps, func(i, j int) bool {
+			if di, dj := strings.Count(ps[i], "/"), strings.Count(ps[j], "/"); di != dj {
+				return di < dj
+			}
+			return ps[i] < ps[j]
+		})
+	}
+	return idx, err
+}
+
+// resolve returns the note a wikilink target points at, or the target with
+// .md appended when no such note exists.
+func (idx *noteIndex) resolve(target string) string {
+	target = strings.TrimSpace(target)
+	if !strings.EqualFold(path.Ext(target), ".md") {
+		target += ".md"
+	}
+	if p, ok := idx.paths[strings.ToLower(target)]; ok {
+		return p
+	}
+	if !strings.Contains(target, "/") {
+		if ps := idx.byName[strings.ToLower(strings.TrimSuffix(target, path.Ext(target)))]; len(ps) > 0 {
+			return ps[0]
+		}
+	}
+	return target
+}
+
+func collectBacklinks(vault string, bad func(string, error)) ([]backlink, error) {
+	idx, err := indexNotes(vault)
+	if err != nil {
+		return nil, err
+	}
+	graphs, paths, err := scanVaultCanvases(vault, bad)
+	if err != nil {
+		return nil, err
+	}
+	var links []backlink
+	for _, p := range paths {
+		for _, n := range graphs[p].Nodes {
+			switch {
+			case n.Type == "file" && n.Node.File != "":
+				links = append(links, backlink{Note: slashPath(n.Node.File), Canvas: p, NodeID: n.ID, Kind: "file"})
+			case n.Node.Text != "":
+				seen := map[string]bool{}
+				for _, m := range wikilinkPattern.FindAllStringSubmatch(n.Node.Text, -1) {
+					note := idx.resolve(m[1])
+					if !seen[note] {
+						seen[note] = true
+						links = append(links, backlink{Note: note, Canvas: p, NodeID: n.ID, Kind: "wikilink"})
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool { return links[i].Note < links[j].Note })
+	return links, nil
+}
+
+func runBacklinks(args []string) {
+	fset := flag.NewFlagSet("backlinks", flag.ExitOnError)
+	vault := fset.String("vault", ".", "vault directory to scan for .canvas files")
+	note := fset.String("note", "", "only references to this vault-relative note path")
+	format := fset.String("format", "csv", "output format: csv (note;canvas;node;kind) or json (note -> references)")
+	outPath := fset.String("out", "-", "output path (or - for stdout)")
+	fset.Parse(args)
+	if fset.NArg() > 0 {
+		*vault = fset.Arg(0)
+	}
+	links, err := collectBacklinks(*vault, func(p string, err error) {
+		slog.Warn("backlinks: skipped unreadable canvas", "path", p, "err", err)
+	})
+	if err != nil {
+		fatalf("backlinks: %v", err)
+	}
+	if *note != "" {
+		want := slashPath(*note)
+		kept := links[:0]
+		for _, l := range links {
+			if strings.EqualFold(l.Note, want) {
+				kept = append(kept, l)
+			}
+		}
+		links = kept
+	}
+
+	out, closeOut, err := openOut(*outPath)
+	if err != nil {
+		fatalf("backlinks: %v", err)
+	}
+	switch *format {
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Comma = ';'
+		w.Write([]string{"note", "canvas", "node", "kind"})
+		for _, l := range links {
+			w.Write([]string{l.Note, l.Canvas, l.NodeID, l.Kind})
+		}
+		w.Flush()
+		err = w.Error()
+	case "json":
+		index := map[string][]backlink{}
+		for _, l := range links {
+			index[l.Note] = append(index[l.Note], l)
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(index)
+	default:
+		err = fmt.Errorf("unknown -format %q (want csv or json)", *format)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fatalf("backlinks: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// bench synthesises a canvas and times parsing and every exporter on it,
+// for catching performance regressions and sizing pipelines.
+
+// benchCanvas builds a canvas of n nodes (mostly text, some file and link
+// nodes, one group per 50) and e random edges, a third of them labelled.
+func benchCanvas(n, e int, seed int64) Canvas {
+	rng := rand.New(rand.NewSource(seed))
+	words := []string{"graph", "note", "idea", "project", "task", "design", "review", "draft", "source", "link"}
+	var c Canvas
+	cols := 10
+	for i := 0; i < n; i++ {
+		node := Node{
+			ID:     fmt.Sprintf("n%d", i),
+			X:      float64((i % cols) * (nodeWidth + nodeGapX)),
+			Y:      float64((i / cols) * (nodeHeight + nodeGapY)),
+			Width:  nodeWidth,
+			Height: nodeHeight,
+		}
+		switch r := rng.Intn(10); {
+		case r < 6:
+			node.Type = "text"
+			node.Text = words[rng.Intn(len(words))] + " " + strconv.Itoa(i) + "\n" + words[rng.Intn(len(words))]
+		case r < 9:
+			node.Type = "file"
+			node.File = fmt.Sprintf("notes/%s/%s %d.md", words[rng.Intn(len(words))], words[rng.Intn(len(words))], i)
+		default:
+			node.Type = "link"
+			node.URL = fmt.Sprintf("https://example.com/%s/%d", words[rng.Intn(len(words))], i)
+		}
+		c.Nodes = append(c.Nodes, node)
+	}
+	for g := 0; g < n/50; g++ {
+		c.Nodes = append(c.Nodes, Node{
+			ID: fmt.Sprintf("g%d", g), Type: "group", Label: "group " + strconv.Itoa(g),
+			X: -20, Y: float64(g*5*(nodeHeight+nodeGapY)) - 20,
+			Width: float64(cols*(nodeWidth+nodeGapX)) + 40, Height: float64(5*(nodeHeight+nodeGapY)) + 40,
+		})
+	}
+	for i := 0; i < e && n > 0; i++ {
+		edge := Edge{ID: fmt.Sprintf("e%d", i), FromNode: fmt.Sprintf("n%d", rng.Intn(n)), ToNode: fmt.Sprintf("n%d", rng.Intn(n))}
+		if rng.Intn(3) == 0 {
+			edge.Label = words[rng.Intn(len(words))]
+		}
+		c.Edges = append(c.Edges, edge)
+	}
+	return c
+}
+
+type benchResult struct {
+	Name       string  `json:"name"`
+	Iterations int     `json:"iterations"`
+	NsPerOp    int64   `json:"ns_per_op"`
+	MBPerSec   float64 `json:"mb_per_s"` // input bytes for parse, output bytes for exporters
+	EdgesPerS  float64 `json:"edges_per_s"`
+	OutBytes   int64   `json:"out_bytes,omitempty"`
+}
+
+// measure runs op until d has passed (at least once).
+func measure(d time.Duration, op func() (int64, error)) (int, time.Duration, int64, error) {
+	start := time.Now()
+	iters := 0
+	var size int64
+	for iters == 0 || time.Since(start) < d {
+		n, err := op()
+		if err != nil {
+			return iters, 0, 0, err
+		}
+		size = n
+		iters++
+	}
+	return iters, time.Since(start), size, nil
+}
+
+// countingWriter discards what it is given, counting the bytes.
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	nodes := fs.Int("nodes", 10000, "nodes in the synthetic canvas")
+	edges := fs.Int("edges", 20000, "edges in the synthetic canvas")
+	seed := fs.Int64("seed", 1, "random seed for the synthetic canvas")
+	formats := fs.String("formats", "", "comma-separated formats to time (default: every built-in format that can stream)")
+	benchtime := fs.Duration("benchtime", time.Second, "how long to repeat each measurement")
+	in := fs.String("in", "", "time this canvas instead of a synthetic one")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	save := fs.String("save", "", "also write the synthetic canvas to this path")
+	fs.Parse(args)
+
+	var data []byte
+	var err error
+	if *in != "" {
+		if data, err = readAllInput(*in); err != nil {
+			fatalf("bench: %v", err)
+		}
+	} else {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(benchCanvas(*nodes, *edges, *seed)); err != nil {
+			fatalf("bench: %v", err)
+		}
+		data = buf.Bytes()
+		if *save != "" {
+			if err := os.WriteFile(*save, data, 0o644); err != nil {
+				fatalf("bench: %v", err)
+			}
+		}
+	}
+	names := splitList(*formats)
+	if len(names) == 0 {
+		for _, name := range exporterNames() {
+			if exporters[name].write != nil {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var results []benchResult
+	add := func(name string, iters int, took time.Duration, size int64, edgeCount int) {
+		perOp := took / time.Duration(iters)
+		secs := perOp.Seconds()
+		results = append(results, benchResult{
+			Name:       name,
+			Iterations: iters,
+			NsPerOp:    perOp.Nanoseconds(),
+			MBPerSec:   float64(size) / 1e6 / secs,
+			EdgesPerS:  float64(edgeCount) / secs,
+		})
+	}
+
+	var g *graph
+	iters, took, _, err := measure(*benchtime, func() (int64, error) {
+		c, err := parseCanvas(data)
+		if err != nil {
+			return 0, err
+		}
+		g = buildGraph(c, false)
+		return int64(len(data)), nil
+	})
+	if err != nil {
+		fatalf("bench: parse: %v", err)
+	}
+	add("parse", iters, took, int64(len(data)), len(g.Edges))
+
+	opts, _ := exportOptionsFrom(nil)
+	for _, name := range names {
+		ex, ok := exporters[name]
+		if !ok || ex.write == nil {
+			fatalf("bench: -formats: %q is not a built-in format that can stream", name)
+		}
+		iters, took, size, err := measure(*benchtime, func() (int64, error) {
+			var w countingWriter
+			err := ex.write(&w, g, opts)
+			return w.n, err
+		})
+		if err != nil {
+			fatalf("bench: %s: %v", name, err)
+		}
+		add(name, iters, took, size, len(g.Edges))
+		results[len(results)-1].OutBytes = size
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]any{"nodes": len(g.Nodes), "edges": len(g.Edges), "bytes": len(data), "results": results})
+		return
+	}
+	fmt.Printf("canvas: %d nodes, %d edges, %d bytes\n", len(g.Nodes), len(g.Edges), len(data))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "step\titerations\ttime/op\tMB/s\tedges/s\toutput\t")
+	for _, r := range results {
+		out := ""
+		if r.OutBytes > 0 {
+			out = strconv.FormatInt(r.OutBytes, 10)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.1f\t%.0f\t%s\t\n", r.Name, r.Iterations, time.Duration(r.NsPerOp).Round(time.Microsecond), r.MBPerSec, r.EdgesPerS, out)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+)
+
+// Minimal Bolt client: enough of the protocol (handshake, PackStream, HELLO,
+// BEGIN/RUN/PULL/COMMIT) to push batched write queries to Neo4j. Bolt 5.0 and
+// 4.2-4.4 are negotiated; those versions all authenticate in HELLO.
+
+const (
+	boltMsgHello    = 0x01
+	boltMsgGoodbye  = 0x02
+	boltMsgRun      = 0x10
+	boltMsgBegin    = 0x11
+	boltMsgCommit   = 0x12
+	boltMsgRollback = 0x13
+	boltMsgPull     = 0x3F
+
+	boltMsgSuccess = 0x70
+	boltMsgRecord  = 0x71
+	boltMsgIgnored = 0x7E
+	boltMsgFailure = 0x7F
+)
+
+type boltConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	db      string
+	chunkSz int
+}
+
+type boltFailure struct {
+	Code    string
+	Message string
+}
+
+func (f *boltFailure) Error() string { return f.Code + ": " + f.Message }
+
+// boltStruct is a decoded PackStream structure we don't interpret.
+type boltStruct struct {
+	Tag    byte
+	Fields []any
+}
+
+func boltHandshake(conn net.Conn) (*boltConn, error) {
+	var hs bytes.Buffer
+	hs.Write([]byte{0x60, 0x60, 0xB0, 0x17})
+	hs.Write([]byte{0, 0, 0, 5}) // 5.0
+	hs.Write([]byte{0, 2, 4, 4}) // 4.4 down to 4.2
+	hs.Write([]byte{0, 0, 0, 0})
+	hs.Write([]byte{0, 0, 0, 0})
+	if _, err := conn.Write(hs.Bytes()); err != nil {
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	var v [4]byte
+	if _, err := io.ReadFull(conn, v[:]); err != nil {
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	if v == [4]byte{} {
+		return nil, errors.New("handshake: server supports none of the offered Bolt versions")
+	}
+	return &boltConn{conn: conn, r: bufio.NewReader(conn), chunkSz: 0xFFFF}, nil
+}
+
+func (b *boltConn) hello(userAgent, user, pass string) error {
+	extra := map[string]any{"user_agent": userAgent}
+	if user != "" {
+		extra["scheme"] = "basic"
+		extra["principal"] = user
+		extra["credentials"] = pass
+	} else {
+		extra["scheme"] = "none"
+	}
+	_, err := b.request(boltMsgHello, extra)
+	return err
+}
+
+func (b *boltConn) txExtra() map[string]any {
+	extra := map[string]any{}
+	if b.db != "" {
+		extra["db"] = b.db
+	}
+	return extra
+}
+
+// run executes query in auto-commit mode and discards any records.
+func (b *boltConn) run(query string, params map[string]any) error {
+	if _, err := b.request(boltMsgRun, query, params, b.txExtra()); err != nil {
+		return err
+	}
+	_, err := b.request(boltMsgPull, map[string]any{"n": int64(-1)})
+	return err
+}
+
+// inTx runs fn inside an explicit transaction, rolling back if it fails.
+func (b *boltConn) inTx(fn func() error) error {
+	if _, err := b.request(boltMsgBegin, b.txExtra()); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		b.request(boltMsgRollback)
+		return err
+	}
+	_, err := b.request(boltMsgCommit)
+	return err
+}
+
+// txRun executes query inside the current explicit transaction.
+func (b *boltConn) txRun(query string, params map[string]any) error {
+	if _, err := b.request(boltMsgRun, query, params, map[string]any{}); err != nil {
+		return err
+	}
+	_, err := b.request(boltMsgPull, map[string]any{"n": int64(-1)})
+	return err
+}
+
+func (b *boltConn) close() error {
+	b.send(boltMsgGoodbye)
+	return b.conn.Close()
+}
+
+// request sends one message and reads responses until SUCCESS or FAILURE,
+// returning the SUCCESS metadata.
+func (b *boltConn) request(tag byte, fields ...any) (map[string]any, error) {
+	if err := b.send(tag, fields...); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := b.recv()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.Tag {
+		case boltMsgRecord:
+			continue
+		case boltMsgSuccess:
+			meta, _ := first(msg.Fields).(map[string]any)
+			return meta, nil
+		case boltMsgFailure:
+			meta, _ := first(msg.Fields).(map[string]any)
+			code, _ := meta["code"].(string)
+			message, _ := meta["message"].(string)
+			return nil, &boltFailure{Code: code, Message: message}
+		case boltMsgIgnored:
+			return nil, errors.New("request ignored by server")
+		default:
+			return nil, fmt.Errorf("unexpected bolt message 0x%02X", msg.Tag)
+		}
+	}
+}
+
+func first(xs []any) any {
+	if len(xs) == 0 {
+		return nil
+	}
+	return xs[0]
+}
+
+func (b *boltConn) send(tag byte, fields ...any) error {
+	var msg bytes.Buffer
+	if err := packStruct(&msg, tag, fields); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	data := msg.Bytes()
+	for len(data) > 0 {
+		n := min(len(data), b.chunkSz)
+		binary.Write(&out, binary.BigEndian, uint16(n))
+		out.Write(data[:n])
+		data = data[n:]
+	}
+	out.Write([]byte{0, 0})
+	_, err := b.conn.Write(out.Bytes())
+	return err
+}
+
+func (b *boltConn) recv() (boltStruct, error) {
+	var msg bytes.Buffer
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(b.r, hdr[:]); err != nil {
+			return boltStruct{}, err
+		}
+		n := int(binary.BigEndian.Uint16(hdr[:]))
+		if n == 0 {
+			if msg.Len() == 0 {
+				continue // NOOP keep-alive chunk
+			}
+			break
+		}
+		if _, err := io.CopyN(&msg, b.r, int64(n)); err != nil {
+			return boltStruct{}, err
+		}
+	}
+	v, err := unpack(bufio.NewReader(&msg))
+	if err != nil {
+		return boltStruct{}, err
+	}
+	s, ok := v.(boltStruct)
+	if !ok {
+		return boltStruct{}, errors.New("bolt message is not a structure")
+	}
+	return s, nil
+}
+
+// PackStream encoding.
+
+func packStruct(w *bytes.Buffer, tag byte, fields []any) error {
+	if len(fields) > 15 {
+		return errors.New("packstream: too many struct fields")
+	}
+	w.WriteByte(0xB0 | byte(len(fields)))
+	w.WriteByte(tag)
+	for _, f := range fields {
+		if err := pack(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func packSize(w *bytes.Buffer, n int, tiny, b8, b16, b32 byte) {
+	switch {
+	case tiny != 0 && n < 16:
+		w.WriteByte(tiny | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		w.WriteByte(b8)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(b16)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(b32)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
+
+func pack(w *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		w.WriteByte(0xC0)
+	case bool:
+		if v {
+			w.WriteByte(0xC3)
+		} else {
+			w.WriteByte(0xC2)
+		}
+	case int:
+		packInt(w, int64(v))
+	case int64:
+		packInt(w, v)
+	case float64:
+		w.WriteByte(0xC1)
+		binary.Write(w, binary.BigEndian, v)
+	case string:
+		packSize(w, len(v), 0x80, 0xD0, 0xD1, 0xD2)
+		w.WriteString(v)
+	case []string:
+		packSize(w, len(v), 0x90, 0xD4, 0xD5, 0xD6)
+		for _, s := range v {
+			pack(w, s)
+		}
+	case []any:
+		packSize(w, len(v), 0x90, 0xD4, 0xD5, 0xD6)
+		for _, x := range v {
+			if err := pack(w, x); err != nil {
+				return err
+			}
+		}
+	case []map[string]any:
+		packSize(w, len(v), 0x90, 0xD4, 0xD5, 0xD6)
+		for _, x := range v {
+			if err := pack(w, x); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		packSize(w, len(v), 0xA0, 0xD8, 0xD9, 0xDA)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			pack(w, k)
+			if err := pack(w, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("packstream: unsupported type %T", v)
+	}
+	return nil
+}
+
+func packInt(w *bytes.Buffer, n int64) {
+	switch {
+	case n >= -16 && n <= 127:
+		w.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		w.WriteByte(0xC8)
+		w.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		w.WriteByte(0xC9)
+		binary.Write(w, binary.BigEndian, int16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		w.WriteByte(0xCA)
+		binary.Write(w, binary.BigEndian, int32(n))
+	default:
+		w.WriteByte(0xCB)
+		binary.Write(w, binary.BigEndian, n)
+	}
+}
+
+// PackStream decoding.
+
+func unpack(r *bufio.Reader) (any, error) {
+	m, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	hi := m & 0xF0
+	switch {
+	case m <= 0x7F:
+		return int64(m), nil
+	case m >= 0xF0:
+		return int64(int8(m)), nil
+	case hi == 0x80:
+		return unpackString(r, int(m&0x0F))
+	case hi == 0x90:
+		return unpackList(r, int(m&0x0F))
+	case hi == 0xA0:
+		return unpackMap(r, int(m&0x0F))
+	case hi == 0xB0:
+		return unpackStruct(r, int(m&0x0F))
+	}
+	switch m {
+	case 0xC0:
+		return nil, nil
+	case 0xC1:
+		var f float64
+		err := binary.Read(r, binary.BigEndian, &f)
+		return f, err
+	case 0xC2:
+		return false, nil
+	case 0xC3:
+		return true, nil
+	case 0xC8:
+		var n int8
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int64(n), err
+	case 0xC9:
+		var n int16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int64(n), err
+	case 0xCA:
+		var n int32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int64(n), err
+	case 0xCB:
+		var n int64
+		err := binary.Read(r, binary.BigEndian, &n)
+		return n, err
+	case 0xCC, 0xCD, 0xCE:
+		n, err := readSize(r, m-0xCC)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return b, err
+	case 0xD0, 0xD1, 0xD2:
+		n, err := readSize(r, m-0xD0)
+		if err != nil {
+			return nil, err
+		}
+		return unpackString(r, n)
+	case 0xD4, 0xD5, 0xD6:
+		n, err := readSize(r, m-0xD4)
+		if err != nil {
+			return nil, err
+		}
+		return unpackList(r, n)
+	case 0xD8, 0xD9, 0xDA:
+		n, err := readSize(r, m-0xD8)
+		if err != nil {
+			return nil, err
+		}
+		return unpackMap(r, n)
+	}
+	return nil, fmt.Errorf("packstream: unknown marker 0x%02X", m)
+}
+
+// readSize reads a 1, 2 or 4 byte big-endian size (width 0, 1 or 2).
+func readSize(r *bufio.Reader, width byte) (int, error) {
+	switch width {
+	case 0:
+		b, err := r.ReadByte()
+		return int(b), err
+	case 1:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	default:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	}
+}
+
+func unpackString(r *bufio.Reader, n int) (string, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return string(b), err
+}
+
+func unpackList(r *bufio.Reader, n int) ([]any, error) {
+	out := make([]any, 0, n)
+	for range n {
+		v, err := unpack(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func unpackMap(r *bufio.Reader, n int) (map[string]any, error) {
+	out := make(map[string]any, n)
+	for range n {
+		k, err := unpack(r)
+		if err != nil {
+			return nil, err
+		}
+		ks, ok := k.(string)
+		if !ok {
+			return nil, errors.New("packstream: non-string map key")
+		}
+		v, err := unpack(r)
+		if err != nil {
+			return nil, err
+		}
+		out[ks] = v
+	}
+	return out, nil
+}
+
+func unpackStruct(r *bufio.Reader, n int) (boltStruct, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return boltStruct{}, err
+	}
+	fields, err := unpackList(r, n)
+	return boltStruct{Tag: tag, Fields: fields}, err
+}
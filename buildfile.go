@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// -format make and taskfile turn a dependency canvas into a build script
+// skeleton: every non-group node is a task and an edge says its source
+// depends on its target (-opt make.edges=before, or taskfile.edges, reads
+// edges the other way, from a task to what comes after it). Tasks are
+// written prerequisites first, each with a placeholder command, and an
+// "all" (Taskfile: "default") task builds every task nothing depends on.
+// A cycle has no build order, so the edges that close one are left out
+// with a warning.
+
+// buildTask is one target of the script.
+type buildTask struct {
+	name  string // target name
+	title string // node text, when it says more than the name
+	deps  []string
+}
+
+// buildTasks returns the tasks in an order that runs prerequisites first,
+// and the names of the tasks nothing depends on.
+func buildTasks(g *graph, format string, opts exportOptions) ([]buildTask, []string, error) {
+	before := false
+	switch v := opts.opt(format, "edges", "depends-on"); v {
+	case "depends-on":
+	case "before":
+		before = true
+	default:
+		return nil, nil, fmt.Errorf("-opt %s.edges: want depends-on or before, got %q", format, v)
+	}
+	dropped := map[int]bool{}
+	for _, i := range feedbackEdges(g) {
+		if e := g.Edges[i]; e.From != e.To {
+			slog.Warn(fmt.Sprintf("-format %s: leaving out the dependency %s -> %s, which closes a cycle", format, g.name(e.From), g.name(e.To)))
+			dropped[i] = true
+		}
+	}
+
+	names := map[string]string{} // node ID -> target name
+	used := map[string]bool{"all": true, "default": true}
+	var ids []string
+	for _, n := range g.Nodes {
+		if n.Type == "group" {
+			continue
+		}
+		name := targetName(n.Name)
+		if name == "" {
+			name = targetName(n.ID)
+		}
+		for i, base := 2, name; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[name] = true
+		names[n.ID] = name
+		ids = append(ids, n.ID)
+	}
+	deps := map[string][]string{}
+	needed := map[string]bool{}
+	for i, e := range g.Edges {
+		task, dep := e.From, e.To
+		if before {
+			task, dep = dep, task
+		}
+		if names[task] == "" || names[dep] == "" || task == dep || dropped[i] {
+			continue
+		}
+		deps[task] = append(deps[task], dep)
+		needed[dep] = true
+	}
+
+	var tasks []buildTask
+	var top []string
+	done := map[string]bool{}
+	var visit func(id string)
+	visit = func(id string) {
+		if done[id] {
+			return
+		}
+		done[id] = true
+		t := buildTask{name: names[id]}
+		for _, d := range deps[id] {
+			visit(d)
+			if !slices.Contains(t.deps, names[d]) {
+				t.deps = append(t.deps, names[d])
+			}
+		}
+		if title := strings.Join(strings.Fields(g.name(id)), " "); title != t.name {
+			t.title = title
+		}
+		tasks = append(tasks, t)
+	}
+	for _, id := range ids {
+		visit(id)
+		if !needed[id] {
+			top = append(top, names[id])
+		}
+	}
+	return tasks, top, nil
+}
+
+// targetName makes a target name from a node's text: lower case, with runs
+// of anything but letters, digits, "_" and "." turned into "-".
+func targetName(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+func writeMakefile(out io.Writer, g *graph, opts exportOptions) error {
+	tasks, top, err := buildTasks(g, "make", opts)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	names := []string{"all"}
+	for _, t := range tasks {
+		names = append(names, t.name)
+	}
+	fmt.Fprintf(w, ".PHONY: %s\n\n", strings.Join(names, " "))
+	fmt.Fprintf(w, "all: %s\n", strings.Join(top, " "))
+	for _, t := range tasks {
+		fmt.Fprintln(w)
+		if t.title != "" {
+			fmt.Fprintf(w, "# %s\n", t.title)
+		}
+		fmt.Fprintf(w, "%s:", t.name)
+		for _, d := range t.deps {
+			fmt.Fprintf(w, " %s", d)
+		}
+		fmt.Fprintf(w, "\n\t@echo %s\n", shellQuote("TODO: "+t.name))
+	}
+	return w.Flush()
+}
+
+func writeTaskfile(out io.Writer, g *graph, opts exportOptions) error {
+	tasks, top, err := buildTasks(g, "taskfile", opts)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	list := func(items []string) string {
+		quoted := make([]string, len(items))
+		for i, s := range items {
+			quoted[i] = yamlQuote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	fmt.Fprint(w, "version: '3'\n\ntasks:\n")
+	fmt.Fprintf(w, "  default:\n    deps: %s\n", list(top))
+	for _, t := range tasks {
+		fmt.Fprintf(w, "\n  %s:\n", yamlQuote(t.name))
+		if t.title != "" {
+			fmt.Fprintf(w, "    desc: %s\n", yamlQuote(t.title))
+		}
+		if len(t.deps) > 0 {
+			fmt.Fprintf(w, "    deps: %s\n", list(t.deps))
+		}
+		fmt.Fprintf(w, "    cmds:\n      - %s\n", yamlQuote("echo "+shellQuote("TODO: "+t.name)))
+	}
+	return w.Flush()
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
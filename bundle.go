@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Edge bundling summarises a detailed canvas: nodes are grouped into
+// bundles (their innermost canvas group, or a detected community) and the
+// edges between two bundles collapse into one edge carrying a count.
+
+// bundleKeys returns, for every node, the ID of the bundle node it belongs
+// to, and the bundle nodes themselves in order of first appearance. Nodes
+// outside any group form a bundle of their own.
+func bundleKeys(g *graph, by string) ([]string, []graphNode, error) {
+	keys := make([]string, len(g.Nodes))
+	var bundles []graphNode
+	seen := map[string]bool{}
+	add := func(n graphNode) {
+		if !seen[n.ID] {
+			seen[n.ID] = true
+			bundles = append(bundles, n)
+		}
+	}
+	switch by {
+	case "groups":
+		for i, n := range g.Nodes {
+			j := i
+			if n.Type != "group" {
+				if grp := g.innermostGroup(i); grp >= 0 {
+					j = grp
+				}
+			}
+			keys[i] = g.Nodes[j].ID
+			add(g.Nodes[j])
+		}
+	case "clusters":
+		for i, c := range louvain(weightedAdjacency(g)) {
+			id := "cluster-" + strconv.Itoa(c+1)
+			keys[i] = id
+			add(graphNode{ID: id, Type: "cluster", Name: "cluster " + strconv.Itoa(c+1)})
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown bundling %q (want groups or clusters)", by)
+	}
+	return keys, bundles, nil
+}
+
+// bundleEdges aggregates the edges between bundles. In replace mode the
+// graph becomes the bundles and their summary edges, each bundle with a
+// size (member count) attribute; in add mode the summary edges (and, for
+// clusters, the cluster nodes) join the raw graph, marked bundle=true.
+// Summary edges are labelled with the distinct labels they stand for and
+// carry a count attribute.
+func bundleEdges(g *graph, by, mode string) error {
+	if mode != "replace" && mode != "add" {
+		return fmt.Errorf("unknown -bundle-mode %q (want replace or add)", mode)
+	}
+	keys, bundles, err := bundleKeys(g, by)
+	if err != nil {
+		return err
+	}
+	bundleOf := make(map[string]string, len(g.Nodes))
+	size := map[string]int{}
+	for i, n := range g.Nodes {
+		bundleOf[n.ID] = keys[i]
+		if n.ID != keys[i] {
+			size[keys[i]]++
+		}
+	}
+
+	type pair struct{ from, to string }
+	var order []pair
+	counts := map[pair]int{}
+	labels := map[pair][]string{}
+	for _, e := range g.Edges {
+		p := pair{bundleOf[e.From], bundleOf[e.To]}
+		if p.from == "" || p.to == "" || p.from == p.to {
+			continue // dangling, or inside one bundle
+		}
+		if counts[p] == 0 {
+			order = append(order, p)
+		}
+		counts[p]++
+		if e.Label != "" && !slices.Contains(labels[p], e.Label) {
+			labels[p] = append(labels[p], e.Label)
+		}
+	}
+
+	if mode == "replace" {
+		g.Nodes, g.Edges = bundles, nil
+	} else {
+		for i := range g.Edges {
+			g.setEdgeAttr(i, "bundle", "")
+		}
+		for _, b := range bundles {
+			if b.Type == "cluster" {
+				g.Nodes = append(g.Nodes, b)
+			}
+		}
+	}
+	g.index()
+	for _, p := range order {
+		g.Edges = append(g.Edges, graphEdge{From: p.from, To: p.to, Label: strings.Join(labels[p], ", ")})
+		i := len(g.Edges) - 1
+		g.setEdgeAttr(i, "count", strconv.Itoa(counts[p]))
+		if mode == "add" {
+			g.setEdgeAttr(i, "bundle", "true")
+		}
+	}
+	if mode == "replace" {
+		for i, n := range g.Nodes {
+			g.setAttr(i, "size", strconv.Itoa(size[n.ID]))
+		}
+	}
+	g.index()
+	return nil
+}
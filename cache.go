@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With -cache, batch runs and daemon jobs remember what they last wrote for
+// each canvas and skip canvases that haven't changed since. The key covers
+// the canvas bytes, the flags, the tool version and, with -vault, the
+// notes' sizes and modification times; an entry also goes stale when one
+// of its outputs is changed or deleted. -force rewrites everything.
+
+// exportCache maps an input (or daemon destination) to what was written
+// from it.
+type exportCache struct {
+	path    string
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+type cacheEntry struct {
+	Key     string         `json:"key"`
+	Outputs []cachedOutput `json:"outputs"`
+}
+
+// cachedOutput is a written file as it was left, so later edits to it are
+// noticed. Size and ModTime are zero for streams, URLs and databases.
+type cachedOutput struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mtime,omitzero"`
+}
+
+// loadExportCache reads the cache at path; a missing file is an empty
+// cache.
+func loadExportCache(path string) (*exportCache, error) {
+	c := &exportCache{path: path, Entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	if c.Entries == nil {
+		c.Entries = map[string]cacheEntry{}
+	}
+	return c, nil
+}
+
+func (c *exportCache) save() error {
+	if dir := filepath.Dir(c.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, append(data, '\n'))
+}
+
+// fresh reports whether name was last written with key and its outputs
+// are untouched since.
+func (c *exportCache) fresh(name, key string) bool {
+	e, ok := c.Entries[name]
+	if !ok || e.Key != key || len(e.Outputs) == 0 {
+		return false
+	}
+	for _, o := range e.Outputs {
+		if now := statOutput(o.Path); now.Size != o.Size || !now.ModTime.Equal(o.ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// record notes that name was written to outputs with key. Runs that wrote
+// to stdout or the clipboard aren't recorded: skipping them would write
+// nothing.
+func (c *exportCache) record(name, key string, outputs []string) {
+	e := cacheEntry{Key: key}
+	for _, path := range outputs {
+		if path == "-" || path == clipboardPath {
+			delete(c.Entries, name)
+			return
+		}
+		e.Outputs = append(e.Outputs, statOutput(path))
+	}
+	c.Entries[name] = e
+}
+
+func statOutput(path string) cachedOutput {
+	o := cachedOutput{Path: path}
+	if path == "-" || path == clipboardPath || isNeo4jURL(path) || isNATSURL(path) || isMQTTURL(path) || isS3URL(path) {
+		return o
+	}
+	if fi, err := os.Stat(path); err == nil {
+		o.Size, o.ModTime = fi.Size(), fi.ModTime().UTC()
+	} else {
+		o.Size = -1 // missing: never matches a recorded output
+	}
+	return o
+}
+
+// cacheFileFlags name the flags whose value is a file read during export;
+// the cache key covers the file's content, not just its name.
+var cacheFileFlags = []string{"aliases", "around", "exclude-nodes", "translate"}
+
+// errUncacheable is returned by exportFingerprints for an export whose
+// result depends on something it can't fingerprint.
+var errUncacheable = errors.New("-translate-cmd runs a command whose output can't be fingerprinted")
+
+// exportFingerprints lists what a cache key covers besides the canvas and
+// the flags: the tool version, the content of the files the flags name
+// (cacheFileFlags, -opt jsonld.context and the files -step arguments
+// read) and, with -vault,
+// the vault's notes. values returns what a flag is set to, nothing when it
+// isn't. The CLI and the daemon both build their keys with it.
+func exportFingerprints(values func(name string) []string) ([]string, error) {
+	if len(values("translate-cmd")) > 0 {
+		return nil, errUncacheable
+	}
+	settings := []string{"version=" + toolVersion()}
+	content := func(label, path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("-%s: %v", label, err)
+		}
+		settings = append(settings, label+"-content="+cacheKey(data))
+		return nil
+	}
+	for _, name := range cacheFileFlags {
+		for _, path := range values(name) {
+			if err := content(name, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, o := range values("opt") {
+		if path, ok := strings.CutPrefix(o, "jsonld.context="); ok && path != "" {
+			if err := content("opt jsonld.context", path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, def := range values("step") {
+		for _, path := range stepFiles(def) {
+			if err := content("step "+def, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, vault := range values("vault") {
+		fp, err := vaultFingerprint(vault)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, "vault-notes="+fp)
+	}
+	return settings, nil
+}
+
+// cacheKey hashes the canvas together with the settings it is exported
+// with.
+func cacheKey(data []byte, settings ...string) string {
+	h := sha256.New()
+	h.Write(data)
+	for _, s := range settings {
+		io.WriteString(h, "\x00"+s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// flagSettings lists the flags set on fs (by name=value, sorted), leaving
+// out the ones that don't change what is written.
+func flagSettings(fs *flag.FlagSet, ignore ...string) []string {
+	var settings []string
+	fs.Visit(func(f *flag.Flag) {
+		if !slices.Contains(ignore, f.Name) {
+			settings = append(settings, f.Name+"="+f.Value.String())
+		}
+	})
+	slices.Sort(settings)
+	return settings
+}
+
+// vaultFingerprint summarises the notes in vault by path, size and
+// modification time, so editing any note invalidates the cache.
+func vaultFingerprint(vault string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(vault, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != vault && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(vault, path)
+		io.WriteString(h, filepath.ToSlash(rel)+"\x00"+strconv.FormatInt(fi.Size(), 10)+"\x00"+strconv.FormatInt(fi.ModTime().UnixNano(), 10)+"\n")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
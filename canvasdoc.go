@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Tools that edit canvases in place work on a canvasDoc rather than Canvas,
+// so fields this tool doesn't model (colours, sides, future additions) and
+// the key order survive the round trip.
+
+// jsonObject is a JSON object that keeps its keys in order.
+type jsonObject []jsonField
+
+type jsonField struct {
+	Key   string
+	Value json.RawMessage
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("want a JSON object")
+	}
+	*o = (*o)[:0]
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		*o = append(*o, jsonField{Key: tok.(string), Value: v})
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.Key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(f.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// get decodes the value of key into v, reporting whether it was present.
+func (o jsonObject) get(key string, v any) bool {
+	for _, f := range o {
+		if f.Key == key {
+			return json.Unmarshal(f.Value, v) == nil
+		}
+	}
+	return false
+}
+
+func (o jsonObject) str(key string) string {
+	var s string
+	o.get(key, &s)
+	return s
+}
+
+// set replaces the value of key, appending it if missing.
+func (o *jsonObject) set(key string, v any) {
+	data, err := marshalNoEscape(v)
+	if err != nil {
+		panic(err) // only called with plain values
+	}
+	for i, f := range *o {
+		if f.Key == key {
+			(*o)[i].Value = data
+			return
+		}
+	}
+	*o = append(*o, jsonField{Key: key, Value: data})
+}
+
+// canvasDoc is a canvas file as an ordered document.
+type canvasDoc struct {
+	root  jsonObject
+	Nodes []jsonObject
+	Edges []jsonObject
+}
+
+func readCanvasDoc(path string) (*canvasDoc, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &canvasDoc{}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}), &d.root); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	d.root.get("nodes", &d.Nodes)
+	d.root.get("edges", &d.Edges)
+	return d, nil
+}
+
+// bytes renders the document the way Obsidian writes canvases: tab
+// indented, without HTML escaping.
+func (d *canvasDoc) bytes() ([]byte, error) {
+	root := append(jsonObject(nil), d.root...)
+	if d.Nodes == nil {
+		d.Nodes = []jsonObject{}
+	}
+	if d.Edges == nil {
+		d.Edges = []jsonObject{}
+	}
+	root.set("nodes", d.Nodes)
+	root.set("edges", d.Edges)
+	compact, err := marshalNoEscape(root)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact, "", "\t"); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeFileAtomic replaces path with data via a temporary file in the same
+// directory, so readers (and Obsidian) never see a half-written canvas.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (d *canvasDoc) write(path string) error {
+	data, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func marshalNoEscape(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
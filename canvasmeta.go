@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Besides nodes and edges, canvases may carry top-level fields of their
+// own: a background image, or a plugin's "metadata" object. They are kept
+// in Canvas.Meta and exported as graph-level data by the formats that have
+// somewhere to put it.
+
+// decodeCanvas reads a canvas object from dec, collecting the top-level
+// fields other than nodes and edges into Meta in file order. data is what
+// dec reads, used to make error offsets absolute.
+func decodeCanvas(dec *json.Decoder, data []byte) (Canvas, error) {
+	var c Canvas
+	tok, err := dec.Token()
+	if err != nil {
+		return c, err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		return c, nil
+	case json.Delim:
+		if tok == '{' {
+			break
+		}
+		return c, &json.UnmarshalTypeError{Value: "array", Type: reflect.TypeFor[Canvas](), Offset: dec.InputOffset()}
+	default:
+		return c, &json.UnmarshalTypeError{Value: jsonKind(tok), Type: reflect.TypeFor[Canvas](), Offset: dec.InputOffset()}
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return c, err
+		}
+		key := tok.(string)
+		rest := data[min(dec.InputOffset(), int64(len(data))):]
+		valueAt := int64(len(data) - len(bytes.TrimLeft(rest, " \t\r\n:")))
+		switch key {
+		case "nodes":
+			err = dec.Decode(&c.Nodes)
+		case "edges":
+			err = dec.Decode(&c.Edges)
+		default:
+			var v json.RawMessage
+			if err = dec.Decode(&v); err == nil {
+				c.Meta = append(c.Meta, jsonField{Key: key, Value: v})
+			}
+		}
+		var typ *json.UnmarshalTypeError
+		if errors.As(err, &typ) {
+			typ.Field = strings.TrimSuffix(key+"."+typ.Field, ".")
+			typ.Offset += valueAt
+		}
+		if err != nil {
+			return c, err
+		}
+	}
+	_, err = dec.Token()
+	return c, err
+}
+
+func jsonKind(tok json.Token) string {
+	switch tok.(type) {
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "value"
+}
+
+// metaField is a canvas metadata field as exported: strings as they are,
+// anything else as compact JSON.
+type metaField struct {
+	Key, Value string
+}
+
+func metaFields(meta jsonObject) []metaField {
+	var out []metaField
+	for _, f := range meta {
+		var s string
+		if json.Unmarshal(f.Value, &s) != nil {
+			var buf bytes.Buffer
+			json.Compact(&buf, f.Value)
+			s = buf.String()
+		}
+		out = append(out, metaField{f.Key, s})
+	}
+	return out
+}
+
+// Background returns the canvas-level background image, if the canvas
+// names one.
+func (c Canvas) Background() string {
+	return c.Meta.str("background")
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// check enforces a vault's canvas quality rules in CI: each rule is a
+// name=value assertion (max-orphans=0, require-labels=true), given with
+// -rule or one per line in a -rules file, and check exits 1 when any canvas
+// breaks one. A rule over an element count reports every offending node
+// or edge, so the report points at what to fix.
+
+type checkRule struct {
+	usage string
+	bool  bool // the value is true or false rather than a count
+	// eval returns the offending issues, given the limit (or 1 for true).
+	eval func(c Canvas, g *graph, limit int) []issue
+}
+
+var checkRules = map[string]checkRule{
+	"max-nodes": {usage: "at most this many nodes", eval: func(c Canvas, g *graph, limit int) []issue {
+		return countIssue("max-nodes", "nodes", len(c.Nodes), limit, "nodes")
+	}},
+	"max-edges": {usage: "at most this many edges", eval: func(c Canvas, g *graph, limit int) []issue {
+		return countIssue("max-edges", "edges", len(c.Edges), limit, "edges")
+	}},
+	"max-components": {usage: "at most this many connected components", eval: func(c Canvas, g *graph, limit int) []issue {
+		return countIssue("max-components", "", len(g.components()), limit, "connected components")
+	}},
+	"max-orphans": {usage: "at most this many nodes (groups aside) without edges", eval: func(c Canvas, g *graph, limit int) []issue {
+		var found []issue
+		for i, n := range g.Nodes {
+			if n.Type != "group" && len(g.out[n.ID]) == 0 && len(g.in[n.ID]) == 0 {
+				found = append(found, issue{Path: fmt.Sprintf("nodes[%d]", i), NodeID: n.ID, Message: fmt.Sprintf("node %s has no edges", describeNode(n))})
+			}
+		}
+		return overLimit("max-orphans", found, limit, "orphan nodes")
+	}},
+	"max-dangling": {usage: "at most this many edges to nodes that don't exist", eval: func(c Canvas, g *graph, limit int) []issue {
+		var found []issue
+		for i, e := range g.Edges {
+			for _, end := range []string{e.From, e.To} {
+				if _, ok := g.node(end); !ok {
+					found = append(found, issue{Path: fmt.Sprintf("edges[%d]", i), NodeID: end, Message: fmt.Sprintf("edge %s points at unknown node %q", describeEdge(g, i), end)})
+					break
+				}
+			}
+		}
+		return overLimit("max-dangling", found, limit, "dangling edges")
+	}},
+	"max-cycles": {usage: "at most this many edges closing a cycle (see validate -dag)", eval: func(c Canvas, g *graph, limit int) []issue {
+		var found []issue
+		for _, i := range feedbackEdges(g) {
+			found = append(found, issue{Path: fmt.Sprintf("edges[%d]", i), NodeID: g.Edges[i].From, Message: fmt.Sprintf("edge %s closes a cycle", describeEdge(g, i))})
+		}
+		return overLimit("max-cycles", found, limit, "edges closing a cycle")
+	}},
+	"max-self-loops": {usage: "at most this many edges from a node to itself", eval: func(c Canvas, g *graph, limit int) []issue {
+		var found []issue
+		for i, e := range g.Edges {
+			if e.From == e.To {
+				found = append(found, issue{Path: fmt.Sprintf("edges[%d]", i), NodeID: e.From, Message: fmt.Sprintf("edge %s is a self-loop", describeEdge(g, i))})
+			}
+		}
+		return overLimit("max-self-loops", found, limit, "self-loops")
+	}},
+	"require-labels": {usage: "every edge has a label", bool: true, eval: func(c Canvas, g *graph, _ int) []issue {
+		var found []issue
+		for i, e := range g.Edges {
+			if strings.TrimSpace(e.Label) == "" {
+				found = append(found, issue{Path: fmt.Sprintf("edges[%d]", i), NodeID: e.From, Message: fmt.Sprintf("edge %s has no label", describeEdge(g, i))})
+			}
+		}
+		return overLimit("require-labels", found, 0, "unlabelled edges")
+	}},
+	"require-node-text": {usage: "no text node is empty", bool: true, eval: func(c Canvas, g *graph, _ int) []issue {
+		var found []issue
+		for i, n := range g.Nodes {
+			if n.Type == "text" && strings.TrimSpace(n.Node.Text) == "" {
+				found = append(found, issue{Path: fmt.Sprintf("nodes[%d]", i), NodeID: n.ID, Message: fmt.Sprintf("text node %s is empty", n.ID)})
+			}
+		}
+		return overLimit("require-node-text", found, 0, "empty text nodes")
+	}},
+}
+
+func describeNode(n graphNode) string {
+	if n.Name == "" {
+		return n.ID
+	}
+	return fmt.Sprintf("%q", n.Name)
+}
+
+func describeEdge(g *graph, i int) string {
+	e := g.Edges[i]
+	name := func(id string) string {
+		if n := g.name(id); n != "" {
+			return n
+		}
+		return id
+	}
+	if e.Label != "" {
+		return fmt.Sprintf("%s -%s-> %s", name(e.From), e.Label, name(e.To))
+	}
+	return name(e.From) + " -> " + name(e.To)
+}
+
+// countIssue reports a count over its limit.
+func countIssue(rule, path string, n, limit int, what string) []issue {
+	if n <= limit {
+		return nil
+	}
+	return []issue{{Severity: "error", Code: rule, Path: path, Message: fmt.Sprintf("%d %s, over the limit of %d", n, what, limit)}}
+}
+
+// overLimit turns the offending elements into issues when there are more
+// than limit of them.
+func overLimit(rule string, found []issue, limit int, what string) []issue {
+	if len(found) <= limit {
+		return nil
+	}
+	for i := range found {
+		found[i].Severity, found[i].Code = "error", rule
+		if limit > 0 {
+			found[i].Message += fmt.Sprintf(" (%d %s, limit %d)", len(found), what, limit)
+		}
+	}
+	return found
+}
+
+// checkAssertion is a parsed rule.
+type checkAssertion struct {
+	name  string
+	limit int
+}
+
+func parseCheckRule(s string) (checkAssertion, error) {
+	name, value, ok := strings.Cut(strings.TrimSpace(s), "=")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	rule, known := checkRules[name]
+	if !known {
+		return checkAssertion{}, fmt.Errorf("unknown rule %q (want %s)", name, strings.Join(sortedKeys(checkRules), ", "))
+	}
+	if !ok {
+		return checkAssertion{}, fmt.Errorf("rule %s: want %s=value", name, name)
+	}
+	if rule.bool {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return checkAssertion{}, fmt.Errorf("rule %s: want true or false, got %q", name, value)
+		}
+		if !b {
+			return checkAssertion{name: name, limit: -1}, nil // off
+		}
+		return checkAssertion{name: name}, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return checkAssertion{}, fmt.Errorf("rule %s: want a count, got %q", name, value)
+	}
+	return checkAssertion{name: name, limit: n}, nil
+}
+
+// String gives the rule back as name=value.
+func (a checkAssertion) String() string {
+	switch {
+	case !checkRules[a.name].bool:
+		return fmt.Sprintf("%s=%d", a.name, a.limit)
+	case a.limit < 0:
+		return a.name + "=false"
+	}
+	return a.name + "=true"
+}
+
+// readCheckRules reads a rules file: one name=value per line, # comments.
+func readCheckRules(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			rules = append(rules, line)
+		}
+	}
+	return rules, sc.Err()
+}
+
+// checkCanvas applies the assertions to one canvas.
+func checkCanvas(c Canvas, rules []checkAssertion) []reportCheck {
+	g := buildGraph(c, true)
+	var checks []reportCheck
+	for _, r := range rules {
+		ck := reportCheck{name: r.String(), skipped: r.limit < 0}
+		if !ck.skipped {
+			ck.issues = checkRules[r.name].eval(c, g, r.limit)
+		}
+		checks = append(checks, ck)
+	}
+	return checks
+}
+
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	var ruleArgs stringsFlag
+	fs.Var(&ruleArgs, "rule", "assertion `name=value`, e.g. max-orphans=0 or require-labels=true (repeatable)")
+	rulesFile := fs.String("rules", "", "`file` of assertions, one name=value per line")
+	asJSON := fs.Bool("json", false, "print violations as JSON lines")
+	report := fs.String("report", "text", reportFlagUsage)
+	list := fs.Bool("list", false, "list the rules and exit")
+	fs.Parse(args)
+	if *asJSON {
+		*report = "json"
+	}
+	checkReportFormat("check", *report)
+	if *list {
+		for _, name := range sortedKeys(checkRules) {
+			value := "N"
+			if checkRules[name].bool {
+				value = "true"
+			}
+			fmt.Printf("%s=%s\t%s\n", name, value, checkRules[name].usage)
+		}
+		return
+	}
+	defs := []string(ruleArgs)
+	if *rulesFile != "" {
+		more, err := readCheckRules(*rulesFile)
+		if err != nil {
+			fatalf("check: %v", err)
+		}
+		defs = append(more, defs...) // -rule overrides the file
+	}
+	if len(defs) == 0 {
+		fatalf("check: no rules given (-rule or -rules; -list shows them)")
+	}
+	byName := map[string]checkAssertion{}
+	for _, d := range defs {
+		a, err := parseCheckRule(d)
+		if err != nil {
+			fatalf("check: %v", err)
+		}
+		byName[a.name] = a
+	}
+	var rules []checkAssertion
+	for _, name := range sortedKeys(byName) {
+		rules = append(rules, byName[name])
+	}
+	paths, err := expandInputs(fs.Args())
+	if err != nil {
+		fatalf("check: %v", err)
+	}
+	if len(paths) == 0 {
+		fatalf("check: no canvases given")
+	}
+
+	failed := false
+	enc := json.NewEncoder(os.Stdout)
+	var reported []reportedCanvas
+	for _, p := range paths {
+		data, err := readAllInput(p)
+		if err != nil {
+			fatalf("check: %v", err)
+		}
+		rc := reportedCanvas{path: p, data: data}
+		c, err := parseCanvas(data)
+		if err != nil {
+			rc.checks = []reportCheck{{name: "parse", issues: []issue{{Severity: "error", Code: "invalid-json", Message: err.Error()}}}}
+		} else {
+			rc.checks = checkCanvas(c, rules) // rules are sorted, so issues come by code
+		}
+		reported = append(reported, rc)
+		for _, is := range rc.issues() {
+			failed = true
+			switch *report {
+			case "sarif", "junit":
+				continue
+			case "json":
+				enc.Encode(struct {
+					Canvas string `json:"canvas"`
+					issue
+				}{p, is})
+				continue
+			}
+			loc := is.Path
+			if loc == "" {
+				loc = "-"
+			}
+			fmt.Printf("%s: %s: %s %s: %s\n", p, loc, is.Severity, is.Code, is.Message)
+		}
+	}
+	if err := writeReport(os.Stdout, "check", *report, reported); err != nil {
+		fatalf("check: %v", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
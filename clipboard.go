@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardPath is the special -in/-out value that reads from or writes to
+// the system clipboard.
+const clipboardPath = "clipboard"
+
+type clipboardCmd struct {
+	name string
+	args []string
+}
+
+// clipboardCommands returns candidate read (paste) and write (copy) commands
+// for the current platform, in order of preference.
+func clipboardCommands() (paste, cp []clipboardCmd) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardCmd{{"pbpaste", nil}}, []clipboardCmd{{"pbcopy", nil}}
+	case "windows":
+		ps := []string{"-NoProfile", "-NonInteractive", "-Command"}
+		return []clipboardCmd{{"powershell", append(ps, "[Console]::OutputEncoding=[Text.Encoding]::UTF8; Get-Clipboard -Raw")}},
+			[]clipboardCmd{{"powershell", append(ps, "[Console]::InputEncoding=[Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())")}}
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		paste = append(paste, clipboardCmd{"wl-paste", []string{"--no-newline"}})
+		cp = append(cp, clipboardCmd{"wl-copy", nil})
+	}
+	paste = append(paste,
+		clipboardCmd{"xclip", []string{"-selection", "clipboard", "-o"}},
+		clipboardCmd{"xsel", []string{"--clipboard", "--output"}},
+	)
+	cp = append(cp,
+		clipboardCmd{"xclip", []string{"-selection", "clipboard", "-i"}},
+		clipboardCmd{"xsel", []string{"--clipboard", "--input"}},
+	)
+	return paste, cp
+}
+
+// firstAvailable returns the first command found in PATH.
+func firstAvailable(cmds []clipboardCmd) (clipboardCmd, error) {
+	var names []string
+	for _, c := range cmds {
+		if _, err := exec.LookPath(c.name); err == nil {
+			return c, nil
+		}
+		names = append(names, c.name)
+	}
+	return clipboardCmd{}, fmt.Errorf("no clipboard tool found (tried %s)", strings.Join(names, ", "))
+}
+
+func readClipboard() ([]byte, error) {
+	paste, _ := clipboardCommands()
+	c, err := firstAvailable(paste)
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", c.name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func writeClipboard(c clipboardCmd, data []byte) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", c.name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func openClipboardIn() (io.Reader, func() error, error) {
+	data, err := readClipboard()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil, errors.New("clipboard is empty")
+	}
+	return bytes.NewReader(data), func() error { return nil }, nil
+}
+
+// openClipboardOut buffers everything written and copies it to the clipboard
+// when closed. The copy tool is resolved up front so a missing tool fails
+// before any conversion work is done.
+func openClipboardOut() (io.Writer, func() error, error) {
+	_, cp := clipboardCommands()
+	c, err := firstAvailable(cp)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	return &buf, func() error { return writeClipboard(c, buf.Bytes()) }, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Community detection runs on the undirected graph, with parallel edges
+// adding weight. Community IDs are numbered from 1 in order of each
+// community's first node on the canvas.
+
+// communityColors tints communities in DOT/GraphML output, cycling when
+// there are more communities than colours.
+var communityColors = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b",
+	"#e377c2", "#7f7f7f", "#bcbd22", "#17becf", "#aec7e8", "#ffbb78",
+}
+
+// weightedAdjacency returns per-node neighbour weights (by index), ignoring
+// direction, self-loops and dangling edges.
+func weightedAdjacency(g *graph) []map[int]float64 {
+	adj := make([]map[int]float64, len(g.Nodes))
+	for i := range adj {
+		adj[i] = map[int]float64{}
+	}
+	for _, e := range g.Edges {
+		a, okA := g.byID[e.From]
+		b, okB := g.byID[e.To]
+		if okA && okB && a != b {
+			adj[a][b]++
+			adj[b][a]++
+		}
+	}
+	return adj
+}
+
+// louvain maximises modularity greedily: nodes move to the neighbouring
+// community with the best gain until nothing moves, then communities are
+// collapsed into single nodes and the process repeats.
+func louvain(adj []map[int]float64) []int {
+	n := len(adj)
+	membership := make([]int, n) // original node -> current super-node
+	for i := range membership {
+		membership[i] = i
+	}
+	self := make([]float64, n) // weight of each super-node's internal edges, counted twice
+	for {
+		comm, moved := louvainPass(adj, self)
+		if !moved {
+			return renumber(membership)
+		}
+		// aggregate communities into super-nodes
+		ids := renumber(comm)
+		k := 0
+		for _, c := range ids {
+			k = max(k, c+1)
+		}
+		next := make([]map[int]float64, k)
+		nextSelf := make([]float64, k)
+		for i := range next {
+			next[i] = map[int]float64{}
+		}
+		for v, ns := range adj {
+			cv := ids[v]
+			nextSelf[cv] += self[v]
+			for u, w := range ns {
+				if cu := ids[u]; cu == cv {
+					nextSelf[cv] += w
+				} else {
+					next[cv][cu] += w
+				}
+			}
+		}
+		for i := range membership {
+			membership[i] = ids[membership[i]]
+		}
+		adj, self = next, nextSelf
+	}
+}
+
+// louvainPass runs the local-moving phase and reports whether any node
+// changed community.
+func louvainPass(adj []map[int]float64, self []float64) ([]int, bool) {
+	n := len(adj)
+	comm := make([]int, n)
+	degree := make([]float64, n) // weighted degree including internal weight
+	tot := make([]float64, n)    // total degree per community
+	m2 := 0.0                    // 2m
+	for v, ns := range adj {
+		comm[v] = v
+		degree[v] = self[v]
+		for _, w := range ns {
+			degree[v] += w
+		}
+		tot[v] = degree[v]
+		m2 += degree[v]
+	}
+	if m2 == 0 {
+		return comm, false
+	}
+	movedAny := false
+	for moved := true; moved; {
+		moved = false
+		for v := 0; v < n; v++ {
+			links := map[int]float64{} // community -> weight from v
+			for u, w := range adj[v] {
+				links[comm[u]] += w
+			}
+			old := comm[v]
+			tot[old] -= degree[v]
+			best, bestGain := old, links[old]-tot[old]*degree[v]/m2
+			for _, c := range slices.Sorted(maps.Keys(links)) {
+				if gain := links[c] - tot[c]*degree[v]/m2; gain > bestGain+1e-12 {
+					best, bestGain = c, gain
+				}
+			}
+			comm[v] = best
+			tot[best] += degree[v]
+			if best != old {
+				moved, movedAny = true, true
+			}
+		}
+	}
+	return comm, movedAny
+}
+
+// labelPropagation gives every node the label most common among its
+// neighbours until labels settle, visiting nodes in a fresh random order
+// each round and breaking ties at random, as the algorithm requires to
+// avoid one label flooding the graph.
+func labelPropagation(adj []map[int]float64, rng *rand.Rand) []int {
+	labels := make([]int, len(adj))
+	for i := range labels {
+		labels[i] = i
+	}
+	for iter := 0; iter < 100; iter++ {
+		changed := false
+		for _, v := range rng.Perm(len(adj)) {
+			if len(adj[v]) == 0 {
+				continue
+			}
+			score := map[int]float64{}
+			for u, w := range adj[v] {
+				score[labels[u]] += w
+			}
+			var best []int
+			top := 0.0
+			for _, l := range slices.Sorted(maps.Keys(score)) {
+				switch s := score[l]; {
+				case s > top:
+					best, top = []int{l}, s
+				case s == top:
+					best = append(best, l)
+				}
+			}
+			if slices.Contains(best, labels[v]) {
+				continue // already holds a winning label
+			}
+			labels[v], changed = best[rng.Intn(len(best))], true
+		}
+		if !changed {
+			break
+		}
+	}
+	return renumber(labels)
+}
+
+// renumber maps arbitrary labels onto 0.. in order of first appearance.
+func renumber(labels []int) []int {
+	ids := map[int]int{}
+	out := make([]int, len(labels))
+	for i, l := range labels {
+		id, ok := ids[l]
+		if !ok {
+			id = len(ids)
+			ids[l] = id
+		}
+		out[i] = id
+	}
+	return out
+}
+
+// addCommunities runs the named algorithm and records each node's
+// community (from 1) and colour as attributes.
+func addCommunities(g *graph, algo string, seed int64) error {
+	var comm []int
+	switch algo {
+	case "louvain":
+		comm = louvain(weightedAdjacency(g))
+	case "label-propagation", "lpa":
+		comm = labelPropagation(weightedAdjacency(g), rand.New(rand.NewSource(seed)))
+	default:
+		return fmt.Errorf("unknown -algo %q (want louvain or label-propagation)", algo)
+	}
+	for i, c := range comm {
+		g.setAttr(i, "community", strconv.Itoa(c+1))
+		g.setAttr(i, "color", communityColors[c%len(communityColors)])
+	}
+	return nil
+}
+
+func runCluster(args []string) {
+	fs := flag.NewFlagSet("cluster", flag.ExitOnError)
+	inPath := fs.String("in", "", "input .canvas path (or - for stdin)")
+	outPath := fs.String("out", "-", "output path (or - for stdout)")
+	algo := fs.String("algo", "louvain", "algorithm: louvain or label-propagation")
+	format := fs.String("format", "csv", "csv (id;name;community), or any export format (dot, graphml, sql, ...) with community and color node attributes")
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	seed := fs.Int64("seed", 1, "random seed for label-propagation")
+	fs.Parse(args)
+	if *inPath == "" && fs.NArg() > 0 {
+		*inPath = fs.Arg(0)
+	}
+	if *inPath == "" {
+		fatalf("cluster: missing -in (or first arg)")
+	}
+	c, err := loadCanvas(*inPath)
+	if err != nil {
+		fatalf("cluster: %v", err)
+	}
+	g := buildGraph(c, *keepPath)
+	if err := addCommunities(g, *algo, *seed); err != nil {
+		fatalf("cluster: %v", err)
+	}
+
+	ex, ok := exporters[*format]
+	if !ok || ex.write == nil {
+		fatalf("cluster: unknown -format %q (want one of: %s)", *format, strings.Join(exporterNames(), ", "))
+	}
+	out, closeOut, err := openOut(*outPath)
+	if err != nil {
+		fatalf("cluster: %v", err)
+	}
+	if *format == "csv" {
+		w := csv.NewWriter(out)
+		w.Comma = ';'
+		w.Write([]string{"id", "name", "community"})
+		for _, n := range g.Nodes {
+			w.Write([]string{n.ID, n.Name, n.Attrs["community"]})
+		}
+		w.Flush()
+		err = w.Error()
+	} else {
+		opts, _ := exportOptionsFrom(nil)
+		err = ex.write(out, g, opts)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fatalf("cluster: %v", err)
+	}
+}
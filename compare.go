@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// compare scores how alike canvases are. Canvases don't share node IDs, so
+// nodes are matched by type and display name and edges by their endpoint
+// names and label.
+
+type similarity struct {
+	A            string  `json:"a"`
+	B            string  `json:"b"`
+	NodeJaccard  float64 `json:"node_jaccard"`
+	EdgeJaccard  float64 `json:"edge_jaccard"`  // from, label, to
+	LinkJaccard  float64 `json:"link_jaccard"`  // from, to: ignores labels
+	EditDistance int     `json:"edit_distance"` // node and edge insertions and deletions
+	Similarity   float64 `json:"similarity"`    // 1 - edit distance / total size
+}
+
+type graphKeys struct {
+	nodes, edges, links map[string]bool
+}
+
+func keysOf(g *graph) graphKeys {
+	k := graphKeys{nodes: map[string]bool{}, edges: map[string]bool{}, links: map[string]bool{}}
+	for _, n := range g.Nodes {
+		k.nodes[n.Type+"\x00"+n.Name] = true
+	}
+	for _, e := range g.Edges {
+		from, to := g.name(e.From), g.name(e.To)
+		k.edges[from+"\x00"+e.Label+"\x00"+to] = true
+		k.links[from+"\x00"+to] = true
+	}
+	return k
+}
+
+// jaccard returns |a∩b| / |a∪b| and the size of the symmetric difference.
+// Two empty sets are identical.
+func jaccard(a, b map[string]bool) (float64, int) {
+	common := 0
+	for k := range a {
+		if b[k] {
+			common++
+		}
+	}
+	union := len(a) + len(b) - common
+	if union == 0 {
+		return 1, 0
+	}
+	return float64(common) / float64(union), union - common
+}
+
+// compareGraphs approximates graph edit distance by the node and edge
+// insertions and deletions needed once nodes are aligned by name; it is an
+// upper bound that ignores relabelling.
+func compareGraphs(a, b graphKeys) similarity {
+	var s similarity
+	var dn, de int
+	s.NodeJaccard, dn = jaccard(a.nodes, b.nodes)
+	s.EdgeJaccard, de = jaccard(a.edges, b.edges)
+	s.LinkJaccard, _ = jaccard(a.links, b.links)
+	s.EditDistance = dn + de
+	total := len(a.nodes) + len(b.nodes) + len(a.edges) + len(b.edges)
+	s.Similarity = 1
+	if total > 0 {
+		s.Similarity = 1 - float64(s.EditDistance)/float64(total)
+	}
+	return s
+}
+
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	minSim := fs.Float64("min", 0, "only report pairs with similarity at least this (0-1)")
+	format := fs.String("format", "table", "output format: table, csv or json")
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	fs.Parse(args)
+	paths, err := expandInputs(fs.Args())
+	if err != nil {
+		fatalf("compare: %v", err)
+	}
+	if len(paths) < 2 {
+		fatalf("compare: want at least two canvases (paths or globs)")
+	}
+	keys := make([]graphKeys, len(paths))
+	for i, path := range paths {
+		c, err := loadCanvas(path)
+		if err != nil {
+			fatalf("compare: %v", err)
+		}
+		keys[i] = keysOf(buildGraph(c, *keepPath))
+	}
+
+	var results []similarity
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			s := compareGraphs(keys[i], keys[j])
+			s.A, s.B = paths[i], paths[j]
+			if s.Similarity >= *minSim {
+				results = append(results, s)
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Similarity > results[j].Similarity })
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if results == nil {
+			results = []similarity{}
+		}
+		enc.Encode(results)
+	case "table", "csv":
+		w := csv.NewWriter(os.Stdout)
+		if *format == "table" {
+			w.Comma = '\t'
+		}
+		f := func(x float64) string { return strconv.FormatFloat(x, 'f', 3, 64) }
+		w.Write([]string{"a", "b", "similarity", "node_jaccard", "edge_jaccard", "link_jaccard", "edit_distance"})
+		for _, s := range results {
+			w.Write([]string{s.A, s.B, f(s.Similarity), f(s.NodeJaccard), f(s.EdgeJaccard), f(s.LinkJaccard), strconv.Itoa(s.EditDistance)})
+		}
+		w.Flush()
+	default:
+		fatalf("compare: unknown -format %q (want table, csv or json)", *format)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Completions and the man page are generated from the flags themselves.
+// Subcommands define their flag sets locally, so they are read back from
+// each one's -h output.
+
+// subcommandSummaries describes every subcommand for completions and the
+// man page; it must list the same names as subcommands.
+var subcommandSummaries = map[string]string{
+	"allpaths":    "list the paths between two sets of nodes",
+	"backlinks":   "index which canvases reference each note",
+	"bench":       "time parsing and every exporter on a synthetic canvas",
+	"check":       "enforce quality rules such as max-orphans=0 on canvases",
+	"cluster":     "assign a community to every node",
+	"compare":     "score how similar canvases are",
+	"completion":  "print a bash, zsh or fish completion script",
+	"corpus":      "collect content-free skeletons of canvases as fixtures",
+	"daemon":      "re-run export jobs on a schedule",
+	"describe":    "show the columns, types and example rows a csv export will have",
+	"diff":        "show what changed between two canvases",
+	"docs":        "print documentation (docs man)",
+	"embed":       "compute node embeddings",
+	"explore":     "browse a canvas interactively",
+	"exporttest":  "check an exporter or export plugin against the conformance corpus",
+	"gen":         "generate a canvas from other data",
+	"history":     "list recorded snapshots",
+	"layout":      "re-position the nodes of a canvas",
+	"merge":       "combine many canvases into one graph",
+	"plugins":     "list installed plugins",
+	"reach":       "tabulate how far each node can reach the others",
+	"rename":      "rewrite file node paths after notes move",
+	"search":      "find nodes across canvases",
+	"serve":       "serve canvases over GraphQL and gRPC",
+	"snapshot":    "record canvas snapshots in the history store",
+	"split":       "split a canvas into one canvas per top-level group",
+	"tasks":       "list the checkbox tasks in text nodes",
+	"tidy":        "snap a canvas to a grid and pull apart overlapping nodes",
+	"validate":    "check canvases for structural problems and broken files",
+	"vault-stats": "summarise canvas usage across a vault",
+	"version":     "print the version, commit, build date and formats",
+}
+
+type flagInfo struct {
+	name, arg, usage string
+}
+
+// commandFlags returns the flags of the command at path (nil for the
+// top-level conversion) by running it with -h.
+func commandFlags(self string, path ...string) []flagInfo {
+	var stderr bytes.Buffer
+	cmd := exec.Command(self, append(path, "-h")...)
+	cmd.Stderr = &stderr
+	cmd.Run() // -h exits non-zero for some commands
+	return parseFlagDefaults(stderr.String())
+}
+
+// parseFlagDefaults reads flag.PrintDefaults output.
+func parseFlagDefaults(s string) []flagInfo {
+	var flags []flagInfo
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "  -"):
+			name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+			flags = append(flags, flagInfo{name: strings.TrimPrefix(name, "-"), arg: arg})
+		case strings.HasPrefix(line, "    \t") && len(flags) > 0:
+			f := &flags[len(flags)-1]
+			f.usage = strings.TrimSpace(f.usage + " " + strings.TrimSpace(line))
+		}
+	}
+	return flags
+}
+
+// flagValues lists the known values of enumerated flags.
+func flagValues(pluginDir string) map[string][]string {
+	formats := exporterNames()
+	if found, err := listPlugins(pluginDir); err == nil {
+		formats = append(formats, found["export"]...)
+	}
+	tables := []string{"edges", "nodes"}
+	known := map[string][]string{ // values of enumerated -opt settings
+		"sql.dialect":   sortedKeys(sqlDialects),
+		"ascii.charset": {"unicode", "ascii"},
+		"parquet.table": tables,
+		"arrow.table":   tables,
+	}
+	var opts []string
+	for _, format := range sortedKeys(formatOptions) {
+		for _, key := range sortedKeys(formatOptions[format]) {
+			opts = append(opts, format+"."+key+"=")
+			for _, v := range known[format+"."+key] {
+				opts = append(opts, format+"."+key+"="+v)
+			}
+		}
+	}
+	steps := []string{"filter:", "dedupe", "dedupe:count", "contract-groups", "reverse", "map-labels:", "script:"}
+	if found, err := listPlugins(pluginDir); err == nil {
+		for _, name := range found["transform"] {
+			steps = append(steps, "plugin:"+name)
+		}
+	}
+	return map[string][]string{
+		"format":      formats,
+		"step":        steps,
+		"normalize":   normForms,
+		"opt":         opts,
+		"self-loops":  {"keep", "drop", "error"},
+		"parallel":    {"keep", "merge-labels", "count"},
+		"folders":     {"node", "group"},
+		"bundle":      {"groups", "clusters"},
+		"bundle-mode": {"replace", "add"},
+		"provenance":  {"comment", "sidecar"},
+		"layout":      {"matrix", "long"},
+		"algo":        {"louvain", "label-propagation", "auto", "grid", "circle", "radial", "tree", "layered", "force"}, // cluster and layouts
+	}
+}
+
+type commandSpec struct {
+	path  []string
+	flags []flagInfo
+}
+
+// commandSpecs collects the top level, every subcommand and every
+// generator.
+func commandSpecs() ([]commandSpec, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	specs := []commandSpec{{flags: commandFlags(self)}}
+	for _, name := range sortedKeys(subcommandSummaries) {
+		switch name {
+		case "gen":
+			specs = append(specs, commandSpec{path: []string{"gen"}})
+			for _, gen := range sortedKeys(generators) {
+				specs = append(specs, commandSpec{path: []string{"gen", gen}, flags: commandFlags(self, "gen", gen)})
+			}
+		case "completion", "docs":
+			specs = append(specs, commandSpec{path: []string{name}})
+		default:
+			specs = append(specs, commandSpec{path: []string{name}, flags: commandFlags(self, name)})
+		}
+	}
+	return specs, nil
+}
+
+func runCompletion(args []string) {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	pluginDir := fs.String("plugins", defaultPluginDir(), "plugins directory whose export formats are offered")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: canvas_tool completion [flags] bash|zsh|fish")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	specs, err := commandSpecs()
+	if err != nil {
+		fatalf("completion: %v", err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	values := flagValues(*pluginDir)
+	switch fs.Arg(0) {
+	case "bash":
+		writeBashCompletion(w, specs, values)
+	case "zsh":
+		fmt.Fprintln(w, "#compdef canvas_tool")
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(w, specs, values)
+	case "fish":
+		writeFishCompletion(w, specs, values)
+	default:
+		fatalf("completion: unknown shell %q (want bash, zsh or fish)", fs.Arg(0))
+	}
+}
+
+func writeBashCompletion(w io.Writer, specs []commandSpec, values map[string][]string) {
+	fmt.Fprintln(w, "# canvas_tool completion; source this file or install it as a completion script")
+	fmt.Fprintln(w, "_canvas_tool() {")
+	var paths []string
+	for _, s := range specs[1:] {
+		paths = append(paths, strings.Join(s.path, " "))
+	}
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmd="" next i`)
+	fmt.Fprintf(w, "	local commands=%q\n", "|"+strings.Join(paths, "|")+"|")
+	fmt.Fprintln(w, `	for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `		next="${cmd:+$cmd }${COMP_WORDS[i]}"`)
+	fmt.Fprintln(w, `		[[ $commands == *"|$next|"* ]] && cmd=$next`)
+	fmt.Fprintln(w, "	done")
+	fmt.Fprintln(w, `	case "$prev" in`)
+	for _, name := range sortedKeys(values) {
+		fmt.Fprintf(w, "	-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, strings.Join(values[name], " "))
+	}
+	fmt.Fprintln(w, "	esac")
+	fmt.Fprintln(w, `	local words=""`)
+	fmt.Fprintln(w, `	case "$cmd" in`)
+	for _, s := range specs {
+		var words []string
+		for _, f := range s.flags {
+			words = append(words, "-"+f.name)
+		}
+		switch len(s.path) {
+		case 0:
+			words = append(words, sortedKeys(subcommandSummaries)...)
+			fmt.Fprintf(w, "	\"\") words=%q ;;\n", strings.Join(words, " "))
+		default:
+			pattern := strings.Join(s.path, " ")
+			if s.path[0] == "gen" && len(s.path) == 1 {
+				words = sortedKeys(generators)
+			}
+			if s.path[0] == "completion" {
+				words = []string{"bash", "zsh", "fish", "-plugins"}
+			}
+			if s.path[0] == "docs" {
+				words = []string{"man"}
+			}
+			fmt.Fprintf(w, "	%q) words=%q ;;\n", pattern, strings.Join(words, " "))
+		}
+	}
+	fmt.Fprintln(w, "	esac")
+	fmt.Fprintln(w, `	if [[ $cur == -* ]]; then`)
+	fmt.Fprintln(w, `		COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	fmt.Fprintln(w, "	else")
+	fmt.Fprintln(w, `		COMPREPLY=($(compgen -W "$(tr ' ' '\n' <<<"$words" | grep -v '^-')" -- "$cur") $(compgen -f -- "$cur"))`)
+	fmt.Fprintln(w, "	fi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o filenames -F _canvas_tool canvas_tool")
+}
+
+func writeFishCompletion(w io.Writer, specs []commandSpec, values map[string][]string) {
+	fmt.Fprintln(w, "# canvas_tool completion for fish")
+	fmt.Fprintln(w, "complete -c canvas_tool -f")
+	for _, name := range sortedKeys(subcommandSummaries) {
+		fmt.Fprintf(w, "complete -c canvas_tool -n __fish_use_subcommand -a %s -d %s\n", name, fishQuote(subcommandSummaries[name]))
+	}
+	for _, gen := range sortedKeys(generators) {
+		fmt.Fprintf(w, "complete -c canvas_tool -n '__fish_seen_subcommand_from gen' -a %s\n", gen)
+	}
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		fmt.Fprintf(w, "complete -c canvas_tool -n '__fish_seen_subcommand_from completion' -a %s\n", shell)
+	}
+	fmt.Fprintln(w, "complete -c canvas_tool -n '__fish_seen_subcommand_from docs' -a man")
+	for _, s := range specs {
+		cond := "__fish_use_subcommand"
+		if len(s.path) > 0 {
+			cond = "__fish_seen_subcommand_from " + s.path[len(s.path)-1]
+		}
+		for _, f := range s.flags {
+			line := fmt.Sprintf("complete -c canvas_tool -n %s -o %s -d %s", fishQuote(cond), f.name, fishQuote(firstSentence(f.usage)))
+			if vals, ok := values[f.name]; ok {
+				line += " -x -a " + fishQuote(strings.Join(vals, " "))
+			} else if f.arg != "" {
+				line += " -r -F"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func firstSentence(s string) string {
+	if i := strings.Index(s, ". "); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, ".")
+}
+
+func runDocs(args []string) {
+	if len(args) != 1 || args[0] != "man" {
+		fmt.Fprintln(os.Stderr, "usage: canvas_tool docs man")
+		os.Exit(2)
+	}
+	specs, err := commandSpecs()
+	if err != nil {
+		fatalf("docs: %v", err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	writeManPage(w, specs, time.Now())
+}
+
+// writeManPage renders a man(7) page for canvas_tool(1).
+func writeManPage(w io.Writer, specs []commandSpec, now time.Time) {
+	esc := func(s string) string {
+		s = strings.ReplaceAll(s, `\`, `\e`)
+		s = strings.ReplaceAll(s, "-", `\-`)
+		if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+			s = `\&` + s
+		}
+		return s
+	}
+	flags := func(fs []flagInfo) {
+		sort.SliceStable(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+		for _, f := range fs {
+			fmt.Fprintln(w, ".TP")
+			if f.arg != "" {
+				fmt.Fprintf(w, ".BI \\-%s \" %s\"\n", esc(f.name), esc(f.arg))
+			} else {
+				fmt.Fprintf(w, ".B \\-%s\n", esc(f.name))
+			}
+			fmt.Fprintln(w, esc(f.usage))
+		}
+	}
+	fmt.Fprintf(w, ".TH CANVAS_TOOL 1 %q\n", now.Format("2006-01-02"))
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintln(w, `canvas_tool \- convert and analyse Obsidian .canvas files`)
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintln(w, ".B canvas_tool")
+	fmt.Fprintln(w, ".RI [ flags ] \" file.canvas\"")
+	fmt.Fprintln(w, ".br")
+	fmt.Fprintln(w, ".B canvas_tool")
+	fmt.Fprintln(w, ".IR command \" [flags] [args]\"")
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	fmt.Fprintln(w, "Without a command, canvas_tool converts a canvas to one of the export formats:")
+	fmt.Fprintln(w, esc(strings.Join(exporterNames(), ", "))+".")
+	fmt.Fprintln(w, "The default is CSV triples of from;label;to.")
+	fmt.Fprintln(w, ".SH OPTIONS")
+	flags(specs[0].flags)
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, s := range specs[1:] {
+		name := strings.Join(s.path, " ")
+		fmt.Fprintf(w, ".SS %s\n", esc(name))
+		if summary := subcommandSummaries[name]; summary != "" {
+			fmt.Fprintln(w, esc(summary)+".")
+		}
+		if name == "gen" {
+			fmt.Fprintln(w, "Generators: "+esc(strings.Join(sortedKeys(generators), ", "))+".")
+		}
+		flags(s.flags)
+	}
+	fmt.Fprintln(w, ".SH ENVIRONMENT")
+	fmt.Fprintln(w, ".TP\n.B CANVAS_TOOL_PLUGINS\nplugins directory")
+	fmt.Fprintln(w, ".TP\n.B NEO4J_PASSWORD\npassword for Neo4j outputs when the URL has none")
+}
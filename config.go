@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// A config file is a JSON object keyed by flag name, e.g.
+//
+//	{"format": "sql", "vault": "~/notes", "field": ["degree_out", "words=word_count of text"]}
+//
+// Arrays set repeatable flags once per element. Flags given on the command
+// line win over the file.
+
+// applyConfig sets every flag of fs named in the config file at path that
+// was not already set on the command line.
+func applyConfig(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var settings map[string]any
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("parse %s: %v", path, err)
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for name, v := range settings {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		for _, v := range values {
+			var s string
+			switch v := v.(type) {
+			case string:
+				s = v
+			case bool:
+				s = strconv.FormatBool(v)
+			case float64:
+				s = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return fmt.Errorf("%s: setting %s: want a string, number, bool or array of them", path, name)
+			}
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("%s: setting %s: %v", path, name, err)
+			}
+		}
+	}
+	return nil
+}
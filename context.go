@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// Long conversions honour a context.Context: reads and writes fail once it
+// is done, which stops the JSON decoder and every exporter at their next
+// I/O, and external processes are started with it so they are killed too.
+
+// ctxReader fails reads once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := context.Cause(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ctxWriter fails writes once ctx is done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := context.Cause(w.ctx); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// readerChunk bounds how much the decoder may read between context checks.
+const readerChunk = 64 << 10
+
+// chunkedReader hands out at most readerChunk bytes per Read.
+type chunkedReader struct{ r io.Reader }
+
+func (c chunkedReader) Read(p []byte) (int, error) {
+	if len(p) > readerChunk {
+		p = p[:readerChunk]
+	}
+	return c.r.Read(p)
+}
+
+// parseCanvasContext is parseCanvas that gives up when ctx is done.
+func parseCanvasContext(ctx context.Context, data []byte) (Canvas, error) {
+	return parseCanvasLimits(ctx, data, parseLimits{})
+}
+
+// parseCanvasLimits parses a canvas that must stay within lim, giving up
+// when ctx is done.
+func parseCanvasLimits(ctx context.Context, data []byte, lim parseLimits) (Canvas, error) {
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}) // optional UTF-8 BOM
+	if err := checkLimits(data, lim); err != nil {
+		return Canvas{}, err
+	}
+	decode := func(strict bool) (Canvas, error) {
+		dec := json.NewDecoder(ctxReader{ctx, chunkedReader{bytes.NewReader(data)}})
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		return decodeCanvas(dec, data)
+	}
+	c, err := decode(true)
+	if err != nil && context.Cause(ctx) == nil {
+		// fall back to lenient decode (Obsidian may add fields)
+		c, err = decode(false)
+	}
+	if cause := context.Cause(ctx); cause != nil {
+		return Canvas{}, cause
+	}
+	if err != nil {
+		return Canvas{}, describeJSONError(data, err)
+	}
+	normalizeKinds(&c)
+	return c, nil
+}
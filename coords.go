@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Coordinate transforms, for tools that don't share Obsidian's convention
+// of y growing downwards from an arbitrary origin: -flip-y mirrors the
+// canvas vertically (y up, as in Gephi), -scale multiplies positions and
+// sizes, and -offset moves everything, in that order. Exports carry the
+// result in the formats that write positions and in the x, y, width and
+// height fields; the generators apply it to the canvas they lay out.
+
+type coordTransform struct {
+	flipY  bool
+	scale  float64
+	offset pointFlag
+}
+
+// pointFlag is an "x,y" flag value.
+type pointFlag struct{ X, Y float64 }
+
+func (p *pointFlag) String() string {
+	return strconv.FormatFloat(p.X, 'f', -1, 64) + "," + strconv.FormatFloat(p.Y, 'f', -1, 64)
+}
+
+func (p *pointFlag) Set(v string) error {
+	xs, ys, ok := strings.Cut(v, ",")
+	x, errX := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if !ok || errX != nil || errY != nil {
+		return fmt.Errorf("want x,y, got %q", v)
+	}
+	p.X, p.Y = x, y
+	return nil
+}
+
+func registerCoordFlags(fs *flag.FlagSet, t *coordTransform) {
+	fs.BoolVar(&t.flipY, "flip-y", false, "mirror coordinates vertically, so y grows upwards")
+	fs.Float64Var(&t.scale, "scale", 1, "multiply coordinates and node sizes by this `factor`")
+	fs.Var(&t.offset, "offset", "add `x,y` to every position, after -flip-y and -scale")
+}
+
+func (t coordTransform) identity() bool {
+	return !t.flipY && t.scale == 1 && t.offset == pointFlag{}
+}
+
+func (t coordTransform) check() error {
+	if t.scale <= 0 {
+		return fmt.Errorf("-scale must be positive, got %g", t.scale)
+	}
+	return nil
+}
+
+// apply transforms the nodes' positions and sizes. Flipping mirrors each
+// card's whole area, so its corner moves to what was its bottom edge.
+func (t coordTransform) apply(nodes []Node) {
+	if t.identity() {
+		return
+	}
+	for i := range nodes {
+		n := &nodes[i]
+		if t.flipY {
+			n.Y = -(n.Y + n.Height)
+		}
+		n.X, n.Y = n.X*t.scale+t.offset.X, n.Y*t.scale+t.offset.Y
+		n.Width, n.Height = n.Width*t.scale, n.Height*t.scale
+	}
+}
+
+// applyGraph transforms the canvas nodes behind g.
+func (t coordTransform) applyGraph(g *graph) {
+	if t.identity() {
+		return
+	}
+	nodes := make([]Node, len(g.Nodes))
+	for i, n := range g.Nodes {
+		nodes[i] = n.Node
+	}
+	t.apply(nodes)
+	for i := range g.Nodes {
+		g.Nodes[i].Node = nodes[i]
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// corpus collects the structure of canvases without their content, for bug
+// reports, regression fixtures and fuzzing seeds. A skeleton keeps node
+// types, geometry, the edges between nodes with their sides and arrowheads,
+// and which edges were labelled; every ID is renumbered (n1, n2, ... and e1,
+// e2, ...) and every text, file path, URL and label is replaced by the new
+// ID, so nothing of the notes survives. Skeletons are named by their hash,
+// so collecting the same canvas twice writes one file.
+
+// skeleton strips c down to its structure.
+func skeleton(c Canvas, geometry bool) Canvas {
+	ids := map[string]string{}
+	anon := func(id string) string {
+		if a, ok := ids[id]; ok {
+			return a
+		}
+		a := "n" + strconv.Itoa(len(ids)+1)
+		ids[id] = a
+		return a
+	}
+	out := Canvas{Nodes: []Node{}, Edges: []Edge{}}
+	for _, n := range c.Nodes {
+		s := Node{ID: anon(n.ID), Type: n.Type}
+		switch n.Type {
+		case "text":
+			s.Text = s.ID
+		case "file":
+			s.File = s.ID + filepath.Ext(n.File)
+		case "link":
+			s.URL = "https://example.invalid/" + s.ID
+		case "group":
+			if n.Label != "" {
+				s.Label = s.ID
+			}
+		}
+		if geometry {
+			s.X, s.Y, s.Width, s.Height = n.X, n.Y, n.Width, n.Height
+		}
+		out.Nodes = append(out.Nodes, s)
+	}
+	for i, e := range c.Edges {
+		s := Edge{
+			ID:       "e" + strconv.Itoa(i+1),
+			FromNode: anon(e.FromNode), ToNode: anon(e.ToNode), // dangling ends stay dangling
+			FromSide: e.FromSide, ToSide: e.ToSide, FromEnd: e.FromEnd, ToEnd: e.ToEnd,
+		}
+		if e.Label != "" || e.Text != "" {
+			s.Label = s.ID
+		}
+		out.Edges = append(out.Edges, s)
+	}
+	return out
+}
+
+func runCorpus(args []string) {
+	fs := flag.NewFlagSet("corpus", flag.ExitOnError)
+	dir := fs.String("dir", "fixtures", "directory to write the skeleton canvases to")
+	geometry := fs.Bool("geometry", true, "keep node positions and sizes (they show group membership and layout)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fatalf("corpus: want canvases or directories to collect")
+	}
+	inputs, err := expandInputs(fs.Args())
+	if err != nil {
+		fatalf("corpus: %v", err)
+	}
+	var paths []string
+	for _, in := range inputs {
+		if fi, err := os.Stat(in); err == nil && fi.IsDir() {
+			found, err := findCanvases(in)
+			if err != nil {
+				fatalf("corpus: %v", err)
+			}
+			for _, rel := range found {
+				paths = append(paths, filepath.Join(in, filepath.FromSlash(rel)))
+			}
+			continue
+		}
+		paths = append(paths, in)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		fatalf("corpus: %v", err)
+	}
+
+	written, known := 0, 0
+	for _, path := range paths {
+		c, err := loadCanvas(path)
+		if err != nil {
+			fatalf("corpus: %v", err)
+		}
+		data, err := json.MarshalIndent(skeleton(c, *geometry), "", "\t")
+		if err != nil {
+			fatalf("corpus: %v", err)
+		}
+		data = append(data, '\n')
+		sum := sha256.Sum256(data)
+		dest := filepath.Join(*dir, hex.EncodeToString(sum[:8])+".canvas")
+		if _, err := os.Stat(dest); err == nil {
+			known++
+			continue
+		}
+		if err := os.WriteFile(dest, data, 0o644); err != nil {
+			fatalf("corpus: %v", err)
+		}
+		written++
+		fmt.Printf("%s: %s (%d nodes, %d edges)\n", dest, path, len(c.Nodes), len(c.Edges))
+	}
+	fmt.Printf("%s: %d new skeletons, %d already collected\n", *dir, written, known)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The daemon re-runs export jobs on a schedule. Its config file holds a
+// list of jobs:
+//
+//	{"jobs": [{
+//	  "name": "kb",
+//	  "schedule": "*/15 * * * *",
+//	  "in": ["vault/*.canvas"],
+//	  "out": "s3://bucket/graphs/{name}{ext}",
+//	  "flags": {"format": "parquet", "keep-path": true}
+//	}]}
+//
+// out may be a file path or an s3://, neo4j:// / bolt://, nats:// or mqtt://
+// URL, with {name} (input basename without extension) and {ext} (format
+// extension) filled in per canvas. flags are the conversion's
+// command-line flags. Each conversion runs as a child process, so a bad
+// canvas fails only its own job. With -cache, canvases unchanged since
+// their last conversion are skipped. Webhooks (see webhook.go) hear about
+// each conversion, and with -metrics-addr the counts and durations of
+// conversions are served to Prometheus (see metrics.go).
+
+type daemonConfig struct {
+	Jobs     []daemonJob `json:"jobs"`
+	Webhooks []webhook   `json:"webhooks"`
+}
+
+type daemonJob struct {
+	Name     string         `json:"name"`
+	Schedule string         `json:"schedule"`
+	In       []string       `json:"in"`
+	Out      string         `json:"out"`
+	Flags    map[string]any `json:"flags"`
+
+	cron *cronSchedule
+	next time.Time
+}
+
+func loadDaemonConfig(path string) (*daemonConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg daemonConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	if len(cfg.Jobs) == 0 {
+		return nil, fmt.Errorf("%s: no jobs", path)
+	}
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if j.Name == "" {
+			j.Name = "job" + strconv.Itoa(i+1)
+		}
+		if len(j.In) == 0 || j.Out == "" {
+			return nil, fmt.Errorf("job %s: in and out are required", j.Name)
+		}
+		if j.cron, err = parseCron(j.Schedule); err != nil {
+			return nil, fmt.Errorf("job %s: %v", j.Name, err)
+		}
+	}
+	for i := range cfg.Webhooks {
+		if err := cfg.Webhooks[i].check(); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// flagArgs turns the job's flags into command-line arguments, sorted by
+// name so runs are reproducible.
+func (j *daemonJob) flagArgs() ([]string, error) {
+	var args []string
+	for _, name := range sortedKeys(j.Flags) {
+		if name == "in" || name == "out" {
+			return nil, fmt.Errorf("job %s: set %s on the job, not in flags", j.Name, name)
+		}
+		values, ok := j.Flags[name].([]any)
+		if !ok {
+			values = []any{j.Flags[name]}
+		}
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				args = append(args, "-"+name+"="+v)
+			case bool:
+				args = append(args, "-"+name+"="+strconv.FormatBool(v))
+			case float64:
+				args = append(args, "-"+name+"="+strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				return nil, fmt.Errorf("job %s: flag %s: want a string, number, bool or array of them", j.Name, name)
+			}
+		}
+	}
+	return args, nil
+}
+
+// flagValues returns the strings the job sets flag name to.
+func (j *daemonJob) flagValues(name string) []string {
+	values, ok := j.Flags[name].([]any)
+	if !ok {
+		values = []any{j.Flags[name]}
+	}
+	var strs []string
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+func (j *daemonJob) format() string {
+	if format, _ := j.Flags["format"].(string); format != "" {
+		return format
+	}
+	return "csv"
+}
+
+func (j *daemonJob) ext() string {
+	if ex, ok := exporters[j.format()]; ok {
+		return ex.ext
+	}
+	return "." + j.format() // export plugin
+}
+
+// event describes one conversion for the webhooks.
+func (j *daemonJob) event(in, dest string, start time.Time, err error) *webhookEvent {
+	ev := &webhookEvent{
+		Event: "success", Job: j.Name, Canvas: in, Output: dest, Format: j.format(),
+		Time: time.Now().UTC().Format(time.RFC3339), DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		ev.Event, ev.Error = "failure", err.Error()
+	}
+	if in != "" {
+		if c, err := loadCanvas(in); err == nil {
+			st := computeStats(buildGraph(c, false))
+			ev.Stats = &st
+		}
+	}
+	return ev
+}
+
+// run converts every input of the job once, logging each result, counting
+// it in metrics and telling hooks. cache may be nil.
+func (j *daemonJob) run(self string, cache *exportCache, hooks []webhook, metrics *daemonMetrics) {
+	jobStart := time.Now()
+	failed := func(err error) {
+		slog.Error("job failed", "job", j.Name, "err", err)
+		metrics.jobFailures.inc(j.Name)
+		if len(hooks) > 0 {
+			notify(hooks, j.event("", j.Out, jobStart, err))
+		}
+	}
+	flags, err := j.flagArgs()
+	if err != nil {
+		failed(err)
+		return
+	}
+	inputs, err := expandInputs(j.In)
+	if err != nil {
+		failed(err)
+		return
+	}
+	var settings []string
+	if cache != nil {
+		more, err := exportFingerprints(j.flagValues)
+		switch {
+		case errors.Is(err, errUncacheable):
+			slog.Warn("job not cached: "+err.Error(), "job", j.Name)
+			cache = nil
+		case err != nil:
+			failed(err)
+			return
+		}
+		settings = slices.Concat(flags, more)
+	}
+	for _, in := range inputs {
+		name := strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))
+		dest := strings.NewReplacer("{name}", name, "{ext}", j.ext()).Replace(j.Out)
+		key := ""
+		if cache != nil {
+			data, err := os.ReadFile(in)
+			if err != nil {
+				slog.Error("conversion failed", "job", j.Name, "in", in, "out", dest, "err", err)
+				metrics.conversions.inc(j.Name, j.format(), "error")
+				continue
+			}
+			if key = cacheKey(data, slices.Concat(settings, []string{"out=" + dest})...); cache.fresh(dest, key) {
+				slog.Debug("unchanged, skipped", "job", j.Name, "in", in, "out", dest)
+				metrics.conversions.inc(j.Name, j.format(), "unchanged")
+				continue
+			}
+		}
+		start := time.Now()
+		err := j.convert(self, flags, in, dest)
+		metrics.conversion(j, start, err)
+		if err != nil {
+			slog.Error("conversion failed", "job", j.Name, "in", in, "out", dest, "err", err)
+		} else {
+			slog.Info("converted", "job", j.Name, "in", in, "out", dest, "duration", time.Since(start).Round(time.Millisecond))
+		}
+		if len(hooks) > 0 {
+			notify(hooks, j.event(in, dest, start, err))
+		}
+		if err != nil {
+			continue
+		}
+		if cache != nil {
+			cache.record(dest, key, []string{dest})
+			if err := cache.save(); err != nil {
+				slog.Error("cache not saved", "job", j.Name, "err", err)
+			}
+		}
+	}
+}
+
+func (j *daemonJob) convert(self string, flags []string, in, dest string) error {
+	out := dest
+	if isS3URL(dest) {
+		tmp, err := os.CreateTemp("", "canvas_tool-*"+j.ext())
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		out = tmp.Name()
+	} else if !isNeo4jURL(dest) && !isNATSURL(dest) && !isMQTTURL(dest) {
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(self, slices.Concat(flags, []string{"-in", in, "-out", out})...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", strings.TrimPrefix(msg, "canvas_tool: "))
+		}
+		return err
+	}
+	if isS3URL(dest) {
+		data, err := os.ReadFile(out)
+		if err != nil {
+			return err
+		}
+		return putS3(dest, data)
+	}
+	return nil
+}
+
+func runDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	configPath := fs.String("config", "canvas_tool_daemon.json", "daemon config file (JSON list of jobs)")
+	once := fs.Bool("once", false, "run every job once now and exit")
+	cachePath := fs.String("cache", "", "remember conversions in this `file` and skip canvases unchanged since their last conversion")
+	force := fs.Bool("force", false, "with -cache, convert every canvas on the first run even if unchanged")
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus metrics of the jobs at /metrics on this `address` (e.g. localhost:9090)")
+	logOpts := registerLogFlags(fs, "text")
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fatalf("daemon: %v", err)
+	}
+
+	cfg, err := loadDaemonConfig(*configPath)
+	if err != nil {
+		fatalf("daemon: %v", err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fatalf("daemon: %v", err)
+	}
+	var cache *exportCache
+	if *cachePath != "" {
+		if cache, err = loadExportCache(*cachePath); err != nil {
+			fatalf("daemon: -cache: %v", err)
+		}
+		if *force {
+			clear(cache.Entries)
+		}
+	}
+	metrics := newDaemonMetrics()
+	if *once {
+		if *metricsAddr != "" {
+			fatalf("daemon: -metrics-addr has nothing to serve with -once")
+		}
+		for i := range cfg.Jobs {
+			cfg.Jobs[i].run(self, cache, cfg.Webhooks, metrics)
+		}
+		return
+	}
+	if *metricsAddr != "" {
+		ln, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			fatalf("daemon: -metrics-addr: %v", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", metrics.reg.handle)
+		go func() {
+			if err := http.Serve(ln, mux); err != nil {
+				fatalf("daemon: -metrics-addr: %v", err)
+			}
+		}()
+		slog.Info("serving metrics", "addr", ln.Addr().String(), "path", "/metrics")
+	}
+
+	now := time.Now()
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if j.next = j.cron.next(now); j.next.IsZero() {
+			fatalf("daemon: job %s: schedule %q never fires", j.Name, j.Schedule)
+		}
+		slog.Info("scheduled", "job", j.Name, "next", j.next.Format(time.RFC3339))
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	for {
+		due := &cfg.Jobs[0]
+		for i := range cfg.Jobs {
+			if cfg.Jobs[i].next.Before(due.next) {
+				due = &cfg.Jobs[i]
+			}
+		}
+		timer := time.NewTimer(time.Until(due.next))
+		select {
+		case <-stop:
+			timer.Stop()
+			slog.Info("stopping")
+			return
+		case <-timer.C:
+		}
+		due.run(self, cache, cfg.Webhooks, metrics)
+		due.next = due.cron.next(time.Now())
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
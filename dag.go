@@ -0,0 +1,152 @@
+package main
+
+import "fmt"
+
+// Canvases meant to be DAGs (dependency maps, plans) are checked by
+// finding a small set of edges whose removal breaks every cycle: a
+// feedback arc set. The minimum is NP-hard; this uses the Eades-Lin-Smyth
+// ordering heuristic and then puts back any edge that no longer closes a
+// cycle, so every edge reported is needed.
+
+// feedbackEdges returns the indexes of edges whose removal leaves g
+// acyclic, in edge order. Edges to undefined nodes are ignored.
+func feedbackEdges(g *graph) []int {
+	var edges []int
+	for i, e := range g.Edges {
+		_, okFrom := g.byID[e.From]
+		_, okTo := g.byID[e.To]
+		if okFrom && okTo {
+			edges = append(edges, i)
+		}
+	}
+	pos := eadesOrder(g, edges)
+
+	// Start from the edges that go forward in the order (acyclic), then
+	// try each backward edge: keep it if it doesn't close a cycle.
+	out := map[string][]string{}
+	var back []int
+	for _, i := range edges {
+		e := g.Edges[i]
+		if pos[e.From] < pos[e.To] {
+			out[e.From] = append(out[e.From], e.To)
+		} else {
+			back = append(back, i)
+		}
+	}
+	var remove []int
+	for _, i := range back {
+		e := g.Edges[i]
+		if e.From != e.To && !reaches(out, e.To, e.From) {
+			out[e.From] = append(out[e.From], e.To)
+			continue
+		}
+		remove = append(remove, i)
+	}
+	return remove
+}
+
+// eadesOrder positions the nodes so that few edges point backwards:
+// sinks go to the end, sources to the front, and otherwise the node with
+// the most outgoing minus incoming edges goes next.
+func eadesOrder(g *graph, edges []int) map[string]int {
+	alive := make(map[string]bool, len(g.Nodes))
+	in, out := map[string]int{}, map[string]int{}
+	succ, pred := map[string][]string{}, map[string][]string{}
+	for _, n := range g.Nodes {
+		alive[n.ID] = true
+	}
+	for _, i := range edges {
+		e := g.Edges[i]
+		if e.From == e.To {
+			continue
+		}
+		out[e.From]++
+		in[e.To]++
+		succ[e.From] = append(succ[e.From], e.To)
+		pred[e.To] = append(pred[e.To], e.From)
+	}
+	remove := func(id string) {
+		alive[id] = false
+		for _, s := range succ[id] {
+			in[s]--
+		}
+		for _, p := range pred[id] {
+			out[p]--
+		}
+	}
+	var front, end []string
+	for left := len(g.Nodes); left > 0; {
+		progress := false
+		for _, n := range g.Nodes {
+			if alive[n.ID] && out[n.ID] == 0 {
+				remove(n.ID)
+				end = append(end, n.ID)
+				left--
+				progress = true
+			}
+		}
+		for _, n := range g.Nodes {
+			if alive[n.ID] && in[n.ID] == 0 {
+				remove(n.ID)
+				front = append(front, n.ID)
+				left--
+				progress = true
+			}
+		}
+		if progress || left == 0 {
+			continue
+		}
+		best := ""
+		for _, n := range g.Nodes {
+			if alive[n.ID] && (best == "" || out[n.ID]-in[n.ID] > out[best]-in[best]) {
+				best = n.ID
+			}
+		}
+		remove(best)
+		front = append(front, best)
+		left--
+	}
+	pos := make(map[string]int, len(g.Nodes))
+	for i, id := range front {
+		pos[id] = i
+	}
+	for i, id := range end {
+		pos[id] = len(g.Nodes) - 1 - i // sinks were found last-first
+	}
+	return pos
+}
+
+// reaches reports whether to is reachable from from along out.
+func reaches(out map[string][]string, from, to string) bool {
+	seen := map[string]bool{from: true}
+	stack := []string{from}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if cur == to {
+			return true
+		}
+		for _, next := range out[cur] {
+			if !seen[next] {
+				seen[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return false
+}
+
+// validateDAG reports the edges to remove to make the canvas acyclic.
+func validateDAG(c Canvas) []issue {
+	g := buildGraph(c, true)
+	var issues []issue
+	for _, i := range feedbackEdges(g) {
+		e := g.Edges[i]
+		msg := fmt.Sprintf("edge %s -> %s closes a cycle; remove it", g.name(e.From), g.name(e.To))
+		if e.Label != "" {
+			msg = fmt.Sprintf("edge %s -%s-> %s closes a cycle; remove it", g.name(e.From), e.Label, g.name(e.To))
+		}
+		issues = append(issues, issue{Severity: "error", Code: "cycle-edge", Message: msg, Path: fmt.Sprintf("edges[%d]", i), NodeID: e.From})
+	}
+	return issues
+}
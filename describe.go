@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// describe tells whoever sets up a spreadsheet or BI import what the CSV
+// export will look like. It runs the conversion for real, as a child
+// process with the flags given after "--", on a sample canvas (-sample, or
+// a small built-in one), so every option that adds or changes columns is
+// accounted for. The child always writes a header row so the columns can
+// be named; the report says whether the real export will have one.
+//
+//	canvas_tool describe -sample plan.canvas -- -edge-kind -opt csv.delim=,
+
+// columnReport describes one column of the export.
+type columnReport struct {
+	Index    int    `json:"index"` // from 1
+	Name     string `json:"name"`
+	Type     string `json:"type"` // string, integer, number, boolean, date or datetime
+	Nullable bool   `json:"nullable"`
+	Example  string `json:"example,omitempty"`
+}
+
+type csvReport struct {
+	Format    string         `json:"format"`
+	Delimiter string         `json:"delimiter"`
+	Header    bool           `json:"header"`
+	Encoding  string         `json:"encoding"`
+	Quoting   string         `json:"quoting"`
+	Columns   []columnReport `json:"columns"`
+	Rows      [][]string     `json:"example_rows"`
+	Sample    string         `json:"sample"`
+}
+
+// describeSample stands in for the user's data when there is no -sample:
+// every node type, labelled and unlabelled edges.
+const describeSample = "node-types"
+
+// conversionFlag returns the values args give the conversion flag name, in
+// order.
+func conversionFlag(args []string, name string) []string {
+	var values []string
+	for i := 0; i < len(args); i++ {
+		a := strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "-")
+		if a == name && i+1 < len(args) {
+			values = append(values, args[i+1])
+			i++
+		} else if v, ok := strings.CutPrefix(a, name+"="); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// columnType names the narrowest type every non-empty value fits.
+func columnType(values []string) string {
+	fits := map[string]func(string) bool{
+		"integer":  func(s string) bool { _, err := strconv.ParseInt(s, 10, 64); return err == nil },
+		"number":   func(s string) bool { _, err := strconv.ParseFloat(s, 64); return err == nil },
+		"boolean":  func(s string) bool { return s == "true" || s == "false" },
+		"date":     func(s string) bool { _, err := time.Parse("2006-01-02", s); return err == nil },
+		"datetime": func(s string) bool { _, err := time.Parse(time.RFC3339, s); return err == nil },
+	}
+	for _, t := range []string{"integer", "number", "boolean", "date", "datetime"} {
+		ok, seen := true, false
+		for _, v := range values {
+			if v == "" {
+				continue
+			}
+			seen = true
+			if !fits[t](v) {
+				ok = false
+				break
+			}
+		}
+		if ok && seen {
+			return t
+		}
+	}
+	return "string"
+}
+
+func describeCSV(data []byte, delim rune, header bool, rows int) (*csvReport, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delim
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read the export back: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the export is empty")
+	}
+	names, body := records[0], records[1:]
+	rep := &csvReport{
+		Format:    "csv",
+		Delimiter: string(delim),
+		Header:    header,
+		Encoding:  "UTF-8, LF line endings, no byte order mark",
+		Quoting:   `fields holding the delimiter, a quote or a line break are wrapped in "..." with quotes doubled`,
+		Rows:      [][]string{},
+	}
+	for i, n := range names {
+		var values []string
+		c := columnReport{Index: i + 1, Name: n}
+		for _, rec := range body {
+			v := ""
+			if i < len(rec) {
+				v = rec[i]
+			}
+			values = append(values, v)
+			if v == "" {
+				c.Nullable = true
+			} else if c.Example == "" {
+				c.Example = v
+			}
+		}
+		c.Type = columnType(values)
+		rep.Columns = append(rep.Columns, c)
+	}
+	for _, rec := range body[:min(rows, len(body))] {
+		rep.Rows = append(rep.Rows, rec)
+	}
+	return rep, nil
+}
+
+func (rep *csvReport) write(w io.Writer) {
+	fmt.Fprintf(w, "format:     %s\n", rep.Format)
+	fmt.Fprintf(w, "delimiter:  %q\n", rep.Delimiter)
+	if rep.Header {
+		fmt.Fprintln(w, "header:     yes, the first row names the columns")
+	} else {
+		fmt.Fprintln(w, "header:     none (-opt csv.header=true adds one)")
+	}
+	fmt.Fprintf(w, "encoding:   %s\n", rep.Encoding)
+	fmt.Fprintf(w, "quoting:    %s\n", rep.Quoting)
+	fmt.Fprintf(w, "sample:     %s\n\ncolumns:\n", rep.Sample)
+	width := 0
+	for _, c := range rep.Columns {
+		width = max(width, len(c.Name))
+	}
+	for _, c := range rep.Columns {
+		typ := c.Type
+		if c.Nullable {
+			typ += ", may be empty"
+		}
+		fmt.Fprintf(w, "  %2d  %-*s  %-22s", c.Index, width, c.Name, typ)
+		if c.Example != "" {
+			fmt.Fprintf(w, "  e.g. %q", c.Example)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "\nexample rows:")
+	if len(rep.Rows) == 0 {
+		fmt.Fprintln(w, "  (none: the sample has no edges)")
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = []rune(rep.Delimiter)[0]
+	for _, row := range rep.Rows {
+		fmt.Fprint(w, "  ")
+		cw.Write(row)
+		cw.Flush()
+	}
+}
+
+func runDescribe(args []string) {
+	fs := flag.NewFlagSet("describe", flag.ExitOnError)
+	sample := fs.String("sample", "", "canvas to convert as the sample (default: a built-in canvas with every node type)")
+	rows := fs.Int("rows", 3, "example rows to show")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: canvas_tool describe [flags] [-- conversion flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	conv := fs.Args()
+	for _, name := range []string{"in", "out", "outdir", "formats"} {
+		if len(conversionFlag(conv, name)) > 0 {
+			fatalf("describe: -%s is chosen by describe; pass only the flags that shape the output", name)
+		}
+	}
+	format := "csv"
+	if fv := conversionFlag(conv, "format"); len(fv) > 0 {
+		format = fv[len(fv)-1]
+	}
+	if format != "csv" {
+		fatalf("describe: -format %s isn't a delimited table; describe reports on csv", format)
+	}
+	delim, header := ';', false
+	for _, o := range conversionFlag(conv, "opt") {
+		switch k, v, _ := strings.Cut(o, "="); k {
+		case "csv.delim":
+			if v != "" {
+				r, err := parseDelimiter(v, nil)
+				if err != nil {
+					fatalf("describe: -opt csv.delim: %v", err)
+				}
+				delim = r
+			}
+		case "csv.header":
+			header, _ = strconv.ParseBool(v)
+		}
+	}
+
+	rep, err := describeExport(conv, *sample, delim, header, *rows)
+	if err != nil {
+		fatalf("describe: %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+		return
+	}
+	rep.write(os.Stdout)
+}
+
+// describeExport converts the sample with the conversion flags conv and
+// reports on the result.
+func describeExport(conv []string, sample string, delim rune, header bool, rows int) (*csvReport, error) {
+	dir, err := os.MkdirTemp("", "canvas_tool-describe-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in, label := sample, sample
+	if in == "" {
+		for _, tc := range exportCorpus {
+			if tc.name == describeSample {
+				in, label = filepath.Join(dir, "sample.canvas"), "built-in ("+describeSample+" canvas of exporttest)"
+				if err := os.WriteFile(in, []byte(tc.canvas), 0o644); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	out := filepath.Join(dir, "out.csv")
+	var stderr bytes.Buffer
+	cmd := exec.Command(self, append(conv, "-opt", "csv.header=true", "-in", in, "-out", out)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			msg, _, _ = strings.Cut(msg, "\n") // not the usage that follows a bad flag
+			return nil, fmt.Errorf("%s", strings.TrimPrefix(msg, "canvas_tool: "))
+		}
+		return nil, err
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := describeCSV(data, delim, header, rows)
+	if err != nil {
+		return nil, err
+	}
+	rep.Sample = label
+	return rep, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diff compares two versions of a canvas. Nodes are matched by ID; edges by
+// endpoints and label, and an unmatched old/new edge pair between the same
+// nodes counts as a label change.
+
+type edgeChange struct {
+	change   string // added, removed, changed or unchanged
+	edge     graphEdge
+	oldLabel string // for changed
+}
+
+// diffColors highlights changes in the combined DOT/GraphML output.
+var diffColors = map[string]string{"added": "green", "removed": "red", "changed": "yellow"}
+
+func diffEdges(old, cur *graph) []edgeChange {
+	type key struct{ from, to, label string }
+	type pair struct{ from, to string }
+	remaining := map[key]int{}
+	for _, e := range old.Edges {
+		remaining[key{e.From, e.To, e.Label}]++
+	}
+	var changes []edgeChange
+	var unmatched []graphEdge
+	for _, e := range cur.Edges {
+		k := key{e.From, e.To, e.Label}
+		if remaining[k] > 0 {
+			remaining[k]--
+			changes = append(changes, edgeChange{change: "unchanged", edge: e})
+			continue
+		}
+		unmatched = append(unmatched, e)
+	}
+	// old edges left over, grouped by endpoints
+	var removed []graphEdge
+	leftover := map[pair][]int{} // indexes into removed
+	for _, e := range old.Edges {
+		k := key{e.From, e.To, e.Label}
+		if remaining[k] == 0 {
+			continue
+		}
+		remaining[k]--
+		p := pair{e.From, e.To}
+		leftover[p] = append(leftover[p], len(removed))
+		removed = append(removed, e)
+	}
+	relabelled := make([]bool, len(removed))
+	for _, e := range unmatched {
+		p := pair{e.From, e.To}
+		if olds := leftover[p]; len(olds) > 0 {
+			relabelled[olds[0]] = true
+			leftover[p] = olds[1:]
+			changes = append(changes, edgeChange{change: "changed", edge: e, oldLabel: removed[olds[0]].Label})
+			continue
+		}
+		changes = append(changes, edgeChange{change: "added", edge: e})
+	}
+	for i, e := range removed {
+		if !relabelled[i] {
+			changes = append(changes, edgeChange{change: "removed", edge: e})
+		}
+	}
+	return changes
+}
+
+// diffGraph combines both versions into one graph whose nodes and edges
+// carry change and color attributes.
+func diffGraph(old, cur *graph) *graph {
+	g := &graph{}
+	for _, n := range cur.Nodes {
+		n.Attrs = nil
+		g.Nodes = append(g.Nodes, n)
+		change := "added"
+		if on, ok := old.node(n.ID); ok {
+			change = "unchanged"
+			if on.Name != n.Name {
+				change = "changed"
+			}
+		}
+		if change != "unchanged" {
+			g.setAttr(len(g.Nodes)-1, "change", change)
+			g.setAttr(len(g.Nodes)-1, "color", diffColors[change])
+		}
+	}
+	for _, n := range old.Nodes {
+		if _, ok := cur.node(n.ID); !ok {
+			n.Attrs = nil
+			g.Nodes = append(g.Nodes, n)
+			g.setAttr(len(g.Nodes)-1, "change", "removed")
+			g.setAttr(len(g.Nodes)-1, "color", diffColors["removed"])
+		}
+	}
+	for _, c := range diffEdges(old, cur) {
+		e := c.edge
+		e.Attrs = nil
+		if c.change == "changed" {
+			e.Label = c.oldLabel + " -> " + e.Label
+		}
+		g.Edges = append(g.Edges, e)
+		if c.change != "unchanged" {
+			g.setEdgeAttr(len(g.Edges)-1, "change", c.change)
+			g.setEdgeAttr(len(g.Edges)-1, "color", diffColors[c.change])
+		}
+	}
+	g.index()
+	return g
+}
+
+// writeDiffText prints one line per difference, like a unified diff of the
+// CSV triples: "+ from;label;to", "- from;label;to", "~ from;old -> new;to".
+func writeDiffText(w io.Writer, old, cur *graph) error {
+	bw := bufio.NewWriter(w)
+	name := func(id string) string {
+		if n, ok := cur.node(id); ok {
+			return n.Name
+		}
+		return old.name(id)
+	}
+	for _, n := range cur.Nodes {
+		if _, ok := old.node(n.ID); !ok {
+			fmt.Fprintf(bw, "+ node %s\n", n.Name)
+		}
+	}
+	for _, n := range old.Nodes {
+		if _, ok := cur.node(n.ID); !ok {
+			fmt.Fprintf(bw, "- node %s\n", n.Name)
+		} else if cn, _ := cur.node(n.ID); cn.Name != n.Name {
+			fmt.Fprintf(bw, "~ node %s -> %s\n", n.Name, cn.Name)
+		}
+	}
+	for _, c := range diffEdges(old, cur) {
+		e := c.edge
+		switch c.change {
+		case "added":
+			fmt.Fprintf(bw, "+ %s;%s;%s\n", name(e.From), e.Label, name(e.To))
+		case "removed":
+			fmt.Fprintf(bw, "- %s;%s;%s\n", name(e.From), e.Label, name(e.To))
+		case "changed":
+			fmt.Fprintf(bw, "~ %s;%s -> %s;%s\n", name(e.From), c.oldLabel, e.Label, name(e.To))
+		}
+	}
+	return bw.Flush()
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text, or any export format (dot, graphml, ...) for a combined graph with change/color attributes")
+	outPath := fs.String("out", "-", "output path (or - for stdout)")
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	oldRev := fs.String("git-rev", "", "read the old canvas as of this git `revision`; with a single path, compare it against the working tree")
+	newRev := fs.String("git-rev-new", "", "read the new canvas as of this git `revision`")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: canvas_tool diff [flags] old.canvas new.canvas")
+		fmt.Fprintln(fs.Output(), "       canvas_tool diff -git-rev REV [flags] file.canvas")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	paths := fs.Args()
+	if len(paths) == 1 && (*oldRev != "" || *newRev != "") {
+		paths = []string{paths[0], paths[0]}
+	}
+	if len(paths) != 2 {
+		fs.Usage()
+		fatalf("diff: want two canvases")
+	}
+	var graphs [2]*graph
+	for i, path := range paths {
+		rev := *oldRev
+		if i == 1 {
+			rev = *newRev
+		}
+		c, err := loadCanvasAt(path, rev)
+		if err != nil {
+			fatalf("diff: %v", err)
+		}
+		graphs[i] = buildGraph(c, *keepPath)
+	}
+	if err := writeDiff(*outPath, *format, graphs[0], graphs[1]); err != nil {
+		fatalf("diff: %v", err)
+	}
+}
+
+func writeDiff(path, format string, old, cur *graph) error {
+	var ex exporter
+	if format != "text" {
+		var ok bool
+		if ex, ok = exporters[format]; !ok || ex.write == nil {
+			return fmt.Errorf("unknown -format %q (want text or one of: %s)", format, strings.Join(exporterNames(), ", "))
+		}
+	}
+	out, closeOut, err := openOut(path)
+	if err != nil {
+		return err
+	}
+	if format == "text" {
+		err = writeDiffText(out, old, cur)
+	} else {
+		opts, _ := exportOptionsFrom(nil)
+		err = ex.write(out, diffGraph(old, cur), opts)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Display templates set how nodes of a type are named, e.g.
+//
+//	-display 'file={{basename}} ({{ext}})' -display 'link={{host}}' -display 'text={{firstline}}'
+//
+// Placeholders: id, type, text, firstline, label, file, path (file with /
+// separators), basename, stem, ext (without the dot), dir, url, host, and
+// any node attribute (e.g. frontmatter keys); unknown ones render empty.
+// Types without a template keep the default naming.
+
+var placeholder = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// parseDisplayTemplates parses type=template entries; "url" is accepted as
+// an alias for link nodes.
+func parseDisplayTemplates(defs []string) (map[string]string, error) {
+	tmpls := map[string]string{}
+	for _, def := range defs {
+		typ, tmpl, ok := strings.Cut(def, "=")
+		if !ok || typ == "" {
+			return nil, fmt.Errorf("want type=template, got %q", def)
+		}
+		if typ == "url" {
+			typ = "link"
+		}
+		tmpls[typ] = tmpl
+	}
+	return tmpls, nil
+}
+
+// displayVars returns the placeholder values for a node.
+func displayVars(n graphNode) map[string]string {
+	file := slashPath(n.Node.File)
+	base := fileBase(file)
+	ext := path.Ext(base)
+	dir := path.Dir(file)
+	if file == "" || dir == "." {
+		dir = ""
+	}
+	host := ""
+	if u, err := url.Parse(n.Node.URL); err == nil {
+		host = u.Host
+	}
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(n.Node.Text), "\n")
+	vars := map[string]string{
+		"id": n.ID, "type": n.Type, "text": n.Node.Text, "firstline": strings.TrimSpace(firstLine),
+		"label": n.Node.Label, "file": n.Node.File, "path": file, "basename": base,
+		"stem": strings.TrimSuffix(base, ext), "ext": strings.TrimPrefix(ext, "."), "dir": dir,
+		"url": n.Node.URL, "host": host,
+	}
+	for k, v := range n.Attrs {
+		if _, builtin := vars[k]; !builtin {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// applyDisplayTemplates renames nodes whose type has a template. A template
+// that renders empty leaves the default name.
+func applyDisplayTemplates(g *graph, tmpls map[string]string) {
+	if len(tmpls) == 0 {
+		return
+	}
+	for i, n := range g.Nodes {
+		tmpl, ok := tmpls[n.Type]
+		if !ok {
+			continue
+		}
+		vars := displayVars(n)
+		name := placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+			return vars[placeholder.FindStringSubmatch(m)[1]]
+		})
+		if name = singleLine(name); name != "" {
+			g.Nodes[i].Name = name
+		}
+	}
+}
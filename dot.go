@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// writeDOT emits a Graphviz digraph. Nodes are keyed by canvas ID and
+// labelled with their display name; type and any extra attributes are
+// carried as node attributes. Edges leave and enter through the ports of
+// the canvas's fromSide and toSide, and keep its arrowheads, so Graphviz
+// routes them as they were drawn (unless -opt dot.ports=false).
+func writeDOT(out io.Writer, g *graph, opts exportOptions) error {
+	ports, err := opts.optBool("dot", "ports", true)
+	if err != nil {
+		return err
+	}
+	rankdir := strings.ToUpper(opts.opt("dot", "rankdir", ""))
+	if rankdir != "" && !slices.Contains([]string{"TB", "LR", "BT", "RL"}, rankdir) {
+		return fmt.Errorf("-opt dot.rankdir: want TB, LR, BT or RL, got %q", rankdir)
+	}
+	w := bufio.NewWriter(out)
+	w.WriteString("digraph canvas {\n")
+	if rankdir != "" {
+		w.WriteString("\trankdir=" + rankdir + ";\n")
+	}
+	for _, m := range g.meta {
+		w.WriteString("\t" + dotID(m.Key) + "=" + dotID(m.Value) + ";\n")
+	}
+	for _, n := range g.Nodes {
+		w.WriteString("\t" + dotID(n.ID) + " [label=" + dotID(n.Name))
+		if n.Type != "" {
+			w.WriteString(", type=" + dotID(n.Type))
+		}
+		for _, a := range g.attrs {
+			if v := n.Attrs[a]; v != "" {
+				w.WriteString(", " + dotID(a) + "=" + dotID(v))
+			}
+		}
+		w.WriteString("];\n")
+	}
+	for _, e := range g.Edges {
+		w.WriteString("\t" + dotID(e.From) + " -> " + dotID(e.To))
+		var attrs []string
+		if e.Label != "" {
+			attrs = append(attrs, "label="+dotID(e.Label))
+		}
+		for _, a := range g.edgeAttrs {
+			if v := e.Attrs[a]; v != "" {
+				attrs = append(attrs, dotID(a)+"="+dotID(v))
+			}
+		}
+		if p, ok := dotPorts[e.Edge.FromSide]; ok && ports {
+			attrs = append(attrs, "tailport="+p)
+		}
+		if p, ok := dotPorts[e.Edge.ToSide]; ok && ports {
+			attrs = append(attrs, "headport="+p)
+		}
+		if dir := dotDir(e); dir != "" {
+			attrs = append(attrs, "dir="+dir)
+		}
+		if len(attrs) > 0 {
+			w.WriteString(" [" + strings.Join(attrs, ", ") + "]")
+		}
+		w.WriteString(";\n")
+	}
+	w.WriteString("}\n")
+	return w.Flush()
+}
+
+// dotPorts are the compass points of the canvas sides.
+var dotPorts = map[string]string{"top": "n", "right": "e", "bottom": "s", "left": "w"}
+
+// dotDir returns the dir attribute for the edge's arrowheads: a canvas
+// arrow points at the target unless fromEnd or toEnd say otherwise.
+func dotDir(e graphEdge) string {
+	if e.Attrs["bidirectional"] == "true" {
+		return "both"
+	}
+	head := e.Edge.ToEnd != "none"
+	tail := e.Edge.FromEnd == "arrow"
+	switch {
+	case head && tail:
+		return "both"
+	case tail:
+		return "back"
+	case !head:
+		return "none"
+	}
+	return ""
+}
+
+// dotID returns s as a DOT identifier, quoting it unless it is a plain
+// alphanumeric name.
+func dotID(s string) string {
+	plain := s != "" && (s[0] < '0' || s[0] > '9')
+	for _, r := range s {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		switch strings.ToLower(s) {
+		case "node", "edge", "graph", "digraph", "subgraph", "strict":
+		default:
+			return s
+		}
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// writeDuckDB loads the graph into a DuckDB database file by feeding the SQL
+// export to the duckdb CLI; the storage format itself is not written here.
+func writeDuckDB(ctx context.Context, path string, g *graph, opts exportOptions) error {
+	bin, err := exec.LookPath("duckdb")
+	if err != nil {
+		return errors.New("the duckdb CLI must be installed and in PATH for -format duckdb")
+	}
+	opts.format = opts.format.with("sql", "dialect", "duckdb")
+	var script bytes.Buffer
+	if err := writeSQL(&script, g, opts); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-bail", path)
+	cmd.Stdin = &script
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("duckdb: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
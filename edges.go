@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// edgeKind classifies an edge by its endpoint types, e.g. "text->file".
+// Dangling endpoints count as "missing".
+func (g *graph) edgeKind(e graphEdge) string {
+	kind := func(id string) string {
+		n, ok := g.node(id)
+		switch {
+		case !ok:
+			return "missing"
+		case n.Type == "":
+			return "unknown"
+		}
+		return n.Type
+	}
+	return kind(e.From) + "->" + kind(e.To)
+}
+
+// filterEdgeKinds keeps the edges whose kind is in include (if non-empty)
+// and not in exclude. A kind may use * for either side, e.g. "file->*".
+func filterEdgeKinds(g *graph, include, exclude []string) {
+	match := func(kinds []string, kind string) bool {
+		return slices.ContainsFunc(kinds, func(k string) bool { return kindMatches(k, kind) })
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		kind := g.edgeKind(e)
+		if (len(include) == 0 || match(include, kind)) && !match(exclude, kind) {
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+	g.index()
+}
+
+func kindMatches(pattern, kind string) bool {
+	pf, pt := splitKind(pattern)
+	kf, kt := splitKind(kind)
+	return (pf == "*" || pf == kf) && (pt == "*" || pt == kt)
+}
+
+func splitKind(k string) (from, to string) {
+	from, to, ok := strings.Cut(k, "->")
+	if !ok {
+		return k, "*" // a bare type matches edges leaving it
+	}
+	return from, to
+}
+
+// addEdgeKinds records each edge's kind as the edge_kind attribute.
+func addEdgeKinds(g *graph) {
+	for i, e := range g.Edges {
+		g.setEdgeAttr(i, "edge_kind", g.edgeKind(e))
+	}
+}
+
+// edgeField is a canvas edge field -edge-attrs can export, by attribute
+// name.
+type edgeField struct {
+	name string
+	get  func(Edge) string
+}
+
+var edgeFields = []edgeField{
+	{"id", func(e Edge) string { return e.ID }},
+	{"color", func(e Edge) string { return e.Color }},
+	{"from_side", func(e Edge) string { return e.FromSide }},
+	{"to_side", func(e Edge) string { return e.ToSide }},
+	{"from_end", func(e Edge) string { return e.FromEnd }},
+	{"to_end", func(e Edge) string { return e.ToEnd }},
+}
+
+// addEdgeFields copies the named canvas edge fields onto the edges as
+// attributes. The columns appear even when no edge sets the field, and
+// edges without it (or added by the tool) leave it empty.
+func addEdgeFields(g *graph, names []string) error {
+	if slices.Contains(names, "all") {
+		names = nil
+		for _, f := range edgeFields {
+			names = append(names, f.name)
+		}
+	}
+	for _, name := range names {
+		i := slices.IndexFunc(edgeFields, func(f edgeField) bool { return f.name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown field %q (want id, color, from_side, to_side, from_end, to_end or all)", name)
+		}
+		if !slices.Contains(g.edgeAttrs, name) {
+			g.edgeAttrs = append(g.edgeAttrs, name)
+		}
+		for j, e := range g.Edges {
+			if v := edgeFields[i].get(e.Edge); v != "" {
+				g.setEdgeAttr(j, name, v)
+			}
+		}
+	}
+	return nil
+}
+
+// splitLabels replaces each edge whose label contains sep with one edge per
+// non-empty segment, trimmed, in order. Unlabelled edges are kept as is.
+func splitLabels(g *graph, sep string) {
+	var edges []graphEdge
+	for _, e := range g.Edges {
+		var parts []string
+		for _, p := range strings.Split(e.Label, sep) {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		if len(parts) == 0 {
+			edges = append(edges, e)
+			continue
+		}
+		for _, p := range parts {
+			split := e
+			split.Label = p
+			split.Attrs = maps.Clone(e.Attrs)
+			edges = append(edges, split)
+		}
+	}
+	g.Edges = edges
+	g.index()
+}
+
+// collapseBidirectional merges each A->B edge with a B->A edge of the same
+// label into one edge, the earlier of the two, marked bidirectional=true.
+// Each edge pairs at most once, so A->B twice and B->A once leaves one
+// plain A->B.
+func collapseBidirectional(g *graph) {
+	type key struct{ from, to, label string }
+	unpaired := map[key][]int{}
+	drop := make([]bool, len(g.Edges))
+	if !slices.Contains(g.edgeAttrs, "bidirectional") {
+		g.edgeAttrs = append(g.edgeAttrs, "bidirectional")
+	}
+	for i, e := range g.Edges {
+		if e.From == e.To {
+			continue
+		}
+		rev := key{e.To, e.From, e.Label}
+		if js := unpaired[rev]; len(js) > 0 {
+			g.setEdgeAttr(js[0], "bidirectional", "true")
+			unpaired[rev] = js[1:]
+			drop[i] = true
+			continue
+		}
+		k := key{e.From, e.To, e.Label}
+		unpaired[k] = append(unpaired[k], i)
+	}
+	edges := g.Edges[:0]
+	for i, e := range g.Edges {
+		if !drop[i] {
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+	g.index()
+}
+
+// applySelfLoopPolicy handles edges from a node to itself: keep, drop or
+// error.
+func applySelfLoopPolicy(g *graph, policy string) error {
+	switch policy {
+	case "keep":
+		return nil
+	case "drop", "error":
+	default:
+		return fmt.Errorf("unknown policy %q (want keep, drop or error)", policy)
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if e.From != e.To {
+			edges = append(edges, e)
+			continue
+		}
+		if policy == "error" {
+			return fmt.Errorf("self-loop on node %s (%s)", e.From, g.name(e.From))
+		}
+	}
+	g.Edges = edges
+	g.index()
+	return nil
+}
+
+// applyParallelPolicy handles several edges between the same ordered pair
+// of nodes: keep them, or collapse them into the first with its distinct
+// labels joined by ", " (merge-labels), also recording how many there were
+// in a count column (count).
+func applyParallelPolicy(g *graph, policy string) error {
+	switch policy {
+	case "keep":
+		return nil
+	case "merge-labels", "count":
+	default:
+		return fmt.Errorf("unknown policy %q (want keep, merge-labels or count)", policy)
+	}
+	type pair struct{ from, to string }
+	first := map[pair]int{}
+	var labels [][]string
+	var counts []int
+	var edges []graphEdge
+	for _, e := range g.Edges {
+		p := pair{e.From, e.To}
+		i, ok := first[p]
+		if !ok {
+			i = len(edges)
+			first[p] = i
+			edges = append(edges, e)
+			labels = append(labels, nil)
+			counts = append(counts, 0)
+		}
+		counts[i]++
+		if e.Label != "" && !slices.Contains(labels[i], e.Label) {
+			labels[i] = append(labels[i], e.Label)
+		}
+	}
+	g.Edges = edges
+	for i := range g.Edges {
+		g.Edges[i].Label = strings.Join(labels[i], ", ")
+		if policy == "count" {
+			g.setEdgeAttr(i, "count", strconv.Itoa(counts[i]))
+		}
+	}
+	g.index()
+	return nil
+}
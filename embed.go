@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// embed computes node2vec embeddings: biased second-order random walks over
+// the undirected graph, fed to skip-gram with negative sampling. With the
+// same -seed the output is reproducible.
+
+type embedOptions struct {
+	dim, walks, walkLength, window, negative, epochs int
+	p, q, lr                                         float64
+	seed                                             int64
+}
+
+// undirectedAdjacency lists each node's neighbours (by index) once,
+// ignoring direction, self-loops and dangling edges.
+func undirectedAdjacency(g *graph) [][]int {
+	adj := make([][]int, len(g.Nodes))
+	seen := map[[2]int]bool{}
+	for _, e := range g.Edges {
+		a, okA := g.byID[e.From]
+		b, okB := g.byID[e.To]
+		if !okA || !okB || a == b || seen[[2]int{a, b}] {
+			continue
+		}
+		seen[[2]int{a, b}], seen[[2]int{b, a}] = true, true
+		adj[a] = append(adj[a], b)
+		adj[b] = append(adj[b], a)
+	}
+	return adj
+}
+
+// randomWalks generates opts.walks walks from every node. After the first
+// step the next node x from v (having come from t) is weighted 1/p if x is
+// t, 1 if x neighbours t, and 1/q otherwise.
+func randomWalks(adj [][]int, opts embedOptions, rng *rand.Rand) [][]int {
+	isNeighbor := make([]map[int]bool, len(adj))
+	for v, ns := range adj {
+		isNeighbor[v] = make(map[int]bool, len(ns))
+		for _, n := range ns {
+			isNeighbor[v][n] = true
+		}
+	}
+	var walks [][]int
+	weights := []float64{}
+	for w := 0; w < opts.walks; w++ {
+		for _, start := range rng.Perm(len(adj)) {
+			walk := []int{start}
+			for len(walk) < opts.walkLength {
+				v := walk[len(walk)-1]
+				ns := adj[v]
+				if len(ns) == 0 {
+					break
+				}
+				if len(walk) == 1 {
+					walk = append(walk, ns[rng.Intn(len(ns))])
+					continue
+				}
+				t := walk[len(walk)-2]
+				weights = weights[:0]
+				total := 0.0
+				for _, x := range ns {
+					wt := 1 / opts.q
+					switch {
+					case x == t:
+						wt = 1 / opts.p
+					case isNeighbor[t][x]:
+						wt = 1
+					}
+					weights = append(weights, wt)
+					total += wt
+				}
+				r := rng.Float64() * total
+				next := ns[len(ns)-1]
+				for i, wt := range weights {
+					if r < wt {
+						next = ns[i]
+						break
+					}
+					r -= wt
+				}
+				walk = append(walk, next)
+			}
+			walks = append(walks, walk)
+		}
+	}
+	return walks
+}
+
+// skipGram trains word2vec-style embeddings on the walks with negative
+// sampling from the unigram^0.75 distribution and a linearly decaying
+// learning rate.
+func skipGram(n int, walks [][]int, opts embedOptions, rng *rand.Rand) [][]float64 {
+	vec := make([][]float64, n)
+	ctx := make([][]float64, n)
+	for i := range vec {
+		vec[i] = make([]float64, opts.dim)
+		ctx[i] = make([]float64, opts.dim)
+		for d := range vec[i] {
+			vec[i][d] = (rng.Float64() - 0.5) / float64(opts.dim)
+		}
+	}
+	counts := make([]float64, n)
+	for _, w := range walks {
+		for _, v := range w {
+			counts[v]++
+		}
+	}
+	var table []int
+	for v, c := range counts {
+		for k := 0; k < int(math.Ceil(math.Pow(c, 0.75))); k++ {
+			table = append(table, v)
+		}
+	}
+	if len(table) == 0 {
+		return vec
+	}
+	sigmoid := func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }
+	grad := make([]float64, opts.dim)
+	steps, total := 0, opts.epochs*len(walks)
+	for epoch := 0; epoch < opts.epochs; epoch++ {
+		for _, walk := range walks {
+			lr := math.Max(opts.lr*(1-float64(steps)/float64(total)), opts.lr*1e-4)
+			steps++
+			for i, center := range walk {
+				lo, hi := max(0, i-opts.window), min(len(walk), i+opts.window+1)
+				for j := lo; j < hi; j++ {
+					if j == i {
+						continue
+					}
+					clear(grad)
+					for k := 0; k <= opts.negative; k++ {
+						target, label := walk[j], 1.0
+						if k > 0 {
+							if target = table[rng.Intn(len(table))]; target == walk[j] {
+								continue
+							}
+							label = 0
+						}
+						dot := 0.0
+						for d := range grad {
+							dot += vec[center][d] * ctx[target][d]
+						}
+						gr := (label - sigmoid(dot)) * lr
+						for d := range grad {
+							grad[d] += gr * ctx[target][d]
+							ctx[target][d] += gr * vec[center][d]
+						}
+					}
+					for d := range grad {
+						vec[center][d] += grad[d]
+					}
+				}
+			}
+		}
+	}
+	return vec
+}
+
+func writeEmbeddingsCSV(out io.Writer, g *graph, vecs [][]float64) error {
+	w := csv.NewWriter(out)
+	w.Comma = ';'
+	header := []string{"id", "name"}
+	for d := range vecs[0] {
+		header = append(header, "v"+strconv.Itoa(d))
+	}
+	w.Write(header)
+	for i, n := range g.Nodes {
+		row := []string{n.ID, n.Name}
+		for _, x := range vecs[i] {
+			row = append(row, strconv.FormatFloat(x, 'g', 6, 32))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeNPY writes a little-endian float32 matrix in NumPy's .npy format,
+// one row per node in canvas order.
+func writeNPY(w io.Writer, vecs [][]float64) error {
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%d, %d), }", len(vecs), len(vecs[0]))
+	// magic + version + header length + header must be a multiple of 64
+	pad := 64 - (10+len(header)+1)%64
+	header += strings.Repeat(" ", pad%64) + "\n"
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(bw, binary.LittleEndian, uint16(len(header)))
+	bw.WriteString(header)
+	buf := make([]byte, 4)
+	for _, row := range vecs {
+		for _, x := range row {
+			binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(x)))
+			bw.Write(buf)
+		}
+	}
+	return bw.Flush()
+}
+
+func runEmbed(args []string) {
+	fs := flag.NewFlagSet("embed", flag.ExitOnError)
+	inPath := fs.String("in", "", "input .canvas path (or - for stdin)")
+	outPath := fs.String("out", "-", "output path (or - for stdout)")
+	format := fs.String("format", "csv", "output format: csv (id;name;v0..) or npy (float32 matrix; row order is written to <out>.ids)")
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	var opts embedOptions
+	fs.IntVar(&opts.dim, "dim", 32, "embedding dimensions")
+	fs.IntVar(&opts.walks, "walks", 10, "random walks per node")
+	fs.IntVar(&opts.walkLength, "walk-length", 40, "nodes per walk")
+	fs.IntVar(&opts.window, "window", 5, "skip-gram context window")
+	fs.IntVar(&opts.negative, "negative", 5, "negative samples per context pair")
+	fs.IntVar(&opts.epochs, "epochs", 1, "training passes over the walks")
+	fs.Float64Var(&opts.p, "p", 1, "node2vec return parameter (higher: fewer immediate backtracks)")
+	fs.Float64Var(&opts.q, "q", 1, "node2vec in-out parameter (<1: explore outward, >1: stay local)")
+	fs.Float64Var(&opts.lr, "lr", 0.025, "initial learning rate")
+	fs.Int64Var(&opts.seed, "seed", 1, "random seed")
+	fs.Parse(args)
+	if *inPath == "" && fs.NArg() > 0 {
+		*inPath = fs.Arg(0)
+	}
+	if *inPath == "" {
+		fatalf("embed: missing -in (or first arg)")
+	}
+	if opts.dim <= 0 || opts.walks <= 0 || opts.walkLength < 2 || opts.epochs <= 0 || opts.p <= 0 || opts.q <= 0 {
+		fatalf("embed: -dim, -walks, -epochs, -p and -q must be positive and -walk-length at least 2")
+	}
+	c, err := loadCanvas(*inPath)
+	if err != nil {
+		fatalf("embed: %v", err)
+	}
+	g := buildGraph(c, *keepPath)
+	if len(g.Nodes) == 0 {
+		fatalf("embed: canvas has no nodes")
+	}
+
+	rng := rand.New(rand.NewSource(opts.seed))
+	vecs := skipGram(len(g.Nodes), randomWalks(undirectedAdjacency(g), opts, rng), opts, rng)
+
+	out, closeOut, err := openOut(*outPath)
+	if err != nil {
+		fatalf("embed: %v", err)
+	}
+	switch *format {
+	case "csv":
+		err = writeEmbeddingsCSV(out, g, vecs)
+	case "npy":
+		if err = writeNPY(out, vecs); err == nil && *outPath != "-" && *outPath != clipboardPath {
+			var ids strings.Builder
+			for _, n := range g.Nodes {
+				ids.WriteString(n.ID + "\n")
+			}
+			err = os.WriteFile(*outPath+".ids", []byte(ids.String()), 0o644)
+		}
+	default:
+		err = fmt.Errorf("unknown -format %q (want csv or npy)", *format)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fatalf("embed: %v", err)
+	}
+}
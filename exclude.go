@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// A node list, for -exclude-nodes and -around, names nodes one per line,
+// with # comments. A line is a node ID or name (names match
+// case-insensitively), or a regular expression between slashes matched
+// against names:
+//
+//	Index
+//	6f1c2a9b3e
+//	/^(TODO|Inbox)\b/
+
+type nodeMatcher struct {
+	exact []string // lower-cased IDs and names
+	res   []*regexp.Regexp
+}
+
+func readNodeMatcher(path string) (*nodeMatcher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &nodeMatcher{}
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for line := 1; sc.Scan(); line++ {
+		if err := m.add(sc.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+	}
+	return m, sc.Err()
+}
+
+// add adds one line of a node list.
+func (m *nodeMatcher) add(line string) error {
+	text := strings.TrimSpace(line)
+	switch {
+	case text == "" || strings.HasPrefix(text, "#"):
+	case len(text) >= 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/"):
+		re, err := regexp.Compile(text[1 : len(text)-1])
+		if err != nil {
+			return err
+		}
+		m.res = append(m.res, re)
+	default:
+		m.exact = append(m.exact, strings.ToLower(text))
+	}
+	return nil
+}
+
+// nodeMatcherFrom builds a matcher from flag values, each a node list
+// entry or @file naming a node list.
+func nodeMatcherFrom(values []string) (*nodeMatcher, error) {
+	m := &nodeMatcher{}
+	for _, v := range values {
+		if path, ok := strings.CutPrefix(v, "@"); ok {
+			fm, err := readNodeMatcher(path)
+			if err != nil {
+				return nil, err
+			}
+			m.exact, m.res = append(m.exact, fm.exact...), append(m.res, fm.res...)
+			continue
+		}
+		if err := m.add(v); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *nodeMatcher) match(n graphNode) bool {
+	for _, s := range m.exact {
+		if s == strings.ToLower(n.ID) || s == strings.ToLower(n.Name) {
+			return true
+		}
+	}
+	for _, re := range m.res {
+		if re.MatchString(n.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeNodes removes the matching nodes and every edge touching them.
+func excludeNodes(g *graph, m *nodeMatcher) {
+	drop := map[string]bool{}
+	nodes := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if m.match(n) {
+			drop[n.ID] = true
+		} else {
+			nodes = append(nodes, n)
+		}
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if !drop[e.From] && !drop[e.To] {
+			edges = append(edges, e)
+		}
+	}
+	g.Nodes, g.Edges = nodes, edges
+	g.index()
+}
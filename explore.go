@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// explore is an interactive, line-based canvas browser: it needs nothing
+// but a plain terminal, which keeps it usable over SSH and in pipes.
+
+const exploreHelp = `commands:
+  nodes [text]          list nodes, optionally only those matching text
+  open <id|name>        show a node and its edges
+  <n>                   open the n'th entry of the last listing
+  back                  return to the previous node
+  label [text]          only show edges whose label contains text (no text: show all)
+  labels                list edge labels with counts
+  select [depth]        add the current node (and neighbours up to depth) to the selection
+  unselect              remove the current node from the selection
+  selection             list the selection
+  clear                 empty the selection
+  export <format> <out> export the selection and the edges between its nodes (out - for stdout)
+  help                  show this help
+  quit                  leave
+`
+
+type explorer struct {
+	g        *graph
+	out      io.Writer
+	cur      string   // current node ID, "" before the first open
+	history  []string // previously opened node IDs
+	choices  []string // node IDs numbered by the last listing
+	label    string   // edge label filter
+	selected map[string]bool
+}
+
+func runExplore(args []string) {
+	fs := flag.NewFlagSet("explore", flag.ExitOnError)
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatalf("explore: usage: canvas_tool explore [flags] file.canvas")
+	}
+	c, err := loadCanvas(fs.Arg(0))
+	if err != nil {
+		fatalf("explore: %v", err)
+	}
+	x := &explorer{g: buildGraph(c, *keepPath), out: os.Stdout, selected: map[string]bool{}}
+	fmt.Fprintf(x.out, "%s: %d nodes, %d edges. Type help for commands.\n", fs.Arg(0), len(x.g.Nodes), len(x.g.Edges))
+
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(x.out, x.prompt())
+		if !in.Scan() {
+			fmt.Fprintln(x.out)
+			return
+		}
+		if !x.command(strings.TrimSpace(in.Text())) {
+			return
+		}
+	}
+}
+
+func (x *explorer) prompt() string {
+	p := "> "
+	if n, ok := x.g.node(x.cur); ok {
+		p = n.Name + " > "
+	}
+	if x.label != "" {
+		p = "[label~" + x.label + "] " + p
+	}
+	return p
+}
+
+// command runs one line and reports whether to keep going.
+func (x *explorer) command(line string) bool {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case "":
+	case "quit", "exit", "q":
+		return false
+	case "help", "?":
+		fmt.Fprint(x.out, exploreHelp)
+	case "nodes", "ls":
+		x.listNodes(arg)
+	case "open":
+		x.open(arg)
+	case "back":
+		if len(x.history) == 0 {
+			fmt.Fprintln(x.out, "no previous node")
+			break
+		}
+		x.cur = x.history[len(x.history)-1]
+		x.history = x.history[:len(x.history)-1]
+		x.show()
+	case "label":
+		x.label = arg
+		if x.cur != "" {
+			x.show()
+		}
+	case "labels":
+		x.listLabels()
+	case "select":
+		x.selectCurrent(arg)
+	case "unselect":
+		delete(x.selected, x.cur)
+	case "selection":
+		x.choices = x.choices[:0]
+		for _, n := range x.g.Nodes {
+			if x.selected[n.ID] {
+				x.choices = append(x.choices, n.ID)
+				fmt.Fprintf(x.out, "%3d  %s\n", len(x.choices), n.Name)
+			}
+		}
+	case "clear":
+		clear(x.selected)
+	case "export":
+		x.export(arg)
+	default:
+		if k, err := strconv.Atoi(cmd); err == nil {
+			if k < 1 || k > len(x.choices) {
+				fmt.Fprintln(x.out, "no such entry")
+				break
+			}
+			x.visit(x.choices[k-1])
+			break
+		}
+		fmt.Fprintf(x.out, "unknown command %q (try help)\n", cmd)
+	}
+	return true
+}
+
+func (x *explorer) listNodes(filter string) {
+	filter = strings.ToLower(filter)
+	x.choices = x.choices[:0]
+	for _, n := range x.g.Nodes {
+		if filter != "" && !strings.Contains(strings.ToLower(n.Name), filter) {
+			continue
+		}
+		x.choices = append(x.choices, n.ID)
+		fmt.Fprintf(x.out, "%3d  %-6s %s\n", len(x.choices), n.Type, n.Name)
+	}
+	if len(x.choices) == 0 {
+		fmt.Fprintln(x.out, "no nodes")
+	}
+}
+
+func (x *explorer) open(ref string) {
+	if _, ok := x.g.node(ref); ok {
+		x.visit(ref)
+		return
+	}
+	for _, n := range x.g.Nodes {
+		if strings.EqualFold(n.Name, ref) {
+			x.visit(n.ID)
+			return
+		}
+	}
+	fmt.Fprintf(x.out, "no node %q\n", ref)
+}
+
+func (x *explorer) visit(id string) {
+	if x.cur != "" && x.cur != id {
+		x.history = append(x.history, x.cur)
+	}
+	x.cur = id
+	x.show()
+}
+
+// show prints the current node and numbers its edges for following.
+func (x *explorer) show() {
+	n, _ := x.g.node(x.cur)
+	fmt.Fprintf(x.out, "%s  [%s %s]", n.Name, n.Type, n.ID)
+	if x.selected[n.ID] {
+		fmt.Fprint(x.out, "  (selected)")
+	}
+	fmt.Fprintln(x.out)
+	if n.Node.Text != "" && n.Node.Text != n.Name {
+		for _, l := range strings.Split(n.Node.Text, "\n") {
+			fmt.Fprintf(x.out, "  | %s\n", l)
+		}
+	}
+	x.choices = x.choices[:0]
+	list := func(heading, arrow string, edges []int, other func(graphEdge) string) {
+		printed := false
+		for _, ei := range edges {
+			e := x.g.Edges[ei]
+			if x.label != "" && !strings.Contains(strings.ToLower(e.Label), strings.ToLower(x.label)) {
+				continue
+			}
+			if !printed {
+				fmt.Fprintln(x.out, heading)
+				printed = true
+			}
+			id := other(e)
+			x.choices = append(x.choices, id)
+			name := x.g.name(id)
+			if name == "" {
+				name = id + " (missing)"
+			}
+			fmt.Fprintf(x.out, "%3d  %s[%s] %s\n", len(x.choices), arrow, e.Label, name)
+		}
+	}
+	list("outgoing:", "-> ", x.g.out[x.cur], func(e graphEdge) string { return e.To })
+	list("incoming:", "<- ", x.g.in[x.cur], func(e graphEdge) string { return e.From })
+	if len(x.choices) == 0 {
+		fmt.Fprintln(x.out, "no edges")
+	}
+}
+
+func (x *explorer) listLabels() {
+	counts := map[string]int{}
+	for _, e := range x.g.Edges {
+		counts[e.Label]++
+	}
+	labels := sortedKeys(counts)
+	sort.SliceStable(labels, func(i, j int) bool { return counts[labels[i]] > counts[labels[j]] })
+	for _, l := range labels {
+		shown := l
+		if shown == "" {
+			shown = "(none)"
+		}
+		fmt.Fprintf(x.out, "%5d  %s\n", counts[l], shown)
+	}
+}
+
+func (x *explorer) selectCurrent(arg string) {
+	if x.cur == "" {
+		fmt.Fprintln(x.out, "open a node first")
+		return
+	}
+	depth := 0
+	if arg != "" {
+		var err error
+		if depth, err = strconv.Atoi(arg); err != nil || depth < 0 {
+			fmt.Fprintln(x.out, "depth must be a number")
+			return
+		}
+	}
+	x.selected[x.cur] = true
+	for _, id := range x.g.neighbors(x.cur, depth, "both") {
+		if _, ok := x.g.node(id); ok {
+			x.selected[id] = true
+		}
+	}
+	fmt.Fprintf(x.out, "%d nodes selected\n", len(x.selected))
+}
+
+func (x *explorer) export(arg string) {
+	format, path, ok := strings.Cut(arg, " ")
+	ex, known := exporters[format]
+	if !ok || !known || ex.write == nil {
+		fmt.Fprintf(x.out, "usage: export <format> <out>, format one of: %s\n", strings.Join(exporterNames(), ", "))
+		return
+	}
+	if len(x.selected) == 0 {
+		fmt.Fprintln(x.out, "selection is empty")
+		return
+	}
+	sub := x.g.subgraph(x.selected)
+
+	out, closeOut, err := openOut(strings.TrimSpace(path))
+	if err == nil {
+		opts, _ := exportOptionsFrom(nil)
+		err = ex.write(out, sub, opts)
+		if cerr := closeOut(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(x.out, "export: %v\n", err)
+		return
+	}
+	fmt.Fprintf(x.out, "exported %d nodes, %d edges\n", len(sub.Nodes), len(sub.Edges))
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exporttest runs an exporter, built in or a plugin, over a corpus of small
+// canonical canvases that cover the awkward cases: no edges, labels with
+// commas, quotes and newlines, groups, dangling edges, self-loops, parallel
+// edges and non-ASCII names. Each output must be written without error and
+// be the same on a second run; with -golden it must also match the file
+// saved for that case under <dir>/<format>/, which -update (re)writes.
+// Plugin authors run it against their export-<format> executable; the
+// goldens under testdata/exporttest pin the built-in formats.
+
+// exportCase is one canvas of the corpus.
+type exportCase struct {
+	name   string
+	canvas string
+}
+
+var exportCorpus = []exportCase{
+	{"empty", `{"nodes": [], "edges": []}`},
+	{"single", `{"nodes": [{"id": "a", "type": "text", "text": "alone", "x": 0, "y": 0, "width": 250, "height": 60}]}`},
+	{"chain", `{
+		"nodes": [
+			{"id": "a", "type": "text", "text": "A", "x": 0, "y": 0, "width": 250, "height": 60},
+			{"id": "b", "type": "text", "text": "B", "x": 300, "y": 0, "width": 250, "height": 60},
+			{"id": "c", "type": "text", "text": "C", "x": 600, "y": 0, "width": 250, "height": 60}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "a", "toNode": "b", "label": "first"},
+			{"id": "e2", "fromNode": "b", "toNode": "c"}
+		]
+	}`},
+	{"node-types", `{
+		"nodes": [
+			{"id": "t", "type": "text", "text": "# Heading\nbody", "x": 0, "y": 0, "width": 250, "height": 60},
+			{"id": "f", "type": "file", "file": "notes/Project plan.md", "x": 300, "y": 0, "width": 250, "height": 60},
+			{"id": "l", "type": "link", "url": "https://example.com/a?b=c&d=e", "x": 600, "y": 0, "width": 250, "height": 60},
+			{"id": "g", "type": "group", "label": "Group", "x": -20, "y": -20, "width": 900, "height": 120}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "t", "toNode": "f", "label": "refers to"},
+			{"id": "e2", "fromNode": "f", "toNode": "l", "label": "cites"}
+		]
+	}`},
+	{"quoting", `{
+		"nodes": [
+			{"id": "a", "type": "text", "text": "comma, \"quote\" and 'apostrophe'", "x": 0, "y": 0, "width": 250, "height": 60},
+			{"id": "b", "type": "text", "text": "<tag> & {braces} ; semi\ttab", "x": 300, "y": 0, "width": 250, "height": 60}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "a", "toNode": "b", "label": "line one\nline two, \"quoted\""}
+		]
+	}`},
+	{"loops-and-parallel", `{
+		"nodes": [
+			{"id": "a", "type": "text", "text": "A", "x": 0, "y": 0, "width": 250, "height": 60},
+			{"id": "b", "type": "text", "text": "B", "x": 300, "y": 0, "width": 250, "height": 60}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "a", "toNode": "a", "label": "self"},
+			{"id": "e2", "fromNode": "a", "toNode": "b", "label": "one"},
+			{"id": "e3", "fromNode": "a", "toNode": "b", "label": "two"},
+			{"id": "e4", "fromNode": "b", "toNode": "a", "label": "back"}
+		]
+	}`},
+	{"dangling", `{
+		"nodes": [{"id": "a", "type": "text", "text": "A", "x": 0, "y": 0, "width": 250, "height": 60}],
+		"edges": [{"id": "e1", "fromNode": "a", "toNode": "missing", "label": "to nowhere"}]
+	}`},
+	{"unicode", `{
+		"nodes": [
+			{"id": "a", "type": "text", "text": "Café ☕", "x": 0, "y": 0, "width": 250, "height": 60},
+			{"id": "b", "type": "text", "text": "日本語のノート", "x": 300, "y": 0, "width": 250, "height": 60},
+			{"id": "c", "type": "file", "file": "Zürich/Übersicht.md", "x": 600, "y": 0, "width": 250, "height": 60}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "a", "toNode": "b", "label": "hängt ab von"},
+			{"id": "e2", "fromNode": "b", "toNode": "c", "label": "→ siehe"}
+		]
+	}`},
+	{"sides-and-ends", `{
+		"nodes": [
+			{"id": "a", "type": "text", "text": "A", "x": 0, "y": 0, "width": 250, "height": 60, "color": "1"},
+			{"id": "b", "type": "text", "text": "B", "x": 0, "y": 200, "width": 250, "height": 60, "color": "#00ff88"}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "a", "fromSide": "bottom", "toNode": "b", "toSide": "top", "fromEnd": "arrow", "toEnd": "arrow", "color": "4"},
+			{"id": "e2", "fromNode": "b", "fromSide": "left", "toNode": "a", "toSide": "left", "toEnd": "none"}
+		]
+	}`},
+}
+
+// exportCheck runs ex over the case and returns its output.
+func exportCheck(ctx context.Context, ex exporter, tc exportCase, opts exportOptions) ([]byte, error) {
+	c, err := parseCanvasContext(ctx, []byte(tc.canvas))
+	if err != nil {
+		return nil, fmt.Errorf("corpus canvas: %v", err)
+	}
+	var first, second bytes.Buffer
+	if err := ex.write(&first, buildGraph(c, false), opts); err != nil {
+		return nil, err
+	}
+	if err := ex.write(&second, buildGraph(c, false), opts); err != nil {
+		return nil, fmt.Errorf("second run: %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		return nil, errors.New("output differs between two runs of the same canvas")
+	}
+	return first.Bytes(), nil
+}
+
+// firstDifference describes where got departs from want.
+func firstDifference(got, want []byte) string {
+	gl, wl := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gl) || i < len(wl); i++ {
+		var g, w string
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if g != w {
+			return fmt.Sprintf("line %d: got %q, want %q", i+1, g, w)
+		}
+	}
+	return "outputs differ"
+}
+
+func runExportTest(args []string) {
+	fs := flag.NewFlagSet("exporttest", flag.ExitOnError)
+	formats := fs.String("formats", "", "comma-separated formats or export plugins to check (default: every built-in format that can stream)")
+	golden := fs.String("golden", "", "directory of expected outputs, one <format>/<case><ext> per case")
+	update := fs.Bool("update", false, "write the outputs to -golden instead of comparing")
+	pluginDir := fs.String("plugins", defaultPluginDir(), "directory to find export plugins in")
+	var pluginOpts stringsFlag
+	fs.Var(&pluginOpts, "plugin-opt", "`key=value` passed to plugins in the request options (repeatable)")
+	only := fs.String("case", "", "run only the corpus cases with these comma-separated `names`")
+	list := fs.Bool("list", false, "list the corpus cases and exit")
+	var opts exportOptions
+	registerExportFlags(fs, &opts)
+	fs.Parse(args)
+
+	if *list {
+		for _, tc := range exportCorpus {
+			fmt.Println(tc.name)
+		}
+		return
+	}
+	if *update && *golden == "" {
+		fatalf("exporttest: -update needs -golden")
+	}
+	cases := exportCorpus
+	if names := splitList(*only); len(names) > 0 {
+		cases = nil
+		for _, name := range names {
+			found := false
+			for _, tc := range exportCorpus {
+				if tc.name == name {
+					cases, found = append(cases, tc), true
+				}
+			}
+			if !found {
+				fatalf("exporttest: no corpus case %q (see -list)", name)
+			}
+		}
+	}
+	names := splitList(*formats)
+	if len(names) == 0 {
+		for _, name := range exporterNames() {
+			if exporters[name].write != nil {
+				names = append(names, name)
+			}
+		}
+	}
+
+	pluginSettings, err := pluginOpts.keyValues()
+	if err != nil {
+		fatalf("exporttest: -plugin-opt: %v", err)
+	}
+
+	ctx := context.Background()
+	failed := 0
+	for _, name := range names {
+		ex, err := lookupExporter(ctx, name, *pluginDir, pluginSettings)
+		if err != nil {
+			fatalf("exporttest: %v", err)
+		}
+		if ex.write == nil {
+			fatalf("exporttest: %s writes only to files and can't be checked", name)
+		}
+		for _, tc := range cases {
+			out, err := exportCheck(ctx, ex, tc, opts)
+			path := filepath.Join(*golden, name, tc.name+ex.ext)
+			switch {
+			case err != nil:
+			case *update:
+				if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+					err = os.WriteFile(path, out, 0o644)
+				}
+			case *golden != "":
+				var want []byte
+				if want, err = os.ReadFile(path); err == nil && !bytes.Equal(out, want) {
+					err = errors.New(firstDifference(out, want))
+				}
+			}
+			if err != nil {
+				failed++
+				fmt.Printf("FAIL\t%s\t%s: %v\n", name, tc.name, err)
+			} else {
+				fmt.Printf("ok\t%s\t%s\n", name, tc.name)
+			}
+		}
+	}
+	if failed > 0 {
+		fatalf("exporttest: %d of %d checks failed", failed, len(names)*len(cases))
+	}
+}
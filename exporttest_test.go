@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestExportGoldens runs the exporttest corpus through every format with
+// goldens under testdata/exporttest. After a deliberate change to a format,
+// refresh them with
+//
+//	canvas_tool exporttest -formats <format> -golden testdata/exporttest -update
+func TestExportGoldens(t *testing.T) {
+	dirs, err := os.ReadDir(filepath.Join("testdata", "exporttest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dirs {
+		format := d.Name()
+		ex, ok := exporters[format]
+		if !ok {
+			t.Errorf("testdata/exporttest/%s: no such format", format)
+			continue
+		}
+		for _, tc := range exportCorpus {
+			t.Run(format+"/"+tc.name, func(t *testing.T) {
+				got, err := exportCheck(context.Background(), ex, tc, exportOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := os.ReadFile(filepath.Join("testdata", "exporttest", format, tc.name+ex.ext))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("output differs from the golden: %s", firstDifference(got, want))
+				}
+			})
+		}
+	}
+}
+
+// corpusGraph builds the graph of the exporttest case name.
+func corpusGraph(t *testing.T, name string) *graph {
+	t.Helper()
+	for _, tc := range exportCorpus {
+		if tc.name == name {
+			c, err := parseCanvas([]byte(tc.canvas))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return buildGraph(c, false)
+		}
+	}
+	t.Fatalf("no corpus case %q", name)
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Computed fields are extra node attributes evaluated from the graph, given
+// as "name=expr" or just "expr" (named after the expression, e.g.
+// word_count_text). Expressions:
+//
+//	degree, degree_in, degree_out
+//	word_count of P, char_count of P, line_count of P
+//	group name, group id      innermost enclosing group
+//	P                         a node property or attribute
+//
+// where P is id, type, name, text, file, url, label, x, y, width, height or
+// an attribute name.
+
+type computedField struct {
+	name string
+	eval func(g *graph, i int) string
+}
+
+func parseComputedField(def string) (computedField, error) {
+	name, expr, ok := strings.Cut(def, "=")
+	if !ok {
+		expr = def
+		name = strings.Join(strings.Fields(strings.ReplaceAll(def, " of ", " ")), "_")
+	}
+	name, expr = strings.TrimSpace(name), strings.TrimSpace(expr)
+	if name == "" || expr == "" {
+		return computedField{}, fmt.Errorf("bad field %q (want name=expr)", def)
+	}
+	f := computedField{name: name}
+	count := func(n int) string { return strconv.Itoa(n) }
+
+	switch expr {
+	case "degree":
+		f.eval = func(g *graph, i int) string { id := g.Nodes[i].ID; return count(len(g.out[id]) + len(g.in[id])) }
+		return f, nil
+	case "degree_in":
+		f.eval = func(g *graph, i int) string { return count(len(g.in[g.Nodes[i].ID])) }
+		return f, nil
+	case "degree_out":
+		f.eval = func(g *graph, i int) string { return count(len(g.out[g.Nodes[i].ID])) }
+		return f, nil
+	case "group name", "group id":
+		byName := expr == "group name"
+		f.eval = func(g *graph, i int) string {
+			j := g.innermostGroup(i)
+			switch {
+			case j < 0:
+				return ""
+			case byName:
+				return g.Nodes[j].Name
+			}
+			return g.Nodes[j].ID
+		}
+		return f, nil
+	}
+
+	if fn, prop, ok := strings.Cut(expr, " of "); ok {
+		prop = strings.TrimSpace(prop)
+		var measure func(string) int
+		switch strings.TrimSpace(fn) {
+		case "word_count":
+			measure = func(s string) int { return len(strings.Fields(s)) }
+		case "char_count":
+			measure = utf8.RuneCountInString
+		case "line_count":
+			measure = func(s string) int {
+				if s == "" {
+					return 0
+				}
+				return strings.Count(strings.TrimRight(s, "\n"), "\n") + 1
+			}
+		default:
+			return computedField{}, fmt.Errorf("field %s: unknown function %q", name, fn)
+		}
+		f.eval = func(g *graph, i int) string { return count(measure(nodeProperty(g.Nodes[i], prop))) }
+		return f, nil
+	}
+	if strings.ContainsAny(expr, " \t") {
+		return computedField{}, fmt.Errorf("field %s: unknown expression %q", name, expr)
+	}
+	f.eval = func(g *graph, i int) string { return nodeProperty(g.Nodes[i], expr) }
+	return f, nil
+}
+
+// nodeProperty returns a node property by column name, falling back to the
+// node's attributes.
+func nodeProperty(n graphNode, prop string) string {
+	switch prop {
+	case "id":
+		return n.ID
+	case "type":
+		return n.Type
+	case "name":
+		return n.Name
+	case "text":
+		return n.Node.Text
+	case "file":
+		return n.Node.File
+	case "url":
+		return n.Node.URL
+	case "label":
+		return n.Node.Label
+	case "x":
+		return strconv.FormatFloat(n.Node.X, 'f', -1, 64)
+	case "y":
+		return strconv.FormatFloat(n.Node.Y, 'f', -1, 64)
+	case "width":
+		return strconv.FormatFloat(n.Node.Width, 'f', -1, 64)
+	case "height":
+		return strconv.FormatFloat(n.Node.Height, 'f', -1, 64)
+	}
+	return n.Attrs[prop]
+}
+
+// applyComputedFields evaluates every field for every node. All fields see
+// the graph as it was before any of them ran.
+func applyComputedFields(g *graph, fields []computedField) {
+	values := make([][]string, len(fields))
+	for fi, f := range fields {
+		values[fi] = make([]string, len(g.Nodes))
+		for i := range g.Nodes {
+			values[fi][i] = f.eval(g, i)
+		}
+	}
+	for fi, f := range fields {
+		for i := range g.Nodes {
+			g.setAttr(i, f.name, values[fi][i])
+		}
+	}
+}
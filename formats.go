@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exportOptions carries format-specific settings from the command line.
+type exportOptions struct {
+	format formatOpts // -opt format.key=value
+}
+
+// formatOptions are the keys each format reads from -opt, with their usage,
+// so tuning a format doesn't take a top-level flag per knob.
+var formatOptions = map[string]map[string]string{
+	"arrow": {
+		"table": "table to write: edges (default) or nodes",
+	},
+	"ascii": {
+		"charset": "characters to draw with: unicode box drawing (default) or plain ascii",
+	},
+	"csv": {
+		"header": "true to start with a header row",
+		"delim":  "field delimiter (default ;)",
+	},
+	"dot": {
+		"rankdir": "layout direction: TB, LR, BT or RL",
+		"ports":   "false to let Graphviz choose where edges meet nodes",
+	},
+	"graphml": {
+		"yed": "true to add yEd geometry, colours and labels",
+	},
+	"ical": {
+		"pattern":  "regular expression finding dates in node text; its first group, if any, is the date (default ISO dates)",
+		"layout":   "Go time layout the dates are in (default 2006-01-02); one with a time of day gives timed events",
+		"duration": "length of timed events (default 1h)",
+	},
+	"jsonld": {
+		"context": "JSON-LD @context file (default: schema.org vocabulary); edge labels become lowerCamelCase terms",
+		"base":    "IRI prefix for node IDs (default urn:canvas:)",
+	},
+	"make": {
+		"edges": "depends-on (an edge points from a task to its prerequisite) or before (to what runs after it)",
+	},
+	"nagios": {
+		"host-template":    "template host definitions use (default generic-host)",
+		"service-template": "template service definitions use (default generic-service)",
+	},
+	"org": {
+		"root": "node ID, name or /regexp/ to start the tree from (default: every node without incoming edges)",
+	},
+	"outline": {
+		"root": "node ID, name or /regexp/ to start the tree from (default: every node without incoming edges)",
+	},
+	"parquet": {
+		"table": "table to write: edges (default) or nodes",
+	},
+	"sql": {
+		"dialect": "postgres (default), mysql, sqlite or duckdb",
+		"batch":   "rows per INSERT statement (default 500)",
+	},
+	"taskfile": {
+		"edges": "depends-on (an edge points from a task to its prerequisite) or before (to what runs after it)",
+	},
+}
+
+// formatOpts holds -opt settings by format, then key.
+type formatOpts map[string]map[string]string
+
+func (f *formatOpts) String() string {
+	var parts []string
+	for format, kv := range *f {
+		for k, v := range kv {
+			parts = append(parts, format+"."+k+"="+v)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (f *formatOpts) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	format, key, dotted := strings.Cut(name, ".")
+	if !ok || !dotted {
+		return fmt.Errorf("want format.key=value, got %q", s)
+	}
+	keys, known := formatOptions[format]
+	if !known {
+		return fmt.Errorf("no options for format %q (have %s)", format, strings.Join(sortedKeys(formatOptions), ", "))
+	}
+	if _, ok := keys[key]; !ok {
+		return fmt.Errorf("unknown %s option %q (want %s)", format, key, strings.Join(sortedKeys(keys), ", "))
+	}
+	if *f == nil {
+		*f = formatOpts{}
+	}
+	if (*f)[format] == nil {
+		(*f)[format] = map[string]string{}
+	}
+	(*f)[format][key] = value
+	return nil
+}
+
+// with returns a copy of f with format.key set to value.
+func (f formatOpts) with(format, key, value string) formatOpts {
+	c := formatOpts{}
+	for fm, kv := range f {
+		c[fm] = maps.Clone(kv)
+	}
+	if c[format] == nil {
+		c[format] = map[string]string{}
+	}
+	c[format][key] = value
+	return c
+}
+
+// opt returns the -opt setting format.key, or def.
+func (o exportOptions) opt(format, key, def string) string {
+	if v, ok := o.format[format][key]; ok {
+		return v
+	}
+	return def
+}
+
+// optBool reads a boolean -opt setting.
+func (o exportOptions) optBool(format, key string, def bool) (bool, error) {
+	v, ok := o.format[format][key]
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("-opt %s.%s: want true or false, got %q", format, key, v)
+	}
+	return b, nil
+}
+
+// optInt reads an integer -opt setting.
+func (o exportOptions) optInt(format, key string, def int) (int, error) {
+	v, ok := o.format[format][key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, fmt.Errorf("-opt %s.%s: want a whole number, got %q", format, key, v)
+	}
+	return n, nil
+}
+
+// registerExportFlags defines -opt, the format-specific settings, on fs.
+func registerExportFlags(fs *flag.FlagSet, opts *exportOptions) {
+	fs.Var(&opts.format, "opt", "format-specific setting `format.key=value`, e.g. dot.rankdir=LR, sql.dialect=mysql, csv.header=true (repeatable)")
+}
+
+// exportOptionsFrom returns the defaults overridden by settings, keyed by
+// format.key as for -opt (e.g. "sql.dialect").
+func exportOptionsFrom(settings map[string]string) (exportOptions, error) {
+	var opts exportOptions
+	for _, k := range sortedKeys(settings) {
+		if !strings.Contains(k, ".") {
+			return opts, fmt.Errorf("unknown option %q (want format.key, e.g. sql.dialect)", k)
+		}
+		if err := opts.format.Set(k + "=" + settings[k]); err != nil {
+			return opts, fmt.Errorf("option %s: %v", k, err)
+		}
+	}
+	return opts, nil
+}
+
+type exporter struct {
+	ext   string // default output file extension
+	write func(w io.Writer, g *graph, opts exportOptions) error
+
+	// toFile is set instead of write for formats that must own the output
+	// file (databases), so they can't stream to stdout.
+	toFile func(ctx context.Context, path string, g *graph, opts exportOptions) error
+}
+
+var exporters = map[string]exporter{
+	"arrow":      {ext: ".arrow", write: writeArrow},
+	"ascii":      {ext: ".txt", write: writeASCII},
+	"csv":        {ext: ".csv", write: writeCSV},
+	"dot":        {ext: ".dot", write: writeDOT},
+	"duckdb":     {ext: ".duckdb", toFile: writeDuckDB},
+	"file-sd":    {ext: ".json", write: writeFileSD},
+	"graphml":    {ext: ".graphml", write: writeGraphML},
+	"html-table": {ext: ".html", write: writeHTMLTable},
+	"ical":       {ext: ".ics", write: writeICal},
+	"jsonld":     {ext: ".jsonld", write: writeJSONLD},
+	"make":       {ext: ".mk", write: writeMakefile},
+	"nagios":     {ext: ".cfg", write: writeNagios},
+	"org":        {ext: ".org", write: writeOrg},
+	"outline":    {ext: ".txt", write: writeOutline},
+	"parquet":    {ext: ".parquet", write: writeParquet},
+	"sql":        {ext: ".sql", write: writeSQL},
+	"taskfile":   {ext: ".yml", write: writeTaskfile},
+	"toml":       {ext: ".toml", write: writeTOML},
+	"yaml":       {ext: ".yaml", write: writeYAML},
+}
+
+func exporterNames() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
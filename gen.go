@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gen: synthesise .canvas files from other sources.
+
+var generators = map[string]func(args []string){
+	"csv":      runGenCSV,
+	"edges":    runGenEdges,
+	"gomod":    runGenGomod,
+	"matrix":   runGenMatrix,
+	"template": runGenTemplate,
+	"toml":     genDocument("toml", parseTOML),
+	"tree":     runGenTree,
+	"yaml":     genDocument("yaml", parseYAML),
+}
+
+func runGen(args []string) {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 0 || generators[args[0]] == nil {
+		fmt.Fprintf(os.Stderr, "usage: canvas_tool gen <%s> [flags]\n", strings.Join(names, "|"))
+		os.Exit(2)
+	}
+	generators[args[0]](args[1:])
+}
+
+// canvasBuilder accumulates nodes and edges with deterministic IDs, so
+// regenerating from the same input yields the same canvas.
+type canvasBuilder struct {
+	c     Canvas
+	byKey map[string]string // node key -> ID
+}
+
+func newCanvasBuilder() *canvasBuilder {
+	return &canvasBuilder{byKey: map[string]string{}}
+}
+
+func canvasID(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// add appends n under key unless a node with that key exists, returning the
+// node's ID either way.
+func (b *canvasBuilder) add(key string, n Node) string {
+	if id, ok := b.byKey[key]; ok {
+		return id
+	}
+	n.ID = canvasID("node", key)
+	if n.Width == 0 {
+		n.Width, n.Height = nodeWidth, nodeHeight
+	}
+	b.byKey[key] = n.ID
+	b.c.Nodes = append(b.c.Nodes, n)
+	return n.ID
+}
+
+func (b *canvasBuilder) text(name string) string {
+	return b.add(name, Node{Type: "text", Text: name})
+}
+
+func (b *canvasBuilder) edge(from, to, label string) {
+	b.c.Edges = append(b.c.Edges, Edge{
+		ID:       canvasID("edge", from, to, label, fmt.Sprint(len(b.c.Edges))),
+		FromNode: from,
+		ToNode:   to,
+		Label:    label,
+	})
+}
+
+// writeCanvas writes c the way Obsidian does: tab-indented JSON.
+func writeCanvas(path string, c Canvas) error {
+	if c.Nodes == nil {
+		c.Nodes = []Node{}
+	}
+	if c.Edges == nil {
+		c.Edges = []Edge{}
+	}
+	var v any = c
+	if len(c.Meta) > 0 {
+		doc := jsonObject{}
+		doc.set("nodes", c.Nodes)
+		doc.set("edges", c.Edges)
+		v = append(doc, c.Meta...)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	out, closeOut, err := openOut(path)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		closeOut()
+		return err
+	}
+	return closeOut()
+}
+
+// genOutPath defaults the output to the input basename with .canvas.
+func genOutPath(in, out string) string {
+	if out != "" {
+		return out
+	}
+	if in == "-" || in == clipboardPath || in == "" {
+		return "-"
+	}
+	return strings.TrimSuffix(filepath.Base(in), filepath.Ext(in)) + ".canvas"
+}
+
+// sniffDelimiter guesses the field separator from the first line.
+func sniffDelimiter(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	best, bestN := ',', -1
+	for _, d := range []rune{',', ';', '\t', '|'} {
+		if n := bytes.Count(line, []byte(string(d))); n > bestN {
+			best, bestN = d, n
+		}
+	}
+	return best
+}
+
+// parseDelimiter accepts a flag value such as ";", "tab" or "" (sniff).
+func parseDelimiter(s string, data []byte) (rune, error) {
+	switch s {
+	case "":
+		return sniffDelimiter(data), nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	if r := []rune(s); len(r) == 1 {
+		return r[0], nil
+	}
+	return 0, fmt.Errorf("delimiter must be a single character, got %q", s)
+}
+
+func readAllInput(path string) ([]byte, error) {
+	in, closeIn, err := openIn(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeIn()
+	return io.ReadAll(in)
+}
+
+// runGenMatrix builds a canvas from an adjacency matrix: the header row and
+// first column name the nodes, and a non-empty cell at (row, col) is an edge
+// row -> col. Cells of 1/x/true/yes give unlabelled edges, 0/false/no none,
+// and anything else becomes the edge label.
+func runGenMatrix(args []string) {
+	fs := flag.NewFlagSet("gen matrix", flag.ExitOnError)
+	inPath := fs.String("in", "", "adjacency matrix CSV (or - for stdin)")
+	outPath := fs.String("out", "", "output .canvas path (or - for stdout). Default: input basename + .canvas")
+	delim := fs.String("delim", "", "field delimiter (default: sniffed from the header)")
+	symmetric := fs.Bool("symmetric", false, "treat the matrix as undirected and read only the upper triangle")
+	var lf layoutFlags
+	registerLayoutFlags(fs, &lf, "auto")
+	fs.Parse(args)
+	if *inPath == "" && fs.NArg() > 0 {
+		*inPath = fs.Arg(0)
+	}
+	if *inPath == "" {
+		fatalf("gen matrix: missing -in")
+	}
+	if err := lf.load(); err != nil {
+		fatalf("gen matrix: %v", err)
+	}
+
+	data, err := readAllInput(*inPath)
+	if err != nil {
+		fatalf("gen matrix: %v", err)
+	}
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	r := csv.NewReader(bytes.NewReader(data))
+	if r.Comma, err = parseDelimiter(*delim, data); err != nil {
+		fatalf("gen matrix: %v", err)
+	}
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		fatalf("gen matrix: %v", err)
+	}
+	c, err := matrixCanvas(rows, *symmetric, lf)
+	if err != nil {
+		fatalf("gen matrix: %v", err)
+	}
+	if err := writeCanvas(genOutPath(*inPath, *outPath), c); err != nil {
+		fatalf("gen matrix: %v", err)
+	}
+}
+
+func matrixCanvas(rows [][]string, symmetric bool, lf layoutFlags) (Canvas, error) {
+	if len(rows) == 0 {
+		return Canvas{}, fmt.Errorf("empty matrix")
+	}
+	b := newCanvasBuilder()
+	header := rows[0]
+	cols := make([]string, len(header))
+	for j := 1; j < len(header); j++ {
+		name := strings.TrimSpace(header[j])
+		if name == "" {
+			return Canvas{}, fmt.Errorf("column %d has no name", j+1)
+		}
+		cols[j] = b.text(name)
+	}
+	for i, row := range rows[1:] {
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		from := b.text(strings.TrimSpace(row[0]))
+		for j := 1; j < len(row) && j < len(header); j++ {
+			if symmetric && j-1 < i {
+				continue
+			}
+			label, ok := matrixCell(row[j])
+			if ok {
+				b.edge(from, cols[j], label)
+			}
+		}
+	}
+	lf.apply(b.c.Nodes, b.c.Edges)
+	return b.c, nil
+}
+
+func matrixCell(s string) (label string, edge bool) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "", "0", "false", "no", "-":
+		return "", false
+	case "1", "x", "true", "yes":
+		return "", true
+	}
+	return s, true
+}
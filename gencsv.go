@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// gen csv: an edge list from a spreadsheet export, taking the source,
+// target and label from whichever columns -map names.
+
+var csvRoles = []string{"source", "target", "label"}
+
+// parseColumnMap parses "source=ColA,target=ColB,label=Relationship".
+func parseColumnMap(spec string) (map[string]string, error) {
+	cols := map[string]string{}
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		role, col, ok := strings.Cut(part, "=")
+		role, col = strings.ToLower(strings.TrimSpace(role)), strings.TrimSpace(col)
+		if !ok || col == "" {
+			return nil, fmt.Errorf("want role=column, got %q", part)
+		}
+		if !slices.Contains(csvRoles, role) {
+			return nil, fmt.Errorf("unknown role %q (want %s)", role, strings.Join(csvRoles, ", "))
+		}
+		cols[role] = col
+	}
+	return cols, nil
+}
+
+// csvColumns resolves the roles to column indexes: a column is a header
+// name (matched case-insensitively) or a 1-based number. Without a map the
+// first three columns are source, target and label.
+func csvColumns(cols map[string]string, header []string) (map[string]int, error) {
+	idx := map[string]int{}
+	if len(cols) == 0 {
+		for i, role := range csvRoles {
+			if i < len(header) || header == nil {
+				idx[role] = i
+			}
+		}
+		return idx, nil
+	}
+	for _, role := range csvRoles {
+		col, ok := cols[role]
+		if !ok {
+			continue
+		}
+		i := -1
+		for j, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), col) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			n, err := strconv.Atoi(col)
+			if err != nil || n < 1 {
+				if header == nil {
+					return nil, fmt.Errorf("%s: with -header=false columns are numbers, got %q", role, col)
+				}
+				return nil, fmt.Errorf("%s: no column %q (have %s)", role, col, strings.Join(header, ", "))
+			}
+			i = n - 1
+		}
+		idx[role] = i
+	}
+	if _, ok := idx["source"]; !ok {
+		return nil, fmt.Errorf("-map needs a source column")
+	}
+	if _, ok := idx["target"]; !ok {
+		return nil, fmt.Errorf("-map needs a target column")
+	}
+	return idx, nil
+}
+
+// csvEdges picks the edges out of rows; rows missing a source or target
+// are skipped and counted.
+func csvEdges(rows [][]string, idx map[string]int) (edges [][3]string, skipped int) {
+	cell := func(row []string, role string) string {
+		i, ok := idx[role]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	for _, row := range rows {
+		e := [3]string{cell(row, "source"), cell(row, "target"), cell(row, "label")}
+		if e[0] == "" || e[1] == "" {
+			skipped++
+			continue
+		}
+		edges = append(edges, e)
+	}
+	return edges, skipped
+}
+
+func runGenCSV(args []string) {
+	fs := flag.NewFlagSet("gen csv", flag.ExitOnError)
+	inPath := fs.String("in", "-", "CSV file with an edge per row (or - for stdin)")
+	outPath := fs.String("out", "", "output .canvas path (or - for stdout). Default: input basename + .canvas")
+	mapping := fs.String("map", "", "columns to read, e.g. \"source=ColA,target=ColB,label=Relationship\"; a column is a header name or 1-based number (default: the first three columns)")
+	delim := fs.String("delim", "", "field delimiter (default: sniffed from the header)")
+	header := fs.Bool("header", true, "the first row names the columns")
+	skip := fs.Int("skip", 0, "rows to skip before the header, e.g. a title row")
+	ignoreCase := fs.Bool("ignore-case", false, "merge node names that differ only in case")
+	var lf layoutFlags
+	registerLayoutFlags(fs, &lf, "layered")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		*inPath = fs.Arg(0)
+	}
+	if err := lf.load(); err != nil {
+		fatalf("gen csv: %v", err)
+	}
+	cols, err := parseColumnMap(*mapping)
+	if err != nil {
+		fatalf("gen csv: -map: %v", err)
+	}
+	data, err := readAllInput(*inPath)
+	if err != nil {
+		fatalf("gen csv: %v", err)
+	}
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	first := data // the header, for sniffing the delimiter
+	for i := 0; i < *skip; i++ {
+		_, first, _ = bytes.Cut(first, []byte("\n"))
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	if r.Comma, err = parseDelimiter(*delim, first); err != nil {
+		fatalf("gen csv: %v", err)
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		fatalf("gen csv: %v", err)
+	}
+	rows = rows[min(*skip, len(rows)):]
+	var names []string
+	if *header && len(rows) > 0 {
+		names, rows = rows[0], rows[1:]
+	}
+	idx, err := csvColumns(cols, names)
+	if err != nil {
+		fatalf("gen csv: %v", err)
+	}
+	edges, skipped := csvEdges(rows, idx)
+	if skipped > 0 {
+		slog.Warn(fmt.Sprintf("gen csv: skipped %d rows without a source or target", skipped))
+	}
+	c, merges := edgeListCanvas(edges, func(s string) string { return s }, sameName(*ignoreCase), lf)
+	reportMerges("gen csv", merges)
+	if err := writeCanvas(genOutPath(*inPath, *outPath), c); err != nil {
+		fatalf("gen csv: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// gen edges / gen gomod: lay out a canvas from an edge list.
+
+// parseEdgeList reads one edge per line: "from to [label ...]". Lines with
+// tabs are split on tabs so names may contain spaces; otherwise on runs of
+// whitespace. Blank lines and # comments are skipped.
+func parseEdgeList(data []byte) ([][3]string, error) {
+	var edges [][3]string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var parts []string
+		if strings.Contains(line, "\t") {
+			parts = strings.SplitN(line, "\t", 3)
+		} else {
+			parts = strings.Fields(line)
+			if len(parts) > 3 {
+				parts = append(parts[:2], strings.Join(parts[2:], " "))
+			}
+		}
+		if len(parts) < 2 {
+			continue
+		}
+		e := [3]string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])}
+		if len(parts) == 3 {
+			e[2] = strings.TrimSpace(parts[2])
+		}
+		edges = append(edges, e)
+	}
+	return edges, sc.Err()
+}
+
+// nameMerge records the spellings merged into the node Name.
+type nameMerge struct {
+	Name     string
+	Variants []string
+}
+
+// sameName returns the key under which import rows name the same node:
+// the name in NFC with runs of whitespace collapsed, case-folded if
+// ignoreCase.
+func sameName(ignoreCase bool) func(string) string {
+	return func(s string) string {
+		s = strings.Join(strings.Fields(normalizeString(s, "nfc")), " ")
+		if ignoreCase {
+			s = strings.ToLower(s)
+		}
+		return s
+	}
+}
+
+// edgeListCanvas builds a canvas from edges, one node per name as key sees
+// it; the first spelling of a name becomes the node text. It returns the
+// merges of differing spellings, in node order.
+func edgeListCanvas(edges [][3]string, rename, key func(string) string, lf layoutFlags) (Canvas, []nameMerge) {
+	b := newCanvasBuilder()
+	seen := map[[3]string]bool{}
+	first := map[string]string{} // key -> first spelling
+	var keys []string
+	variants := map[string][]string{}
+	node := func(name string) string {
+		name = rename(name)
+		k := key(name)
+		if f, ok := first[k]; !ok {
+			first[k] = name
+			keys = append(keys, k)
+		} else if name != f && !slices.Contains(variants[k], name) {
+			variants[k] = append(variants[k], name)
+		}
+		return b.add(k, Node{Type: "text", Text: first[k]})
+	}
+	for _, e := range edges {
+		from, to := node(e[0]), node(e[1])
+		edge := [3]string{from, to, e[2]}
+		if seen[edge] {
+			continue // e.g. several versions collapsed onto one module
+		}
+		seen[edge] = true
+		b.edge(from, to, e[2])
+	}
+	lf.apply(b.c.Nodes, b.c.Edges)
+	var merges []nameMerge
+	for _, k := range keys {
+		if len(variants[k]) > 0 {
+			merges = append(merges, nameMerge{first[k], variants[k]})
+		}
+	}
+	return b.c, merges
+}
+
+// reportMerges logs each merge of differently spelt names.
+func reportMerges(cmd string, merges []nameMerge) {
+	for _, m := range merges {
+		quoted := make([]string, len(m.Variants))
+		for i, v := range m.Variants {
+			quoted[i] = strconv.Quote(v)
+		}
+		slog.Info(fmt.Sprintf("%s: merged %s into %q", cmd, strings.Join(quoted, ", "), m.Name))
+	}
+}
+
+func runGenEdges(args []string) {
+	fs := flag.NewFlagSet("gen edges", flag.ExitOnError)
+	inPath := fs.String("in", "-", "edge list: one \"from to [label]\" per line (or - for stdin)")
+	outPath := fs.String("out", "", "output .canvas path (or - for stdout). Default: input basename + .canvas")
+	ignoreCase := fs.Bool("ignore-case", false, "merge node names that differ only in case")
+	var lf layoutFlags
+	registerLayoutFlags(fs, &lf, "layered")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		*inPath = fs.Arg(0)
+	}
+	if err := lf.load(); err != nil {
+		fatalf("gen edges: %v", err)
+	}
+	data, err := readAllInput(*inPath)
+	if err != nil {
+		fatalf("gen edges: %v", err)
+	}
+	edges, err := parseEdgeList(data)
+	if err != nil {
+		fatalf("gen edges: %v", err)
+	}
+	c, merges := edgeListCanvas(edges, func(s string) string { return s }, sameName(*ignoreCase), lf)
+	reportMerges("gen edges", merges)
+	if err := writeCanvas(genOutPath(*inPath, *outPath), c); err != nil {
+		fatalf("gen edges: %v", err)
+	}
+}
+
+// runGenGomod lays out a Go module dependency graph, read from -in or by
+// running `go mod graph` in -dir.
+func runGenGomod(args []string) {
+	fs := flag.NewFlagSet("gen gomod", flag.ExitOnError)
+	inPath := fs.String("in", "", "saved `go mod graph` output (or - for stdin). Default: run go mod graph in -dir")
+	dir := fs.String("dir", ".", "module directory for go mod graph")
+	outPath := fs.String("out", "", "output .canvas path (or - for stdout). Default: gomod.canvas")
+	stripVersions := fs.Bool("strip-versions", false, "drop @version suffixes, merging versions of a module into one node")
+	var lf layoutFlags
+	registerLayoutFlags(fs, &lf, "layered")
+	fs.Parse(args)
+	if err := lf.load(); err != nil {
+		fatalf("gen gomod: %v", err)
+	}
+
+	var data []byte
+	var err error
+	if *inPath != "" {
+		data, err = readAllInput(*inPath)
+	} else {
+		cmd := exec.Command("go", "mod", "graph")
+		cmd.Dir = *dir
+		data, err = cmd.Output()
+		if ee, ok := err.(*exec.ExitError); ok {
+			fatalf("gen gomod: go mod graph: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+	}
+	if err != nil {
+		fatalf("gen gomod: %v", err)
+	}
+
+	rename := func(s string) string { return s }
+	if *stripVersions {
+		rename = func(s string) string {
+			mod, _, _ := strings.Cut(s, "@")
+			return mod
+		}
+	}
+	edges, err := parseEdgeList(data)
+	if err != nil {
+		fatalf("gen gomod: %v", err)
+	}
+	c, _ := edgeListCanvas(edges, rename, func(s string) string { return s }, lf)
+	out := *outPath
+	if out == "" {
+		out = "gomod.canvas"
+		if *inPath != "" {
+			out = genOutPath(*inPath, "")
+		}
+	}
+	if err := writeCanvas(out, c); err != nil {
+		fatalf("gen gomod: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEdgeList(t *testing.T) {
+	edges, err := parseEdgeList([]byte("# deps\na b\n\nb\tc d\tneeds it\nc d uses  this\nlonely\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][3]string{{"a", "b", ""}, {"b", "c d", "needs it"}, {"c", "d", "uses this"}}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("edges %q, want %q", edges, want)
+	}
+}
+
+// TestParseEdgeListTooLong checks that a line longer than the scanner
+// buffer is reported rather than ending the list early.
+func TestParseEdgeListTooLong(t *testing.T) {
+	data := "a b\n" + strings.Repeat("x", 17*1024*1024) + " y\nc d\n"
+	if _, err := parseEdgeList([]byte(data)); err != bufio.ErrTooLong {
+		t.Errorf("error %v, want %v", err, bufio.ErrTooLong)
+	}
+}
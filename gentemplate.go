@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// gen template: scaffold a brainstorming canvas from a list of cards. Each
+// line of the -nodes file is a card; "\n" in a line breaks the card text.
+// Lines with arrows are edges instead, naming cards that are created if
+// not listed:
+//
+//	Goals
+//	Risks
+//	Goals -> Risks -> Mitigations : informs
+//	Budget <- Goals
+//	Risks -- Budget
+//
+// "->" and "<-" point the way they read and "--" draws a line without an
+// arrow; a chain is linked pairwise and the text after a final ":" labels
+// each of its edges. Blank lines and # comments are skipped.
+
+type templateEdge struct {
+	from, to, label string
+	undirected      bool
+}
+
+var templateArrows = []string{"->", "<-", "--"}
+
+// parseTemplate reads the cards and edges of a template file.
+func parseTemplate(data []byte) (names []string, edges []templateEdge, err error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts, arrows := splitArrows(text)
+		if len(arrows) == 0 {
+			names = append(names, templateText(text))
+			continue
+		}
+		last, label, _ := strings.Cut(parts[len(parts)-1], ":")
+		parts[len(parts)-1] = last
+		for i, p := range parts {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				return nil, nil, fmt.Errorf("line %d: %s with nothing on one side", line, arrows[max(i-1, 0)])
+			}
+			parts[i] = templateText(p)
+		}
+		for i, a := range arrows {
+			e := templateEdge{from: parts[i], to: parts[i+1], label: strings.TrimSpace(label), undirected: a == "--"}
+			if a == "<-" {
+				e.from, e.to = e.to, e.from
+			}
+			edges = append(edges, e)
+		}
+	}
+	return names, edges, sc.Err()
+}
+
+// splitArrows splits text around the arrows in it.
+func splitArrows(text string) (parts, arrows []string) {
+	start := 0
+	for i := 0; i+1 < len(text); i++ {
+		if a := text[i : i+2]; slices.Contains(templateArrows, a) {
+			parts = append(parts, text[start:i])
+			arrows = append(arrows, a)
+			start = i + 2
+			i++
+		}
+	}
+	return append(parts, text[start:]), arrows
+}
+
+func templateText(s string) string {
+	return strings.ReplaceAll(s, `\n`, "\n")
+}
+
+// templateCanvas lays out a card per name, in order of first mention.
+func templateCanvas(names []string, edges []templateEdge, key func(string) string, lf layoutFlags) (Canvas, []nameMerge) {
+	b := newCanvasBuilder()
+	first := map[string]string{}
+	var keys []string
+	variants := map[string][]string{}
+	node := func(name string) string {
+		k := key(name)
+		if f, ok := first[k]; !ok {
+			first[k] = name
+			keys = append(keys, k)
+		} else if name != f && !slices.Contains(variants[k], name) {
+			variants[k] = append(variants[k], name)
+		}
+		return b.add(k, Node{Type: "text", Text: first[k]})
+	}
+	for _, n := range names {
+		node(n)
+	}
+	for _, e := range edges {
+		from, to := node(e.from), node(e.to)
+		b.edge(from, to, e.label)
+		if e.undirected {
+			b.c.Edges[len(b.c.Edges)-1].ToEnd = "none"
+		}
+	}
+	lf.apply(b.c.Nodes, b.c.Edges)
+	var merges []nameMerge
+	for _, k := range keys {
+		if len(variants[k]) > 0 {
+			merges = append(merges, nameMerge{first[k], variants[k]})
+		}
+	}
+	return b.c, merges
+}
+
+func runGenTemplate(args []string) {
+	fs := flag.NewFlagSet("gen template", flag.ExitOnError)
+	nodesPath := fs.String("nodes", "-", "file of cards, one per line, and optional \"a -> b\" edges (or - for stdin)")
+	edgesPath := fs.String("edges", "", "further edges in the same notation, kept apart from the card list")
+	outPath := fs.String("out", "", "output .canvas path (or - for stdout). Default: nodes basename + .canvas")
+	ignoreCase := fs.Bool("ignore-case", false, "merge card names that differ only in case")
+	var lf layoutFlags
+	registerLayoutFlags(fs, &lf, "grid")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		*nodesPath = fs.Arg(0)
+	}
+	if err := lf.load(); err != nil {
+		fatalf("gen template: %v", err)
+	}
+	data, err := readAllInput(*nodesPath)
+	if err != nil {
+		fatalf("gen template: %v", err)
+	}
+	names, edges, err := parseTemplate(data)
+	if err != nil {
+		fatalf("gen template: %s: %v", *nodesPath, err)
+	}
+	if *edgesPath != "" {
+		data, err := readAllInput(*edgesPath)
+		if err != nil {
+			fatalf("gen template: %v", err)
+		}
+		more, moreEdges, err := parseTemplate(data)
+		if err != nil {
+			fatalf("gen template: %s: %v", *edgesPath, err)
+		}
+		names, edges = append(names, more...), append(edges, moreEdges...)
+	}
+	c, merges := templateCanvas(names, edges, sameName(*ignoreCase), lf)
+	reportMerges("gen template", merges)
+	if err := writeCanvas(genOutPath(*nodesPath, *outPath), c); err != nil {
+		fatalf("gen template: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gen tree: map a directory hierarchy onto a canvas.
+
+const (
+	groupPadding = 40
+	groupHeader  = 40 // room for the group label
+	groupCols    = 3  // files per row inside a group
+)
+
+type treeOptions struct {
+	root     string // file node paths are made relative to this
+	hidden   bool
+	maxDepth int
+	exts     map[string]bool
+	layout   layoutFlags // for -folders node
+}
+
+type dirEntry struct {
+	name     string
+	path     string
+	isDir    bool
+	children []*dirEntry
+}
+
+func runGenTree(args []string) {
+	fs := flag.NewFlagSet("gen tree", flag.ExitOnError)
+	inPath := fs.String("in", ".", "directory to map")
+	outPath := fs.String("out", "", "output .canvas path (or - for stdout). Default: directory name + .canvas")
+	folders := fs.String("folders", "node", "render folders as text nodes with containment edges (node) or as nested groups (group)")
+	root := fs.String("root", "", "make file node paths relative to this directory, normally the vault root (default: -in)")
+	hidden := fs.Bool("hidden", false, "include dot files and folders")
+	maxDepth := fs.Int("max-depth", 0, "stop descending below this depth (0 = unlimited)")
+	exts := fs.String("ext", "", "comma-separated file extensions to include, e.g. .md,.canvas (default: all)")
+	var lf layoutFlags
+	registerLayoutFlags(fs, &lf, "tree")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		*inPath = fs.Arg(0)
+	}
+	if *folders != "node" && *folders != "group" {
+		fatalf("gen tree: -folders must be node or group")
+	}
+	if err := lf.load(); err != nil {
+		fatalf("gen tree: %v", err)
+	}
+
+	opts := treeOptions{root: *root, hidden: *hidden, maxDepth: *maxDepth, layout: lf}
+	if opts.root == "" {
+		opts.root = *inPath
+	}
+	if *exts != "" {
+		opts.exts = map[string]bool{}
+		for _, e := range strings.Split(*exts, ",") {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if !strings.HasPrefix(e, ".") {
+				e = "." + e
+			}
+			opts.exts[e] = true
+		}
+	}
+
+	abs, err := filepath.Abs(*inPath)
+	if err != nil {
+		fatalf("gen tree: %v", err)
+	}
+	top, err := walkDir(abs, filepath.Base(abs), 0, opts)
+	if err != nil {
+		fatalf("gen tree: %v", err)
+	}
+
+	var c Canvas
+	if *folders == "group" {
+		c, err = treeGroupsCanvas(top, opts)
+	} else {
+		c, err = treeNodesCanvas(top, opts)
+	}
+	if err != nil {
+		fatalf("gen tree: %v", err)
+	}
+	out := *outPath
+	if out == "" {
+		out = filepath.Base(abs) + ".canvas"
+	}
+	if err := writeCanvas(out, c); err != nil {
+		fatalf("gen tree: %v", err)
+	}
+}
+
+func walkDir(path, name string, depth int, opts treeOptions) (*dirEntry, error) {
+	d := &dirEntry{name: name, path: path, isDir: true}
+	if opts.maxDepth > 0 && depth >= opts.maxDepth {
+		return d, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []*dirEntry
+	for _, e := range entries {
+		if !opts.hidden && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		p := filepath.Join(path, e.Name())
+		switch {
+		case e.IsDir():
+			sub, err := walkDir(p, e.Name(), depth+1, opts)
+			if err != nil {
+				return nil, err
+			}
+			d.children = append(d.children, sub)
+		case e.Type().IsRegular():
+			if opts.exts != nil && !opts.exts[strings.ToLower(filepath.Ext(e.Name()))] {
+				continue
+			}
+			files = append(files, &dirEntry{name: e.Name(), path: p})
+		}
+	}
+	d.children = append(files, d.children...) // files first, then folders
+	return d, nil
+}
+
+// vaultPath returns the file node path for p: relative to root, with
+// forward slashes as Obsidian stores them.
+func (o treeOptions) vaultPath(p string) (string, error) {
+	root, err := filepath.Abs(o.root)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func treeNodesCanvas(top *dirEntry, opts treeOptions) (Canvas, error) {
+	b := newCanvasBuilder()
+	children := map[string][]string{}
+	var visit func(d *dirEntry) (string, error)
+	visit = func(d *dirEntry) (string, error) {
+		if !d.isDir {
+			file, err := opts.vaultPath(d.path)
+			if err != nil {
+				return "", err
+			}
+			return b.add(d.path, Node{Type: "file", File: file}), nil
+		}
+		id := b.add(d.path, Node{Type: "text", Text: d.name + "/"})
+		for _, c := range d.children {
+			cid, err := visit(c)
+			if err != nil {
+				return "", err
+			}
+			b.edge(id, cid, "")
+			children[id] = append(children[id], cid)
+		}
+		return id, nil
+	}
+	rootID, err := visit(top)
+	if err != nil {
+		return Canvas{}, err
+	}
+	if opts.layout.algo == "tree" {
+		opts.layout.style.apply(b.c.Nodes, b.c.Edges)
+		treeLayout(b.c.Nodes, []string{rootID}, children, opts.layout.gap)
+		opts.layout.coords.apply(b.c.Nodes)
+	} else {
+		opts.layout.apply(b.c.Nodes, b.c.Edges)
+	}
+	return b.c, nil
+}
+
+// treeGroupsCanvas nests a group per folder. Files sit in a grid at the top
+// of their folder's group, subfolders are stacked below.
+func treeGroupsCanvas(top *dirEntry, opts treeOptions) (Canvas, error) {
+	b := newCanvasBuilder()
+	var place func(d *dirEntry, x, y float64) (w, h float64, err error)
+	place = func(d *dirEntry, x, y float64) (float64, float64, error) {
+		gi := len(b.c.Nodes)
+		b.add(d.path, Node{Type: "group", Label: d.name})
+
+		cx, cy := x+groupPadding, y+groupHeader
+		width := float64(nodeWidth)
+		col, rowH := 0, 0.0
+		for _, c := range d.children {
+			if c.isDir {
+				continue
+			}
+			file, err := opts.vaultPath(c.path)
+			if err != nil {
+				return 0, 0, err
+			}
+			b.add(c.path, Node{Type: "file", File: file,
+				X: cx + float64(col*(nodeWidth+nodeGapX/2)), Y: cy, Width: nodeWidth, Height: nodeHeight})
+			width = max(width, float64((col+1)*nodeWidth+col*nodeGapX/2))
+			rowH = nodeHeight + nodeGapY/2
+			if col++; col == groupCols {
+				col, cy, rowH = 0, cy+rowH, 0
+			}
+		}
+		cy += rowH
+		for _, c := range d.children {
+			if !c.isDir {
+				continue
+			}
+			w, h, err := place(c, cx, cy)
+			if err != nil {
+				return 0, 0, err
+			}
+			width = max(width, w)
+			cy += h + nodeGapY/2
+		}
+		g := &b.c.Nodes[gi]
+		g.X, g.Y = x, y
+		g.Width = width + 2*groupPadding
+		g.Height = max(cy-y+groupPadding/2, groupHeader+nodeHeight)
+		return g.Width, g.Height, nil
+	}
+	if _, _, err := place(top, 0, 0); err != nil {
+		return Canvas{}, fmt.Errorf("layout: %v", err)
+	}
+	return b.c, nil
+}
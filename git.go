@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// readGitRevision returns the content of path as of rev (anything git
+// rev-parse accepts, e.g. HEAD~5 or a tag), read from the object database
+// with git show so the working tree is left alone.
+func readGitRevision(ctx context.Context, rev, path string) ([]byte, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "show", rev+":./"+base)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git show %s:%s: %s", rev, path, msg)
+		}
+		return nil, fmt.Errorf("git show %s:%s: %v", rev, path, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// loadCanvasAt is loadCanvas reading from a git revision when rev is set.
+func loadCanvasAt(path, rev string) (Canvas, error) {
+	if rev == "" {
+		return loadCanvas(path)
+	}
+	data, err := readGitRevision(context.Background(), rev, path)
+	if err != nil {
+		return Canvas{}, err
+	}
+	c, err := parseCanvas(data)
+	if err != nil {
+		return Canvas{}, fmt.Errorf("parse %s at %s: %v", path, rev, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sort"
+)
+
+// graph is the resolved view of a canvas that exporters work from: every node
+// has its display name computed once, and edges carry their effective label.
+type graph struct {
+	Nodes []graphNode
+	Edges []graphEdge
+
+	// attrs names the extra per-node attributes (frontmatter keys, computed
+	// fields), in the order exporters emit them as columns.
+	attrs     []string
+	edgeAttrs []string    // likewise for edges
+	meta      []metaField // canvas-level fields, for graph-level output
+
+	byID map[string]int
+	out  map[string][]int // node ID -> indexes into Edges
+	in   map[string][]int
+}
+
+type graphNode struct {
+	ID    string
+	Type  string
+	Name  string
+	Node  Node
+	Attrs map[string]string
+}
+
+type graphEdge struct {
+	From  string
+	To    string
+	Label string
+	Edge  Edge // the canvas edge; zero for edges the tool adds
+	Attrs map[string]string
+}
+
+func buildGraph(c Canvas, keepPath bool) *graph {
+	g := &graph{meta: metaFields(c.Meta)}
+	for _, n := range c.Nodes {
+		g.Nodes = append(g.Nodes, graphNode{
+			ID:   n.ID,
+			Type: n.Type,
+			Name: singleLine(nodeDisplay(n, keepPath)),
+			Node: n,
+		})
+	}
+	for _, e := range c.Edges {
+		label := e.Label
+		if label == "" {
+			label = e.Text
+		}
+		g.Edges = append(g.Edges, graphEdge{From: e.FromNode, To: e.ToNode, Label: singleLine(label), Edge: e})
+	}
+	g.index()
+	return g
+}
+
+// index rebuilds the lookup tables after Nodes or Edges change.
+func (g *graph) index() {
+	g.byID = make(map[string]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		g.byID[n.ID] = i
+	}
+	g.out = make(map[string][]int)
+	g.in = make(map[string][]int)
+	for i, e := range g.Edges {
+		g.out[e.From] = append(g.out[e.From], i)
+		g.in[e.To] = append(g.in[e.To], i)
+	}
+}
+
+// mergeNodes folds each node in into (node ID -> surviving ID) into the
+// survivor: their edges are rewired to it and the nodes removed.
+func (g *graph) mergeNodes(into map[string]string) {
+	if len(into) == 0 {
+		return
+	}
+	nodes := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if _, ok := into[n.ID]; !ok {
+			nodes = append(nodes, n)
+		}
+	}
+	g.Nodes = nodes
+	for i, e := range g.Edges {
+		if id, ok := into[e.From]; ok {
+			g.Edges[i].From = id
+		}
+		if id, ok := into[e.To]; ok {
+			g.Edges[i].To = id
+		}
+	}
+	g.index()
+}
+
+// setAttr sets an extra attribute on the i'th node, registering the key as a
+// column the first time it is seen.
+func (g *graph) setAttr(i int, key, value string) {
+	if !slices.Contains(g.attrs, key) {
+		g.attrs = append(g.attrs, key)
+	}
+	if g.Nodes[i].Attrs == nil {
+		g.Nodes[i].Attrs = map[string]string{}
+	}
+	g.Nodes[i].Attrs[key] = value
+}
+
+// setEdgeAttr is setAttr for the i'th edge.
+func (g *graph) setEdgeAttr(i int, key, value string) {
+	if !slices.Contains(g.edgeAttrs, key) {
+		g.edgeAttrs = append(g.edgeAttrs, key)
+	}
+	if g.Edges[i].Attrs == nil {
+		g.Edges[i].Attrs = map[string]string{}
+	}
+	g.Edges[i].Attrs[key] = value
+}
+
+// node returns the node with the given ID, if the canvas defines it.
+func (g *graph) node(id string) (graphNode, bool) {
+	i, ok := g.byID[id]
+	if !ok {
+		return graphNode{}, false
+	}
+	return g.Nodes[i], true
+}
+
+// name returns the display name of a node, or "" for dangling references.
+func (g *graph) name(id string) string {
+	n, _ := g.node(id)
+	return n.Name
+}
+
+// neighbors returns the IDs reachable from id within depth hops, in BFS
+// order, excluding id itself. dir is "out", "in" or "both".
+func (g *graph) neighbors(id string, depth int, dir string) []string {
+	seen := map[string]bool{id: true}
+	frontier := []string{id}
+	var found []string
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []string
+		visit := func(other string) {
+			if !seen[other] {
+				seen[other] = true
+				found = append(found, other)
+				next = append(next, other)
+			}
+		}
+		for _, cur := range frontier {
+			if dir != "in" {
+				for _, ei := range g.out[cur] {
+					visit(g.Edges[ei].To)
+				}
+			}
+			if dir != "out" {
+				for _, ei := range g.in[cur] {
+					visit(g.Edges[ei].From)
+				}
+			}
+		}
+		frontier = next
+	}
+	return found
+}
+
+// around returns the subgraph within depth hops, either direction, of
+// the nodes m matches, or an error if it matches none.
+func (g *graph) around(m *nodeMatcher, depth int) (*graph, error) {
+	keep := map[string]bool{}
+	for _, n := range g.Nodes {
+		if !m.match(n) {
+			continue
+		}
+		keep[n.ID] = true
+		for _, id := range g.neighbors(n.ID, depth, "both") {
+			keep[id] = true
+		}
+	}
+	if len(keep) == 0 {
+		return nil, errors.New("no node matches")
+	}
+	return g.subgraph(keep), nil
+}
+
+// subgraph returns the nodes in keep and the edges between them.
+func (g *graph) subgraph(keep map[string]bool) *graph {
+	sub := &graph{attrs: g.attrs, edgeAttrs: g.edgeAttrs, meta: g.meta}
+	for _, n := range g.Nodes {
+		if keep[n.ID] {
+			sub.Nodes = append(sub.Nodes, n)
+		}
+	}
+	for _, e := range g.Edges {
+		if keep[e.From] && keep[e.To] {
+			sub.Edges = append(sub.Edges, e)
+		}
+	}
+	sub.index()
+	return sub
+}
+
+// components splits g into its weakly connected components, largest first
+// and otherwise in canvas order. Groups without edges belong to none.
+func (g *graph) components() []*graph {
+	seen := map[string]bool{}
+	var sets []map[string]bool
+	for _, n := range g.Nodes {
+		if seen[n.ID] || n.Type == "group" && len(g.out[n.ID])+len(g.in[n.ID]) == 0 {
+			continue
+		}
+		set := map[string]bool{n.ID: true}
+		for _, id := range g.neighbors(n.ID, len(g.Nodes), "both") {
+			set[id] = true
+		}
+		for id := range set {
+			seen[id] = true
+		}
+		sets = append(sets, set)
+	}
+	sort.SliceStable(sets, func(i, j int) bool { return len(sets[i]) > len(sets[j]) })
+	parts := make([]*graph, len(sets))
+	for i, set := range sets {
+		parts[i] = g.subgraph(set)
+	}
+	return parts
+}
@@ -2,123 +2,581 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type Canvas struct {
 	Nodes []Node `json:"nodes"`
 	Edges []Edge `json:"edges"`
+
+	// Meta holds the other top-level fields, e.g. "background" or a
+	// plugin's "metadata", as raw JSON in file order.
+	Meta jsonObject `json:"-"`
 }
 
 type Node struct {
-	ID    string `json:"id"`
-	Type  string `json:"type"`
-	Text  string `json:"text"`
-	File  string `json:"file"`
-	URL   string `json:"url"`
-	Label string `json:"label"`
+	ID     string  `json:"id"`
+	Type   string  `json:"type"`
+	Text   string  `json:"text,omitempty"`
+	File   string  `json:"file,omitempty"`
+	URL    string  `json:"url,omitempty"`
+	Label  string  `json:"label,omitempty"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Color  string  `json:"color,omitempty"`
+
+	Subpath         string `json:"subpath,omitempty"`         // file nodes
+	Background      string `json:"background,omitempty"`      // group nodes
+	BackgroundStyle string `json:"backgroundStyle,omitempty"` // group nodes
 }
 
 type Edge struct {
+	ID       string `json:"id,omitempty"`
 	FromNode string `json:"fromNode"`
 	ToNode   string `json:"toNode"`
-	Label    string `json:"label"`
-	Text     string `json:"text"` // some exports use "text" instead of "label"
+	Label    string `json:"label,omitempty"`
+	Text     string `json:"text,omitempty"` // some exports use "text" instead of "label"
+	Color    string `json:"color,omitempty"`
+	FromSide string `json:"fromSide,omitempty"` // top, right, bottom or left
+	ToSide   string `json:"toSide,omitempty"`
+	FromEnd  string `json:"fromEnd,omitempty"` // none or arrow
+	ToEnd    string `json:"toEnd,omitempty"`
+}
+
+// subcommands are dispatched on the first argument; anything else is a
+// plain conversion driven by the top-level flags.
+var subcommands = map[string]func(args []string){
+	"allpaths":    runAllPaths,
+	"backlinks":   runBacklinks,
+	"bench":       runBench,
+	"check":       runCheck,
+	"cluster":     runCluster,
+	"compare":     runCompare,
+	"completion":  runCompletion,
+	"corpus":      runCorpus,
+	"daemon":      runDaemon,
+	"describe":    runDescribe,
+	"diff":        runDiff,
+	"docs":        runDocs,
+	"embed":       runEmbed,
+	"explore":     runExplore,
+	"exporttest":  runExportTest,
+	"gen":         runGen,
+	"history":     runHistory,
+	"layout":      runLayout,
+	"merge":       runMerge,
+	"plugins":     runPlugins,
+	"reach":       runReach,
+	"rename":      runRename,
+	"search":      runSearch,
+	"serve":       runServe,
+	"snapshot":    runSnapshot,
+	"split":       runSplit,
+	"tasks":       runTasks,
+	"tidy":        runTidy,
+	"validate":    runValidate,
+	"vault-stats": runVaultStats,
+	"version":     runVersion,
 }
 
 func main() {
-	inPath := flag.String("in", "", "input .canvas path (or - for stdin)")
-	outPath := flag.String("out", "", "output .csv path (or - for stdout). Default: input basename + .csv")
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
+
+	inFlag := flag.String("in", "", "input .canvas path (or - for stdin, clipboard for the system clipboard); further inputs and globs may follow as arguments")
+	var outPaths stringsFlag
+	flag.Var(&outPaths, "out", "output `path` (or - for stdout, clipboard for the system clipboard). Default: input basename + format extension. Repeat to write several files from one parse, each in the format its extension names")
+	format := flag.String("format", "csv", "output format: "+strings.Join(exporterNames(), ", "))
+	nameTemplate := flag.String("name-template", "", "output file name template for -outdir and templated -out paths, with {{.Basename}}, {{.Format}}, {{.Ext}} and {{.Component}} (default \"{{.Basename}}.{{.Ext}}\")")
+	splitComponents := flag.Bool("split-components", false, "write each connected component to its own output, numbered largest first as {{.Component}}")
+	formatList := flag.String("formats", "", "comma-separated `formats` to write in one run, each to -outdir as input basename + extension")
+	outDir := flag.String("outdir", "", "directory for outputs named after the input (default: current directory)")
 	keepPath := flag.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	pathStyle := flag.String("path-style", "", "how file nodes are named: base, relative (to -vault), absolute or posix; overrides -keep-path")
+	neo4jBatch := flag.Int("neo4j-batch", 1000, "rows per transaction when -out is a neo4j:// or bolt:// URL")
+	gitRev := flag.String("git-rev", "", "read -in as of this git `revision` (e.g. HEAD~5) instead of the working tree")
+	vault := flag.String("vault", "", "Obsidian vault directory that file node paths are relative to")
+	frontmatterKeys := flag.String("frontmatter", "", "comma-separated frontmatter `keys` of -vault notes to export as node attributes (e.g. tags,status,due)")
+	obsidianURIs := flag.Bool("obsidian-uri", false, "add obsidian://open links for file nodes (obsidian_uri) and the canvas itself (canvas_uri) using -vault")
+	vaultName := flag.String("vault-name", "", "vault name for -obsidian-uri (default: base name of -vault)")
+	tagEdges := flag.Bool("tag-edges", false, "add virtual edges, labelled #tag, between -vault notes that share a tag")
+	provMode := flag.String("provenance", "", "record the source canvas, its sha256, tool version and time in each output: `mode` comment (a header in dot, graphml and csv; a sidecar otherwise) or sidecar (<out>.meta.json)")
+	since := flag.String("since", "", "keep only edges touching a -vault note modified within this `age` (30d, 2w, 36h) or since a date")
+	onlyGroup := flag.String("only-group", "", "export only the nodes inside the group with this `name` (or ID)")
+	externalEdges := flag.Bool("external-edges", false, "with -only-group, keep edges crossing the group boundary, marked external")
+	idHash := flag.Bool("id-hash", false, "replace the canvas's random node IDs with hashes of the node content, stable across re-saves")
+	edgeFields := flag.String("edge-attrs", "", "comma-separated canvas edge `fields` to export as edge attributes: id, color, from_side, to_side, from_end, to_end, or all")
+	edgeKind := flag.Bool("edge-kind", false, "add an edge_kind column derived from the endpoint types (text->file, file->link, ...)")
+	includeKinds := flag.String("include-kinds", "", "comma-separated edge `kinds` to keep, e.g. file->file,text->* (default: all)")
+	excludeKinds := flag.String("exclude-kinds", "", "comma-separated edge `kinds` to drop")
+	selfLoops := flag.String("self-loops", "keep", "edges from a node to itself: keep, drop or error")
+	normalize := flag.String("normalize", "nfc", "Unicode `form` node names and edge labels are compared and written in: "+strings.Join(normForms, ", "))
+	stream := flag.Bool("stream", false, "write -format csv while reading each canvas, without building the graph, for canvases too big for memory; only -keep-path, -normalize and -opt apply")
+	mergeCase := flag.Bool("merge-case-insensitive", false, "treat node names differing only in case as one node, merged into the first spelling")
+	aliasPath := flag.String("aliases", "", "`file` of \"alias -> canonical\" node names; aliased nodes are renamed and merged")
+	excludePath := flag.String("exclude-nodes", "", "`file` of node IDs, names or /regexps/ to remove, with their edges, before export")
+	aroundPath := flag.String("around", "", "export only the neighbourhoods of the nodes listed in this `file` (IDs, names or /regexps/, as for -exclude-nodes)")
+	depth := flag.Int("depth", 1, "with -around, how many hops from the listed nodes to include")
+	minDegree := flag.Int("min-degree", 0, "repeatedly drop nodes with fewer than `N` distinct neighbours (groups are kept)")
+	var pruneLeaves roundsFlag
+	flag.Var(&pruneLeaves, "prune-leaves", "drop nodes with at most one neighbour; -prune-leaves=`k` repeats k times, peeling k layers")
+	translatePath := flag.String("translate", "", "dictionary `file` of \"label -> translation\" lines applied to edge labels")
+	translateCmd := flag.String("translate-cmd", "", "`command` translating the edge labels -translate misses that don't look English: reads lang<TAB>label lines, writes one translation per line")
+	labelLang := flag.Bool("label-lang", false, "add a label_lang edge attribute with the guessed language of each label")
+	splitLabelOn := flag.String("split-label-on", "", "split edge labels on this `separator` (e.g. \"/\") into one edge per segment")
+	collapseBidi := flag.Bool("collapse-bidirectional", false, "merge A->B and B->A edges with the same label into one edge marked bidirectional (dir=both in dot)")
+	parallel := flag.String("parallel", "keep", "several edges between the same nodes: keep, merge-labels or count")
+	bundle := flag.String("bundle", "", "aggregate edges between `groups` or clusters into summary edges with a count")
+	bundleMode := flag.String("bundle-mode", "replace", "with -bundle: replace the graph with the bundles, or add the summary edges to it")
+	pluginDir := flag.String("plugins", defaultPluginDir(), "directory holding export-<format> and transform-<name> plugin executables")
+	var transforms, pluginOpts, fieldDefs, displayDefs, stepDefs stringsFlag
+	flag.Var(&displayDefs, "display", "name nodes of a type with a template, `type=template`, e.g. \"file={{basename}} ({{ext}})\", \"link={{host}}\", \"text={{firstline}}\" (repeatable)")
+	flag.Var(&fieldDefs, "field", "computed node field `name=expr` (e.g. degree_out, \"words=word_count of text\", \"group name\"), emitted as a node attribute (repeatable)")
+	flag.Var(&stepDefs, "step", "graph transformation `step` to run before export, in order: filter:LIST, filter:!LIST, dedupe[:count], contract-groups, reverse, map-labels:FILE, plugin:NAME or script:FILE (repeatable)")
+	flag.Var(&transforms, "transform", "run transform plugin `name` before export (repeatable)")
+	flag.Var(&pluginOpts, "plugin-opt", "`key=value` passed to plugins in the request options (repeatable)")
+	var opts exportOptions
+	registerExportFlags(flag.CommandLine, &opts)
+	var coords coordTransform
+	registerCoordFlags(flag.CommandLine, &coords)
+	configPath := flag.String("config", "", "JSON file of default flag values keyed by flag name")
+	var limits parseLimits
+	registerLimitFlags(flag.CommandLine, &limits, parseLimits{})
+	cachePath := flag.String("cache", "", "remember outputs in this `file` and skip inputs unchanged since the last run")
+	force := flag.Bool("force", false, "with -cache, rewrite every output even if its input is unchanged")
+	timeout := flag.Duration("timeout", 0, "give up after this long, e.g. 30s (default: no limit)")
+	logOpts := registerLogFlags(flag.CommandLine, "plain")
 	flag.Parse()
+	if *configPath != "" {
+		if err := applyConfig(flag.CommandLine, *configPath); err != nil {
+			fatalf("-config: %v", err)
+		}
+	}
+	if err := logOpts.setup(); err != nil {
+		fatalf("%v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, *timeout, fmt.Errorf("timed out after %s", *timeout))
+		defer cancel()
+	}
 
-	if *inPath == "" && flag.NArg() > 0 {
-		*inPath = flag.Arg(0)
+	var inputs []string
+	if *inFlag != "" {
+		inputs = append(inputs, *inFlag)
+	}
+	more, err := expandInputs(flag.Args())
+	if err != nil {
+		fatalf("%v", err)
 	}
-	if *inPath == "" {
+	if inputs = append(inputs, more...); len(inputs) == 0 {
 		fatalf("missing -in (or first arg)")
 	}
-
-	if *outPath == "" {
-		if *inPath == "-" {
-			*outPath = "-"
-		} else {
-			base := strings.TrimSuffix(filepath.Base(*inPath), filepath.Ext(*inPath))
-			*outPath = base + ".csv"
-		}
+	pluginSettings, err := pluginOpts.keyValues()
+	if err != nil {
+		fatalf("-plugin-opt: %v", err)
 	}
-
-	in, closeIn, err := openIn(*inPath)
+	displayTemplates, err := parseDisplayTemplates(displayDefs)
 	if err != nil {
-		fatalf("open input: %v", err)
+		fatalf("-display: %v", err)
 	}
-	defer closeIn()
-
-	data, err := io.ReadAll(in)
+	var aliases map[string]string
+	if *aliasPath != "" {
+		if aliases, err = readAliases(*aliasPath); err != nil {
+			fatalf("-aliases: %v", err)
+		}
+	}
+	if err := coords.check(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := checkNormForm(*normalize); err != nil {
+		fatalf("-normalize: %v", err)
+	}
+	var translations map[string]string
+	if *translatePath != "" {
+		if translations, err = readTranslations(*translatePath); err != nil {
+			fatalf("-translate: %v", err)
+		}
+	}
+	var stopNodes graphStep
+	if *excludePath != "" {
+		m, err := readNodeMatcher(*excludePath)
+		if err != nil {
+			fatalf("-exclude-nodes: %v", err)
+		}
+		stopNodes = filterStep{m: m, drop: true}
+	}
+	var steps []graphStep
+	for _, def := range stepDefs {
+		s, err := parseStep(def, *pluginDir, pluginSettings)
+		if err != nil {
+			fatalf("-step: %v", err)
+		}
+		steps = append(steps, s)
+	}
+	for _, name := range transforms {
+		s, err := parseStep("plugin:"+name, *pluginDir, pluginSettings)
+		if err != nil {
+			fatalf("-transform: %v", err)
+		}
+		steps = append(steps, s)
+	}
+	var parallelStep graphStep
+	switch *parallel {
+	case "keep":
+	case "merge-labels", "count":
+		parallelStep = dedupeStep{*parallel}
+	default:
+		fatalf("-parallel: unknown policy %q (want keep, merge-labels or count)", *parallel)
+	}
+	var focus *nodeMatcher
+	if *aroundPath != "" {
+		if focus, err = readNodeMatcher(*aroundPath); err != nil {
+			fatalf("-around: %v", err)
+		}
+	}
+	var fields []computedField
+	for _, def := range fieldDefs {
+		f, err := parseComputedField(def)
+		if err != nil {
+			fatalf("-field: %v", err)
+		}
+		fields = append(fields, f)
+	}
+	names, err := newOutputNamer(*nameTemplate, *outDir, *splitComponents)
 	if err != nil {
-		fatalf("read input: %v", err)
+		fatalf("-name-template: %v", err)
+	}
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			fatalf("-outdir: %v", err)
+		}
+	}
+	if *provMode != "" && !slices.Contains(provenanceModes, *provMode) {
+		fatalf("-provenance: unknown mode %q (want comment or sidecar)", *provMode)
+	}
+	var cache *exportCache
+	var settings []string
+	if *cachePath != "" {
+		if cache, err = loadExportCache(*cachePath); err != nil {
+			fatalf("-cache: %v", err)
+		}
+		more, err := exportFingerprints(func(name string) []string {
+			f := flag.Lookup(name)
+			if sf, ok := f.Value.(*stringsFlag); ok {
+				return *sf
+			}
+			if v := f.Value.String(); v != "" {
+				return []string{v}
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, errUncacheable):
+			slog.Warn("-cache is ignored: " + err.Error())
+			cache = nil
+		case err != nil:
+			fatalf("-cache: %v", err)
+		}
+		settings = append(flagSettings(flag.CommandLine, "cache", "force", "in", "config", "log-format", "log-level", "timeout"), more...)
+		if *since != "" {
+			slog.Warn("-cache is ignored with -since, whose cutoff moves every run")
+			cache = nil
+		}
 	}
-	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}) // optional UTF-8 BOM
+	if *stream {
+		flag.Visit(func(f *flag.Flag) {
+			if !slices.Contains(streamFlags, f.Name) {
+				fatalf("-stream can't be combined with -%s", f.Name)
+			}
+		})
+		for _, inPath := range inputs {
+			targets, err := outputTargets(ctx, names.bind(inPath, ""), outPaths, *format, nil, *pluginDir, pluginSettings)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			if err := streamTargets(ctx, inPath, targets, *keepPath, *normalize, opts); err != nil {
+				fatalf("%v", err)
+			}
+		}
+		return
+	}
+	written := map[string]string{} // output path -> input, to catch collisions
 
-	var c Canvas
-	dec := json.NewDecoder(bytes.NewReader(data))
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&c); err != nil {
-		// fall back to lenient decode (Obsidian may add fields)
-		if err2 := json.Unmarshal(data, &c); err2 != nil {
+	for _, inPath := range inputs {
+		var data []byte
+		if *gitRev != "" {
+			if data, err = readGitRevision(ctx, *gitRev, inPath); err != nil {
+				fatalf("%v", err)
+			}
+		} else {
+			in, closeIn, err := openIn(inPath)
+			if err != nil {
+				fatalf("open input: %v", err)
+			}
+			data, err = io.ReadAll(in)
+			closeIn()
+			if err != nil {
+				fatalf("read input: %v", err)
+			}
+		}
+		cacheName, key := "", ""
+		if cache != nil && inPath != "-" && inPath != clipboardPath {
+			cacheName, _ = filepath.Abs(inPath)
+			key = cacheKey(data, settings...)
+			if !*force && cache.fresh(cacheName, key) {
+				slog.Debug("unchanged, skipped", "in", inPath)
+				continue
+			}
+		}
+		c, err := parseCanvasLimits(ctx, data, limits)
+		if err != nil {
 			fatalf("parse .canvas JSON: %v", err)
 		}
-	}
 
-	nodeByID := make(map[string]Node, len(c.Nodes))
-	for _, n := range c.Nodes {
-		nodeByID[n.ID] = n
+		g := buildGraph(c, *keepPath)
+		normalizeGraph(g, *normalize)
+		slog.Debug("parsed canvas", "in", inPath, "nodes", len(g.Nodes), "edges", len(g.Edges))
+		if *pathStyle != "" {
+			if err := applyPathStyle(g, *pathStyle, *vault); err != nil {
+				fatalf("-path-style: %v", err)
+			}
+		}
+		if *frontmatterKeys != "" {
+			if *vault == "" {
+				fatalf("-frontmatter needs -vault")
+			}
+			if err := joinFrontmatter(g, *vault, splitList(*frontmatterKeys)); err != nil {
+				fatalf("frontmatter: %v", err)
+			}
+		}
+		if *obsidianURIs {
+			if *vault == "" {
+				fatalf("-obsidian-uri needs -vault")
+			}
+			addObsidianURIs(g, *vault, *vaultName, inPath)
+		}
+		if *onlyGroup != "" {
+			if err := sliceGroup(g, *onlyGroup, *externalEdges); err != nil {
+				fatalf("-only-group: %v", err)
+			}
+		}
+		applyDisplayTemplates(g, displayTemplates)
+		if aliases != nil {
+			applyAliases(g, aliases)
+		}
+		if *mergeCase {
+			if n := mergeCaseInsensitive(g); n > 0 {
+				slog.Info(fmt.Sprintf("%s: merged %d nodes differing only in case", inPath, n))
+			}
+		}
+		if stopNodes != nil {
+			g, _ = stopNodes.apply(ctx, g)
+		}
+		if focus != nil {
+			if g, err = g.around(focus, *depth); err != nil {
+				fatalf("-around: %s: %v", inPath, err)
+			}
+		}
+		if *minDegree > 0 {
+			pruneBelow(g, *minDegree, -1)
+		}
+		if pruneLeaves > 0 {
+			pruneBelow(g, 2, int(pruneLeaves))
+		}
+		if *tagEdges {
+			if *vault == "" {
+				fatalf("-tag-edges needs -vault")
+			}
+			if err := addTagEdges(g, *vault); err != nil {
+				fatalf("tag edges: %v", err)
+			}
+		}
+		if *since != "" {
+			if *vault == "" {
+				fatalf("-since needs -vault")
+			}
+			cutoff, err := parseSince(*since, time.Now())
+			if err != nil {
+				fatalf("-since: %v", err)
+			}
+			filterRecent(g, *vault, cutoff)
+		}
+		if g, err = runSteps(ctx, g, steps); err != nil {
+			fatalf("%v", err)
+		}
+
+		if *splitLabelOn != "" {
+			splitLabels(g, *splitLabelOn)
+		}
+		if *labelLang {
+			detectLabelLanguages(g)
+		}
+		if translations != nil || *translateCmd != "" {
+			n, err := translateLabels(ctx, g, translations, *translateCmd)
+			if err != nil {
+				fatalf("-translate-cmd: %v", err)
+			}
+			slog.Info(fmt.Sprintf("%s: translated %d edge labels", inPath, n))
+		}
+		if *includeKinds != "" || *excludeKinds != "" {
+			filterEdgeKinds(g, splitList(*includeKinds), splitList(*excludeKinds))
+		}
+		if err := applySelfLoopPolicy(g, *selfLoops); err != nil {
+			fatalf("-self-loops: %v", err)
+		}
+		if *collapseBidi {
+			collapseBidirectional(g)
+		}
+		if parallelStep != nil {
+			g, _ = parallelStep.apply(ctx, g)
+		}
+		if *bundle != "" {
+			if err := bundleEdges(g, *bundle, *bundleMode); err != nil {
+				fatalf("-bundle: %v", err)
+			}
+		}
+		if *edgeFields != "" {
+			if err := addEdgeFields(g, splitList(*edgeFields)); err != nil {
+				fatalf("-edge-attrs: %v", err)
+			}
+		}
+		if *edgeKind {
+			addEdgeKinds(g)
+		}
+		coords.applyGraph(g)
+		applyComputedFields(g, fields)
+		if *idHash {
+			hashNodeIDs(g)
+		}
+		if err := context.Cause(ctx); err != nil {
+			fatalf("%v", err)
+		}
+
+		var outputs []string
+		parts := []*graph{g}
+		if *splitComponents {
+			parts = g.components()
+		}
+		for i, part := range parts {
+			component := ""
+			if *splitComponents {
+				component = strconv.Itoa(i + 1)
+			}
+			targets, err := outputTargets(ctx, names.bind(inPath, component), outPaths, *format, splitList(*formatList), *pluginDir, pluginSettings)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			for _, t := range targets {
+				if prev, ok := written[t.path]; ok && t.path != "-" {
+					fatalf("%s and %s would both be written to %s (use -outdir with a -name-template)", prev, inPath, t.path)
+				}
+				written[t.path] = inPath
+				if *provMode != "" {
+					t.prov, t.provMode = newProvenance(inPath, data, time.Now()), *provMode
+				}
+				if err := t.emit(ctx, part, opts, *neo4jBatch); err != nil {
+					fatalf("%v", err)
+				}
+				outputs = append(outputs, t.path)
+				slog.Debug("wrote output", "in", inPath, "out", t.path, "format", t.format, "nodes", len(part.Nodes), "edges", len(part.Edges))
+			}
+		}
+		if cacheName != "" {
+			cache.record(cacheName, key, outputs)
+			if err := cache.save(); err != nil {
+				fatalf("-cache: %v", err)
+			}
+		}
 	}
+}
 
-	out, closeOut, err := openOut(*outPath)
+func writeCSV(out io.Writer, g *graph, opts exportOptions) error {
+	w, err := newCSVWriter(out, opts, g.edgeAttrs)
 	if err != nil {
-		fatalf("open output: %v", err)
+		return err
 	}
-	defer func() {
-		if err := closeOut(); err != nil {
-			fatalf("close output: %v", err)
+	for _, e := range g.Edges {
+		row := []string{g.name(e.From), e.Label, g.name(e.To)}
+		for _, a := range g.edgeAttrs { // opt-in extras such as edge_kind
+			row = append(row, e.Attrs[a])
+		}
+		if err := w.Write(row); err != nil {
+			return err
 		}
-	}()
+	}
+
+	w.Flush()
+	return w.Error()
+}
 
+// newCSVWriter returns a writer set up by the csv -opt settings, with the
+// header row already written if csv.header asks for one.
+func newCSVWriter(out io.Writer, opts exportOptions, attrs []string) (*csv.Writer, error) {
 	w := csv.NewWriter(out)
 	w.Comma = ';'
 	w.UseCRLF = false
-
-	for _, e := range c.Edges {
-		from := nodeDisplay(nodeByID[e.FromNode], *keepPath)
-		to := nodeDisplay(nodeByID[e.ToNode], *keepPath)
-
-		label := e.Label
-		if label == "" {
-			label = e.Text
+	if d := opts.opt("csv", "delim", ";"); d != ";" && d != "" {
+		r, err := parseDelimiter(d, nil)
+		if err != nil {
+			return nil, fmt.Errorf("-opt csv.delim: %v", err)
 		}
-
-		from = singleLine(from)
-		label = singleLine(label)
-		to = singleLine(to)
-
-		if err := w.Write([]string{from, label, to}); err != nil {
-			fatalf("write csv: %v", err)
+		w.Comma = r
+	}
+	header, err := opts.optBool("csv", "header", false)
+	if err != nil {
+		return nil, err
+	}
+	if header {
+		if err := w.Write(append([]string{"source", "label", "target"}, attrs...)); err != nil {
+			return nil, err
 		}
 	}
+	return w, nil
+}
 
-	w.Flush()
-	if err := w.Error(); err != nil {
-		fatalf("flush csv: %v", err)
+func parseCanvas(data []byte) (Canvas, error) {
+	return parseCanvasContext(context.Background(), data)
+}
+
+// loadCanvas reads and parses a canvas from a path accepted by -in.
+func loadCanvas(path string) (Canvas, error) {
+	in, closeIn, err := openIn(path)
+	if err != nil {
+		return Canvas{}, err
+	}
+	defer closeIn()
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return Canvas{}, err
+	}
+	c, err := parseCanvas(data)
+	if err != nil {
+		return Canvas{}, fmt.Errorf("parse %s: %v", path, err)
 	}
+	return c, nil
 }
 
 func nodeDisplay(n Node, keepPath bool) string {
@@ -134,7 +592,7 @@ func nodeDisplay(n Node, keepPath bool) string {
 		if keepPath {
 			return n.File
 		}
-		return filepath.Base(n.File)
+		return fileBase(n.File)
 	case n.URL != "":
 		return n.URL
 	case n.ID != "":
@@ -155,6 +613,9 @@ func openIn(path string) (io.Reader, func() error, error) {
 	if path == "-" {
 		return os.Stdin, func() error { return nil }, nil
 	}
+	if path == clipboardPath {
+		return openClipboardIn()
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, nil, err
@@ -166,6 +627,9 @@ func openOut(path string) (io.Writer, func() error, error) {
 	if path == "-" {
 		return os.Stdout, func() error { return nil }, nil
 	}
+	if path == clipboardPath {
+		return openClipboardOut()
+	}
 	f, err := os.Create(path)
 	if err != nil {
 		return nil, nil, err
@@ -174,6 +638,40 @@ func openOut(path string) (io.Writer, func() error, error) {
 }
 
 func fatalf(format string, args ...any) {
-	fmt.Fprintf(os.Stderr, "canvas_tool: "+format+"\n", args...)
+	slog.Error(fmt.Sprintf(format, args...))
 	os.Exit(1)
-}
\ No newline at end of file
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// keyValues parses key=value entries.
+func (f stringsFlag) keyValues() (map[string]string, error) {
+	m := make(map[string]string, len(f))
+	for _, kv := range f {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("want key=value, got %q", kv)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A graph document is the editable form of a graph: its metadata, a list
+// of nodes with their canvas fields and attributes, then a list of edges.
+// Documents are trees of docMap, []any and scalars (string, float64, or
+// nil for an empty value), so one shape serves every text syntax that
+// writes them, and reads back into a canvas for `gen`.
+
+// docMap is a mapping that keeps its keys in order.
+type docMap []docPair
+
+type docPair struct {
+	Key   string
+	Value any
+}
+
+func (m *docMap) add(key string, v any) {
+	*m = append(*m, docPair{key, v})
+}
+
+// addStr adds a string field unless it is empty.
+func (m *docMap) addStr(key, v string) {
+	if v != "" {
+		m.add(key, v)
+	}
+}
+
+// graphDocument returns g as a document. Node and edge fields are the
+// canvas ones, plus each node's display name and the attributes columnar
+// formats export.
+func graphDocument(g *graph) docMap {
+	var doc docMap
+	if len(g.meta) > 0 {
+		var meta docMap
+		for _, f := range g.meta {
+			meta.add(f.Key, f.Value)
+		}
+		doc.add("meta", meta)
+	}
+	nodes := make([]any, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		cn := n.Node
+		var m docMap
+		m.add("id", n.ID)
+		m.add("type", n.Type)
+		m.add("name", n.Name)
+		if n.Type == "text" {
+			m.add("text", cn.Text) // even if empty, else the name takes its place
+		} else {
+			m.addStr("text", cn.Text)
+		}
+		m.addStr("file", cn.File)
+		m.addStr("subpath", cn.Subpath)
+		m.addStr("url", cn.URL)
+		if n.Type == "group" {
+			m.add("label", cn.Label)
+		} else {
+			m.addStr("label", cn.Label)
+		}
+		m.add("x", cn.X)
+		m.add("y", cn.Y)
+		m.add("width", cn.Width)
+		m.add("height", cn.Height)
+		m.addStr("color", cn.Color)
+		m.addStr("background", cn.Background)
+		m.addStr("backgroundStyle", cn.BackgroundStyle)
+		if attrs := docAttrs(g.attrs, n.Attrs); len(attrs) > 0 {
+			m.add("attrs", attrs)
+		}
+		nodes = append(nodes, m)
+	}
+	doc.add("nodes", nodes)
+	edges := make([]any, 0, len(g.Edges))
+	for _, e := range g.Edges {
+		var m docMap
+		m.addStr("id", e.Edge.ID)
+		m.add("from", e.From)
+		m.add("to", e.To)
+		m.addStr("label", e.Label)
+		m.addStr("color", e.Edge.Color)
+		m.addStr("fromSide", e.Edge.FromSide)
+		m.addStr("toSide", e.Edge.ToSide)
+		m.addStr("fromEnd", e.Edge.FromEnd)
+		m.addStr("toEnd", e.Edge.ToEnd)
+		if attrs := docAttrs(g.edgeAttrs, e.Attrs); len(attrs) > 0 {
+			m.add("attrs", attrs)
+		}
+		edges = append(edges, m)
+	}
+	doc.add("edges", edges)
+	return doc
+}
+
+// docAttrs returns the set values of attrs, in column order.
+func docAttrs(names []string, attrs map[string]string) docMap {
+	var m docMap
+	for _, k := range names {
+		m.addStr(k, attrs[k])
+	}
+	return m
+}
+
+// canvasFromDocument turns a graph document back into a canvas. Only the
+// canvas fields are read: attrs are derived data and are skipped. Edge
+// ends name a node by ID or, failing that, by name. Nodes without an ID get
+// one derived from their content; a node with only a name becomes a text
+// node of that name. Nodes without x and y are laid out with lf, below the
+// others.
+func canvasFromDocument(doc any, lf layoutFlags) (Canvas, error) {
+	var c Canvas
+	root, ok := doc.(docMap)
+	if !ok {
+		return c, errors.New("want a mapping with nodes and edges")
+	}
+	var nodeList, edgeList []any
+	for _, p := range root {
+		switch p.Key {
+		case "meta":
+			meta, ok := p.Value.(docMap)
+			if !ok && p.Value != nil {
+				return c, errors.New("meta: want a mapping")
+			}
+			for _, f := range meta {
+				s, err := docString(f.Value)
+				if err != nil {
+					return c, fmt.Errorf("meta.%s: %v", f.Key, err)
+				}
+				c.Meta = append(c.Meta, jsonField{Key: f.Key, Value: metaValue(s)})
+			}
+		case "nodes", "edges":
+			list, ok := p.Value.([]any)
+			if !ok && p.Value != nil {
+				return c, fmt.Errorf("%s: want a list", p.Key)
+			}
+			if p.Key == "nodes" {
+				nodeList = list
+			} else {
+				edgeList = list
+			}
+		default:
+			return c, fmt.Errorf("unknown top-level key %q", p.Key)
+		}
+	}
+
+	byID := map[string]bool{}
+	byName := map[string]string{} // lower-cased name -> ID, "" if ambiguous
+	var unplaced []int
+	for i, v := range nodeList {
+		path := fmt.Sprintf("nodes[%d]", i)
+		n, name, placed, err := docNode(v, path)
+		if err != nil {
+			return c, err
+		}
+		if n.ID == "" {
+			n.ID = canvasID("node", n.Type, n.Text, n.File, n.URL, n.Label, name)
+		}
+		if byID[n.ID] {
+			return c, fmt.Errorf("%s: duplicate id %q", path, n.ID)
+		}
+		byID[n.ID] = true
+		if name != "" {
+			key := strings.ToLower(name)
+			if _, dup := byName[key]; dup {
+				byName[key] = ""
+			} else {
+				byName[key] = n.ID
+			}
+		}
+		if !placed {
+			unplaced = append(unplaced, len(c.Nodes))
+		}
+		c.Nodes = append(c.Nodes, n)
+	}
+
+	resolve := func(ref, path string) (string, error) {
+		if byID[ref] {
+			return ref, nil
+		}
+		id, ok := byName[strings.ToLower(ref)]
+		switch {
+		case !ok:
+			return "", fmt.Errorf("%s: no node %q", path, ref)
+		case id == "":
+			return "", fmt.Errorf("%s: several nodes are named %q; use an id", path, ref)
+		}
+		return id, nil
+	}
+	for i, v := range edgeList {
+		path := fmt.Sprintf("edges[%d]", i)
+		m, ok := v.(docMap)
+		if !ok {
+			return c, fmt.Errorf("%s: want a mapping", path)
+		}
+		var e Edge
+		fields := map[string]*string{
+			"id": &e.ID, "from": &e.FromNode, "to": &e.ToNode, "label": &e.Label,
+			"color": &e.Color, "fromSide": &e.FromSide, "toSide": &e.ToSide,
+			"fromEnd": &e.FromEnd, "toEnd": &e.ToEnd,
+		}
+		for _, p := range m {
+			if p.Key == "attrs" {
+				continue
+			}
+			dst, ok := fields[p.Key]
+			if !ok {
+				return c, fmt.Errorf("%s: unknown field %q", path, p.Key)
+			}
+			s, err := docString(p.Value)
+			if err != nil {
+				return c, fmt.Errorf("%s.%s: %v", path, p.Key, err)
+			}
+			*dst = s
+		}
+		var err error
+		if e.FromNode, err = resolve(e.FromNode, path+".from"); err != nil {
+			return c, err
+		}
+		if e.ToNode, err = resolve(e.ToNode, path+".to"); err != nil {
+			return c, err
+		}
+		if e.ID == "" {
+			e.ID = canvasID("edge", e.FromNode, e.ToNode, e.Label, strconv.Itoa(i))
+		}
+		c.Edges = append(c.Edges, e)
+	}
+	lf.style.apply(c.Nodes, c.Edges)
+	placeUnplaced(c, unplaced, lf)
+	lf.coords.apply(c.Nodes)
+	return c, nil
+}
+
+// docNode reads one node of a document, returning it with its name and
+// whether it has a position.
+func docNode(v any, path string) (n Node, name string, placed bool, err error) {
+	m, ok := v.(docMap)
+	if !ok {
+		return n, "", false, fmt.Errorf("%s: want a mapping", path)
+	}
+	strs := map[string]*string{
+		"id": &n.ID, "type": &n.Type, "name": &name, "text": &n.Text,
+		"file": &n.File, "subpath": &n.Subpath, "url": &n.URL, "label": &n.Label,
+		"color": &n.Color, "background": &n.Background, "backgroundStyle": &n.BackgroundStyle,
+	}
+	nums := map[string]*float64{"x": &n.X, "y": &n.Y, "width": &n.Width, "height": &n.Height}
+	has := map[string]bool{}
+	for _, p := range m {
+		has[p.Key] = true
+		if dst, ok := strs[p.Key]; ok {
+			if *dst, err = docString(p.Value); err != nil {
+				return n, "", false, fmt.Errorf("%s.%s: %v", path, p.Key, err)
+			}
+			continue
+		}
+		if dst, ok := nums[p.Key]; ok {
+			if *dst, err = docNumber(p.Value); err != nil {
+				return n, "", false, fmt.Errorf("%s.%s: %v", path, p.Key, err)
+			}
+			continue
+		}
+		if p.Key != "attrs" {
+			return n, "", false, fmt.Errorf("%s: unknown field %q", path, p.Key)
+		}
+	}
+	if n.Type == "" {
+		switch {
+		case n.File != "":
+			n.Type = "file"
+		case n.URL != "":
+			n.Type = "link"
+		default:
+			n.Type = "text"
+		}
+	}
+	switch n.Kind() {
+	case KindText:
+		if !has["text"] {
+			n.Text = name
+		}
+	case KindGroup:
+		if !has["label"] {
+			n.Label = name
+		}
+	}
+	if n.Width == 0 || n.Height == 0 {
+		n.Width, n.Height = nodeWidth, nodeHeight
+	}
+	return n, name, has["x"] && has["y"], nil
+}
+
+// placeUnplaced lays out the nodes at idx, below the rest.
+func placeUnplaced(c Canvas, idx []int, lf layoutFlags) {
+	nodes := c.Nodes
+	if len(idx) == 0 {
+		return
+	}
+	top := 0.0
+	if len(idx) < len(nodes) {
+		top = math.Inf(-1)
+		placed := map[int]bool{}
+		for _, i := range idx {
+			placed[i] = true
+		}
+		for i, n := range nodes {
+			if !placed[i] {
+				top = math.Max(top, n.Y+n.Height+lf.gap.Y)
+			}
+		}
+	}
+	sub := make([]Node, len(idx))
+	for k, i := range idx {
+		sub[k] = nodes[i]
+	}
+	lf.place(sub, c.Edges)
+	// Layouts centred on the origin (circle, force) are moved to start at
+	// the left edge, under the placed nodes.
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, n := range sub {
+		minX, minY = math.Min(minX, n.X), math.Min(minY, n.Y)
+	}
+	for k, i := range idx {
+		nodes[i].X, nodes[i].Y = sub[k].X-minX, top+sub[k].Y-minY
+	}
+}
+
+func docString(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", errors.New("want a string")
+}
+
+func docNumber(v any) (float64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, errors.New("want a number")
+}
+
+// metaValue reverses metaFields: values that are JSON objects, arrays,
+// numbers or booleans are kept as JSON, anything else is a string.
+func metaValue(s string) json.RawMessage {
+	if json.Valid([]byte(s)) && !strings.HasPrefix(strings.TrimSpace(s), `"`) {
+		return json.RawMessage(s)
+	}
+	data, _ := marshalNoEscape(s)
+	return data
+}
+
+// genDocument returns the gen command that turns a graph document written
+// by -format name, perhaps edited since, back into a canvas.
+func genDocument(name string, parse func([]byte) (any, error)) func(args []string) {
+	return func(args []string) {
+		fs := flag.NewFlagSet("gen "+name, flag.ExitOnError)
+		inPath := fs.String("in", "-", "graph document, as written by -format "+name+" (or - for stdin)")
+		outPath := fs.String("out", "", "output .canvas path (or - for stdout). Default: input basename + .canvas")
+		var lf layoutFlags
+		registerLayoutFlags(fs, &lf, "grid")
+		fs.Parse(args)
+		if fs.NArg() > 0 {
+			*inPath = fs.Arg(0)
+		}
+		if err := lf.load(); err != nil {
+			fatalf("gen %s: %v", name, err)
+		}
+		data, err := readAllInput(*inPath)
+		if err != nil {
+			fatalf("gen %s: %v", name, err)
+		}
+		doc, err := parse(data)
+		if err != nil {
+			fatalf("gen %s: %s: %v", name, *inPath, err)
+		}
+		c, err := canvasFromDocument(doc, lf)
+		if err != nil {
+			fatalf("gen %s: %s: %v", name, *inPath, err)
+		}
+		if err := writeCanvas(genOutPath(*inPath, *outPath), c); err != nil {
+			fatalf("gen %s: %v", name, err)
+		}
+	}
+}
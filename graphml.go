@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// writeGraphML emits a GraphML document with string keys for the node
+// name, type and extra attributes, and the edge label. With -opt
+// graphml.yed=true it adds yEd's graphics, so the canvas opens in yEd with
+// its positions, sizes, colours and arrowheads.
+func writeGraphML(out io.Writer, g *graph, opts exportOptions) error {
+	yed, err := opts.optBool("graphml", "yed", false)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	num := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	esc := func(s string) string {
+		var sb strings.Builder
+		xml.EscapeText(&sb, []byte(s))
+		return sb.String()
+	}
+
+	w.WriteString(xml.Header)
+	if yed {
+		w.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:y="http://www.yworks.com/xml/graphml">` + "\n")
+		w.WriteString(`  <key id="ng" for="node" yfiles.type="nodegraphics"/>` + "\n")
+		w.WriteString(`  <key id="eg" for="edge" yfiles.type="edgegraphics"/>` + "\n")
+	} else {
+		w.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	}
+	nodeKeys := append([]string{"name", "type"}, g.attrs...)
+	for i, k := range nodeKeys {
+		fmt.Fprintf(w, "  <key id=\"d%d\" for=\"node\" attr.name=\"%s\" attr.type=\"string\"/>\n", i, esc(k))
+	}
+	edgeKeys := append([]string{"label"}, g.edgeAttrs...)
+	for i, k := range edgeKeys {
+		fmt.Fprintf(w, "  <key id=\"d%d\" for=\"edge\" attr.name=\"%s\" attr.type=\"string\"/>\n", len(nodeKeys)+i, esc(k))
+	}
+	for i, m := range g.meta {
+		fmt.Fprintf(w, "  <key id=\"g%d\" for=\"graph\" attr.name=\"%s\" attr.type=\"string\"/>\n", i, esc(m.Key))
+	}
+	w.WriteString(`  <graph id="canvas" edgedefault="directed">` + "\n")
+	for i, m := range g.meta {
+		fmt.Fprintf(w, "    <data key=\"g%d\">%s</data>\n", i, esc(m.Value))
+	}
+	for _, n := range g.Nodes {
+		fmt.Fprintf(w, "    <node id=\"%s\">\n", esc(n.ID))
+		for i, k := range nodeKeys {
+			var v string
+			var ok bool
+			switch i {
+			case 0:
+				v, ok = n.Name, true
+			case 1:
+				v, ok = n.Type, n.Type != ""
+			default:
+				v = n.Attrs[k]
+				ok = v != ""
+			}
+			if ok {
+				fmt.Fprintf(w, "      <data key=\"d%d\">%s</data>\n", i, esc(v))
+			}
+		}
+		if yed {
+			c := n.Node
+			fill := `color="` + yedColor(c.Color, "#FFFFFF") + `"`
+			if n.Type == "group" {
+				fill = `hasColor="false"`
+			}
+			fmt.Fprintf(w, "      <data key=\"ng\"><y:ShapeNode><y:Geometry x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\"/><y:Fill %s transparent=\"false\"/><y:NodeLabel>%s</y:NodeLabel><y:Shape type=\"roundrectangle\"/></y:ShapeNode></data>\n",
+				num(c.X), num(c.Y), num(c.Width), num(c.Height), fill, esc(n.Name))
+		}
+		w.WriteString("    </node>\n")
+	}
+	for i, e := range g.Edges {
+		fmt.Fprintf(w, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">", i, esc(e.From), esc(e.To))
+		for j, k := range edgeKeys {
+			v := e.Label
+			if j > 0 {
+				v = e.Attrs[k]
+			}
+			if v != "" {
+				fmt.Fprintf(w, "<data key=\"d%d\">%s</data>", len(nodeKeys)+j, esc(v))
+			}
+		}
+		if yed {
+			source, target := "none", "standard"
+			if e.Edge.FromEnd == "arrow" || e.Attrs["bidirectional"] == "true" {
+				source = "standard"
+			}
+			if e.Edge.ToEnd == "none" {
+				target = "none"
+			}
+			fmt.Fprintf(w, `<data key="eg"><y:PolyLineEdge><y:LineStyle color="%s" type="line" width="1.0"/><y:Arrows source="%s" target="%s"/>`, yedColor(e.Edge.Color, "#000000"), source, target)
+			if e.Label != "" {
+				fmt.Fprintf(w, "<y:EdgeLabel>%s</y:EdgeLabel>", esc(e.Label))
+			}
+			w.WriteString("</y:PolyLineEdge></data>")
+		}
+		w.WriteString("</edge>\n")
+	}
+	w.WriteString("  </graph>\n</graphml>\n")
+	return w.Flush()
+}
+
+// canvasPresetColors are the hex values of Obsidian's colours 1 to 6.
+var canvasPresetColors = map[string]string{
+	"1": "#FB464C", "2": "#E9973F", "3": "#E0DE71", "4": "#44CF6E", "5": "#53DFDD", "6": "#A882FF",
+}
+
+// yedColor returns a canvas colour as #RRGGBB, or def if it has none.
+func yedColor(c, def string) string {
+	if hex, ok := canvasPresetColors[c]; ok {
+		return hex
+	}
+	if validCanvasColor(c) {
+		return strings.ToUpper(c)
+	}
+	return def
+}
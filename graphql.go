@@ -0,0 +1,855 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Small GraphQL executor covering what query clients send in practice:
+// queries with aliases, arguments, variables, fragments, inline fragments,
+// @skip/@include and __typename. Mutations, subscriptions and introspection
+// are not supported; the schema is whatever the resolvers answer.
+
+// A query is refused when its selections nest deeper than gqlMaxDepth,
+// with fragments expanded, or when the fields below the top level return
+// more than gqlMaxNested objects between them. A top-level list is bounded
+// by the canvases, but every nested outgoing, incoming or neighbors list
+// multiplies the result by the fan-out of the graph.
+const (
+	gqlMaxDepth  = 16
+	gqlMaxNested = 10_000
+)
+
+// gqlResolver is implemented by every object type in the schema.
+type gqlResolver interface {
+	typename() string
+	// field returns a scalar, nil, a gqlResolver, a []gqlResolver or a
+	// []string.
+	field(name string, args map[string]any) (any, error)
+}
+
+type gqlError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+type gqlResponse struct {
+	Data   *gqlObject `json:"data,omitempty"`
+	Errors []gqlError `json:"errors,omitempty"`
+}
+
+// executeGraphQL parses and runs a query document against root. A non-nil
+// error means the request itself was invalid and nothing was executed.
+func executeGraphQL(query, opName string, vars map[string]any, root gqlResolver) (gqlResponse, error) {
+	doc, err := parseGraphQL(query)
+	if err != nil {
+		return gqlResponse{}, err
+	}
+	op, err := doc.operation(opName)
+	if err != nil {
+		return gqlResponse{}, err
+	}
+	if op.kind != "query" {
+		return gqlResponse{}, fmt.Errorf("%s operations are not supported", op.kind)
+	}
+	if depth, err := doc.depth(op.sel, map[string]int{}, map[string]bool{}); err != nil {
+		return gqlResponse{}, err
+	} else if depth > gqlMaxDepth {
+		return gqlResponse{}, fmt.Errorf("query nests %d levels deep with its fragments; the limit is %d", depth, gqlMaxDepth)
+	}
+	resolved := make(map[string]any, len(op.vars))
+	for _, v := range op.vars {
+		if val, ok := vars[v.name]; ok {
+			resolved[v.name] = val
+		} else if v.def != nil {
+			resolved[v.name] = gqlLiteral(v.def, nil)
+		}
+	}
+	x := &gqlExec{doc: doc, vars: resolved}
+	data := x.selectionSet(root, op.sel, nil)
+	if x.nested > gqlMaxNested {
+		return gqlResponse{}, fmt.Errorf("query returns more than %d nodes and edges below the top level; select fewer nested fields or a smaller depth", gqlMaxNested)
+	}
+	return gqlResponse{Data: data, Errors: x.errs}, nil
+}
+
+// depth returns how many levels of fields sel nests, expanding fragments,
+// and refuses fragments that spread themselves. known memoizes the depth
+// of each fragment; active holds the ones being expanded.
+func (d *gqlDocument) depth(sel []gqlSelection, known map[string]int, active map[string]bool) (int, error) {
+	deepest := 0
+	for _, s := range sel {
+		var n int
+		var err error
+		switch {
+		case s.spread != "":
+			frag, ok := d.fragments[s.spread]
+			if !ok {
+				continue // reported when the query runs
+			}
+			if active[s.spread] {
+				return 0, fmt.Errorf("fragment %q spreads itself", s.spread)
+			}
+			var seen bool
+			if n, seen = known[s.spread]; !seen {
+				active[s.spread] = true
+				n, err = d.depth(frag.sel, known, active)
+				delete(active, s.spread)
+				known[s.spread] = n
+			}
+		case s.inline:
+			n, err = d.depth(s.sel, known, active)
+		default:
+			n, err = d.depth(s.sel, known, active)
+			n++
+		}
+		if err != nil {
+			return 0, err
+		}
+		deepest = max(deepest, n)
+	}
+	return deepest, nil
+}
+
+// Execution.
+
+type gqlExec struct {
+	doc    *gqlDocument
+	vars   map[string]any
+	errs   []gqlError
+	nested int // objects returned below the top level, against gqlMaxNested
+}
+
+func (x *gqlExec) selectionSet(obj gqlResolver, sel []gqlSelection, path []any) *gqlObject {
+	out := &gqlObject{}
+	x.collect(obj, sel, path, out, map[string]bool{})
+	return out
+}
+
+// collect adds the fields sel selects on obj to out. spread holds the
+// fragments already collected into out: spreading one again adds nothing.
+func (x *gqlExec) collect(obj gqlResolver, sel []gqlSelection, path []any, out *gqlObject, spread map[string]bool) {
+	for _, s := range sel {
+		if x.nested > gqlMaxNested {
+			return
+		}
+		if !x.included(s.dirs) {
+			continue
+		}
+		switch {
+		case s.spread != "":
+			frag, ok := x.doc.fragments[s.spread]
+			if !ok {
+				x.fail(path, "unknown fragment %q", s.spread)
+				continue
+			}
+			if frag.typeCond == obj.typename() && !spread[s.spread] {
+				spread[s.spread] = true
+				x.collect(obj, frag.sel, path, out, spread)
+			}
+		case s.inline:
+			if s.typeCond == "" || s.typeCond == obj.typename() {
+				x.collect(obj, s.sel, path, out, spread)
+			}
+		default:
+			key := s.name
+			if s.alias != "" {
+				key = s.alias
+			}
+			if out.has(key) {
+				continue
+			}
+			out.set(key, x.field(obj, s, append(slices.Clip(path), key)))
+		}
+	}
+}
+
+func (x *gqlExec) included(dirs []gqlDirective) bool {
+	for _, d := range dirs {
+		cond, _ := gqlLiteral(d.args["if"], x.vars).(bool)
+		if (d.name == "skip" && cond) || (d.name == "include" && !cond) {
+			return false
+		}
+	}
+	return true
+}
+
+func (x *gqlExec) field(obj gqlResolver, s gqlSelection, path []any) any {
+	if s.name == "__typename" {
+		return obj.typename()
+	}
+	args := make(map[string]any, len(s.args))
+	for k, v := range s.args {
+		args[k] = gqlLiteral(v, x.vars)
+	}
+	v, err := obj.field(s.name, args)
+	if err != nil {
+		x.fail(path, "%v", err)
+		return nil
+	}
+	if len(path) > 1 {
+		switch v := v.(type) {
+		case gqlResolver:
+			x.nested++
+		case []gqlResolver:
+			x.nested += len(v)
+		}
+		if x.nested > gqlMaxNested {
+			return nil
+		}
+	}
+	return x.complete(v, s, path)
+}
+
+func (x *gqlExec) complete(v any, s gqlSelection, path []any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case gqlResolver:
+		if len(s.sel) == 0 {
+			x.fail(path, "field %q of type %s must have a selection of subfields", s.name, v.typename())
+			return nil
+		}
+		return x.selectionSet(v, s.sel, path)
+	case []gqlResolver:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = x.complete(item, s, append(slices.Clip(path), i))
+		}
+		return list
+	}
+	if len(s.sel) > 0 {
+		x.fail(path, "field %q is a scalar and has no subfields", s.name)
+		return nil
+	}
+	return v
+}
+
+func (x *gqlExec) fail(path []any, format string, args ...any) {
+	x.errs = append(x.errs, gqlError{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+// gqlObject is a JSON object that keeps selection order, as GraphQL requires.
+type gqlObject struct {
+	keys []string
+	vals []any
+}
+
+func (o *gqlObject) has(key string) bool { return slices.Contains(o.keys, key) }
+
+func (o *gqlObject) set(key string, v any) {
+	o.keys = append(o.keys, key)
+	o.vals = append(o.vals, v)
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(o.vals[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Argument helpers for resolvers.
+
+func gqlArgString(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+func gqlArgInt(args map[string]any, name string, def int) int {
+	switch v := args[name].(type) {
+	case int64:
+		return int(v)
+	case float64: // JSON variables
+		return int(v)
+	}
+	return def
+}
+
+func gqlArgObject(args map[string]any, name string) map[string]any {
+	m, _ := args[name].(map[string]any)
+	return m
+}
+
+// Document model.
+
+type gqlDocument struct {
+	ops       []*gqlOperation
+	fragments map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	kind string
+	name string
+	vars []gqlVarDef
+	sel  []gqlSelection
+}
+
+type gqlVarDef struct {
+	name string
+	def  any // literal default, or nil
+}
+
+type gqlFragment struct {
+	typeCond string
+	sel      []gqlSelection
+}
+
+type gqlDirective struct {
+	name string
+	args map[string]any
+}
+
+// gqlSelection is a field, a fragment spread (spread set) or an inline
+// fragment (inline set).
+type gqlSelection struct {
+	alias  string
+	name   string
+	args   map[string]any
+	dirs   []gqlDirective
+	sel    []gqlSelection
+	spread string
+	inline bool
+
+	typeCond string
+}
+
+// Literal values are Go values, except variables and enums.
+type gqlVariable string
+type gqlEnum string
+
+func (d *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(d.ops) != 1 {
+			return nil, errors.New("operationName is required when the document has several operations")
+		}
+		return d.ops[0], nil
+	}
+	for _, op := range d.ops {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// gqlLiteral resolves variables inside a parsed value. Enum values become
+// plain strings.
+func gqlLiteral(v any, vars map[string]any) any {
+	switch v := v.(type) {
+	case gqlVariable:
+		return vars[string(v)]
+	case gqlEnum:
+		return string(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = gqlLiteral(item, vars)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = gqlLiteral(item, vars)
+		}
+		return out
+	}
+	return v
+}
+
+// Parsing.
+
+type gqlToken struct {
+	kind byte // 'p'unct, 'n'ame, 'i'nt, 'f'loat, 's'tring, 0 for EOF
+	val  string
+	pos  int
+}
+
+func gqlLex(src string) ([]gqlToken, error) {
+	var toks []gqlToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, gqlToken{'p', "...", i})
+			i += 3
+		case strings.IndexByte("!$&()=:@[]{}|", c) >= 0:
+			toks = append(toks, gqlToken{'p', string(c), i})
+			i++
+		case c == '_' || isASCIILetter(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || isASCIILetter(src[j]) || isASCIIDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, gqlToken{'n', src[i:j], i})
+			i = j
+		case c == '-' || isASCIIDigit(c):
+			j := i + 1
+			kind := byte('i')
+			for j < len(src) && (isASCIIDigit(src[j]) || strings.IndexByte(".eE+-", src[j]) >= 0) {
+				if !isASCIIDigit(src[j]) {
+					kind = 'f'
+				}
+				j++
+			}
+			toks = append(toks, gqlToken{kind, src[i:j], i})
+			i = j
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated block string at %d", i)
+			}
+			toks = append(toks, gqlToken{'s', strings.TrimSpace(src[i+3 : i+3+end]), i})
+			i += end + 6
+		case c == '"':
+			s, n, err := gqlString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at %d", err, i)
+			}
+			toks = append(toks, gqlToken{'s', s, i})
+			i += n
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			if r == '\uFEFF' {
+				i += 3
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character %q at %d", r, i)
+		}
+	}
+	return append(toks, gqlToken{pos: len(src)}), nil
+}
+
+// gqlString decodes a quoted string at the start of src, returning the value
+// and the number of bytes consumed.
+func gqlString(src string) (string, int, error) {
+	var sb strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			return sb.String(), i + 1, nil
+		case '\n':
+			return "", 0, errors.New("unterminated string")
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, errors.New("unterminated string")
+			}
+			i++
+			switch e := src[i]; e {
+			case 'u':
+				if i+4 >= len(src) {
+					return "", 0, errors.New("bad unicode escape")
+				}
+				n, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, errors.New("bad unicode escape")
+				}
+				sb.WriteRune(rune(n))
+				i += 4
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+func isASCIILetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isASCIIDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+type gqlParser struct {
+	toks  []gqlToken
+	i     int
+	depth int // of the selection sets, values and types being parsed
+}
+
+func parseGraphQL(src string) (*gqlDocument, error) {
+	toks, err := gqlLex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{toks: toks}
+	doc := &gqlDocument{fragments: map[string]*gqlFragment{}}
+	for p.peek().kind != 0 {
+		switch t := p.peek(); {
+		case t.kind == 'p' && t.val == "{":
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, &gqlOperation{kind: "query", sel: sel})
+		case t.kind == 'n' && t.val == "fragment":
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.keyword("on"); err != nil {
+				return nil, err
+			}
+			typeCond, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = &gqlFragment{typeCond: typeCond, sel: sel}
+		case t.kind == 'n' && (t.val == "query" || t.val == "mutation" || t.val == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.ops) == 0 {
+		return nil, errors.New("document contains no operations")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() gqlToken { return p.toks[p.i] }
+
+func (p *gqlParser) next() gqlToken {
+	t := p.toks[p.i]
+	if t.kind != 0 {
+		p.i++
+	}
+	return t
+}
+
+func (p *gqlParser) unexpected() error {
+	t := p.peek()
+	if t.kind == 0 {
+		return errors.New("unexpected end of document")
+	}
+	return fmt.Errorf("unexpected %q at %d", t.val, t.pos)
+}
+
+func (p *gqlParser) isPunct(v string) bool {
+	t := p.peek()
+	return t.kind == 'p' && t.val == v
+}
+
+func (p *gqlParser) punct(v string) error {
+	if !p.isPunct(v) {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.peek().kind != 'n' {
+		return "", p.unexpected()
+	}
+	return p.next().val, nil
+}
+
+// nest enters a selection set, list or object value or list type, so a
+// document can't nest deep enough to exhaust the stack. The caller defers
+// p.unnest.
+func (p *gqlParser) nest() error {
+	p.depth++
+	if p.depth > gqlMaxDepth {
+		return fmt.Errorf("document nests deeper than %d levels at %d", gqlMaxDepth, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *gqlParser) unnest() { p.depth-- }
+
+func (p *gqlParser) keyword(kw string) error {
+	if t := p.peek(); t.kind != 'n' || t.val != kw {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: p.next().val}
+	if p.peek().kind == 'n' {
+		op.name = p.next().val
+	}
+	if p.isPunct("(") {
+		p.next()
+		for !p.isPunct(")") {
+			if err := p.punct("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.punct(":"); err != nil {
+				return nil, err
+			}
+			if err := p.skipType(); err != nil {
+				return nil, err
+			}
+			v := gqlVarDef{name: name}
+			if p.isPunct("=") {
+				p.next()
+				if v.def, err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, v)
+		}
+		p.next()
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.sel = sel
+	return op, nil
+}
+
+// skipType consumes a type reference such as [ID!]!; types aren't checked.
+func (p *gqlParser) skipType() error {
+	if p.isPunct("[") {
+		defer p.unnest()
+		if err := p.nest(); err != nil {
+			return err
+		}
+		p.next()
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.punct("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.isPunct("!") {
+		p.next()
+	}
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	defer p.unnest()
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	if err := p.punct("{"); err != nil {
+		return nil, err
+	}
+	var sel []gqlSelection
+	for !p.isPunct("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, s)
+	}
+	p.next()
+	return sel, nil
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	var s gqlSelection
+	var err error
+	if p.isPunct("...") {
+		p.next()
+		if t := p.peek(); t.kind == 'n' && t.val != "on" {
+			s.spread = p.next().val
+			s.dirs, err = p.directives()
+			return s, err
+		}
+		s.inline = true
+		if p.peek().kind == 'n' {
+			p.next() // "on"
+			if s.typeCond, err = p.name(); err != nil {
+				return s, err
+			}
+		}
+		if s.dirs, err = p.directives(); err != nil {
+			return s, err
+		}
+		s.sel, err = p.selectionSet()
+		return s, err
+	}
+
+	if s.name, err = p.name(); err != nil {
+		return s, err
+	}
+	if p.isPunct(":") {
+		p.next()
+		s.alias = s.name
+		if s.name, err = p.name(); err != nil {
+			return s, err
+		}
+	}
+	if p.isPunct("(") {
+		if s.args, err = p.arguments(); err != nil {
+			return s, err
+		}
+	}
+	if s.dirs, err = p.directives(); err != nil {
+		return s, err
+	}
+	if p.isPunct("{") {
+		s.sel, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *gqlParser) arguments() (map[string]any, error) {
+	p.next() // (
+	args := map[string]any{}
+	for !p.isPunct(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.punct(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var dirs []gqlDirective
+	for p.isPunct("@") {
+		p.next()
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d := gqlDirective{name: name}
+		if p.isPunct("(") {
+			if d.args, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+func (p *gqlParser) value() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case 'i':
+		return strconv.ParseInt(t.val, 10, 64)
+	case 'f':
+		return strconv.ParseFloat(t.val, 64)
+	case 's':
+		return t.val, nil
+	case 'n':
+		switch t.val {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return gqlEnum(t.val), nil
+	case 'p':
+		switch t.val {
+		case "$":
+			name, err := p.name()
+			return gqlVariable(name), err
+		case "[":
+			defer p.unnest()
+			if err := p.nest(); err != nil {
+				return nil, err
+			}
+			list := []any{}
+			for !p.isPunct("]") {
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			p.next()
+			return list, nil
+		case "{":
+			defer p.unnest()
+			if err := p.nest(); err != nil {
+				return nil, err
+			}
+			obj := map[string]any{}
+			for !p.isPunct("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.punct(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			p.next()
+			return obj, nil
+		}
+	}
+	if t.kind != 0 {
+		p.i--
+	}
+	return nil, p.unexpected()
+}
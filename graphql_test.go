@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fanOut is a Node whose neighbors are n more of itself, so every nested
+// neighbors selection multiplies the result by n.
+type fanOut int
+
+func (fanOut) typename() string { return "Node" }
+
+func (f fanOut) field(name string, _ map[string]any) (any, error) {
+	switch name {
+	case "id":
+		return int(f), nil
+	case "node":
+		return f, nil
+	case "neighbors":
+		out := make([]gqlResolver, f)
+		for i := range out {
+			out[i] = f
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown field %q on Node", name)
+}
+
+// nestQuery returns a query selecting field levels deep, ending in id.
+func nestQuery(field string, levels int) string {
+	return "{" + strings.Repeat(field+"{", levels-1) + "id" + strings.Repeat("}", levels)
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name, query, want string
+	}{
+		{"at the depth limit", nestQuery("node", gqlMaxDepth), ""},
+		{"over the depth limit", nestQuery("node", gqlMaxDepth+1), "nests deeper than 16 levels"},
+		{"far over the depth limit", nestQuery("node", 100_000), "nests deeper than 16 levels"},
+		{"nested list value", "{node(x: " + strings.Repeat("[", 100_000) + ") {id}}", "nests deeper than 16 levels"},
+		{"nested list type", "query($x: " + strings.Repeat("[", 100_000) + "ID) {id}", "nests deeper than 16 levels"},
+		{"deep through fragments", "{node{...A}} fragment A on Node {node{...B}} fragment B on Node {" + nestQuery("node", gqlMaxDepth-1)[1:], "query nests 17 levels deep"},
+		{"fragment cycle", "{node{...A}} fragment A on Node {id ...B} fragment B on Node {node{...A}}", `fragment "A" spreads itself`},
+		{"within the budget", "{neighbors{neighbors{neighbors{id}}}}", ""},
+		{"over the budget", "{neighbors{neighbors{neighbors{neighbors{neighbors{id}}}}}}", "more than 10000 nodes and edges"},
+		{"aliases count too", "{neighbors{a: neighbors{neighbors{neighbors{id}}} b: neighbors{neighbors{neighbors{id}}}}}", "more than 10000 nodes and edges"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := executeGraphQL(tc.query, "", nil, fanOut(10))
+			switch {
+			case tc.want == "" && err != nil:
+				t.Fatalf("error %v", err)
+			case tc.want == "" && len(resp.Errors) > 0:
+				t.Fatalf("errors %v", resp.Errors)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Fatalf("error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestGraphQLRepeatedSpreads checks that spreading the same fragments over
+// and over costs nothing once they have been collected.
+func TestGraphQLRepeatedSpreads(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("{node{...F0}}")
+	for i := range 64 {
+		fmt.Fprintf(&b, " fragment F%d on Node {id ...F%d ...F%d}", i, i+1, i+1)
+	}
+	b.WriteString(" fragment F64 on Node {id}")
+	resp, err := executeGraphQL(b.String(), "", nil, fanOut(1))
+	if err != nil || len(resp.Errors) > 0 {
+		t.Fatalf("error %v %v", err, resp.Errors)
+	}
+}
+
+func TestGraphQLBodyLimit(t *testing.T) {
+	s := &server{limits: parseLimits{maxBytes: 64}, store: newGraphStore(nil, false, "nfc", serveLimits)}
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"query": "{canvases}"}`, http.StatusOK},
+		{`{"query": "{canvases}", "variables": {"pad": "` + strings.Repeat("x", 64) + `"}}`, http.StatusRequestEntityTooLarge},
+	} {
+		rec := httptest.NewRecorder()
+		s.handleGraphQL(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tc.body)))
+		if rec.Code != tc.status {
+			t.Errorf("POST of %d bytes: status %d, want %d: %s", len(tc.body), rec.Code, tc.status, rec.Body)
+		}
+	}
+}
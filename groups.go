@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Canvas groups don't list their members; a node belongs to every group
+// whose rectangle contains its own.
+
+func (n Node) within(g Node) bool {
+	return n.X >= g.X && n.Y >= g.Y && n.X+n.Width <= g.X+g.Width && n.Y+n.Height <= g.Y+g.Height
+}
+
+// innermostGroup returns the index of the smallest group node containing
+// g.Nodes[i], or -1.
+func (g *graph) innermostGroup(i int) int {
+	best := -1
+	for j, grp := range g.Nodes {
+		if j == i || grp.Type != "group" || !g.Nodes[i].Node.within(grp.Node) {
+			continue
+		}
+		if best < 0 || grp.Node.Width*grp.Node.Height < g.Nodes[best].Node.Width*g.Nodes[best].Node.Height {
+			best = j
+		}
+	}
+	return best
+}
+
+// sliceGroup narrows g to the nodes inside the group(s) named name (by
+// display name or ID) and the edges between them. With external set, edges
+// crossing the boundary are kept too, along with their outside endpoints;
+// both are marked external=true.
+func sliceGroup(g *graph, name string, external bool) error {
+	var groups []Node
+	for _, n := range g.Nodes {
+		if n.Type == "group" && (n.Name == name || n.ID == name) {
+			groups = append(groups, n.Node)
+		}
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("no group named %q", name)
+	}
+	inside := map[string]bool{}
+	for _, n := range g.Nodes {
+		for _, grp := range groups {
+			if n.ID != grp.ID && n.Node.within(grp) {
+				inside[n.ID] = true
+			}
+		}
+	}
+
+	outside := map[string]bool{}
+	var edges []graphEdge
+	for _, e := range g.Edges {
+		switch {
+		case inside[e.From] && inside[e.To]:
+		case external && (inside[e.From] || inside[e.To]):
+			if e.Attrs == nil {
+				e.Attrs = map[string]string{}
+			}
+			e.Attrs["external"] = "true"
+			outside[e.From] = !inside[e.From]
+			outside[e.To] = !inside[e.To]
+		default:
+			continue
+		}
+		edges = append(edges, e)
+	}
+	var nodes []graphNode
+	for _, n := range g.Nodes {
+		if inside[n.ID] || outside[n.ID] {
+			nodes = append(nodes, n)
+		}
+	}
+	g.Nodes, g.Edges = nodes, edges
+	if external {
+		if !slices.Contains(g.edgeAttrs, "external") {
+			g.edgeAttrs = append(g.edgeAttrs, "external")
+		}
+		for i, n := range g.Nodes {
+			if outside[n.ID] {
+				g.setAttr(i, "external", "true")
+			}
+		}
+	}
+	g.index()
+	return nil
+}
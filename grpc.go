@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRPC transport for the CanvasTool service (proto/canvastool.proto), served
+// over h2c next to the HTTP endpoints. Messages are uncompressed.
+
+const grpcService = "/canvastool.v1.CanvasTool/"
+
+const grpcMaxMessage = 64 << 20
+
+// gRPC status codes used here.
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcResourceLimit   = 8
+	grpcUnimplemented   = 12
+	grpcInternal        = 13
+	grpcUnauthenticated = 16
+)
+
+type grpcStatus struct {
+	code int
+	msg  string
+}
+
+func (s *grpcStatus) Error() string { return s.msg }
+
+func grpcErrorf(code int, format string, args ...any) error {
+	return &grpcStatus{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+func (s *server) handleGRPC(w http.ResponseWriter, r *http.Request) {
+	if !isGRPC(r) {
+		http.Error(w, "gRPC requires HTTP/2 and application/grpc", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+
+	var err error
+	switch strings.TrimPrefix(r.URL.Path, grpcService) {
+	case "Convert":
+		err = grpcUnary(w, r.Body, s.grpcConvert)
+	case "Validate":
+		err = grpcUnary(w, r.Body, s.grpcValidate)
+	case "Stats":
+		err = s.grpcStats(w, r.Body)
+	default:
+		err = grpcErrorf(grpcUnimplemented, "unknown method %s", r.URL.Path)
+	}
+	writeGRPCStatus(w, err)
+}
+
+func grpcUnary(w http.ResponseWriter, body io.Reader, fn func([]byte) ([]byte, error)) error {
+	req, err := readGRPCMessage(body)
+	if err == io.EOF {
+		return grpcErrorf(grpcInvalidArgument, "missing request message")
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := fn(req)
+	if err != nil {
+		return err
+	}
+	return writeGRPCMessage(w, resp)
+}
+
+// readGRPCMessage reads one length-prefixed message, returning io.EOF when
+// the client has finished sending.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, grpcErrorf(grpcInvalidArgument, "read message: %v", err)
+	}
+	if hdr[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > grpcMaxMessage {
+		return nil, grpcErrorf(grpcResourceLimit, "message of %d bytes exceeds limit", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "read message: %v", err)
+	}
+	return msg, nil
+}
+
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) error {
+	var hdr [5]byte
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(msg)))
+	if _, err := w.Write(append(hdr[:], msg...)); err != nil {
+		return err
+	}
+	http.NewResponseController(w).Flush()
+	return nil
+}
+
+func writeGRPCStatus(w http.ResponseWriter, err error) {
+	code, msg := grpcOK, ""
+	if err != nil {
+		var st *grpcStatus
+		if errors.As(err, &st) {
+			code, msg = st.code, st.msg
+		} else {
+			code, msg = grpcInternal, err.Error()
+		}
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(msg))
+	}
+}
+
+func grpcPercentEncode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7E || c == '%' {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func (s *server) grpcConvert(req []byte) ([]byte, error) {
+	fields, err := pbFields(req)
+	if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	var data []byte
+	format, keepPath := "csv", false
+	settings := map[string]string{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			data = f.data
+		case 2:
+			if len(f.data) > 0 {
+				format = string(f.data)
+			}
+		case 3:
+			keepPath = f.varint != 0
+		case 4:
+			k, v, err := pbMapEntry(f.data)
+			if err != nil {
+				return nil, grpcErrorf(grpcInvalidArgument, "options: %v", err)
+			}
+			settings[k] = v
+		}
+	}
+
+	out, g, err := s.convert(data, format, keepPath, settings, "grpc")
+	if err != nil {
+		return nil, err
+	}
+
+	var resp pbWriter
+	resp.bytes(1, out.Bytes())
+	resp.int32(2, int32(len(g.Nodes)))
+	resp.int32(3, int32(len(g.Edges)))
+	return resp.buf, nil
+}
+
+// convert renders a client's canvas in format, returning the output and the
+// graph; errors are grpcStatus values. source names the entry point in the
+// metrics.
+func (s *server) convert(data []byte, format string, keepPath bool, settings map[string]string, source string) (*bytes.Buffer, *graph, error) {
+	ex, ok := exporters[format]
+	if !ok || ex.write == nil {
+		return nil, nil, grpcErrorf(grpcInvalidArgument, "unsupported format %q", format)
+	}
+	if _, ok := settings["jsonld.context"]; ok {
+		return nil, nil, grpcErrorf(grpcInvalidArgument, "option jsonld.context reads server files and is not allowed")
+	}
+	opts, err := exportOptionsFrom(settings)
+	if err != nil {
+		return nil, nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+
+	start := time.Now()
+	c, err := s.parse(data)
+	if err != nil {
+		s.metrics.parseFailures.inc(source)
+		s.metrics.conversion(format, start, 0, err)
+		if isLimitError(err) {
+			return nil, nil, grpcErrorf(grpcResourceLimit, "%v", err)
+		}
+		return nil, nil, grpcErrorf(grpcInvalidArgument, "parse canvas: %v", err)
+	}
+	g := buildGraph(c, keepPath)
+	normalizeGraph(g, s.form)
+	var out bytes.Buffer
+	err = ex.write(&out, g, opts)
+	s.metrics.conversion(format, start, len(g.Edges), err)
+	if err != nil {
+		return nil, nil, grpcErrorf(grpcInvalidArgument, "write %s: %v", format, err)
+	}
+	return &out, g, nil
+}
+
+// parse decodes a canvas sent by a client, within the server's limits.
+func (s *server) parse(data []byte) (Canvas, error) {
+	return parseCanvasLimits(context.Background(), data, s.limits)
+}
+
+func (s *server) grpcValidate(req []byte) ([]byte, error) {
+	fields, err := pbFields(req)
+	if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	var data []byte
+	for _, f := range fields {
+		if f.num == 1 {
+			data = f.data
+		}
+	}
+
+	var issues []issue
+	if c, err := s.parse(data); err != nil {
+		s.metrics.parseFailures.inc("grpc")
+		code := "invalid-json"
+		if isLimitError(err) {
+			code = "too-large"
+		}
+		issues = []issue{{Severity: "error", Code: code, Message: err.Error()}}
+	} else {
+		issues = validateCanvas(c)
+	}
+
+	var resp pbWriter
+	resp.bool(1, !hasErrors(issues))
+	for _, is := range issues {
+		var m pbWriter
+		m.string(1, is.Severity)
+		m.string(2, is.Code)
+		m.string(3, is.Message)
+		m.string(4, is.Path)
+		m.string(5, is.NodeID)
+		resp.message(2, &m)
+	}
+	return resp.buf, nil
+}
+
+// grpcStats answers every streamed canvas as soon as it arrives.
+func (s *server) grpcStats(w http.ResponseWriter, body io.Reader) error {
+	for {
+		req, err := readGRPCMessage(body)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fields, err := pbFields(req)
+		if err != nil {
+			return grpcErrorf(grpcInvalidArgument, "%v", err)
+		}
+		var name string
+		var data []byte
+		for _, f := range fields {
+			switch f.num {
+			case 1:
+				name = string(f.data)
+			case 2:
+				data = f.data
+			}
+		}
+
+		var resp pbWriter
+		resp.string(1, name)
+		if c, err := s.parse(data); err != nil {
+			s.metrics.parseFailures.inc("grpc")
+			resp.string(2, err.Error())
+		} else {
+			g := buildGraph(c, false)
+			normalizeGraph(g, s.form)
+			st := computeStats(g)
+			resp.int32(3, int32(st.Nodes))
+			resp.int32(4, int32(st.Edges))
+			types := make([]string, 0, len(st.NodeTypes))
+			for t := range st.NodeTypes {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+			for _, t := range types {
+				var entry pbWriter
+				entry.string(1, t)
+				entry.int32(2, int32(st.NodeTypes[t]))
+				resp.message(5, &entry)
+			}
+			resp.int32(6, int32(st.Labels))
+			resp.int32(7, int32(st.Unlabelled))
+			resp.int32(8, int32(st.Orphans))
+			resp.int32(9, int32(st.Dangling))
+			resp.int32(10, int32(st.SelfLoops))
+		}
+		if err := writeGRPCMessage(w, resp.buf); err != nil {
+			return err
+		}
+	}
+}
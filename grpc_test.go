@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestGRPCStatsNormalizes checks that Stats counts edge labels differing
+// only in Unicode form as one, as Convert and /convert see them.
+func TestGRPCStatsNormalizes(t *testing.T) {
+	canvas := `{
+		"nodes": [
+			{"id": "a", "type": "text", "text": "A", "x": 0, "y": 0, "width": 10, "height": 10},
+			{"id": "b", "type": "text", "text": "B", "x": 20, "y": 0, "width": 10, "height": 10}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "a", "toNode": "b", "label": "café"},
+			{"id": "e2", "fromNode": "b", "toNode": "a", "label": "café"}
+		]
+	}`
+	var req pbWriter
+	req.string(1, "c")
+	req.bytes(2, []byte(canvas))
+	var body bytes.Buffer
+	body.Write([]byte{0, 0, 0, 0, 0})
+	binary.BigEndian.PutUint32(body.Bytes()[1:], uint32(len(req.buf)))
+	body.Write(req.buf)
+
+	for _, tc := range []struct {
+		form   string
+		labels uint64
+	}{{"nfc", 1}, {"none", 2}} {
+		s := &server{metrics: newServeMetrics(), form: tc.form, limits: serveLimits}
+		rec := httptest.NewRecorder()
+		if err := s.grpcStats(rec, bytes.NewReader(body.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := readGRPCMessage(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields, err := pbFields(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		labels := uint64(0)
+		for _, f := range fields {
+			if f.num == 6 {
+				labels = f.varint
+			}
+		}
+		if labels != tc.labels {
+			t.Errorf("-normalize %s: %d labels, want %d", tc.form, labels, tc.labels)
+		}
+	}
+}
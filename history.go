@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The history store is a JSONL file with one snapshot per line, appended by
+// `snapshot` and read by `history`.
+
+type snapshot struct {
+	Time   time.Time  `json:"time"`
+	Canvas string     `json:"canvas"`
+	Hash   string     `json:"hash"`
+	Stats  graphStats `json:"stats"`
+	Edges  [][]string `json:"edges,omitempty"` // from, label, to
+}
+
+func defaultHistoryPath() string {
+	cfg, err := os.UserConfigDir()
+	if err != nil {
+		return "canvas_history.jsonl"
+	}
+	return filepath.Join(cfg, "canvas_tool", "history.jsonl")
+}
+
+// graphHash fingerprints a graph's content, ignoring layout, IDs and
+// ordering: the sorted node names and from/label/to triples.
+func graphHash(g *graph) string {
+	var lines []string
+	for _, n := range g.Nodes {
+		lines = append(lines, "n\x00"+n.Type+"\x00"+n.Name)
+	}
+	for _, e := range g.Edges {
+		lines = append(lines, "e\x00"+g.name(e.From)+"\x00"+e.Label+"\x00"+g.name(e.To))
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, l := range lines {
+		io.WriteString(h, l+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readHistory(path string) ([]snapshot, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var snaps []snapshot
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var s snapshot
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		snaps = append(snaps, s)
+	}
+	return snaps, sc.Err()
+}
+
+func appendHistory(path string, s snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runSnapshot(args []string) {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	store := fs.String("store", defaultHistoryPath(), "history file (JSONL)")
+	withEdges := fs.Bool("edges", false, "record the full edge list, not just the hash and counts")
+	ifChanged := fs.Bool("if-changed", false, "skip canvases whose hash matches their latest snapshot")
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	fs.Parse(args)
+	paths, err := expandInputs(fs.Args())
+	if err != nil {
+		fatalf("snapshot: %v", err)
+	}
+	if len(paths) == 0 {
+		fatalf("snapshot: no canvases given")
+	}
+
+	latest := map[string]string{}
+	if *ifChanged {
+		snaps, err := readHistory(*store)
+		if err != nil {
+			fatalf("snapshot: %v", err)
+		}
+		for _, s := range snaps {
+			latest[s.Canvas] = s.Hash
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, path := range paths {
+		c, err := loadCanvas(path)
+		if err != nil {
+			fatalf("snapshot: %v", err)
+		}
+		g := buildGraph(c, *keepPath)
+		name := path
+		if abs, err := filepath.Abs(path); err == nil {
+			name = abs
+		}
+		s := snapshot{Time: now, Canvas: name, Hash: graphHash(g), Stats: computeStats(g)}
+		if *ifChanged && latest[name] == s.Hash {
+			continue
+		}
+		if *withEdges {
+			for _, e := range g.Edges {
+				s.Edges = append(s.Edges, []string{g.name(e.From), e.Label, g.name(e.To)})
+			}
+		}
+		if err := appendHistory(*store, s); err != nil {
+			fatalf("snapshot: %v", err)
+		}
+		fmt.Printf("%s\t%s\t%d nodes\t%d edges\n", path, s.Hash[:12], s.Stats.Nodes, s.Stats.Edges)
+	}
+}
+
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	store := fs.String("store", defaultHistoryPath(), "history file (JSONL)")
+	canvas := fs.String("canvas", "", "only snapshots of this canvas path")
+	since := fs.String("since", "", "only snapshots at or after this time (2006-01-02, RFC 3339, or an age such as 30d)")
+	format := fs.String("format", "table", "output format: table, csv or jsonl")
+	fs.Parse(args)
+
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = parseSince(*since, time.Now()); err != nil {
+			fatalf("history: -since: %v", err)
+		}
+	}
+	var want string
+	if *canvas != "" {
+		want = *canvas
+		if abs, err := filepath.Abs(want); err == nil {
+			want = abs
+		}
+	}
+	snaps, err := readHistory(*store)
+	if err != nil {
+		fatalf("history: %v", err)
+	}
+	var selected []snapshot
+	for _, s := range snaps {
+		if (want == "" || s.Canvas == want) && !s.Time.Before(from) {
+			selected = append(selected, s)
+		}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	switch *format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, s := range selected {
+			enc.Encode(s)
+		}
+	case "csv", "table":
+		cw := csv.NewWriter(w)
+		if *format == "table" {
+			cw.Comma = '\t'
+		}
+		cw.Write([]string{"time", "canvas", "hash", "nodes", "edges", "labels", "orphans", "dangling"})
+		for _, s := range selected {
+			hash := s.Hash
+			if *format == "table" {
+				hash = hash[:min(12, len(hash))]
+			}
+			cw.Write([]string{
+				s.Time.Format(time.RFC3339), s.Canvas, hash,
+				strconv.Itoa(s.Stats.Nodes), strconv.Itoa(s.Stats.Edges), strconv.Itoa(s.Stats.Labels),
+				strconv.Itoa(s.Stats.Orphans), strconv.Itoa(s.Stats.Dangling),
+			})
+		}
+		cw.Flush()
+	default:
+		fatalf("history: unknown -format %q (want table, csv or jsonl)", *format)
+	}
+}
+
+// parseTime accepts a date or an RFC 3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// parseSince accepts what parseTime does, or an age before now: a Go
+// duration ("36h") or a count of days or weeks ("30d", "2w").
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := parseTime(s); err == nil {
+		return t, nil
+	}
+	if n, unit := strings.TrimRight(s, "dw"), strings.TrimLeft(s, "0123456789"); n != "" && (unit == "d" || unit == "w") {
+		days, err := strconv.Atoi(n)
+		if err == nil {
+			if unit == "w" {
+				days *= 7
+			}
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("bad time %q (want 2006-01-02, RFC 3339, 30d, 2w or a duration like 36h)", s)
+}
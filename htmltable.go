@@ -0,0 +1,151 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// writeHTMLTable emits a standalone page: a filterable, sortable table of
+// edges and a summary of the nodes. Nodes with an obsidian_uri attribute
+// link back into the vault, and link nodes to their URL.
+func writeHTMLTable(out io.Writer, g *graph, _ exportOptions) error {
+	type nodeCell struct {
+		Name string
+		Link template.URL
+	}
+	cell := func(id string) nodeCell {
+		n, ok := g.node(id)
+		if !ok {
+			return nodeCell{Name: id + " (missing)"}
+		}
+		c := nodeCell{Name: n.Name}
+		// html/template only passes http(s) links through, so vouch for
+		// the ones built here
+		if uri := n.Attrs["obsidian_uri"]; strings.HasPrefix(uri, "obsidian://") {
+			c.Link = template.URL(uri)
+		} else if u := n.Node.URL; n.Type == "link" && (strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://")) {
+			c.Link = template.URL(u)
+		}
+		return c
+	}
+	type edgeRow struct {
+		From, To nodeCell
+		Label    string
+		Extra    []string
+	}
+	type nodeRow struct {
+		Node    nodeCell
+		Type    string
+		Out, In int
+		Extra   []string
+	}
+	page := struct {
+		EdgeColumns, NodeColumns []string
+		Edges                    []edgeRow
+		Nodes                    []nodeRow
+		Types                    []string
+		TypeCounts               map[string]int
+	}{EdgeColumns: g.edgeAttrs, TypeCounts: map[string]int{}}
+
+	for _, a := range g.attrs {
+		if a != "obsidian_uri" {
+			page.NodeColumns = append(page.NodeColumns, a)
+		}
+	}
+	for _, e := range g.Edges {
+		r := edgeRow{From: cell(e.From), To: cell(e.To), Label: e.Label}
+		for _, a := range g.edgeAttrs {
+			r.Extra = append(r.Extra, e.Attrs[a])
+		}
+		page.Edges = append(page.Edges, r)
+	}
+	for _, n := range g.Nodes {
+		r := nodeRow{Node: cell(n.ID), Type: n.Type, Out: len(g.out[n.ID]), In: len(g.in[n.ID])}
+		for _, a := range page.NodeColumns {
+			r.Extra = append(r.Extra, n.Attrs[a])
+		}
+		page.Nodes = append(page.Nodes, r)
+		if page.TypeCounts[n.Type] == 0 {
+			page.Types = append(page.Types, n.Type)
+		}
+		page.TypeCounts[n.Type]++
+	}
+	sort.Strings(page.Types)
+	return htmlTablePage.Execute(out, page)
+}
+
+var htmlTablePage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Canvas export</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; cursor: pointer; user-select: none; }
+th.asc::after { content: " \25B2"; } th.desc::after { content: " \25BC"; }
+tr.hidden { display: none; }
+input { font: inherit; padding: 4px; width: 24em; }
+.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Canvas export</h1>
+<p>{{len .Nodes}} nodes, {{len .Edges}} edges{{range .Types}} &middot; {{index $.TypeCounts .}} {{if .}}{{.}}{{else}}untyped{{end}}{{end}}</p>
+
+<h2>Edges</h2>
+<input type="search" placeholder="Filter edges" data-filter="edges">
+<table id="edges">
+<thead><tr><th>From</th><th>Label</th><th>To</th>{{range .EdgeColumns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Edges}}
+<tr><td>{{template "node" .From}}</td><td>{{.Label}}</td><td>{{template "node" .To}}</td>{{range .Extra}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+
+<h2>Nodes</h2>
+<input type="search" placeholder="Filter nodes" data-filter="nodes">
+<table id="nodes">
+<thead><tr><th>Name</th><th>Type</th><th>Out</th><th>In</th>{{range .NodeColumns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Nodes}}
+<tr><td>{{template "node" .Node}}</td><td>{{.Type}}</td><td class="num">{{.Out}}</td><td class="num">{{.In}}</td>{{range .Extra}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+
+<script>
+document.querySelectorAll("input[data-filter]").forEach(function (input) {
+  var rows = document.getElementById(input.dataset.filter).tBodies[0].rows;
+  input.addEventListener("input", function () {
+    var q = input.value.toLowerCase();
+    for (var i = 0; i < rows.length; i++) {
+      rows[i].classList.toggle("hidden", q !== "" && rows[i].textContent.toLowerCase().indexOf(q) < 0);
+    }
+  });
+});
+document.querySelectorAll("th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table"), body = table.tBodies[0], col = th.cellIndex;
+    var desc = th.classList.contains("asc");
+    table.querySelectorAll("th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+    th.classList.add(desc ? "desc" : "asc");
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[col].textContent, y = b.cells[col].textContent;
+      var nx = parseFloat(x), ny = parseFloat(y);
+      var c = !isNaN(nx) && !isNaN(ny) ? nx - ny : x.localeCompare(y);
+      return desc ? -c : c;
+    });
+    rows.forEach(function (r) { body.appendChild(r); });
+  });
+});
+</script>
+</body>
+</html>
+{{define "node"}}{{if .Link}}<a href="{{.Link}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}{{end}}
+`))
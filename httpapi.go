@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// POST /convert is the plain HTTP counterpart of the gRPC Convert call: the
+// body is the canvas, the query string picks the format and options, and
+// the response is the export itself, with the node and edge counts in
+// X-Canvas-Nodes and X-Canvas-Edges. Errors are JSON objects with a code
+// and a message. /openapi.json describes these endpoints for client
+// generators.
+
+// apiError is the body of every HTTP error response.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiErrorCodes maps gRPC status codes to the HTTP API's.
+var apiErrorCodes = map[int]struct {
+	code   string
+	status int
+}{
+	grpcInvalidArgument: {"invalid_argument", http.StatusBadRequest},
+	grpcResourceLimit:   {"resource_exhausted", http.StatusRequestEntityTooLarge},
+	grpcUnauthenticated: {"unauthenticated", http.StatusUnauthorized},
+	grpcInternal:        {"internal", http.StatusInternalServerError},
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Code: code, Message: msg})
+}
+
+// formatMediaTypes are the Content-Type of each format's output; the rest
+// are text/plain.
+var formatMediaTypes = map[string]string{
+	"arrow":      "application/vnd.apache.arrow.file",
+	"csv":        "text/csv; charset=utf-8",
+	"dot":        "text/vnd.graphviz; charset=utf-8",
+	"graphml":    "application/graphml+xml",
+	"html-table": "text/html; charset=utf-8",
+	"jsonld":     "application/ld+json",
+	"parquet":    "application/vnd.apache.parquet",
+	"sql":        "application/sql",
+	"toml":       "application/toml",
+	"yaml":       "application/yaml",
+}
+
+func (s *server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "POST a canvas to /convert")
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	keepPath, _ := strconv.ParseBool(q.Get("keep_path"))
+	settings := map[string]string{}
+	for k, vs := range q {
+		if k != "format" && k != "keep_path" && len(vs) > 0 {
+			settings[k] = vs[len(vs)-1]
+		}
+	}
+	body := io.Reader(r.Body)
+	if s.limits.maxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, s.limits.maxBytes+1) // one over, so the parser reports the limit
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, "resource_exhausted", "canvas is over the "+strconv.FormatInt(s.limits.maxBytes, 10)+" byte limit")
+			return
+		}
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "read canvas: "+err.Error())
+		return
+	}
+
+	out, g, err := s.convert(data, format, keepPath, settings, "http")
+	if err != nil {
+		var st *grpcStatus
+		if !errors.As(err, &st) {
+			st = &grpcStatus{code: grpcInternal, msg: err.Error()}
+		}
+		c := apiErrorCodes[st.code]
+		writeAPIError(w, c.status, c.code, st.msg)
+		return
+	}
+	mediaType, ok := formatMediaTypes[format]
+	if !ok {
+		mediaType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("X-Canvas-Nodes", strconv.Itoa(len(g.Nodes)))
+	w.Header().Set("X-Canvas-Edges", strconv.Itoa(len(g.Edges)))
+	w.Write(out.Bytes())
+}
+
+func (s *server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(openAPISpec(s.auth))
+}
+
+// convertOptionSchema describes the export settings /convert accepts in
+// its query string: the -opt settings as format.key.
+func convertOptionSchema() map[string]any {
+	props := map[string]any{}
+	for _, format := range sortedKeys(formatOptions) {
+		for _, key := range sortedKeys(formatOptions[format]) {
+			if format+"."+key == "jsonld.context" {
+				continue
+			}
+			props[format+"."+key] = map[string]any{"type": "string", "description": formatOptions[format][key]}
+		}
+	}
+	return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+}
+
+// openAPISpec returns the OpenAPI 3 description of the HTTP endpoints.
+func openAPISpec(auth bool) map[string]any {
+	var formats []string
+	for _, name := range exporterNames() {
+		if exporters[name].write != nil {
+			formats = append(formats, name)
+		}
+	}
+	errorResponse := func(desc string) map[string]any {
+		return map[string]any{
+			"description": desc,
+			"content":     map[string]any{"application/json": map[string]any{"schema": ref("Error")}},
+		}
+	}
+	outputs := map[string]any{}
+	for _, name := range formats {
+		mt, ok := formatMediaTypes[name]
+		if !ok {
+			mt = "text/plain"
+		}
+		mt, _, _ = strings.Cut(mt, ";")
+		outputs[mt] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+	}
+	params := []any{
+		map[string]any{"name": "format", "in": "query", "schema": ref("Format")},
+		map[string]any{"name": "keep_path", "in": "query", "description": "keep full paths for file nodes", "schema": map[string]any{"type": "boolean", "default": false}},
+		map[string]any{"name": "options", "in": "query", "style": "form", "explode": true, "schema": ref("ConvertOptions")},
+	}
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "canvas_tool serve",
+			"version":     toolVersion(),
+			"description": "Convert Obsidian canvases to other graph formats and query the served canvases.",
+		},
+		"paths": map[string]any{
+			"/convert": map[string]any{
+				"post": map[string]any{
+					"operationId": "convert",
+					"summary":     "Convert a canvas to another format",
+					"parameters":  params,
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  map[string]any{"application/json": map[string]any{"schema": ref("Canvas")}},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "the export, in the format's media type",
+							"headers": map[string]any{
+								"X-Canvas-Nodes": map[string]any{"schema": map[string]any{"type": "integer"}},
+								"X-Canvas-Edges": map[string]any{"schema": map[string]any{"type": "integer"}},
+							},
+							"content": outputs,
+						},
+						"400": errorResponse("bad canvas, format or option"),
+						"401": errorResponse("missing or unknown bearer token"),
+						"413": errorResponse("canvas over the server's size limits"),
+						"429": errorResponse("rate limit exceeded; see Retry-After"),
+					},
+				},
+			},
+			"/graphql": map[string]any{
+				"post": map[string]any{
+					"operationId": "graphql",
+					"summary":     "Query the served canvases with GraphQL",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+							"type":     "object",
+							"required": []string{"query"},
+							"properties": map[string]any{
+								"query":         map[string]any{"type": "string"},
+								"operationName": map[string]any{"type": "string"},
+								"variables":     map[string]any{"type": "object", "additionalProperties": true},
+							},
+						}}},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "GraphQL result",
+							"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"data":   map[string]any{"type": "object", "additionalProperties": true},
+									"errors": map[string]any{"type": "array", "items": map[string]any{"type": "object", "properties": map[string]any{"message": map[string]any{"type": "string"}}}},
+								},
+							}}},
+						},
+					},
+				},
+			},
+			"/metrics": map[string]any{
+				"get": map[string]any{
+					"operationId": "metrics",
+					"summary":     "Prometheus metrics",
+					"responses": map[string]any{
+						"200": map[string]any{"description": "metrics in the Prometheus text format", "content": map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}},
+					},
+				},
+			},
+			"/openapi.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "openapi",
+					"summary":     "This description",
+					"responses":   map[string]any{"200": map[string]any{"description": "OpenAPI 3 document", "content": map[string]any{"application/json": map[string]any{}}}},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Format":         map[string]any{"type": "string", "enum": formats, "default": "csv"},
+				"ConvertOptions": convertOptionSchema(),
+				"Error": map[string]any{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": map[string]any{
+						"code":    map[string]any{"type": "string", "enum": []string{"invalid_argument", "resource_exhausted", "unauthenticated", "rate_limited", "method_not_allowed", "internal"}},
+						"message": map[string]any{"type": "string"},
+					},
+				},
+				"Canvas": map[string]any{
+					"type":     "object",
+					"required": []string{"nodes"},
+					"properties": map[string]any{
+						"nodes": map[string]any{"type": "array", "items": ref("Node")},
+						"edges": map[string]any{"type": "array", "items": ref("Edge")},
+					},
+					"additionalProperties": true,
+				},
+				"Node": map[string]any{
+					"type":     "object",
+					"required": []string{"id", "type"},
+					"properties": map[string]any{
+						"id":     map[string]any{"type": "string"},
+						"type":   map[string]any{"type": "string", "enum": []string{"text", "file", "link", "group"}},
+						"text":   map[string]any{"type": "string"},
+						"file":   map[string]any{"type": "string"},
+						"url":    map[string]any{"type": "string"},
+						"label":  map[string]any{"type": "string"},
+						"x":      map[string]any{"type": "number"},
+						"y":      map[string]any{"type": "number"},
+						"width":  map[string]any{"type": "number"},
+						"height": map[string]any{"type": "number"},
+						"color":  map[string]any{"type": "string"},
+					},
+					"additionalProperties": true,
+				},
+				"Edge": map[string]any{
+					"type":     "object",
+					"required": []string{"fromNode", "toNode"},
+					"properties": map[string]any{
+						"id":       map[string]any{"type": "string"},
+						"fromNode": map[string]any{"type": "string"},
+						"toNode":   map[string]any{"type": "string"},
+						"label":    map[string]any{"type": "string"},
+						"fromSide": map[string]any{"type": "string", "enum": []string{"top", "right", "bottom", "left"}},
+						"toSide":   map[string]any{"type": "string", "enum": []string{"top", "right", "bottom", "left"}},
+						"fromEnd":  map[string]any{"type": "string", "enum": []string{"none", "arrow"}},
+						"toEnd":    map[string]any{"type": "string", "enum": []string{"none", "arrow"}},
+						"color":    map[string]any{"type": "string"},
+					},
+					"additionalProperties": true,
+				},
+			},
+		},
+	}
+	if auth {
+		spec["components"].(map[string]any)["securitySchemes"] = map[string]any{
+			"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+		}
+		spec["security"] = []any{map[string]any{"bearer": []string{}}}
+	}
+	return spec
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
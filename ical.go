@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// -format ical turns the dated nodes of a timeline or roadmap canvas into
+// an .ics calendar. A node is an event when its text matches -opt
+// ical.pattern (default: an ISO date such as 2026-10-14); the pattern's
+// first group, or else the whole match, is parsed with the Go layout in
+// ical.layout. A second match in the same node ends the event, so
+// "Beta 2026-11-02 .. 2026-11-20" spans both days. Layouts without a time
+// give all-day events; with one, events last ical.duration (default 1h).
+// With -obsidian-uri, each event links back to its note, or to the canvas.
+
+const (
+	icalDefaultPattern = `\b\d{4}-\d{2}-\d{2}\b`
+	icalDefaultLayout  = "2006-01-02"
+)
+
+type icalEvent struct {
+	uid         string
+	start, end  time.Time
+	allDay      bool
+	summary     string
+	description string
+	url         string
+}
+
+func icalEvents(g *graph, opts exportOptions) ([]icalEvent, error) {
+	re, err := regexp.Compile(opts.opt("ical", "pattern", icalDefaultPattern))
+	if err != nil {
+		return nil, fmt.Errorf("-opt ical.pattern: %v", err)
+	}
+	layout := opts.opt("ical", "layout", icalDefaultLayout)
+	allDay := !strings.Contains(layout, "04") // no minutes, so no time of day
+	d := opts.opt("ical", "duration", "1h")
+	duration, err := time.ParseDuration(d)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("-opt ical.duration: want a positive duration such as 30m, got %q", d)
+	}
+
+	var events []icalEvent
+	for _, n := range g.Nodes {
+		text := n.Node.Text
+		switch n.Type {
+		case "text":
+		case "file":
+			text = strings.TrimSuffix(n.Name, path.Ext(n.Name))
+		default:
+			text = n.Name
+		}
+		matches := re.FindAllStringSubmatchIndex(text, 2)
+		if len(matches) == 0 {
+			continue
+		}
+		var dates []time.Time
+		for _, m := range matches {
+			lo, hi := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				lo, hi = m[2], m[3]
+			}
+			t, err := time.ParseInLocation(layout, text[lo:hi], time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("node %s: date %q doesn't fit -opt ical.layout %q", n.ID, text[lo:hi], layout)
+			}
+			dates = append(dates, t)
+		}
+		ev := icalEvent{
+			uid:         n.ID + "@canvas_tool",
+			start:       dates[0],
+			allDay:      allDay,
+			summary:     icalSummary(re.ReplaceAllString(firstLine(text), "")),
+			description: strings.TrimSpace(text),
+			url:         n.Attrs["obsidian_uri"],
+		}
+		if ev.summary == "" {
+			ev.summary = n.ID
+		}
+		if ev.url == "" {
+			ev.url = n.Attrs["canvas_uri"]
+		}
+		switch {
+		case len(dates) > 1 && dates[1].After(dates[0]) && allDay:
+			ev.end = dates[1].AddDate(0, 0, 1) // DTEND is exclusive
+		case len(dates) > 1 && dates[1].After(dates[0]):
+			ev.end = dates[1]
+		case allDay:
+			ev.end = dates[0].AddDate(0, 0, 1)
+		default:
+			ev.end = dates[0].Add(duration)
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// icalSummary tidies a title once its dates are cut out.
+func icalSummary(s string) string {
+	s = strings.TrimLeft(s, "#")
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Trim(s, " -–—:.,;()[]|")
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func writeICal(out io.Writer, g *graph, opts exportOptions) error {
+	events, err := icalEvents(g, opts)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	line := func(s string) { icalFold(w, s) }
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//canvas_tool//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, ev := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + icalText(ev.uid))
+		line("DTSTAMP:" + stamp)
+		if ev.allDay {
+			line("DTSTART;VALUE=DATE:" + ev.start.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + ev.end.Format("20060102"))
+		} else {
+			line("DTSTART:" + ev.start.UTC().Format("20060102T150405Z"))
+			line("DTEND:" + ev.end.UTC().Format("20060102T150405Z"))
+		}
+		line("SUMMARY:" + icalText(ev.summary))
+		if ev.description != ev.summary {
+			line("DESCRIPTION:" + icalText(ev.description))
+		}
+		if ev.url != "" {
+			line("URL:" + ev.url)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return w.Flush()
+}
+
+// icalText escapes a TEXT value (RFC 5545 3.3.11).
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icalFold writes a content line, folded at 75 octets without splitting a
+// UTF-8 sequence.
+func icalFold(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // the leading space counts
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
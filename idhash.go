@@ -0,0 +1,68 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+)
+
+// Obsidian gives nodes random IDs, so two saves of the same board export
+// differently. -id-hash swaps them for IDs derived from what each node
+// says, which survive copying, re-creating and reordering nodes.
+
+// contentID is the hash of a node's kind and content, prefixed so it is
+// still a valid identifier in every format. Nodes added by the tool (such
+// as -bundle clusters) have no canvas content and hash their name.
+func contentID(gn graphNode) string {
+	n := gn.Node
+	parts := []string{gn.Type, n.Text, slashPath(n.File), n.URL, n.Label}
+	if n.Text == "" && n.File == "" && n.URL == "" && n.Label == "" {
+		parts = append(parts, gn.Name)
+	}
+	h := sha256.New()
+	for _, s := range parts {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return "n" + hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// hashNodeIDs renames every node to its contentID and rewires the edges.
+// Nodes with the same content are told apart by position, top to bottom
+// then left to right, and numbered -2, -3 and so on after the first.
+func hashNodeIDs(g *graph) {
+	byHash := map[string][]int{}
+	for i, n := range g.Nodes {
+		id := contentID(n)
+		byHash[id] = append(byHash[id], i)
+	}
+	rename := make(map[string]string, len(g.Nodes))
+	for id, idxs := range byHash {
+		slices.SortStableFunc(idxs, func(a, b int) int {
+			na, nb := g.Nodes[a].Node, g.Nodes[b].Node
+			return cmp.Or(cmp.Compare(na.Y, nb.Y), cmp.Compare(na.X, nb.X))
+		})
+		for k, i := range idxs {
+			newID := id
+			if k > 0 {
+				newID += "-" + strconv.Itoa(k+1)
+			}
+			rename[g.Nodes[i].ID] = newID
+		}
+	}
+	for i := range g.Nodes {
+		g.Nodes[i].ID = rename[g.Nodes[i].ID]
+		g.Nodes[i].Node.ID = g.Nodes[i].ID
+	}
+	for i, e := range g.Edges {
+		if id, ok := rename[e.From]; ok {
+			g.Edges[i].From = id
+		}
+		if id, ok := rename[e.To]; ok {
+			g.Edges[i].To = id
+		}
+	}
+	g.index()
+}
@@ -0,0 +1,28 @@
+package main
+
+// interner stores each distinct string once and hands out small integer
+// handles, so a graph merged from thousands of canvases keeps one copy of
+// every repeated name, path and label.
+type interner struct {
+	ids  map[string]int32
+	strs []string
+}
+
+func newInterner() *interner {
+	in := &interner{ids: map[string]int32{}}
+	in.intern("") // handle 0 is the empty string
+	return in
+}
+
+func (in *interner) intern(s string) int32 {
+	if id, ok := in.ids[s]; ok {
+		return id
+	}
+	id := int32(len(in.strs))
+	s = string([]byte(s)) // don't pin the buffer s was sliced from
+	in.ids[s] = id
+	in.strs = append(in.strs, s)
+	return id
+}
+
+func (in *interner) str(id int32) string { return in.strs[id] }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// defaultJSONLDContext maps everything onto schema.org.
+var defaultJSONLDContext = map[string]any{"@vocab": "https://schema.org/"}
+
+// jsonLDTypes maps canvas node types onto schema.org classes.
+var jsonLDTypes = map[string]string{
+	"text":  "Thing",
+	"file":  "DigitalDocument",
+	"link":  "WebPage",
+	"group": "Collection",
+}
+
+// jsonLDNodeTerms are the properties writeJSONLD sets from the node itself.
+var jsonLDNodeTerms = map[string]bool{"name": true, "text": true, "contentUrl": true, "url": true}
+
+// writeJSONLD emits one @graph entry per node; each outgoing edge becomes a
+// property named after its label in lowerCamelCase ("depends on" -> dependsOn)
+// so the @context can map it to an IRI. A label that would land on one of
+// the node's own properties gets an "edge" prefix ("name" -> edgeName).
+func writeJSONLD(out io.Writer, g *graph, opts exportOptions) error {
+	ctx, err := loadJSONLDContext(opts.opt("jsonld", "context", ""))
+	if err != nil {
+		return err
+	}
+	base := opts.opt("jsonld", "base", "urn:canvas:")
+	id := func(nodeID string) string { return base + nodeID }
+
+	entries := make([]map[string]any, 0, len(g.Nodes))
+	index := make(map[string]map[string]any, len(g.Nodes))
+	for _, n := range g.Nodes {
+		e := map[string]any{"@id": id(n.ID), "name": n.Name}
+		if t, ok := jsonLDTypes[n.Type]; ok {
+			e["@type"] = t
+		}
+		if n.Node.Text != "" {
+			e["text"] = n.Node.Text
+		}
+		if n.Node.File != "" {
+			e["contentUrl"] = n.Node.File
+		}
+		if n.Node.URL != "" {
+			e["url"] = n.Node.URL
+		}
+		entries = append(entries, e)
+		index[n.ID] = e
+	}
+	for _, edge := range g.Edges {
+		from, ok := index[edge.From]
+		if !ok {
+			continue
+		}
+		prop := jsonLDTerm(edge.Label)
+		if jsonLDNodeTerms[prop] {
+			prop = "edge" + strings.ToUpper(prop[:1]) + prop[1:]
+		}
+		refs, _ := from[prop].([]map[string]string)
+		from[prop] = append(refs, map[string]string{"@id": id(edge.To)})
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{"@context": ctx, "@graph": entries})
+}
+
+func loadJSONLDContext(path string) (any, error) {
+	if path == "" {
+		return defaultJSONLDContext, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse JSON-LD context %s: %v", path, err)
+	}
+	// accept either a bare context object or a document wrapping one
+	if ctx, ok := doc["@context"]; ok {
+		return ctx, nil
+	}
+	return doc, nil
+}
+
+// jsonLDTerm turns an edge label into a lowerCamelCase term.
+func jsonLDTerm(label string) string {
+	var sb strings.Builder
+	upper := false
+	for _, r := range label {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = sb.Len() > 0
+			continue
+		}
+		switch {
+		case sb.Len() == 0:
+			sb.WriteRune(unicode.ToLower(r))
+		case upper:
+			sb.WriteRune(unicode.ToUpper(r))
+		default:
+			sb.WriteRune(r)
+		}
+		upper = false
+	}
+	if sb.Len() == 0 {
+		return "relatedTo"
+	}
+	return sb.String()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestJSONLDEdgeTerms checks that edges whose labels match a property of
+// the node keep both.
+func TestJSONLDEdgeTerms(t *testing.T) {
+	c, err := parseCanvas([]byte(`{
+		"nodes": [
+			{"id": "a", "type": "link", "url": "https://example.com", "x": 0, "y": 0, "width": 250, "height": 60},
+			{"id": "b", "type": "text", "text": "B", "x": 300, "y": 0, "width": 250, "height": 60}
+		],
+		"edges": [
+			{"id": "e1", "fromNode": "a", "toNode": "b", "label": "name"},
+			{"id": "e2", "fromNode": "a", "toNode": "b", "label": "url"},
+			{"id": "e3", "fromNode": "b", "toNode": "a", "label": "text"},
+			{"id": "e4", "fromNode": "b", "toNode": "a", "label": "content url"},
+			{"id": "e5", "fromNode": "b", "toNode": "a", "label": "depends on"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeJSONLD(&buf, buildGraph(c, false), exportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Graph []map[string]any `json:"@graph"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	toA := []any{map[string]any{"@id": "urn:canvas:a"}}
+	toB := []any{map[string]any{"@id": "urn:canvas:b"}}
+	want := []map[string]any{
+		{"@id": "urn:canvas:a", "@type": "WebPage", "name": "https://example.com", "url": "https://example.com", "edgeName": toB, "edgeUrl": toB},
+		{"@id": "urn:canvas:b", "@type": "Thing", "name": "B", "text": "B", "edgeText": toA, "edgeContentUrl": toA, "dependsOn": toA},
+	}
+	if !reflect.DeepEqual(doc.Graph, want) {
+		t.Errorf("@graph = %v\nwant %v", doc.Graph, want)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Default geometry for generated nodes, matching Obsidian's new-card size.
+const (
+	nodeWidth  = 250
+	nodeHeight = 60
+	nodeGapX   = 100
+	nodeGapY   = 80
+)
+
+// spacing is the gap left between neighbouring cards.
+type spacing struct {
+	X, Y float64
+}
+
+var defaultSpacing = spacing{nodeGapX, nodeGapY}
+
+// circleLayout places n nodes evenly on a circle large enough that
+// neighbouring cards don't overlap.
+func circleLayout(nodes []Node, gap spacing) {
+	n := len(nodes)
+	if n == 1 {
+		nodes[0].X, nodes[0].Y = 0, 0
+		return
+	}
+	step := nodeWidth + gap.X
+	radius := math.Max(step*float64(n)/(2*math.Pi), step)
+	for i := range nodes {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		nodes[i].X = math.Round(radius * math.Cos(a))
+		nodes[i].Y = math.Round(radius * math.Sin(a))
+	}
+}
+
+// gridLayout places nodes row by row in a roughly square grid.
+func gridLayout(nodes []Node, gap spacing) {
+	cols := max(int(math.Ceil(math.Sqrt(float64(len(nodes))))), 1)
+	for i := range nodes {
+		nodes[i].X = float64(i%cols) * (nodeWidth + gap.X)
+		nodes[i].Y = float64(i/cols) * (nodeHeight + gap.Y)
+	}
+}
+
+// autoLayout picks a layout suited to the number of nodes: a circle keeps
+// edges readable for small graphs, a grid keeps large ones compact.
+func autoLayout(nodes []Node, gap spacing) {
+	if len(nodes) == 0 {
+		return
+	}
+	if len(nodes) <= 40 {
+		circleLayout(nodes, gap)
+	} else {
+		gridLayout(nodes, gap)
+	}
+}
+
+// treeLayout positions a forest left to right: depth sets the column,
+// leaves are stacked top to bottom and parents are centred on their
+// children. Nodes not reachable from roots keep their position.
+func treeLayout(nodes []Node, roots []string, children map[string][]string, gap spacing) {
+	idx := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		idx[n.ID] = i
+	}
+	placed := map[string]bool{}
+	nextY := 0.0
+	var place func(id string, depth int) float64
+	place = func(id string, depth int) float64 {
+		i, ok := idx[id]
+		if !ok || placed[id] {
+			return nextY
+		}
+		placed[id] = true
+		n := &nodes[i]
+		n.X = float64(depth) * (nodeWidth + gap.X)
+		var ys []float64
+		for _, c := range children[id] {
+			if !placed[c] {
+				ys = append(ys, place(c, depth+1))
+			}
+		}
+		if len(ys) == 0 {
+			n.Y = nextY
+			nextY += n.Height + gap.Y/2
+		} else {
+			n.Y = (ys[0] + ys[len(ys)-1]) / 2
+		}
+		return n.Y
+	}
+	for _, r := range roots {
+		place(r, 0)
+	}
+}
+
+// layeredLayout arranges a directed graph in columns, left to right: each
+// node sits one column after its furthest predecessor (back edges of cycles
+// are ignored), and nodes within a column are ordered by the average row of
+// their predecessors to reduce crossings.
+func layeredLayout(nodes []Node, edges []Edge, gap spacing) {
+	layer, columns, preds := layerNodes(nodes, edges)
+	row := make([]float64, len(nodes))
+	for ci, col := range columns {
+		if ci > 0 {
+			bary := make(map[int]float64, len(col))
+			for _, v := range col {
+				sum, n := 0.0, 0
+				for _, p := range preds[v] {
+					if layer[p] < ci {
+						sum += row[p]
+						n++
+					}
+				}
+				if n > 0 {
+					bary[v] = sum / float64(n)
+				}
+			}
+			sort.SliceStable(col, func(i, j int) bool { return bary[col[i]] < bary[col[j]] })
+		}
+		for r, v := range col {
+			row[v] = float64(r)
+			nodes[v].X = float64(ci) * (nodeWidth + gap.X)
+			nodes[v].Y = float64(r) * (nodeHeight + gap.Y/2)
+		}
+	}
+}
+
+// layerNodes puts each node one layer past its furthest predecessor, once
+// back edges are dropped to break cycles. columns lists each layer's nodes
+// in topological order; preds excludes self-loops.
+func layerNodes(nodes []Node, edges []Edge) (layer []int, columns [][]int, preds [][]int) {
+	idx := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		idx[n.ID] = i
+	}
+	preds = make([][]int, len(nodes))
+	succs := make([][]int, len(nodes))
+	for _, e := range edges {
+		f, okF := idx[e.FromNode]
+		t, okT := idx[e.ToNode]
+		if okF && okT && f != t {
+			preds[t] = append(preds[t], f)
+			succs[f] = append(succs[f], t)
+		}
+	}
+
+	// drop back edges found by DFS so the rest is acyclic
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make([]int, len(nodes))
+	back := map[[2]int]bool{}
+	var order []int // reverse postorder = topological order
+	var dfs func(int)
+	dfs = func(v int) {
+		state[v] = active
+		for _, w := range succs[v] {
+			switch state[w] {
+			case unvisited:
+				dfs(w)
+			case active:
+				back[[2]int{v, w}] = true
+			}
+		}
+		state[v] = done
+		order = append(order, v)
+	}
+	for v := range nodes {
+		if state[v] == unvisited && len(preds[v]) == 0 {
+			dfs(v)
+		}
+	}
+	for v := range nodes {
+		if state[v] == unvisited {
+			dfs(v)
+		}
+	}
+
+	layer = make([]int, len(nodes))
+	for i := len(order) - 1; i >= 0; i-- {
+		v := order[i]
+		for _, w := range succs[v] {
+			if !back[[2]int{v, w}] && layer[w] < layer[v]+1 {
+				layer[w] = layer[v] + 1
+			}
+		}
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		v := order[i]
+		for len(columns) <= layer[v] {
+			columns = append(columns, nil)
+		}
+		columns[layer[v]] = append(columns[layer[v]], v)
+	}
+	return layer, columns, preds
+}
+
+// forceLayout is Fruchterman–Reingold: every pair of nodes repels, edges
+// pull their ends together, and the step size cools linearly over the
+// iterations. Nodes start from their current position, or from a circle
+// when they are all stacked at one point.
+func forceLayout(nodes []Node, edges []Edge, iterations int, seed int64, gap spacing) {
+	n := len(nodes)
+	if n < 2 {
+		return
+	}
+	idx := make(map[string]int, n)
+	for i, nd := range nodes {
+		idx[nd.ID] = i
+	}
+	stacked := true
+	for _, nd := range nodes[1:] {
+		if nd.X != nodes[0].X || nd.Y != nodes[0].Y {
+			stacked = false
+			break
+		}
+	}
+	if stacked {
+		circleLayout(nodes, gap)
+	}
+	x := make([]float64, n)
+	y := make([]float64, n)
+	for i, nd := range nodes {
+		x[i], y[i] = nd.X+nd.Width/2, nd.Y+nd.Height/2
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	k := nodeWidth + gap.X // ideal edge length
+	temp := k * math.Sqrt(float64(n))
+	dx := make([]float64, n)
+	dy := make([]float64, n)
+	for it := 0; it < iterations; it++ {
+		clear(dx)
+		clear(dy)
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				ddx, ddy := x[i]-x[j], y[i]-y[j]
+				d := math.Hypot(ddx, ddy)
+				if d < 0.01 { // coincident: push apart in a random direction
+					a := rng.Float64() * 2 * math.Pi
+					ddx, ddy, d = math.Cos(a), math.Sin(a), 1
+				}
+				f := k * k / d
+				dx[i] += ddx / d * f
+				dy[i] += ddy / d * f
+				dx[j] -= ddx / d * f
+				dy[j] -= ddy / d * f
+			}
+		}
+		for _, e := range edges {
+			f, okF := idx[e.FromNode]
+			t, okT := idx[e.ToNode]
+			if !okF || !okT || f == t {
+				continue
+			}
+			ddx, ddy := x[f]-x[t], y[f]-y[t]
+			d := math.Max(math.Hypot(ddx, ddy), 0.01)
+			a := d * d / k
+			dx[f] -= ddx / d * a
+			dy[f] -= ddy / d * a
+			dx[t] += ddx / d * a
+			dy[t] += ddy / d * a
+		}
+		limit := temp * (1 - float64(it)/float64(iterations))
+		for i := range nodes {
+			d := math.Hypot(dx[i], dy[i])
+			if d > 0 {
+				step := math.Min(d, limit)
+				x[i] += dx[i] / d * step
+				y[i] += dy[i] / d * step
+			}
+		}
+	}
+	for i := range nodes {
+		nodes[i].X = math.Round(x[i] - nodes[i].Width/2)
+		nodes[i].Y = math.Round(y[i] - nodes[i].Height/2)
+	}
+}
+
+// radialLayout puts the roots (nodes without incoming edges) at the centre
+// and each further level of a BFS tree on a ring around it. A subtree gets
+// a slice of its ring in proportion to its leaves, so branches stay apart.
+func radialLayout(nodes []Node, edges []Edge, gap spacing) {
+	if len(nodes) == 0 {
+		return
+	}
+	idx := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		idx[n.ID] = i
+	}
+	succs := make([][]int, len(nodes))
+	hasPred := make([]bool, len(nodes))
+	for _, e := range edges {
+		f, okF := idx[e.FromNode]
+		t, okT := idx[e.ToNode]
+		if okF && okT && f != t {
+			succs[f] = append(succs[f], t)
+			hasPred[t] = true
+		}
+	}
+	// BFS from the roots, then from whatever cycles left unreached.
+	depth := make([]int, len(nodes))
+	parent := make([]int, len(nodes))
+	children := make([][]int, len(nodes))
+	seen := make([]bool, len(nodes))
+	var roots []int
+	bfs := func(r int) {
+		roots = append(roots, r)
+		seen[r], parent[r] = true, -1
+		for queue := []int{r}; len(queue) > 0; queue = queue[1:] {
+			v := queue[0]
+			for _, w := range succs[v] {
+				if !seen[w] {
+					seen[w], parent[w], depth[w] = true, v, depth[v]+1
+					children[v] = append(children[v], w)
+					queue = append(queue, w)
+				}
+			}
+		}
+	}
+	for v := range nodes {
+		if !hasPred[v] && !seen[v] {
+			bfs(v)
+		}
+	}
+	for v := range nodes {
+		if !seen[v] {
+			bfs(v)
+		}
+	}
+	// With several roots, they form the first ring around an empty centre.
+	shift := 0
+	if len(roots) > 1 {
+		shift = 1
+	}
+	leaves := make([]float64, len(nodes))
+	var count func(v int) float64
+	count = func(v int) float64 {
+		leaves[v] = 0
+		for _, c := range children[v] {
+			leaves[v] += count(c)
+		}
+		leaves[v] = math.Max(leaves[v], 1)
+		return leaves[v]
+	}
+	total := 0.0
+	for _, r := range roots {
+		total += count(r)
+	}
+	// Each node's slice of its ring and the angle it sits at.
+	angle := make([]float64, len(nodes))
+	narrowest := map[int]float64{} // ring -> smallest slice on it
+	maxRing := 0
+	var slice func(v int, from, to float64)
+	slice = func(v int, from, to float64) {
+		angle[v] = (from + to) / 2
+		ring := depth[v] + shift
+		if w, ok := narrowest[ring]; !ok || to-from < w {
+			narrowest[ring] = to - from
+		}
+		maxRing = max(maxRing, ring)
+		width := to - from
+		for _, c := range children[v] {
+			span := width * leaves[c] / leaves[v]
+			slice(c, from, from+span)
+			from += span
+		}
+	}
+	from := 0.0
+	for _, r := range roots {
+		span := 2 * math.Pi * leaves[r] / total
+		slice(r, from, from+span)
+		from += span
+	}
+	// Rings are a card apart, and far enough out that the narrowest slice
+	// on each still holds a card.
+	radius := make([]float64, maxRing+1)
+	for d := 1; d <= maxRing; d++ {
+		radius[d] = radius[d-1] + nodeWidth + gap.X
+		if w := narrowest[d]; w < math.Pi {
+			radius[d] = math.Max(radius[d], (nodeWidth+gap.X)/w)
+		}
+	}
+	for v := range nodes {
+		r := radius[depth[v]+shift]
+		nodes[v].X = math.Round(r*math.Cos(angle[v]) - nodes[v].Width/2)
+		nodes[v].Y = math.Round(r*math.Sin(angle[v]) - nodes[v].Height/2)
+	}
+}
+
+// layoutFlags selects and tunes the layout of a generated canvas.
+type layoutFlags struct {
+	algo       string
+	gap        spacing
+	iterations int
+	seed       int64
+	stylePath  string
+	style      styleRules
+	coords     coordTransform
+}
+
+var layoutAlgos = []string{"auto", "grid", "circle", "radial", "tree", "layered", "force"}
+
+// registerLayoutFlags defines -algo (defaulting to algo) and the spacing
+// and force layout settings on fs.
+func registerLayoutFlags(fs *flag.FlagSet, lf *layoutFlags, algo string) {
+	fs.StringVar(&lf.algo, "algo", algo, "layout: "+strings.Join(layoutAlgos, ", "))
+	fs.Float64Var(&lf.gap.X, "gap-x", nodeGapX, "horizontal space between cards")
+	fs.Float64Var(&lf.gap.Y, "gap-y", nodeGapY, "vertical space between cards")
+	fs.IntVar(&lf.iterations, "iterations", 300, "force layout iterations")
+	fs.Int64Var(&lf.seed, "seed", 1, "random seed for the force layout")
+	fs.StringVar(&lf.stylePath, "style", "", "rules file colouring and sizing nodes by type, degree, cluster or name (see style.go)")
+	registerCoordFlags(fs, &lf.coords)
+}
+
+// load checks the flags and reads the -style file.
+func (lf *layoutFlags) load() error {
+	if !slices.Contains(layoutAlgos, lf.algo) {
+		return fmt.Errorf("unknown -algo %q (want %s)", lf.algo, strings.Join(layoutAlgos, ", "))
+	}
+	if err := lf.coords.check(); err != nil {
+		return err
+	}
+	if lf.stylePath != "" {
+		var err error
+		if lf.style, err = readStyle(lf.stylePath); err != nil {
+			return fmt.Errorf("-style: %v", err)
+		}
+	}
+	return nil
+}
+
+// apply styles nodes, so rule sizes feed the layout, places them and
+// transforms the result.
+func (lf layoutFlags) apply(nodes []Node, edges []Edge) {
+	lf.style.apply(nodes, edges)
+	lf.place(nodes, edges)
+	lf.coords.apply(nodes)
+}
+
+// place positions nodes with the selected layout; edges drive all but grid
+// and circle. A tree layout starts from the nodes without a parent.
+func (lf layoutFlags) place(nodes []Node, edges []Edge) {
+	switch lf.algo {
+	case "auto":
+		autoLayout(nodes, lf.gap)
+	case "grid":
+		gridLayout(nodes, lf.gap)
+	case "circle":
+		circleLayout(nodes, lf.gap)
+	case "radial":
+		radialLayout(nodes, edges, lf.gap)
+	case "layered":
+		layeredLayout(nodes, edges, lf.gap)
+	case "tree":
+		hasParent := map[string]bool{}
+		children := map[string][]string{}
+		for _, e := range edges {
+			if e.FromNode != e.ToNode {
+				children[e.FromNode] = append(children[e.FromNode], e.ToNode)
+				hasParent[e.ToNode] = true
+			}
+		}
+		// Roots first; the rest only matter for cycles with no way in.
+		var roots, others []string
+		for _, n := range nodes {
+			if hasParent[n.ID] {
+				others = append(others, n.ID)
+			} else {
+				roots = append(roots, n.ID)
+			}
+		}
+		treeLayout(nodes, append(roots, others...), children, lf.gap)
+	case "force":
+		forceLayout(nodes, edges, lf.iterations, lf.seed, lf.gap)
+	}
+}
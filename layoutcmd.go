@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"sort"
+)
+
+// layout re-positions the nodes of an existing canvas and writes their new
+// x/y back (with -style, also the colours and sizes the rules set), leaving
+// everything else in the file untouched.
+
+func runLayout(args []string) {
+	fs := flag.NewFlagSet("layout", flag.ExitOnError)
+	var lf layoutFlags
+	registerLayoutFlags(fs, &lf, "force")
+	out := fs.String("out", "", "write the laid-out canvas here instead of over the input")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatalf("layout: want one canvas")
+	}
+	if err := lf.load(); err != nil {
+		fatalf("layout: %v", err)
+	}
+	in := fs.Arg(0)
+	doc, err := readCanvasDoc(in)
+	if err != nil {
+		fatalf("layout: %v", err)
+	}
+	if *out == "" {
+		*out = in
+	}
+
+	// Groups aren't laid out themselves: each is refitted around the nodes
+	// it contained before the move.
+	nodes := make([]Node, len(doc.Nodes))
+	for i, o := range doc.Nodes {
+		nodes[i] = o.node()
+	}
+	var cards []Node
+	var cardIdx, groups []int
+	for i, n := range nodes {
+		if n.Type == "group" {
+			groups = append(groups, i)
+		} else {
+			cards = append(cards, n)
+			cardIdx = append(cardIdx, i)
+		}
+	}
+	members := map[int][]int{}
+	for _, g := range groups {
+		for i, n := range nodes {
+			if i != g && n.within(nodes[g]) {
+				members[g] = append(members[g], i)
+			}
+		}
+	}
+	var edges []Edge
+	for _, e := range doc.Edges {
+		edges = append(edges, Edge{FromNode: e.str("fromNode"), ToNode: e.str("toNode")})
+	}
+
+	lf.apply(cards, edges)
+	for j, i := range cardIdx {
+		nodes[i].X, nodes[i].Y = cards[j].X, cards[j].Y
+		if len(lf.style) > 0 || lf.coords.scale != 1 {
+			nodes[i].Width, nodes[i].Height = cards[j].Width, cards[j].Height
+			doc.Nodes[i].set("width", cards[j].Width)
+			doc.Nodes[i].set("height", cards[j].Height)
+			if cards[j].Color != "" {
+				doc.Nodes[i].set("color", cards[j].Color)
+			}
+		}
+	}
+
+	// Innermost groups first, so outer groups fit around refitted ones.
+	sort.SliceStable(groups, func(a, b int) bool {
+		return nodes[groups[a]].Width*nodes[groups[a]].Height < nodes[groups[b]].Width*nodes[groups[b]].Height
+	})
+	for _, g := range groups {
+		if len(members[g]) == 0 {
+			continue
+		}
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, m := range members[g] {
+			minX, minY = math.Min(minX, nodes[m].X), math.Min(minY, nodes[m].Y)
+			maxX, maxY = math.Max(maxX, nodes[m].X+nodes[m].Width), math.Max(maxY, nodes[m].Y+nodes[m].Height)
+		}
+		nodes[g].X, nodes[g].Y = minX-groupPadding, minY-groupPadding
+		nodes[g].Width, nodes[g].Height = maxX-minX+2*groupPadding, maxY-minY+2*groupPadding
+		doc.Nodes[g].set("width", nodes[g].Width)
+		doc.Nodes[g].set("height", nodes[g].Height)
+	}
+	for i := range doc.Nodes {
+		doc.Nodes[i].set("x", nodes[i].X)
+		doc.Nodes[i].set("y", nodes[i].Y)
+	}
+	if err := doc.write(*out); err != nil {
+		fatalf("layout: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Canvases from untrusted sources are checked before they are decoded: a
+// streaming scan bounds nesting depth and the number of nodes and edges
+// without materialising anything, so an oversized or hostile upload costs
+// no more than reading it.
+
+// parseLimits bounds what parseCanvasLimits accepts; zero means no limit.
+type parseLimits struct {
+	maxBytes int64
+	maxNodes int
+	maxEdges int
+	maxDepth int
+}
+
+// Canvases nest four levels deep; anything far deeper is not a canvas.
+// Without a limit encoding/json still stops at 10000 levels.
+const defaultMaxDepth = 64
+
+// serveLimits are the defaults for canvases uploaded to the server.
+var serveLimits = parseLimits{maxBytes: 16 << 20, maxNodes: 100_000, maxEdges: 200_000, maxDepth: defaultMaxDepth}
+
+// registerLimitFlags defines -max-bytes, -max-nodes, -max-edges and
+// -max-depth on fs, defaulting to def.
+func registerLimitFlags(fs *flag.FlagSet, lim *parseLimits, def parseLimits) {
+	fs.Int64Var(&lim.maxBytes, "max-bytes", def.maxBytes, "reject canvases larger than this many bytes (0: no limit)")
+	fs.IntVar(&lim.maxNodes, "max-nodes", def.maxNodes, "reject canvases with more nodes than this (0: no limit)")
+	fs.IntVar(&lim.maxEdges, "max-edges", def.maxEdges, "reject canvases with more edges than this (0: no limit)")
+	fs.IntVar(&lim.maxDepth, "max-depth", def.maxDepth, "reject JSON nested deeper than this (0: no limit)")
+}
+
+// limitError reports input rejected for its size rather than its content.
+type limitError struct{ msg string }
+
+func (e *limitError) Error() string { return e.msg }
+
+func limitErrorf(format string, args ...any) error {
+	return &limitError{fmt.Sprintf(format, args...)}
+}
+
+func isLimitError(err error) bool {
+	var le *limitError
+	return errors.As(err, &le)
+}
+
+// checkLimits scans data token by token, checking the size limits and that
+// the document is a single JSON object.
+func checkLimits(data []byte, lim parseLimits) error {
+	if lim.maxBytes > 0 && int64(len(data)) > lim.maxBytes {
+		return limitErrorf("canvas is %d bytes, over the %d byte limit", len(data), lim.maxBytes)
+	}
+	if lim.maxNodes <= 0 && lim.maxEdges <= 0 && lim.maxDepth <= 0 {
+		return nil // nothing to scan for; the decoder reports bad JSON
+	}
+	sc := &limitScanner{dec: json.NewDecoder(bytes.NewReader(data)), lim: lim}
+	if sc.lim.maxDepth <= 0 {
+		sc.lim.maxDepth = 10000 // encoding/json's own ceiling
+	}
+	err := sc.document()
+	var le *limitError
+	if err != nil && !errors.As(err, &le) {
+		return describeJSONError(data, err)
+	}
+	return err
+}
+
+type limitScanner struct {
+	dec *json.Decoder
+	lim parseLimits
+}
+
+func (sc *limitScanner) document() error {
+	tok, err := sc.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return errors.New("a canvas must be a JSON object")
+	}
+	for sc.dec.More() {
+		key, err := sc.dec.Token()
+		if err != nil {
+			return err
+		}
+		limit, what := 0, ""
+		switch key {
+		case "nodes":
+			limit, what = sc.lim.maxNodes, "nodes"
+		case "edges":
+			limit, what = sc.lim.maxEdges, "edges"
+		}
+		if what == "" {
+			if err := sc.value(2); err != nil {
+				return err
+			}
+			continue
+		}
+		tok, err := sc.dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("%q must be a list", what)
+		}
+		for n := 1; sc.dec.More(); n++ {
+			if limit > 0 && n > limit {
+				return limitErrorf("canvas has more than %d %s", limit, what)
+			}
+			if err := sc.value(3); err != nil {
+				return err
+			}
+		}
+		if _, err := sc.dec.Token(); err != nil {
+			return err
+		}
+	}
+	if _, err := sc.dec.Token(); err != nil {
+		return err
+	}
+	if _, err := sc.dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the canvas object")
+	}
+	return nil
+}
+
+// value skips one JSON value that starts at the given nesting depth.
+func (sc *limitScanner) value(depth int) error {
+	tok, err := sc.dec.Token()
+	if err != nil {
+		return err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	if depth > sc.lim.maxDepth {
+		return limitErrorf("JSON nested deeper than %d levels", sc.lim.maxDepth)
+	}
+	for sc.dec.More() {
+		if d == '{' {
+			if _, err := sc.dec.Token(); err != nil { // key
+				return err
+			}
+		}
+		if err := sc.value(depth + 1); err != nil {
+			return err
+		}
+	}
+	_, err = sc.dec.Token()
+	return err
+}
+
+// describeJSONError turns decoder errors into messages with a line and
+// column, naming the field for type mismatches.
+func describeJSONError(data []byte, err error) error {
+	var syn *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syn):
+		line, col := lineCol(data, syn.Offset)
+		return fmt.Errorf("line %d, column %d: %v", line, col, syn)
+	case errors.As(err, &typ):
+		line, col := lineCol(data, typ.Offset)
+		field := typ.Field
+		if field == "" {
+			field = "value"
+		}
+		want := "a " + typ.Type.Kind().String()
+		switch typ.Type.Kind() {
+		case reflect.Float64, reflect.Int:
+			want = "a number"
+		case reflect.Slice:
+			want = "a list"
+		case reflect.Struct, reflect.Map:
+			want = "an object"
+		}
+		return fmt.Errorf("line %d, column %d: %s must be %s, not %s", line, col, field, want, typ.Value)
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		return errors.New("invalid JSON: unexpected end of input")
+	}
+	return err
+}
+
+// lineCol converts a byte offset to a 1-based line and column.
+func lineCol(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Diagnostics go through log/slog. Interactive use keeps the traditional
+// "canvas_tool: message" lines; pipelines pick text or JSON records with
+// -log-format.
+
+// plainHandler writes "canvas_tool: message key=value ..." lines.
+type plainHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+	attrs []slog.Attr
+}
+
+func (h *plainHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString("canvas_tool: ")
+	if r.Level == slog.LevelWarn || r.Level == slog.LevelDebug {
+		b.WriteString(strings.ToLower(r.Level.String()) + ": ")
+	}
+	b.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		if v := a.Value.Resolve().String(); strings.ContainsAny(v, " \t\"=") || v == "" {
+			fmt.Fprintf(&b, " %s=%q", a.Key, v)
+		} else {
+			fmt.Fprintf(&b, " %s=%s", a.Key, v)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	b.WriteByte('\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &c
+}
+
+// WithGroup is not supported; groups are flattened.
+func (h *plainHandler) WithGroup(string) slog.Handler { return h }
+
+type logOptions struct {
+	format, level string
+}
+
+// registerLogFlags defines -log-format and -log-level on fs; format is the
+// default format for the command.
+func registerLogFlags(fs *flag.FlagSet, format string) *logOptions {
+	o := &logOptions{}
+	fs.StringVar(&o.format, "log-format", format, "diagnostics format: plain, text (key=value records) or json")
+	fs.StringVar(&o.level, "log-level", "info", "least severe diagnostics shown: debug, info, warn or error")
+	return o
+}
+
+// setup installs the default logger the flags describe.
+func (o *logOptions) setup() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.level)); err != nil {
+		return fmt.Errorf("-log-level: want debug, info, warn or error")
+	}
+	var h slog.Handler
+	switch o.format {
+	case "plain":
+		h = &plainHandler{mu: &sync.Mutex{}, w: os.Stderr, level: level}
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	default:
+		return fmt.Errorf("-log-format: unknown format %q (want plain, text or json)", o.format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+func init() {
+	// Commands without log flags get plain diagnostics.
+	slog.SetDefault(slog.New(&plainHandler{mu: &sync.Mutex{}, w: os.Stderr, level: slog.LevelInfo}))
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// merge combines many canvases into one graph. File nodes that point at
+// the same note become one node, as do link nodes with the same URL; text
+// and group nodes stay distinct per canvas. Canvases are read one at a
+// time into a compact interned form, so only the merged result is held.
+// With -edge-provenance every merged edge lists the canvases and edge IDs
+// it was merged from, so a relationship drawn in several canvases can be
+// traced back to each of them.
+
+type mergedNode struct {
+	key, typ, name int32
+	file, url      int32
+	canvases       int32 // number of canvases the node appears in
+	lastCanvas     int32 // index of the last canvas counted in canvases
+}
+
+type mergedEdge struct {
+	from, to, label int32 // node indexes and label handle
+}
+
+// edgeOrigin is one canvas edge a merged edge stands for.
+type edgeOrigin struct {
+	canvas, edge int32 // path and edge ID handles
+}
+
+type mergedGraph struct {
+	strs  *interner
+	nodes []mergedNode
+	byKey map[int32]int32 // key handle -> node index
+	edges map[mergedEdge]int32
+	order []mergedEdge // edges in first-seen order
+	form  string       // Unicode normal form of names, labels and path keys
+
+	caseless bool // text nodes merge by name, ignoring case
+
+	provenance bool // record the origins of each edge
+	origins    map[mergedEdge][]edgeOrigin
+}
+
+func newMergedGraph(form string) *mergedGraph {
+	return &mergedGraph{strs: newInterner(), byKey: map[int32]int32{}, edges: map[mergedEdge]int32{}, origins: map[mergedEdge][]edgeOrigin{}, form: form}
+}
+
+// mergeKey is the identity of a node across canvases; file paths compare in
+// the Unicode normal form. With caseless, text nodes are identified by
+// their case-folded name.
+func mergeKey(canvas string, n Node, form string, caseless bool) string {
+	switch c := n.Content().(type) {
+	case FileNode:
+		if c.File != "" {
+			return "file\x00" + strings.ToLower(normalizeString(slashPath(c.File), form))
+		}
+	case LinkNode:
+		if c.URL != "" {
+			return "link\x00" + c.URL
+		}
+	case TextNode:
+		if name := singleLine(nodeDisplay(n, true)); caseless && name != "" {
+			return "text\x00" + strings.ToLower(normalizeString(name, form))
+		}
+	}
+	return "node\x00" + canvas + "\x00" + n.ID
+}
+
+// add merges the canvas with index ci, loaded from path.
+func (m *mergedGraph) add(ci int32, path string, c Canvas) {
+	local := make(map[string]int32, len(c.Nodes))
+	for _, n := range c.Nodes {
+		key := m.strs.intern(mergeKey(path, n, m.form, m.caseless))
+		idx, ok := m.byKey[key]
+		if !ok {
+			idx = int32(len(m.nodes))
+			m.byKey[key] = idx
+			m.nodes = append(m.nodes, mergedNode{
+				key:        key,
+				typ:        m.strs.intern(n.Type),
+				name:       m.strs.intern(normalizeString(singleLine(nodeDisplay(n, true)), m.form)),
+				file:       m.strs.intern(slashPath(n.File)),
+				url:        m.strs.intern(n.URL),
+				lastCanvas: -1,
+			})
+		}
+		if mn := &m.nodes[idx]; mn.lastCanvas != ci {
+			mn.lastCanvas = ci
+			mn.canvases++
+		}
+		local[n.ID] = idx
+	}
+	for i, e := range c.Edges {
+		from, okFrom := local[e.FromNode]
+		to, okTo := local[e.ToNode]
+		if !okFrom || !okTo {
+			continue // dangling edges can't be placed in the merged graph
+		}
+		label := e.Label
+		if label == "" {
+			label = e.Text
+		}
+		me := mergedEdge{from: from, to: to, label: m.strs.intern(normalizeString(singleLine(label), m.form))}
+		if m.edges[me] == 0 {
+			m.order = append(m.order, me)
+		}
+		m.edges[me]++
+		if m.provenance {
+			id := e.ID
+			if id == "" {
+				id = fmt.Sprintf("edges[%d]", i) // no ID to trace it by; its place in the file
+			}
+			m.origins[me] = append(m.origins[me], edgeOrigin{canvas: m.strs.intern(path), edge: m.strs.intern(id)})
+		}
+	}
+}
+
+// graph expands the merged graph for the exporters. Nodes get the IDs m1,
+// m2, ... and canvases (how many canvases show the node) attributes; edges
+// get a count of the canvases' edges they stand for and, with provenance,
+// source_canvas and source_edge: the origins in matching comma-separated
+// lists.
+func (m *mergedGraph) graph() *graph {
+	g := &graph{Nodes: make([]graphNode, len(m.nodes)), Edges: make([]graphEdge, len(m.order))}
+	for i, n := range m.nodes {
+		id := "m" + strconv.Itoa(i+1)
+		g.Nodes[i] = graphNode{
+			ID:   id,
+			Type: m.strs.str(n.typ),
+			Name: m.strs.str(n.name),
+			Node: Node{ID: id, Type: m.strs.str(n.typ), File: m.strs.str(n.file), URL: m.strs.str(n.url)},
+		}
+	}
+	for i, e := range m.order {
+		g.Edges[i] = graphEdge{From: g.Nodes[e.from].ID, To: g.Nodes[e.to].ID, Label: m.strs.str(e.label)}
+	}
+	g.index()
+	for i, n := range m.nodes {
+		g.setAttr(i, "canvases", strconv.Itoa(int(n.canvases)))
+	}
+	for i, e := range m.order {
+		g.setEdgeAttr(i, "count", strconv.Itoa(int(m.edges[e])))
+		if m.provenance {
+			var canvases, ids []string
+			for _, o := range m.origins[e] {
+				canvases = append(canvases, m.strs.str(o.canvas))
+				ids = append(ids, m.strs.str(o.edge))
+			}
+			g.setEdgeAttr(i, "source_canvas", strings.Join(canvases, ","))
+			g.setEdgeAttr(i, "source_edge", strings.Join(ids, ","))
+		}
+	}
+	return g
+}
+
+// parseByteSize reads sizes such as 512MiB, 2G or 1048576.
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimRightFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	unit := strings.ToLower(strings.TrimSpace(s[len(num):]))
+	mult := map[string]int64{"": 1, "b": 1, "k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10, "m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20, "g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30}[unit]
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || mult == 0 {
+		return 0, fmt.Errorf("bad size %q (want e.g. 512MiB or 2G)", s)
+	}
+	return n * mult, nil
+}
+
+// heapInUse is the memory the Go heap currently holds.
+func heapInUse() int64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.HeapInuse)
+}
+
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	vault := fs.String("vault", "", "merge every canvas in this vault (instead of listing canvases)")
+	format := fs.String("format", "csv", "output format: "+strings.Join(exporterNames(), ", "))
+	out := fs.String("out", "-", "output path (or - for stdout)")
+	mergeCase := fs.Bool("merge-case-insensitive", false, "merge text nodes whose names differ only in case, across and within canvases")
+	form := fs.String("normalize", "nfc", "Unicode form names, labels and file paths are compared and written in: "+strings.Join(normForms, ", "))
+	provenance := fs.Bool("edge-provenance", false, "add source_canvas and source_edge columns: the canvases and edge IDs each merged edge came from")
+	maxMemory := fs.String("max-memory", "", "stop with an error once the heap grows past this size, e.g. 2GiB (default: no limit)")
+	var opts exportOptions
+	registerExportFlags(fs, &opts)
+	fs.Parse(args)
+
+	if err := checkNormForm(*form); err != nil {
+		fatalf("merge: -normalize: %v", err)
+	}
+	paths, err := expandInputs(fs.Args())
+	if err != nil {
+		fatalf("merge: %v", err)
+	}
+	if *vault != "" {
+		found, err := findCanvases(*vault)
+		if err != nil {
+			fatalf("merge: %v", err)
+		}
+		for _, p := range found {
+			paths = append(paths, filepath.Join(*vault, filepath.FromSlash(p)))
+		}
+	}
+	if len(paths) == 0 {
+		fatalf("merge: no canvases given (paths, globs or -vault)")
+	}
+	var limit int64
+	if *maxMemory != "" {
+		if limit, err = parseByteSize(*maxMemory); err != nil {
+			fatalf("merge: -max-memory: %v", err)
+		}
+		debug.SetMemoryLimit(limit) // collect harder before giving up
+	}
+	names, err := newOutputNamer("", "", false)
+	if err != nil {
+		fatalf("merge: %v", err)
+	}
+	targets, err := outputTargets(context.Background(), names.bind("merged", ""), []string{*out}, *format, nil, defaultPluginDir(), nil)
+	if err != nil {
+		fatalf("merge: %v", err)
+	}
+
+	m := newMergedGraph(*form)
+	m.caseless = *mergeCase
+	m.provenance = *provenance
+	for i, p := range paths {
+		rel := p
+		if *vault != "" {
+			if r, err := filepath.Rel(*vault, p); err == nil {
+				rel = filepath.ToSlash(r)
+			}
+		}
+		c, err := loadCanvas(p)
+		if err != nil {
+			slog.Warn("merge: skipped unreadable canvas", "path", p, "err", err)
+			continue
+		}
+		m.add(int32(i), rel, c)
+		if limit > 0 && heapInUse() > limit {
+			fatalf("merge: over -max-memory %s after %d of %d canvases (%d nodes, %d edges)", *maxMemory, i+1, len(paths), len(m.nodes), len(m.order))
+		}
+	}
+	slog.Debug("merged", "canvases", len(paths), "nodes", len(m.nodes), "edges", len(m.order), "strings", len(m.strs.strs))
+	g := m.graph()
+	for _, t := range targets {
+		if err := t.emit(context.Background(), g, opts, 1000); err != nil {
+			fatalf("merge: %v", err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal Prometheus instrumentation for serve and daemon mode: labelled
+// counters and histograms rendered in the text exposition format.
+
+type metricVec struct {
+	name    string
+	help    string
+	kind    string // counter or histogram
+	labels  []string
+	buckets []float64 // histograms only
+
+	mu     sync.Mutex
+	series map[string]*metricSeries // keyed by joined label values
+}
+
+type metricSeries struct {
+	values []string
+	value  float64  // counter value, or histogram sum
+	counts []uint64 // per-bucket (non-cumulative) histogram counts
+	count  uint64
+}
+
+type metricsRegistry struct {
+	vecs []*metricVec
+}
+
+func (r *metricsRegistry) counter(name, help string, labels ...string) *metricVec {
+	v := &metricVec{name: name, help: help, kind: "counter", labels: labels, series: map[string]*metricSeries{}}
+	r.vecs = append(r.vecs, v)
+	return v
+}
+
+func (r *metricsRegistry) histogram(name, help string, buckets []float64, labels ...string) *metricVec {
+	v := &metricVec{name: name, help: help, kind: "histogram", labels: labels, buckets: buckets, series: map[string]*metricSeries{}}
+	r.vecs = append(r.vecs, v)
+	return v
+}
+
+func (v *metricVec) get(values []string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &metricSeries{values: values, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *metricVec) add(delta float64, values ...string) {
+	v.mu.Lock()
+	v.get(values).value += delta
+	v.mu.Unlock()
+}
+
+func (v *metricVec) inc(values ...string) { v.add(1, values...) }
+
+func (v *metricVec) observe(x float64, values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s := v.get(values)
+	s.value += x
+	s.count++
+	for i, b := range v.buckets {
+		if x <= b {
+			s.counts[i]++
+			break
+		}
+	}
+}
+
+// handle serves the registry at /metrics.
+func (r *metricsRegistry) handle(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.write(w)
+}
+
+func (r *metricsRegistry) write(w io.Writer) {
+	for _, v := range r.vecs {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+		v.mu.Lock()
+		keys := make([]string, 0, len(v.series))
+		for k := range v.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := v.series[k]
+			if v.kind == "counter" {
+				fmt.Fprintf(w, "%s%s %s\n", v.name, metricLabels(v.labels, s.values, ""), formatMetric(s.value))
+				continue
+			}
+			var cum uint64
+			for i, b := range v.buckets {
+				cum += s.counts[i]
+				fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, metricLabels(v.labels, s.values, formatMetric(b)), cum)
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, metricLabels(v.labels, s.values, "+Inf"), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", v.name, metricLabels(v.labels, s.values, ""), formatMetric(s.value))
+			fmt.Fprintf(w, "%s_count%s %d\n", v.name, metricLabels(v.labels, s.values, ""), s.count)
+		}
+		v.mu.Unlock()
+	}
+}
+
+func metricLabels(names, values []string, le string) string {
+	var parts []string
+	for i, n := range names {
+		parts = append(parts, n+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		parts = append(parts, `le="`+le+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatMetric(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+
+// serveMetrics are the metrics exported by `serve` at /metrics.
+type serveMetrics struct {
+	reg           metricsRegistry
+	conversions   *metricVec
+	parseFailures *metricVec
+	edgesExported *metricVec
+	latency       *metricVec
+	reloads       *metricVec
+	rejected      *metricVec
+}
+
+func newServeMetrics() *serveMetrics {
+	m := &serveMetrics{}
+	m.conversions = m.reg.counter("canvas_tool_conversions_total", "Conversions performed, by format and result.", "format", "result")
+	m.parseFailures = m.reg.counter("canvas_tool_parse_failures_total", "Canvases that failed to parse, by entry point.", "source")
+	m.edgesExported = m.reg.counter("canvas_tool_edges_exported_total", "Edges written by successful conversions, by format.", "format")
+	m.latency = m.reg.histogram("canvas_tool_conversion_duration_seconds", "Conversion latency, by format.",
+		[]float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}, "format")
+	m.reloads = m.reg.counter("canvas_tool_reloads_total", "Served canvases reloaded after changing on disk, by result.", "result")
+	m.rejected = m.reg.counter("canvas_tool_rejected_requests_total", "Requests refused by -tokens or -rate, by reason.", "reason")
+	return m
+}
+
+// conversion records the outcome of one conversion that started at start.
+func (m *serveMetrics) conversion(format string, start time.Time, edges int, err error) {
+	m.latency.observe(time.Since(start).Seconds(), format)
+	if err != nil {
+		m.conversions.inc(format, "error")
+		return
+	}
+	m.conversions.inc(format, "ok")
+	m.edgesExported.add(float64(edges), format)
+}
+
+// daemonMetrics are the metrics exported by `daemon -metrics-addr`.
+type daemonMetrics struct {
+	reg         metricsRegistry
+	conversions *metricVec
+	latency     *metricVec
+	jobFailures *metricVec
+}
+
+func newDaemonMetrics() *daemonMetrics {
+	m := &daemonMetrics{}
+	m.conversions = m.reg.counter("canvas_tool_conversions_total", "Canvases converted by daemon jobs, by job, format and result (ok, error or unchanged).", "job", "format", "result")
+	m.latency = m.reg.histogram("canvas_tool_conversion_duration_seconds", "Conversion time including the child process, by job and format.",
+		[]float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 60}, "job", "format")
+	m.jobFailures = m.reg.counter("canvas_tool_job_failures_total", "Job runs that failed before converting anything, such as on bad flags or inputs, by job.", "job")
+	return m
+}
+
+// conversion records the outcome of one conversion of job that started at
+// start.
+func (m *daemonMetrics) conversion(j *daemonJob, start time.Time, err error) {
+	m.latency.observe(time.Since(start).Seconds(), j.Name, j.format())
+	if err != nil {
+		m.conversions.inc(j.Name, j.format(), "error")
+		return
+	}
+	m.conversions.inc(j.Name, j.format(), "ok")
+}
@@ -0,0 +1,125 @@
+//go:build ignore
+
+// mknormtables writes normtables.go, the Unicode data behind -normalize,
+// from the Unicode Character Database:
+//
+//	go run mknormtables.go UnicodeData.txt CompositionExclusions.txt
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		log.Fatal("usage: go run mknormtables.go UnicodeData.txt CompositionExclusions.txt")
+	}
+	ccc := map[rune]uint8{}
+	canonical := map[rune][]rune{}
+	compat := map[rune][]rune{}
+	eachLine(os.Args[1], func(fields []string) {
+		r := hex(fields[0])
+		if n, _ := strconv.Atoi(fields[3]); n > 0 {
+			ccc[r] = uint8(n)
+		}
+		if d := fields[5]; d != "" {
+			m := canonical
+			if strings.HasPrefix(d, "<") {
+				_, d, _ = strings.Cut(d, "> ")
+				m = compat
+			}
+			for _, h := range strings.Fields(d) {
+				m[r] = append(m[r], hex(h))
+			}
+		}
+	})
+	var excluded []rune
+	version := ""
+	eachLine(os.Args[2], func(fields []string) {
+		excluded = append(excluded, hex(fields[0]))
+	})
+	if data, err := os.ReadFile(os.Args[2]); err == nil {
+		first, _, _ := strings.Cut(string(data), "\n")
+		version = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(first, "#")), "CompositionExclusions-"), ".txt")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by mknormtables.go from Unicode %s; DO NOT EDIT.\n\npackage main\n\n", version)
+	b.WriteString("var combiningClass = map[rune]uint8{\n")
+	for _, r := range sortedRunes(ccc) {
+		fmt.Fprintf(&b, "0x%04X: %d,\n", r, ccc[r])
+	}
+	b.WriteString("}\n\n")
+	for _, t := range []struct {
+		name string
+		m    map[rune][]rune
+	}{{"canonicalDecomp", canonical}, {"compatDecomp", compat}} {
+		fmt.Fprintf(&b, "var %s = map[rune]string{\n", t.name)
+		for _, r := range sortedRunes(t.m) {
+			fmt.Fprintf(&b, "0x%04X: %s,\n", r, strconv.QuoteToASCII(string(t.m[r])))
+		}
+		b.WriteString("}\n\n")
+	}
+	sort.Slice(excluded, func(i, j int) bool { return excluded[i] < excluded[j] })
+	b.WriteString("// compositionExclusions never recompose; singletons and composites\n// starting with a non-starter are left out, as they follow from the\n// tables above.\nvar compositionExclusions = map[rune]bool{\n")
+	for _, r := range excluded {
+		fmt.Fprintf(&b, "0x%04X: true,\n", r)
+	}
+	b.WriteString("}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("normtables.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// eachLine calls fn with the ;-separated fields of each data line.
+func eachLine(path string, fn func([]string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		fn(fields)
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func hex(s string) rune {
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		log.Fatalf("bad code point %q", s)
+	}
+	return rune(n)
+}
+
+func sortedRunes[V any](m map[rune]V) []rune {
+	rs := make([]rune, 0, len(m))
+	for r := range m {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i] < rs[j] })
+	return rs
+}
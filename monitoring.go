@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// -format file-sd and nagios read a canvas as a network diagram: every
+// non-group node is a host, named by its text (an address attribute, e.g.
+// from frontmatter, overrides where it is reached), and an edge labelled
+// with a service, a port or both ("http:80", "ssh/22", "9100", "ping")
+// says that service runs on the edge's target. Unlabelled edges are plain
+// links; Nagios takes the hosts linking to a host as its parents. The
+// innermost group a host sits in becomes its Prometheus group label and
+// Nagios hostgroup.
+
+// hostService is one monitored service.
+type hostService struct {
+	host    string
+	address string
+	name    string // "" for a bare port
+	port    int    // 0 without one
+	group   string
+}
+
+var serviceLabelRE = regexp.MustCompile(`^([A-Za-z][\w.-]*)?[\s:/]*([0-9]{1,5})?$`)
+
+// parseServiceLabel splits an edge label into a service name and port;
+// ok is false for labels that are neither.
+func parseServiceLabel(label string) (name string, port int, ok bool) {
+	m := serviceLabelRE.FindStringSubmatch(strings.TrimSpace(label))
+	if m == nil || m[0] == "" {
+		return "", 0, false
+	}
+	if m[2] != "" {
+		if port, _ = strconv.Atoi(m[2]); port < 1 || port > 65535 {
+			return "", 0, false
+		}
+	}
+	return m[1], port, true
+}
+
+// monitoredHost is a host node and where it sits.
+type monitoredHost struct {
+	name    string
+	address string
+	group   string
+	parents []string
+}
+
+// networkHosts returns the hosts in canvas order and the services the
+// edges put on them.
+func networkHosts(g *graph) ([]*monitoredHost, []hostService) {
+	var hosts []*monitoredHost
+	byID := map[string]*monitoredHost{}
+	for i, n := range g.Nodes {
+		name := strings.TrimSpace(n.Name)
+		if n.Type == "group" || name == "" {
+			continue
+		}
+		h := &monitoredHost{name: name, address: name}
+		if a := n.Attrs["address"]; a != "" {
+			h.address = a
+		}
+		if gi := g.innermostGroup(i); gi >= 0 {
+			h.group = g.Nodes[gi].Name
+		}
+		hosts = append(hosts, h)
+		byID[n.ID] = h
+	}
+	var services []hostService
+	for _, e := range g.Edges {
+		to, ok := byID[e.To]
+		if !ok {
+			continue
+		}
+		if e.Label == "" {
+			if from, ok := byID[e.From]; ok && from != to && !slices.Contains(to.parents, from.name) {
+				to.parents = append(to.parents, from.name)
+			}
+			continue
+		}
+		name, port, ok := parseServiceLabel(e.Label)
+		if !ok {
+			slog.Debug("edge label is not a service, skipping", "label", e.Label, "to", to.name)
+			continue
+		}
+		services = append(services, hostService{host: to.name, address: to.address, name: name, port: port, group: to.group})
+	}
+	return hosts, services
+}
+
+// writeFileSD writes Prometheus file_sd JSON: one target group per service
+// and host group, with the service as the job label. Services without a
+// port can't be scraped and are left out.
+func writeFileSD(out io.Writer, g *graph, _ exportOptions) error {
+	type targetGroup struct {
+		Targets []string          `json:"targets"`
+		Labels  map[string]string `json:"labels,omitempty"`
+	}
+	_, services := networkHosts(g)
+	groups := []*targetGroup{}
+	index := map[[2]string]*targetGroup{}
+	for _, s := range services {
+		if s.port == 0 {
+			continue
+		}
+		job := s.name
+		if job == "" {
+			job = "port_" + strconv.Itoa(s.port)
+		}
+		key := [2]string{job, s.group}
+		tg, ok := index[key]
+		if !ok {
+			tg = &targetGroup{Labels: map[string]string{"job": job}}
+			if s.group != "" {
+				tg.Labels["group"] = s.group
+			}
+			index[key] = tg
+			groups = append(groups, tg)
+		}
+		target := s.address + ":" + strconv.Itoa(s.port)
+		if strings.Contains(s.address, ":") { // IPv6
+			target = "[" + s.address + "]:" + strconv.Itoa(s.port)
+		}
+		if !slices.Contains(tg.Targets, target) {
+			tg.Targets = append(tg.Targets, target)
+		}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(groups)
+}
+
+// writeNagios writes Nagios object definitions: a host per host node, a
+// hostgroup per group and a service per labelled edge, checked with
+// check_tcp when it has a port. The templates the objects use come from
+// -opt nagios.host-template and nagios.service-template.
+func writeNagios(out io.Writer, g *graph, opts exportOptions) error {
+	hostTemplate := opts.opt("nagios", "host-template", "generic-host")
+	serviceTemplate := opts.opt("nagios", "service-template", "generic-service")
+	hosts, services := networkHosts(g)
+	w := bufio.NewWriter(out)
+	define := func(kind string, fields [][2]string) {
+		fmt.Fprintf(w, "define %s {\n", kind)
+		width := 0
+		for _, f := range fields {
+			width = max(width, len(f[0]))
+		}
+		for _, f := range fields {
+			if f[1] != "" {
+				fmt.Fprintf(w, "    %-*s  %s\n", width, f[0], nagiosValue(f[1]))
+			}
+		}
+		fmt.Fprint(w, "}\n\n")
+	}
+
+	var groups []string
+	for _, h := range hosts {
+		if h.group != "" && !slices.Contains(groups, h.group) {
+			groups = append(groups, h.group)
+		}
+	}
+	for _, grp := range groups {
+		define("hostgroup", [][2]string{{"hostgroup_name", nagiosName(grp)}, {"alias", grp}})
+	}
+	for _, h := range hosts {
+		parents := make([]string, len(h.parents))
+		for i, p := range h.parents {
+			parents[i] = nagiosName(p)
+		}
+		fields := [][2]string{
+			{"use", hostTemplate},
+			{"host_name", nagiosName(h.name)},
+			{"alias", h.name},
+			{"address", h.address},
+			{"parents", strings.Join(parents, ",")},
+		}
+		if h.group != "" {
+			fields = append(fields, [2]string{"hostgroups", nagiosName(h.group)})
+		}
+		define("host", fields)
+	}
+	seen := map[string]bool{}
+	for _, s := range services {
+		desc := s.name
+		switch {
+		case desc == "":
+			desc = "port " + strconv.Itoa(s.port)
+		case s.port != 0:
+			desc += " " + strconv.Itoa(s.port)
+		}
+		key := s.host + "\x00" + desc
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		fields := [][2]string{
+			{"use", serviceTemplate},
+			{"host_name", nagiosName(s.host)},
+			{"service_description", desc},
+		}
+		if s.port != 0 {
+			fields = append(fields, [2]string{"check_command", "check_tcp!" + strconv.Itoa(s.port)})
+		}
+		define("service", fields)
+	}
+	return w.Flush()
+}
+
+// nagiosName makes an object name from a node's text: Nagios names can't
+// hold spaces or a few punctuation characters.
+func nagiosName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || strings.ContainsRune("`~!$%^&*|'\"<>?,()=;", r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// nagiosValue keeps a directive value on one line.
+func nagiosValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MQTT publisher: -out mqtt://[user:pass@]host[:port]/topic/prefix sends the
+// graph's stats to <prefix>/stats and its edge list to <prefix>/edges, each
+// as one JSON message, for dashboards fed from a broker. The prefix defaults
+// to "canvas". The query string tunes the publish:
+//
+//	stats=<topic>, edges=<topic>  publish there instead ("-" skips the message)
+//	qos=0|1                       delivery guarantee (default 1)
+//	retain=true|false             keep the last message for new subscribers (default true)
+//
+// mqtts:// connects over TLS (port 8883). Without a password in the URL,
+// MQTT_PASSWORD is used. This speaks MQTT 3.1.1.
+
+func isMQTTURL(s string) bool {
+	return strings.HasPrefix(s, "mqtt://") || strings.HasPrefix(s, "mqtts://")
+}
+
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttDisconnect = 14
+)
+
+// mqttConnackErrors are the CONNACK return codes.
+var mqttConnackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+type mqttConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID uint16
+}
+
+// writePacket sends one control packet.
+func (mc *mqttConn) writePacket(kind, flags byte, body []byte) error {
+	if len(body) > 268435455 {
+		return fmt.Errorf("packet of %d bytes is over MQTT's limit", len(body))
+	}
+	hdr := []byte{kind<<4 | flags}
+	for n := len(body); ; { // remaining length, 7 bits a byte
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		hdr = append(hdr, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := mc.conn.Write(append(hdr, body...))
+	return err
+}
+
+// readPacket reads one control packet.
+func (mc *mqttConn) readPacket() (kind byte, body []byte, err error) {
+	first, err := mc.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := mc.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+	}
+	body = make([]byte, n)
+	_, err = io.ReadFull(mc.r, body)
+	return first >> 4, body, err
+}
+
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// dialMQTT connects and logs in. Like dialNeo4j, the connection is closed
+// when ctx is done.
+func dialMQTT(ctx context.Context, u *url.URL) (*mqttConn, error) {
+	tlsOn := u.Scheme == "mqtts"
+	host := u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if tlsOn {
+			port = "8883"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if tlsOn {
+		td := &tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = td.DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(ctx, func() { conn.Close() })
+	mc := &mqttConn{conn: conn, r: bufio.NewReader(conn)}
+
+	flags := byte(0x02) // clean session
+	var user, pass string
+	if u.User != nil {
+		user = u.User.Username()
+		flags |= 0x80
+		var ok bool
+		if pass, ok = u.User.Password(); !ok {
+			pass = os.Getenv("MQTT_PASSWORD") // keep secrets out of shell history
+		}
+		if pass != "" {
+			flags |= 0x40
+		}
+	}
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, flags) // protocol level 4 is 3.1.1
+	body = binary.BigEndian.AppendUint16(body, 60)
+	body = mqttString(body, fmt.Sprintf("canvas_tool-%d", os.Getpid()))
+	if flags&0x80 != 0 {
+		body = mqttString(body, user)
+	}
+	if flags&0x40 != 0 {
+		body = mqttString(body, pass)
+	}
+	if err := mc.writePacket(mqttConnect, 0, body); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	kind, ack, err := mc.readPacket()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read CONNACK: %v", err)
+	}
+	if kind != mqttConnack || len(ack) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("not an MQTT broker: got packet type %d", kind)
+	}
+	if ack[1] != 0 {
+		conn.Close()
+		msg, ok := mqttConnackErrors[ack[1]]
+		if !ok {
+			msg = "return code " + strconv.Itoa(int(ack[1]))
+		}
+		return nil, fmt.Errorf("connect refused: %s", msg)
+	}
+	return mc, nil
+}
+
+// publish sends msg to topic, waiting for the broker's PUBACK at QoS 1.
+func (mc *mqttConn) publish(topic string, msg []byte, qos byte, retain bool) error {
+	flags := qos << 1
+	if retain {
+		flags |= 1
+	}
+	body := mqttString(nil, topic)
+	mc.nextID++
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, mc.nextID)
+	}
+	if err := mc.writePacket(mqttPublish, flags, append(body, msg...)); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	for {
+		kind, ack, err := mc.readPacket()
+		if err != nil {
+			return fmt.Errorf("%s: wait for PUBACK: %v", topic, err)
+		}
+		if kind == mqttPuback && len(ack) >= 2 && binary.BigEndian.Uint16(ack) == mc.nextID {
+			return nil
+		}
+	}
+}
+
+func writeMQTT(ctx context.Context, rawURL string, g *graph) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	prefix := strings.Trim(u.Path, "/")
+	if prefix == "" {
+		prefix = "canvas"
+	}
+	topics := map[string]string{"stats": prefix + "/stats", "edges": prefix + "/edges"}
+	for k := range topics {
+		if t := q.Get(k); t != "" {
+			topics[k] = t
+		}
+	}
+	for _, t := range topics {
+		if strings.ContainsAny(t, "+#") {
+			return fmt.Errorf("topic %q may not contain wildcards", t)
+		}
+	}
+	qos := byte(1)
+	switch q.Get("qos") {
+	case "", "1":
+	case "0":
+		qos = 0
+	default:
+		return fmt.Errorf("qos %q: want 0 or 1", q.Get("qos"))
+	}
+	retain := true
+	if s := q.Get("retain"); s != "" {
+		if retain, err = strconv.ParseBool(s); err != nil {
+			return fmt.Errorf("retain %q: want true or false", s)
+		}
+	}
+
+	mc, err := dialMQTT(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer mc.conn.Close()
+	stats, _ := json.Marshal(computeStats(g))
+	pg := toPluginGraph(g)
+	if pg.Edges == nil {
+		pg.Edges = []pluginEdge{} // [], not null
+	}
+	edges, _ := json.Marshal(pg.Edges)
+	for _, m := range []struct {
+		topic string
+		msg   []byte
+	}{{topics["stats"], stats}, {topics["edges"], edges}} {
+		if m.topic == "-" {
+			continue
+		}
+		if err := mc.publish(m.topic, m.msg, qos, retain); err != nil {
+			return err
+		}
+	}
+	return mc.writePacket(mqttDisconnect, 0, nil)
+}
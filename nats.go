@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// NATS publisher: -out nats://[user:pass@|token@]host[:port]/prefix sends
+// every node to <prefix>.nodes and every edge to <prefix>.edges, one JSON
+// message each in the plugin protocol's node and edge shapes, so a canvas
+// can feed an event-driven pipeline. The prefix defaults to "canvas" and
+// may use the -out template fields, e.g. nats://localhost/kb.{{.Basename}}.
+// nats+tls:// always uses TLS; plain nats:// upgrades when the server asks.
+// The publish is confirmed with a PING, so errors the server reports (a
+// denied subject, an oversized message) fail the export.
+
+func isNATSURL(s string) bool {
+	return strings.HasPrefix(s, "nats://") || strings.HasPrefix(s, "nats+tls://")
+}
+
+// natsInfo is the part of the server's INFO message used here.
+type natsInfo struct {
+	TLSRequired  bool  `json:"tls_required"`
+	AuthRequired bool  `json:"auth_required"`
+	MaxPayload   int64 `json:"max_payload"`
+}
+
+type natsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	info natsInfo
+}
+
+// dialNATS connects and logs in. Like dialNeo4j, the connection is closed
+// when ctx is done.
+func dialNATS(ctx context.Context, u *url.URL) (*natsConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(ctx, func() { conn.Close() })
+	nc := &natsConn{conn: conn, r: bufio.NewReader(conn)}
+	line, err := nc.r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read INFO: %v", err)
+	}
+	payload, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok || json.Unmarshal([]byte(payload), &nc.info) != nil {
+		conn.Close()
+		return nil, fmt.Errorf("not a NATS server: %q", strings.TrimSpace(line))
+	}
+	if nc.info.TLSRequired || u.Scheme == "nats+tls" {
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS: %v", err)
+		}
+		nc.conn, nc.r = tc, bufio.NewReader(tc)
+	}
+	nc.w = bufio.NewWriter(nc.conn)
+
+	connect := map[string]any{
+		"verbose": false, "pedantic": false, "lang": "go", "protocol": 1,
+		"name": "canvas_tool", "version": toolVersion(),
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect["user"], connect["pass"] = u.User.Username(), pass
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	} else if token := os.Getenv("NATS_TOKEN"); token != "" { // keep secrets out of shell history
+		connect["auth_token"] = token
+	}
+	data, _ := json.Marshal(connect)
+	fmt.Fprintf(nc.w, "CONNECT %s\r\n", data)
+	if err := nc.flush(); err != nil {
+		nc.conn.Close()
+		return nil, fmt.Errorf("authenticate: %v", err)
+	}
+	return nc, nil
+}
+
+func (nc *natsConn) publish(subject string, msg []byte) error {
+	if nc.info.MaxPayload > 0 && int64(len(msg)) > nc.info.MaxPayload {
+		return fmt.Errorf("%s: message of %d bytes is over the server's %d byte limit", subject, len(msg), nc.info.MaxPayload)
+	}
+	fmt.Fprintf(nc.w, "PUB %s %d\r\n", subject, len(msg))
+	nc.w.Write(msg)
+	_, err := nc.w.WriteString("\r\n")
+	return err
+}
+
+// flush sends what is buffered and waits for the server to answer a PING,
+// returning the first error it reported on the way.
+func (nc *natsConn) flush() error {
+	nc.w.WriteString("PING\r\n")
+	if err := nc.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := nc.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			nc.w.WriteString("PONG\r\n")
+			nc.w.Flush()
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
+
+func writeNATS(ctx context.Context, rawURL string, g *graph) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	prefix := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", ".")
+	if prefix == "" {
+		prefix = "canvas"
+	}
+	if strings.ContainsAny(prefix, " \t*>") {
+		return fmt.Errorf("subject prefix %q may not contain spaces or wildcards", prefix)
+	}
+	nc, err := dialNATS(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer nc.conn.Close()
+
+	pg := toPluginGraph(g)
+	for _, n := range pg.Nodes {
+		msg, _ := json.Marshal(n)
+		if err := nc.publish(prefix+".nodes", msg); err != nil {
+			return err
+		}
+	}
+	for _, e := range pg.Edges {
+		msg, _ := json.Marshal(e)
+		if err := nc.publish(prefix+".edges", msg); err != nil {
+			return err
+		}
+	}
+	return nc.flush()
+}
@@ -0,0 +1,88 @@
+package main
+
+// The JSON Canvas spec has four node kinds, each with its own fields. Node
+// keeps the union of them as it appears in the file; Kind and Content give
+// exporters a typed view to switch on.
+
+// NodeKind is a canvas node type.
+type NodeKind uint8
+
+const (
+	KindUnknown NodeKind = iota // a type the spec doesn't define
+	KindText
+	KindFile
+	KindLink
+	KindGroup
+)
+
+var nodeKindNames = [...]string{KindUnknown: "unknown", KindText: "text", KindFile: "file", KindLink: "link", KindGroup: "group"}
+
+func (k NodeKind) String() string { return nodeKindNames[k] }
+
+// parseNodeKind maps a node's type to its kind. "url", which some older
+// exporters write for link nodes, is read as link.
+func parseNodeKind(typ string) NodeKind {
+	switch typ {
+	case "text":
+		return KindText
+	case "file":
+		return KindFile
+	case "link", "url":
+		return KindLink
+	case "group":
+		return KindGroup
+	}
+	return KindUnknown
+}
+
+// Kind returns the node's kind.
+func (n Node) Kind() NodeKind { return parseNodeKind(n.Type) }
+
+// TextNode holds Markdown text.
+type TextNode struct {
+	Text string
+}
+
+// FileNode embeds a vault file, optionally at a heading or block.
+type FileNode struct {
+	File    string
+	Subpath string // e.g. "#Heading"
+}
+
+// LinkNode embeds a web page.
+type LinkNode struct {
+	URL string
+}
+
+// GroupNode is a labelled region containing other nodes.
+type GroupNode struct {
+	Label           string
+	Background      string // image path
+	BackgroundStyle string // cover, ratio or repeat
+}
+
+// Content returns the node's kind-specific fields as a TextNode, FileNode,
+// LinkNode or GroupNode, or nil for an unknown kind.
+func (n Node) Content() any {
+	switch n.Kind() {
+	case KindText:
+		return TextNode{Text: n.Text}
+	case KindFile:
+		return FileNode{File: n.File, Subpath: n.Subpath}
+	case KindLink:
+		return LinkNode{URL: n.URL}
+	case KindGroup:
+		return GroupNode{Label: n.Label, Background: n.Background, BackgroundStyle: n.BackgroundStyle}
+	}
+	return nil
+}
+
+// normalizeKinds rewrites type aliases to the spec's names, so code that
+// compares Type sees "link" for every link node.
+func normalizeKinds(c *Canvas) {
+	for i, n := range c.Nodes {
+		if k := n.Kind(); k != KindUnknown && n.Type != k.String() {
+			c.Nodes[i].Type = k.String()
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// -normalize brings node names and edge labels to one Unicode form before
+// they are compared or written, so "é" typed on Windows (one code point)
+// and "é" from macOS (e plus a combining accent) name the same node. NFC
+// is the default; nfd, nfkc and nfkd are the other forms, and none turns
+// it off. The tables are generated by mknormtables.go.
+
+var normForms = []string{"nfc", "nfd", "nfkc", "nfkd", "none"}
+
+// Hangul syllables decompose by arithmetic rather than by table.
+const (
+	hangulBase  = 0xAC00
+	hangulL     = 0x1100
+	hangulV     = 0x1161
+	hangulT     = 0x11A7
+	hangulVN    = 21
+	hangulTN    = 28
+	hangulCount = 19 * hangulVN * hangulTN
+)
+
+// primaryComposites maps each pair that canonically composes to its
+// composite, built on first use from the decompositions.
+var primaryComposites = sync.OnceValue(func() map[[2]rune]rune {
+	pairs := map[[2]rune]rune{}
+	for r, d := range canonicalDecomp {
+		rs := []rune(d)
+		if len(rs) == 2 && !compositionExclusions[r] && combiningClass[r] == 0 && combiningClass[rs[0]] == 0 {
+			pairs[[2]rune{rs[0], rs[1]}] = r
+		}
+	}
+	return pairs
+})
+
+func checkNormForm(form string) error {
+	for _, f := range normForms {
+		if form == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown form %q (want %s)", form, strings.Join(normForms, ", "))
+}
+
+// normalizeString returns s in form, one of normForms.
+func normalizeString(s, form string) string {
+	if form == "none" || form == "" || isASCII(s) {
+		return s
+	}
+	compat := form == "nfkc" || form == "nfkd"
+	var rs []rune
+	for _, r := range s {
+		rs = decompose(rs, r, compat)
+	}
+	// canonical order: each run of non-starters stably by combining class
+	for i := 0; i < len(rs); {
+		if combiningClass[rs[i]] == 0 {
+			i++
+			continue
+		}
+		j := i
+		for j < len(rs) && combiningClass[rs[j]] != 0 {
+			j++
+		}
+		run := rs[i:j]
+		sort.SliceStable(run, func(a, b int) bool { return combiningClass[run[a]] < combiningClass[run[b]] })
+		i = j
+	}
+	if form == "nfc" || form == "nfkc" {
+		rs = compose(rs)
+	}
+	return string(rs)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// decompose appends the full decomposition of r.
+func decompose(rs []rune, r rune, compat bool) []rune {
+	if s := r - hangulBase; s >= 0 && s < hangulCount {
+		rs = append(rs, hangulL+s/(hangulVN*hangulTN), hangulV+s%(hangulVN*hangulTN)/hangulTN)
+		if t := s % hangulTN; t > 0 {
+			rs = append(rs, hangulT+t)
+		}
+		return rs
+	}
+	d, ok := canonicalDecomp[r]
+	if !ok && compat {
+		d, ok = compatDecomp[r]
+	}
+	if !ok {
+		return append(rs, r)
+	}
+	for _, c := range d {
+		rs = decompose(rs, c, compat)
+	}
+	return rs
+}
+
+// compose recombines a decomposed, canonically ordered string: a mark joins
+// the last starter unless a mark of the same or higher class sits between
+// them.
+func compose(rs []rune) []rune {
+	pairs := primaryComposites()
+	out := rs[:0]
+	starter, lastClass := -1, uint8(0)
+	for _, r := range rs {
+		class := combiningClass[r]
+		if starter >= 0 && (lastClass < class || lastClass == 0 && len(out)-1 == starter) {
+			s := out[starter]
+			if c, ok := composeHangul(s, r); ok {
+				out[starter] = c
+				continue
+			}
+			if c, ok := pairs[[2]rune{s, r}]; ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter, lastClass = len(out), 0
+		} else {
+			lastClass = class
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func composeHangul(a, b rune) (rune, bool) {
+	if a >= hangulL && a < hangulL+19 && b >= hangulV && b < hangulV+hangulVN {
+		return hangulBase + ((a-hangulL)*hangulVN+b-hangulV)*hangulTN, true
+	}
+	if s := a - hangulBase; s >= 0 && s < hangulCount && s%hangulTN == 0 && b > hangulT && b < hangulT+hangulTN {
+		return a + b - hangulT, true
+	}
+	return 0, false
+}
+
+// normalizeGraph puts node names and texts and edge labels in form. File
+// paths are left as they are, so notes are still found on disk.
+func normalizeGraph(g *graph, form string) {
+	if form == "none" {
+		return
+	}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		n.Name = normalizeString(n.Name, form)
+		n.Node.Text = normalizeString(n.Node.Text, form)
+		n.Node.Label = normalizeString(n.Node.Label, form)
+	}
+	for i := range g.Edges {
+		e := &g.Edges[i]
+		e.Label = normalizeString(e.Label, form)
+		e.Edge.Label = normalizeString(e.Edge.Label, form)
+		e.Edge.Text = normalizeString(e.Edge.Text, form)
+	}
+}
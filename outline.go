@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// -format outline and org: the graph as a mind-map tree, for reading in a
+// plain text editor. The tree follows edges from the roots: -opt
+// outline.root (org.root), or by default every node nothing points to,
+// then whatever is left over (cycles with no way in). A node is expanded where it is first reached;
+// reaching it again, through a cycle or a second parent, prints a
+// reference instead. An edge label prefixes its child as "[label]".
+
+// outlineItem is one line of the tree.
+type outlineItem struct {
+	depth int
+	name  string
+	label string
+	ref   bool // already expanded above
+	at    int  // for a reference, the index of the expanded item
+}
+
+// treeify lays out the tree from the nodes root matches, or from every
+// node without incoming edges; format names the -opt setting root is from.
+func treeify(g *graph, format, root string) ([]outlineItem, error) {
+	var roots []string
+	if root != "" {
+		m := &nodeMatcher{}
+		if err := m.add(root); err != nil {
+			return nil, fmt.Errorf("-opt %s.root: %v", format, err)
+		}
+		for _, n := range g.Nodes {
+			if m.match(n) {
+				roots = append(roots, n.ID)
+			}
+		}
+		if len(roots) == 0 {
+			return nil, fmt.Errorf("-opt %s.root: no node matches %q", format, root)
+		}
+	} else {
+		for _, n := range g.Nodes {
+			entered := false
+			for _, ei := range g.in[n.ID] {
+				entered = entered || g.Edges[ei].From != n.ID
+			}
+			if !entered {
+				roots = append(roots, n.ID)
+			}
+		}
+	}
+
+	var items []outlineItem
+	seen := map[string]int{} // ID -> index of its expanded item
+	var visit func(id, label string, depth int)
+	visit = func(id, label string, depth int) {
+		at, ref := seen[id]
+		items = append(items, outlineItem{depth: depth, name: g.name(id), label: label, ref: ref, at: at})
+		if ref {
+			return
+		}
+		seen[id] = len(items) - 1
+		for _, ei := range g.out[id] {
+			if e := g.Edges[ei]; e.To != id {
+				visit(e.To, e.Label, depth+1)
+			}
+		}
+	}
+	for _, id := range roots {
+		if _, ok := seen[id]; !ok {
+			visit(id, "", 0)
+		}
+	}
+	if root == "" {
+		for _, n := range g.Nodes {
+			if _, ok := seen[n.ID]; !ok {
+				visit(n.ID, "", 0)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (it outlineItem) text() string {
+	s := it.name
+	if it.label != "" {
+		s = "[" + it.label + "] " + s
+	}
+	return s
+}
+
+// writeOutline writes the tree tab-indented, marking references with "↑".
+func writeOutline(out io.Writer, g *graph, opts exportOptions) error {
+	items, err := treeify(g, "outline", opts.opt("outline", "root", ""))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	for _, it := range items {
+		s := it.text()
+		if it.ref {
+			s += " ↑"
+		}
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat("\t", it.depth), s)
+	}
+	return w.Flush()
+}
+
+// writeOrg writes the tree as org-mode headings; a reference links to the
+// heading where its node is expanded.
+func writeOrg(out io.Writer, g *graph, opts exportOptions) error {
+	items, err := treeify(g, "org", opts.opt("org", "root", ""))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	for _, it := range items {
+		s := it.text()
+		if it.ref {
+			s = strings.TrimSuffix(s, it.name) + "[[*" + orgLinkText(items[it.at].text()) + "][" + it.name + "]]"
+		}
+		fmt.Fprintf(w, "%s %s\n", strings.Repeat("*", it.depth+1), s)
+	}
+	return w.Flush()
+}
+
+// orgLinkText escapes the brackets that would end an org link early.
+func orgLinkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// One run can write several outputs from a single parse: repeated -out
+// paths, each in the format its extension names, or -formats into -outdir.
+// Output names come from a template, so batches of inputs and split
+// components don't collide.
+
+// outputTarget is one file (or stream, or database) to write.
+type outputTarget struct {
+	format string
+	ex     exporter
+	path   string
+
+	prov     *provenance // nil without -provenance
+	provMode string      // comment or sidecar
+}
+
+// lookupExporter returns the built-in exporter or export plugin for name.
+func lookupExporter(ctx context.Context, name, pluginDir string, settings map[string]string) (exporter, error) {
+	if ex, ok := exporters[name]; ok {
+		return ex, nil
+	}
+	path, err := findPlugin(pluginDir, "export", name)
+	if err != nil {
+		return exporter{}, fmt.Errorf("unknown -format %q (want one of: %s, or an export plugin)", name, strings.Join(exporterNames(), ", "))
+	}
+	return pluginExporter(ctx, path, name, settings), nil
+}
+
+// formatForPath names the built-in format whose extension path has, or "".
+func formatForPath(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, name := range exporterNames() {
+		if exporters[name].ext == ext {
+			return name
+		}
+	}
+	return ""
+}
+
+// outputName is what a -name-template can refer to.
+type outputName struct {
+	Basename  string // input file name without its extension
+	Format    string // e.g. csv
+	Ext       string // extension without the dot, e.g. html for html-table
+	Component string // component number with -split-components, else ""
+}
+
+// outputNamer turns an output name template into paths under a directory.
+type outputNamer struct {
+	tmpl *template.Template
+	dir  string
+	set  bool // -outdir or -name-template was given
+}
+
+func newOutputNamer(text, dir string, components bool) (*outputNamer, error) {
+	set := text != "" || dir != ""
+	if text == "" {
+		text = "{{.Basename}}.{{.Ext}}"
+		if components {
+			text = "{{.Basename}}-{{.Component}}.{{.Ext}}"
+		}
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &outputNamer{tmpl: tmpl, dir: dir, set: set}, nil
+}
+
+// boundNamer names the outputs of one input (and component).
+type boundNamer struct {
+	*outputNamer
+	in, component string
+}
+
+func (n *outputNamer) bind(in, component string) boundNamer {
+	return boundNamer{n, in, component}
+}
+
+func (b boundNamer) data(format, ext string) outputName {
+	base := strings.TrimSuffix(filepath.Base(b.in), filepath.Ext(b.in))
+	if b.in == "-" {
+		base = "stdin"
+	}
+	return outputName{Basename: base, Format: format, Ext: strings.TrimPrefix(ext, "."), Component: b.component}
+}
+
+// defaultPath names an output after the input in the output directory.
+// Without -outdir or -name-template, stdin and the clipboard write back to
+// where they came from.
+func (b boundNamer) defaultPath(format, ext string) (string, error) {
+	if !b.set && b.component == "" && (b.in == "-" || b.in == clipboardPath) {
+		return b.in, nil
+	}
+	var name strings.Builder
+	if err := b.tmpl.Execute(&name, b.data(format, ext)); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.dir, name.String()), nil
+}
+
+// expand fills in the template fields of an explicit -out path, so one
+// -out can name every component or input.
+func (b boundNamer) expand(path, format, ext string) (string, error) {
+	if !strings.Contains(path, "{{") {
+		return path, nil
+	}
+	tmpl, err := template.New("out").Option("missingkey=error").Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("-out: %v", err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, b.data(format, ext)); err != nil {
+		return "", fmt.Errorf("-out: %v", err)
+	}
+	return out.String(), nil
+}
+
+// outputTargets resolves -out, -format and -formats into the list of
+// outputs to write. With several -out paths the format comes from each
+// extension, falling back to -format when the extension isn't one.
+func outputTargets(ctx context.Context, names boundNamer, outs []string, format string, formats []string, pluginDir string, settings map[string]string) ([]outputTarget, error) {
+	if len(formats) > 0 && len(outs) > 0 {
+		return nil, fmt.Errorf("-formats writes to -outdir; it can't be combined with -out")
+	}
+	var targets []outputTarget
+	add := func(name, path string) error {
+		ex, err := lookupExporter(ctx, name, pluginDir, settings)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			path, err = names.defaultPath(name, ex.ext)
+		} else {
+			path, err = names.expand(path, name, ex.ext)
+		}
+		if err != nil {
+			return err
+		}
+		targets = append(targets, outputTarget{format: name, ex: ex, path: path})
+		return nil
+	}
+	switch {
+	case len(formats) > 0:
+		for _, f := range formats {
+			if err := add(f, ""); err != nil {
+				return nil, err
+			}
+		}
+	case len(outs) > 1:
+		for _, path := range outs {
+			name := format
+			if byExt := formatForPath(path); byExt != "" && !isNeo4jURL(path) && !isNATSURL(path) && !isMQTTURL(path) {
+				name = byExt
+			}
+			if err := add(name, path); err != nil {
+				return nil, err
+			}
+		}
+	case len(outs) == 1:
+		return targets, add(format, outs[0])
+	default:
+		return targets, add(format, "")
+	}
+	return targets, nil
+}
+
+// emit writes g to the target, stopping early if ctx is done.
+func (t outputTarget) emit(ctx context.Context, g *graph, opts exportOptions, neo4jBatch int) error {
+	if isNeo4jURL(t.path) {
+		if err := writeNeo4j(ctx, t.path, g, neo4jBatch); err != nil {
+			return fmt.Errorf("neo4j: %v", err)
+		}
+		return nil
+	}
+	if isNATSURL(t.path) {
+		if err := writeNATS(ctx, t.path, g); err != nil {
+			return fmt.Errorf("nats: %v", err)
+		}
+		return nil
+	}
+	if isMQTTURL(t.path) {
+		if err := writeMQTT(ctx, t.path, g); err != nil {
+			return fmt.Errorf("mqtt: %v", err)
+		}
+		return nil
+	}
+	if t.ex.toFile != nil {
+		if t.path == "-" || t.path == clipboardPath {
+			return fmt.Errorf("-format %s needs a file path for -out", t.format)
+		}
+		if err := t.ex.toFile(ctx, t.path, g, opts); err != nil {
+			return fmt.Errorf("write %s: %v", t.format, err)
+		}
+		return t.recordProvenance()
+	}
+	out, closeOut, err := openOut(t.path)
+	if err != nil {
+		return fmt.Errorf("open output: %v", err)
+	}
+	if err := t.ex.write(t.provenanceWriter(ctxWriter{ctx, out}), g, opts); err != nil {
+		closeOut()
+		return fmt.Errorf("write %s: %v", t.format, err)
+	}
+	if err := closeOut(); err != nil {
+		return fmt.Errorf("close output: %v", err)
+	}
+	return t.recordProvenance()
+}
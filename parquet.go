@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Minimal Parquet writer: one row group, one uncompressed PLAIN data page per
+// column, every column a UTF8 BYTE_ARRAY. Metadata is Thrift compact protocol.
+
+const (
+	parquetByteArray = 6
+	parquetRequired  = 0
+	parquetOptional  = 1
+	parquetUTF8      = 0 // ConvertedType
+	parquetPlain     = 0
+	parquetRLE       = 3
+	parquetDataPage  = 0
+)
+
+func writeParquet(out io.Writer, g *graph, opts exportOptions) error {
+	t, err := selectTable(g, opts.opt("parquet", "table", "edges"))
+	if err != nil {
+		return err
+	}
+
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(t.columns))
+	for ci, col := range t.columns {
+		var page bytes.Buffer
+		if !col.required {
+			levels := make([]bool, len(t.rows))
+			for ri, row := range t.rows {
+				levels[ri] = row[ci] != nil
+			}
+			rle := parquetDefLevels(levels)
+			binary.Write(&page, binary.LittleEndian, uint32(len(rle)))
+			page.Write(rle)
+		}
+		for _, row := range t.rows {
+			if v := row[ci]; v != nil {
+				binary.Write(&page, binary.LittleEndian, uint32(len(*v)))
+				page.WriteString(*v)
+			}
+		}
+
+		var hdr thriftWriter
+		hdr.i32(1, parquetDataPage)
+		hdr.i32(2, int32(page.Len()))
+		hdr.i32(3, int32(page.Len()))
+		hdr.beginStruct(5) // DataPageHeader
+		hdr.i32(1, int32(len(t.rows)))
+		hdr.i32(2, parquetPlain)
+		hdr.i32(3, parquetRLE)
+		hdr.i32(4, parquetRLE)
+		hdr.endStruct()
+		hdr.stop()
+
+		chunks[ci] = chunk{offset: int64(file.Len()), size: int64(hdr.buf.Len() + page.Len())}
+		file.Write(hdr.buf.Bytes())
+		file.Write(page.Bytes())
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1) // version
+	meta.beginList(2, thriftStruct, len(t.columns)+1)
+	meta.beginElem() // root
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(t.columns)))
+	meta.endStruct()
+	for _, col := range t.columns {
+		rep := int32(parquetOptional)
+		if col.required {
+			rep = parquetRequired
+		}
+		meta.beginElem()
+		meta.i32(1, parquetByteArray)
+		meta.i32(3, rep)
+		meta.str(4, col.name)
+		meta.i32(6, parquetUTF8)
+		meta.beginStruct(10) // LogicalType
+		meta.beginStruct(1)  // STRING
+		meta.endStruct()
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(3, int64(len(t.rows)))
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginElem() // RowGroup
+	meta.beginList(1, thriftStruct, len(t.columns))
+	var total int64
+	for ci, col := range t.columns {
+		c := chunks[ci]
+		total += c.size
+		meta.beginElem() // ColumnChunk
+		meta.i64(2, c.offset)
+		meta.beginStruct(3) // ColumnMetaData
+		meta.i32(1, parquetByteArray)
+		meta.beginList(2, thriftI32, 2)
+		meta.listI32(parquetPlain)
+		meta.listI32(parquetRLE)
+		meta.beginList(3, thriftBinary, 1)
+		meta.listStr(col.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(len(t.rows)))
+		meta.i64(6, c.size)
+		meta.i64(7, c.size)
+		meta.i64(9, c.offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(len(t.rows)))
+	meta.endStruct()
+	meta.str(6, "canvas_tool")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString("PAR1")
+
+	_, err = out.Write(file.Bytes())
+	return err
+}
+
+// parquetDefLevels encodes 0/1 definition levels with the RLE/bit-packed
+// hybrid encoding, using RLE runs only (bit width 1).
+func parquetDefLevels(levels []bool) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		buf.Write(binary.AppendUvarint(nil, uint64(j-i)<<1))
+		if levels[i] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i = j
+	}
+	return buf.Bytes()
+}
+
+// Thrift compact protocol, write side only.
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+type thriftWriter struct {
+	buf    bytes.Buffer
+	last   int16
+	nested []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(n int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64((n<<1)^(n>>63))))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+// beginElem starts a struct that is a list element (no field header).
+func (t *thriftWriter) beginElem() {
+	t.nested = append(t.nested, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.last = t.nested[len(t.nested)-1]
+	t.nested = t.nested[:len(t.nested)-1]
+}
+
+func (t *thriftWriter) stop() { t.buf.WriteByte(0) }
+
+func (t *thriftWriter) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xF0 | elem)
+		t.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) { t.varint(int64(v)) }
+
+func (t *thriftWriter) listStr(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// thriftReader decodes the Thrift compact protocol independently of
+// thriftWriter: structs become map[int16]any keyed by field ID, lists
+// []any, integers int64 and binaries string.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) readByte() byte {
+	if r.pos >= len(r.b) {
+		panic(fmt.Sprintf("thrift: read past the end at %d", r.pos))
+	}
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("thrift: bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 3:
+		return int64(int8(r.readByte()))
+	case 4, 5, 6: // i16, i32, i64
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.uvarint())
+		if r.pos+n > len(r.b) {
+			panic(fmt.Sprintf("thrift: binary of %d bytes runs past the end", n))
+		}
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		h := r.readByte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(h & 0x0F)
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("thrift: unexpected type %d at %d", typ, r.pos))
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	m := map[int16]any{}
+	last := int16(0)
+	for {
+		h := r.readByte()
+		if h == 0 {
+			return m
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		if _, dup := m[id]; dup {
+			panic(fmt.Sprintf("thrift: field %d twice", id))
+		}
+		m[id] = r.value(h & 0x0F)
+		last = id
+	}
+}
+
+// readParquet decodes a file written by writeParquet back into column
+// names, whether each is required, and the rows.
+func readParquet(data []byte) (names []string, required []bool, rows [][]*string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		return nil, nil, nil, fmt.Errorf("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLen
+	if footerStart < 4 {
+		return nil, nil, nil, fmt.Errorf("footer length %d runs past the start", footerLen)
+	}
+	fr := &thriftReader{b: data[footerStart : len(data)-8]}
+	meta := fr.structure()
+	if fr.pos != footerLen {
+		return nil, nil, nil, fmt.Errorf("footer decodes to %d of its %d bytes", fr.pos, footerLen)
+	}
+	numRows := int(meta[3].(int64))
+
+	schema := meta[2].([]any)
+	root := schema[0].(map[int16]any)
+	if int(root[5].(int64)) != len(schema)-1 {
+		return nil, nil, nil, fmt.Errorf("root has %d children for %d columns", root[5], len(schema)-1)
+	}
+	for _, el := range schema[1:] {
+		el := el.(map[int16]any)
+		if el[1].(int64) != parquetByteArray || el[6].(int64) != parquetUTF8 {
+			return nil, nil, nil, fmt.Errorf("column %v is not a UTF8 byte array", el[4])
+		}
+		names = append(names, el[4].(string))
+		required = append(required, el[3].(int64) == parquetRequired)
+	}
+
+	groups := meta[4].([]any)
+	if len(groups) != 1 {
+		return nil, nil, nil, fmt.Errorf("%d row groups, want 1", len(groups))
+	}
+	group := groups[0].(map[int16]any)
+	if int(group[3].(int64)) != numRows {
+		return nil, nil, nil, fmt.Errorf("row group has %d rows, file %d", group[3], numRows)
+	}
+	chunks := group[1].([]any)
+	if len(chunks) != len(names) {
+		return nil, nil, nil, fmt.Errorf("%d column chunks for %d columns", len(chunks), len(names))
+	}
+	rows = make([][]*string, numRows)
+	for i := range rows {
+		rows[i] = make([]*string, len(names))
+	}
+	var total int64
+	for ci, ch := range chunks {
+		cm := ch.(map[int16]any)[3].(map[int16]any)
+		if path := cm[3].([]any); len(path) != 1 || path[0] != names[ci] {
+			return nil, nil, nil, fmt.Errorf("column %d: path %v, want [%s]", ci, path, names[ci])
+		}
+		if int(cm[5].(int64)) != numRows {
+			return nil, nil, nil, fmt.Errorf("column %s: %d values, want %d", names[ci], cm[5], numRows)
+		}
+		offset, size := int(cm[9].(int64)), cm[7].(int64)
+		total += size
+		pr := &thriftReader{b: data, pos: offset}
+		hdr := pr.structure()
+		pageLen := int(hdr[3].(int64))
+		if int64(pr.pos-offset+pageLen) != size {
+			return nil, nil, nil, fmt.Errorf("column %s: header and page are %d bytes, metadata says %d", names[ci], pr.pos-offset+pageLen, size)
+		}
+		if n := int(hdr[5].(map[int16]any)[1].(int64)); n != numRows {
+			return nil, nil, nil, fmt.Errorf("column %s: page has %d values, want %d", names[ci], n, numRows)
+		}
+		page := data[pr.pos : pr.pos+pageLen]
+		defined := make([]bool, numRows)
+		if required[ci] {
+			for i := range defined {
+				defined[i] = true
+			}
+		} else {
+			n := int(binary.LittleEndian.Uint32(page))
+			lr := &thriftReader{b: page[4 : 4+n]}
+			for i := 0; lr.pos < n; {
+				h := lr.uvarint()
+				if h&1 != 0 {
+					return nil, nil, nil, fmt.Errorf("column %s: bit-packed definition levels", names[ci])
+				}
+				v := lr.readByte()
+				for k := uint64(0); k < h>>1; k++ {
+					defined[i] = v == 1
+					i++
+				}
+			}
+			page = page[4+n:]
+		}
+		for ri := range rows {
+			if !defined[ri] {
+				continue
+			}
+			n := int(binary.LittleEndian.Uint32(page))
+			s := string(page[4 : 4+n])
+			rows[ri][ci] = &s
+			page = page[4+n:]
+		}
+		if len(page) != 0 {
+			return nil, nil, nil, fmt.Errorf("column %s: %d bytes left over in the page", names[ci], len(page))
+		}
+	}
+	if group[2].(int64) != total {
+		return nil, nil, nil, fmt.Errorf("row group is %d bytes, its chunks %d", group[2], total)
+	}
+	return names, required, rows, nil
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	for _, tc := range exportCorpus {
+		for _, name := range []string{"edges", "nodes"} {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				g := corpusGraph(t, tc.name)
+				var buf bytes.Buffer
+				opts := exportOptions{format: formatOpts{"parquet": {"table": name}}}
+				if err := writeParquet(&buf, g, opts); err != nil {
+					t.Fatal(err)
+				}
+				names, required, rows, err := readParquet(buf.Bytes())
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := selectTable(g, name)
+				for i, col := range want.columns {
+					if i >= len(names) || names[i] != col.name || required[i] != col.required {
+						t.Fatalf("columns %v (required %v), want %+v", names, required, want.columns)
+					}
+				}
+				if len(names) != len(want.columns) {
+					t.Fatalf("%d columns, want %d", len(names), len(want.columns))
+				}
+				if !slices.EqualFunc(rows, want.rows, func(a, b []*string) bool { return slices.EqualFunc(a, b, sameCell) }) {
+					t.Errorf("rows %s, want %s", formatRows(rows), formatRows(want.rows))
+				}
+			})
+		}
+	}
+}
+
+func TestParquetDefLevels(t *testing.T) {
+	for _, tc := range []struct {
+		levels []bool
+		want   []byte
+	}{
+		{nil, nil},
+		{[]bool{true}, []byte{2, 1}},
+		{[]bool{true, true, false}, []byte{4, 1, 2, 0}},
+		{slices.Repeat([]bool{false}, 200), []byte{0x90, 0x03, 0}}, // a run over 63 takes a two-byte varint
+	} {
+		if got := parquetDefLevels(tc.levels); !bytes.Equal(got, tc.want) {
+			t.Errorf("parquetDefLevels(%v) = %x, want %x", tc.levels, got, tc.want)
+		}
+	}
+}
+
+func sameCell(a, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func formatRows(rows [][]*string) string {
+	var out [][]string
+	for _, row := range rows {
+		var r []string
+		for _, c := range row {
+			if c == nil {
+				r = append(r, "NULL")
+			} else {
+				r = append(r, fmt.Sprintf("%q", *c))
+			}
+		}
+		out = append(out, r)
+	}
+	return fmt.Sprint(out)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// File node paths are vault-relative with forward slashes when Obsidian
+// writes them, but canvases edited by hand or on other systems may use
+// backslashes, drive letters or absolute paths. These helpers treat both
+// separators alike wherever the tool runs.
+
+// slashPath returns p with every backslash turned into a forward slash.
+func slashPath(p string) string { return strings.ReplaceAll(p, `\`, "/") }
+
+// fileBase is the last element of a file node path, whichever separator
+// it uses.
+func fileBase(p string) string {
+	p = slashPath(p)
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
+
+// driveLetter reports a Windows drive prefix such as "C:".
+func driveLetter(p string) (string, bool) {
+	if len(p) >= 2 && p[1] == ':' && (p[0] >= 'a' && p[0] <= 'z' || p[0] >= 'A' && p[0] <= 'Z') {
+		return p[:2], true
+	}
+	return "", false
+}
+
+// isAbsFile reports whether a file node path is absolute on any system.
+func isAbsFile(p string) bool {
+	if _, ok := driveLetter(p); ok {
+		return true
+	}
+	return strings.HasPrefix(slashPath(p), "/")
+}
+
+var pathStyles = []string{"base", "relative", "absolute", "posix"}
+
+// styleFilePath renders a file node path:
+//
+//	base      file name only (the default)
+//	relative  vault-relative with / separators (paths outside the vault stay absolute)
+//	absolute  absolute native path, resolved against the vault
+//	posix     absolute with / separators and C: written as /c
+func styleFilePath(file, style, vault string) (string, error) {
+	slashed := slashPath(file)
+	abs := func() string {
+		if isAbsFile(file) {
+			return filepath.FromSlash(slashed)
+		}
+		root := vault
+		if root == "" {
+			root = "."
+		}
+		if a, err := filepath.Abs(filepath.Join(root, filepath.FromSlash(slashed))); err == nil {
+			return a
+		}
+		return filepath.Join(root, filepath.FromSlash(slashed))
+	}
+	switch style {
+	case "base", "":
+		return fileBase(file), nil
+	case "relative":
+		if !isAbsFile(file) {
+			return path.Clean(strings.TrimPrefix(slashed, "./")), nil
+		}
+		if vault != "" {
+			if root, err := filepath.Abs(vault); err == nil {
+				if rel, err := filepath.Rel(root, filepath.FromSlash(slashed)); err == nil && !strings.HasPrefix(rel, "..") {
+					return filepath.ToSlash(rel), nil
+				}
+			}
+		}
+		return slashed, nil
+	case "absolute":
+		return abs(), nil
+	case "posix":
+		p := slashPath(abs())
+		if drive, ok := driveLetter(p); ok {
+			p = "/" + strings.ToLower(drive[:1]) + p[2:]
+		}
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown path style %q (want %s)", style, strings.Join(pathStyles, ", "))
+}
+
+// applyPathStyle renames the file nodes whose display name comes from their
+// path.
+func applyPathStyle(g *graph, style, vault string) error {
+	for i, n := range g.Nodes {
+		if n.Node.File == "" || n.Node.Text != "" || n.Node.Label != "" {
+			continue
+		}
+		name, err := styleFilePath(n.Node.File, style, vault)
+		if err != nil {
+			return err
+		}
+		g.Nodes[i].Name = singleLine(name)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Plugins are executables in the plugins directory named export-<format> or
+// transform-<name>. Each is run once per conversion and sent a JSON request
+// on stdin:
+//
+//	{"version": 1, "options": {...}, "graph": {"nodes": [...], "edges": [...]}}
+//
+// The graph also carries the canvas's own top-level fields, if any, as
+// "meta": {"key": "value", ...}. An exporter writes the finished output to
+// stdout. A transform writes back a graph object ({"nodes": [...],
+// "edges": [...]}) that replaces the input; the metadata is kept as it was.
+// A non-zero exit status fails the conversion with the plugin's stderr.
+
+const pluginProtocolVersion = 1
+
+type pluginGraph struct {
+	Nodes []pluginNode      `json:"nodes"`
+	Edges []pluginEdge      `json:"edges"`
+	Meta  map[string]string `json:"meta,omitempty"`
+}
+
+type pluginNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Text  string `json:"text,omitempty"`
+	File  string `json:"file,omitempty"`
+	URL   string `json:"url,omitempty"`
+	Label string `json:"label,omitempty"`
+
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+type pluginEdge struct {
+	From  string            `json:"from"`
+	To    string            `json:"to"`
+	Label string            `json:"label"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+type pluginRequest struct {
+	Version int               `json:"version"`
+	Options map[string]string `json:"options"`
+	Graph   pluginGraph       `json:"graph"`
+}
+
+func defaultPluginDir() string {
+	if dir := os.Getenv("CANVAS_TOOL_PLUGINS"); dir != "" {
+		return dir
+	}
+	cfg, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cfg, "canvas_tool", "plugins")
+}
+
+// findPlugin returns the executable for kind ("export" or "transform") and
+// name, allowing a Windows executable extension.
+func findPlugin(dir, kind, name string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("no plugins directory for %s plugin %q", kind, name)
+	}
+	base := filepath.Join(dir, kind+"-"+name)
+	candidates := []string{base}
+	if runtime.GOOS == "windows" {
+		candidates = []string{base + ".exe", base + ".bat", base + ".cmd"}
+	}
+	for _, c := range candidates {
+		if fi, err := os.Stat(c); err == nil && !fi.IsDir() {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("no %s plugin %q in %s", kind, name, dir)
+}
+
+// listPlugins returns the plugin names of each kind found in dir.
+func listPlugins(dir string) (map[string][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string][]string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		if runtime.GOOS != "windows" {
+			name = e.Name()
+		}
+		for _, kind := range []string{"export", "transform"} {
+			if rest, ok := strings.CutPrefix(name, kind+"-"); ok && rest != "" {
+				found[kind] = append(found[kind], rest)
+			}
+		}
+	}
+	for _, names := range found {
+		sort.Strings(names)
+	}
+	return found, nil
+}
+
+func runPlugin(ctx context.Context, path string, g *graph, options map[string]string, stdout io.Writer) error {
+	req := pluginRequest{Version: pluginProtocolVersion, Options: options, Graph: toPluginGraph(g)}
+	if req.Options == nil {
+		req.Options = map[string]string{}
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s: %v: %s", filepath.Base(path), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// pluginExporter wraps an export plugin as a regular exporter.
+func pluginExporter(ctx context.Context, path, name string, options map[string]string) exporter {
+	return exporter{
+		ext: "." + name,
+		write: func(w io.Writer, g *graph, _ exportOptions) error {
+			return runPlugin(ctx, path, g, options, w)
+		},
+	}
+}
+
+func runTransformPlugin(ctx context.Context, path string, g *graph, options map[string]string) (*graph, error) {
+	var out bytes.Buffer
+	if err := runPlugin(ctx, path, g, options, &out); err != nil {
+		return nil, err
+	}
+	var pg pluginGraph
+	if err := json.Unmarshal(out.Bytes(), &pg); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid graph on stdout: %v", filepath.Base(path), err)
+	}
+	ng := fromPluginGraph(pg, g.attrs, g.edgeAttrs)
+	ng.meta = g.meta
+	return ng, nil
+}
+
+func toPluginGraph(g *graph) pluginGraph {
+	pg := pluginGraph{Nodes: []pluginNode{}, Edges: []pluginEdge{}}
+	for _, n := range g.Nodes {
+		pg.Nodes = append(pg.Nodes, pluginNode{
+			ID: n.ID, Type: n.Type, Name: n.Name,
+			Text: n.Node.Text, File: n.Node.File, URL: n.Node.URL, Label: n.Node.Label,
+			Attrs: n.Attrs,
+		})
+	}
+	for _, e := range g.Edges {
+		pg.Edges = append(pg.Edges, pluginEdge{From: e.From, To: e.To, Label: e.Label, Attrs: e.Attrs})
+	}
+	for _, m := range g.meta {
+		if pg.Meta == nil {
+			pg.Meta = map[string]string{}
+		}
+		pg.Meta[m.Key] = m.Value
+	}
+	return pg
+}
+
+// fromPluginGraph converts a plugin's graph back; attribute columns keep the
+// order of attrs and edgeAttrs, with any the plugin added appended in sorted order.
+func fromPluginGraph(pg pluginGraph, attrs, edgeAttrs []string) *graph {
+	g := &graph{attrs: slices.Clone(attrs), edgeAttrs: slices.Clone(edgeAttrs)}
+	for i, n := range pg.Nodes {
+		g.Nodes = append(g.Nodes, graphNode{
+			ID: n.ID, Type: n.Type, Name: n.Name,
+			Node: Node{ID: n.ID, Type: n.Type, Text: n.Text, File: n.File, URL: n.URL, Label: n.Label},
+		})
+		for _, k := range slices.Sorted(maps.Keys(n.Attrs)) {
+			g.setAttr(i, k, n.Attrs[k])
+		}
+	}
+	for i, e := range pg.Edges {
+		g.Edges = append(g.Edges, graphEdge{From: e.From, To: e.To, Label: e.Label})
+		for _, k := range slices.Sorted(maps.Keys(e.Attrs)) {
+			g.setEdgeAttr(i, k, e.Attrs[k])
+		}
+	}
+	g.index()
+	return g
+}
+
+func runPlugins(args []string) {
+	fs := flag.NewFlagSet("plugins", flag.ExitOnError)
+	dir := fs.String("dir", defaultPluginDir(), "plugins directory")
+	fs.Parse(args)
+
+	found, err := listPlugins(*dir)
+	if err != nil {
+		fatalf("plugins: %v", err)
+	}
+	for _, kind := range []string{"export", "transform"} {
+		for _, name := range found[kind] {
+			fmt.Printf("%s\t%s\n", kind, name)
+		}
+	}
+}
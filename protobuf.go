@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Protocol Buffers wire format helpers for the hand-written gRPC messages in
+// proto/canvastool.proto. Writers omit proto3 default values.
+
+const (
+	pbVarint = 0
+	pbI64    = 1
+	pbLen    = 2
+	pbI32    = 5
+)
+
+type pbWriter struct{ buf []byte }
+
+func (w *pbWriter) tag(field, wire int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field)<<3|uint64(wire))
+}
+
+func (w *pbWriter) int32(field int, v int32) {
+	if v != 0 {
+		w.tag(field, pbVarint)
+		w.buf = binary.AppendUvarint(w.buf, uint64(int64(v)))
+	}
+}
+
+func (w *pbWriter) bool(field int, v bool) {
+	if v {
+		w.tag(field, pbVarint)
+		w.buf = append(w.buf, 1)
+	}
+}
+
+func (w *pbWriter) bytes(field int, b []byte) {
+	if len(b) > 0 {
+		w.tag(field, pbLen)
+		w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+		w.buf = append(w.buf, b...)
+	}
+}
+
+func (w *pbWriter) string(field int, s string) { w.bytes(field, []byte(s)) }
+
+// message embeds m; it is written even when empty (repeated elements).
+func (w *pbWriter) message(field int, m *pbWriter) {
+	w.tag(field, pbLen)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(m.buf)))
+	w.buf = append(w.buf, m.buf...)
+}
+
+type pbField struct {
+	num    int
+	wire   int
+	varint uint64
+	data   []byte // pbLen payload
+}
+
+var errPBTruncated = errors.New("protobuf: truncated message")
+
+func pbFields(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errPBTruncated
+		}
+		b = b[n:]
+		f := pbField{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case pbVarint:
+			f.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errPBTruncated
+			}
+			b = b[n:]
+		case pbI64, pbI32:
+			size := 8
+			if f.wire == pbI32 {
+				size = 4
+			}
+			if len(b) < size {
+				return nil, errPBTruncated
+			}
+			b = b[size:]
+		case pbLen:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, errPBTruncated
+			}
+			f.data = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			return nil, errors.New("protobuf: unsupported wire type")
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// pbMapEntry decodes a map<string, string> entry.
+func pbMapEntry(b []byte) (key, value string, err error) {
+	fields, err := pbFields(b)
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			key = string(f.data)
+		case 2:
+			value = string(f.data)
+		}
+	}
+	return key, value, err
+}
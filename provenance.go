@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// With -provenance, exported files record where they came from: the
+// source canvas, its SHA-256, the tool version and when they were written.
+// Formats that allow comments get a header; the rest get a sidecar
+// <output>.meta.json.
+
+// provenance describes the canvas an output was made from.
+type provenance struct {
+	Source    string `json:"source"`
+	SHA256    string `json:"sha256"`
+	Tool      string `json:"tool"`
+	Version   string `json:"version"`
+	Generated string `json:"generated"`
+	Format    string `json:"format,omitempty"`
+}
+
+func newProvenance(source string, data []byte, now time.Time) *provenance {
+	sum := sha256.Sum256(data)
+	if source == "-" {
+		source = "stdin"
+	}
+	return &provenance{
+		Source:    source,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Tool:      "canvas_tool",
+		Version:   toolVersion(),
+		Generated: now.UTC().Format(time.RFC3339),
+	}
+}
+
+func (p *provenance) lines() []string {
+	return []string{
+		"generated by " + p.Tool + " " + p.Version + " at " + p.Generated,
+		"source: " + p.Source,
+		"sha256: " + p.SHA256,
+	}
+}
+
+// provenanceComment returns the comment header for format and whether the
+// header goes after the first line (the XML declaration), or ok=false if
+// the format has no comment syntax. CSV readers skip "#" lines only when
+// told to, e.g. csv.Reader.Comment.
+func provenanceComment(format string, p *provenance) (header []byte, afterFirstLine, ok bool) {
+	var b bytes.Buffer
+	switch format {
+	case "dot":
+		for _, l := range p.lines() {
+			b.WriteString("// " + l + "\n")
+		}
+	case "csv", "toml", "yaml":
+		for _, l := range p.lines() {
+			b.WriteString("# " + l + "\n")
+		}
+	case "graphml":
+		b.WriteString("<!--\n")
+		for _, l := range p.lines() {
+			b.WriteString("  " + strings.ReplaceAll(l, "--", "- -") + "\n")
+		}
+		b.WriteString("-->\n")
+		afterFirstLine = true
+	default:
+		return nil, false, false
+	}
+	return b.Bytes(), afterFirstLine, true
+}
+
+// headerWriter inserts header into the stream, either up front or after
+// the first line.
+type headerWriter struct {
+	w              io.Writer
+	header         []byte
+	afterFirstLine bool
+	done           bool
+}
+
+func (h *headerWriter) Write(p []byte) (int, error) {
+	if h.done {
+		return h.w.Write(p)
+	}
+	split := 0
+	if h.afterFirstLine {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			return h.w.Write(p)
+		}
+		split = i + 1
+	}
+	if _, err := h.w.Write(p[:split]); err != nil {
+		return 0, err
+	}
+	if _, err := h.w.Write(h.header); err != nil {
+		return split, err
+	}
+	h.done = true
+	n, err := h.w.Write(p[split:])
+	return split + n, err
+}
+
+// writeSidecar writes p as JSON next to the output at path.
+func writeSidecar(path string, p *provenance) error {
+	data, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path+".meta.json", append(data, '\n'))
+}
+
+// provenanceModes are the values -provenance accepts.
+var provenanceModes = []string{"comment", "sidecar"}
+
+// provenanceWriter adds the comment header to out when the target wants
+// one and its format has a comment syntax.
+func (t outputTarget) provenanceWriter(out io.Writer) io.Writer {
+	if t.prov == nil || t.provMode != "comment" {
+		return out
+	}
+	header, after, ok := provenanceComment(t.format, t.prov)
+	if !ok {
+		return out
+	}
+	return &headerWriter{w: out, header: header, afterFirstLine: after}
+}
+
+// recordProvenance writes the sidecar for a finished output when -provenance
+// asks for one, or asks for a comment the format can't hold. Streams and
+// databases have nowhere to put a sidecar.
+func (t outputTarget) recordProvenance() error {
+	if t.prov == nil {
+		return nil
+	}
+	if _, _, ok := provenanceComment(t.format, t.prov); t.provMode == "comment" && ok && t.ex.toFile == nil {
+		return nil
+	}
+	if t.path == "-" || t.path == clipboardPath || isNeo4jURL(t.path) || isNATSURL(t.path) || isMQTTURL(t.path) {
+		slog.Warn(fmt.Sprintf("-provenance: no sidecar for %s output", t.format), "out", t.path)
+		return nil
+	}
+	p := *t.prov
+	p.Format = t.format
+	return writeSidecar(t.path, &p)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Pruning strips weakly connected nodes to leave the skeleton of a dense
+// canvas. Degree counts distinct neighbours, so parallel edges and
+// self-loops don't keep a node alive. Groups are never pruned.
+
+// roundsFlag is an int flag that may be given bare: -prune-leaves means
+// one round, -prune-leaves=3 three.
+type roundsFlag int
+
+func (f *roundsFlag) String() string { return strconv.Itoa(int(*f)) }
+
+func (f *roundsFlag) Set(v string) error {
+	switch v {
+	case "true":
+		*f = 1
+		return nil
+	case "false":
+		*f = 0
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return errors.New("want a number of rounds")
+	}
+	*f = roundsFlag(n)
+	return nil
+}
+
+func (f *roundsFlag) IsBoolFlag() bool { return true }
+
+// neighbours counts each node's distinct neighbours among the nodes alive.
+func neighbours(g *graph, alive map[string]bool) map[string]int {
+	adj := map[string]map[string]bool{}
+	for _, e := range g.Edges {
+		if e.From == e.To || !alive[e.From] || !alive[e.To] {
+			continue
+		}
+		for _, p := range [][2]string{{e.From, e.To}, {e.To, e.From}} {
+			if adj[p[0]] == nil {
+				adj[p[0]] = map[string]bool{}
+			}
+			adj[p[0]][p[1]] = true
+		}
+	}
+	deg := make(map[string]int, len(adj))
+	for id, ns := range adj {
+		deg[id] = len(ns)
+	}
+	return deg
+}
+
+// pruneBelow removes nodes with fewer than min neighbours in rounds, each
+// round judging degrees on what the previous one left, until a round
+// removes nothing or rounds run out (rounds < 0: no limit).
+func pruneBelow(g *graph, min, rounds int) {
+	alive := make(map[string]bool, len(g.Nodes))
+	for _, n := range g.Nodes {
+		alive[n.ID] = true
+	}
+	pruned := map[string]bool{}
+	for r := 0; rounds < 0 || r < rounds; r++ {
+		deg := neighbours(g, alive)
+		removed := false
+		for _, n := range g.Nodes {
+			if alive[n.ID] && n.Type != "group" && deg[n.ID] < min {
+				alive[n.ID], pruned[n.ID] = false, true
+				removed = true
+			}
+		}
+		if !removed {
+			break
+		}
+	}
+	nodes := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if !pruned[n.ID] {
+			nodes = append(nodes, n)
+		}
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if !pruned[e.From] && !pruned[e.To] {
+			edges = append(edges, e)
+		}
+	}
+	g.Nodes, g.Edges = nodes, edges
+	g.index()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"strconv"
+)
+
+// reach reports how far each node can reach every other, for impact
+// analysis: hop counts along edges, from every node or just the roots
+// given. Groups are left out.
+
+// distances returns the hop count from id to every node it reaches,
+// itself included at 0.
+func (g *graph) distances(id string, undirected bool) map[string]int {
+	dist := map[string]int{id: 0}
+	dir := "out"
+	if undirected {
+		dir = "both"
+	}
+	// neighbors is BFS, so a node first appears at its shortest distance.
+	for d, frontier := 1, []string{id}; len(frontier) > 0; d++ {
+		var next []string
+		for _, cur := range frontier {
+			for _, other := range g.neighbors(cur, 1, dir) {
+				if _, ok := dist[other]; !ok {
+					dist[other] = d
+					next = append(next, other)
+				}
+			}
+		}
+		frontier = next
+	}
+	return dist
+}
+
+func runReach(args []string) {
+	fs := flag.NewFlagSet("reach", flag.ExitOnError)
+	var from stringsFlag
+	fs.Var(&from, "from", "root `node`: an ID, name, /regexp/ or @file of them (repeatable; default: every node)")
+	layout := fs.String("layout", "matrix", "matrix (a row per root, a column per node, cells are hop counts) or long (from;to;distance rows for reachable pairs)")
+	undirected := fs.Bool("undirected", false, "follow edges in both directions")
+	ids := fs.Bool("ids", false, "label rows and columns with node IDs instead of names")
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	out := fs.String("out", "-", "output `path`, or - for stdout")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatalf("reach: usage: canvas_tool reach [flags] file.canvas")
+	}
+	if *layout != "matrix" && *layout != "long" {
+		fatalf("reach: unknown -layout %q (want matrix or long)", *layout)
+	}
+	c, err := loadCanvas(fs.Arg(0))
+	if err != nil {
+		fatalf("reach: %v", err)
+	}
+	g := buildGraph(c, *keepPath)
+	var roots *nodeMatcher
+	if len(from) > 0 {
+		if roots, err = nodeMatcherFrom(from); err != nil {
+			fatalf("reach: -from: %v", err)
+		}
+	}
+
+	var nodes, starts []graphNode
+	for _, n := range g.Nodes {
+		if n.Type == "group" {
+			continue
+		}
+		nodes = append(nodes, n)
+		if roots == nil || roots.match(n) {
+			starts = append(starts, n)
+		}
+	}
+	if len(starts) == 0 {
+		fatalf("reach: -from matches no node")
+	}
+	label := func(n graphNode) string {
+		if *ids {
+			return n.ID
+		}
+		return n.Name
+	}
+
+	w, closeOut, err := openOut(*out)
+	if err != nil {
+		fatalf("reach: %v", err)
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	if *layout == "matrix" {
+		header := []string{""}
+		for _, n := range nodes {
+			header = append(header, label(n))
+		}
+		cw.Write(header)
+	} else {
+		cw.Write([]string{"from", "to", "distance"})
+	}
+	for _, s := range starts {
+		dist := g.distances(s.ID, *undirected)
+		if *layout == "long" {
+			for _, n := range nodes {
+				if d, ok := dist[n.ID]; ok && n.ID != s.ID {
+					cw.Write([]string{label(s), label(n), strconv.Itoa(d)})
+				}
+			}
+			continue
+		}
+		row := []string{label(s)}
+		for _, n := range nodes {
+			cell := ""
+			if d, ok := dist[n.ID]; ok {
+				cell = strconv.Itoa(d)
+			}
+			row = append(row, cell)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		fatalf("reach: %v", err)
+	}
+	if err := closeOut(); err != nil {
+		fatalf("reach: %v", err)
+	}
+}
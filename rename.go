@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rename rewrites file node paths after notes are moved or renamed.
+
+type renameRule struct {
+	from, to string
+	prefix   bool // folder rule: from and to end in /
+}
+
+// parseRenameMap reads one "old -> new" or "old<TAB>new" pair per line.
+// Blank lines and # comments are skipped. Pairs ending in / rename a
+// folder and everything below it.
+func parseRenameMap(data string) ([]renameRule, error) {
+	var rules []renameRule
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		from, to, ok := strings.Cut(text, "\t")
+		if !ok {
+			from, to, ok = strings.Cut(text, " -> ")
+		}
+		from, to = slashPath(strings.TrimSpace(from)), slashPath(strings.TrimSpace(to))
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("line %d: want \"old -> new\"", line)
+		}
+		prefix := strings.HasSuffix(from, "/")
+		if prefix != strings.HasSuffix(to, "/") {
+			return nil, fmt.Errorf("line %d: folder renames need a trailing / on both sides", line)
+		}
+		rules = append(rules, renameRule{from: from, to: to, prefix: prefix})
+	}
+	return rules, sc.Err()
+}
+
+// apply returns the new path for file, matching case-insensitively as
+// Obsidian does on most systems. Exact rules win over folder rules.
+func applyRenames(rules []renameRule, file string) (string, bool) {
+	p := slashPath(file)
+	for _, r := range rules {
+		if !r.prefix && strings.EqualFold(p, r.from) {
+			return r.to, true
+		}
+	}
+	for _, r := range rules {
+		if r.prefix && len(p) > len(r.from) && strings.EqualFold(p[:len(r.from)], r.from) {
+			return r.to + p[len(r.from):], true
+		}
+	}
+	return file, false
+}
+
+func runRename(args []string) {
+	fs := flag.NewFlagSet("rename", flag.ExitOnError)
+	mapPath := fs.String("map", "", "file of \"old -> new\" path pairs, one per line (folders end in /)")
+	from := fs.String("from", "", "single old path (instead of -map)")
+	to := fs.String("to", "", "single new path (with -from)")
+	vault := fs.String("vault", "", "rewrite every canvas in this vault (instead of listing canvases)")
+	dryRun := fs.Bool("dry-run", false, "report the changes without writing")
+	backup := fs.Bool("backup", false, "keep the original of each rewritten canvas as <name>.canvas.bak")
+	fs.Parse(args)
+
+	var rules []renameRule
+	switch {
+	case *mapPath != "":
+		data, err := os.ReadFile(*mapPath)
+		if err != nil {
+			fatalf("rename: %v", err)
+		}
+		if rules, err = parseRenameMap(string(data)); err != nil {
+			fatalf("rename: %s: %v", *mapPath, err)
+		}
+	case *from != "" && *to != "":
+		var err error
+		if rules, err = parseRenameMap(*from + "\t" + *to); err != nil {
+			fatalf("rename: %v", err)
+		}
+	default:
+		fatalf("rename: need -map, or -from and -to")
+	}
+
+	paths, err := expandInputs(fs.Args())
+	if err != nil {
+		fatalf("rename: %v", err)
+	}
+	if *vault != "" {
+		found, err := findCanvases(*vault)
+		if err != nil {
+			fatalf("rename: %v", err)
+		}
+		for _, p := range found {
+			paths = append(paths, filepath.Join(*vault, filepath.FromSlash(p)))
+		}
+	}
+	if len(paths) == 0 {
+		fatalf("rename: no canvases given (paths, globs or -vault)")
+	}
+
+	// Parse every canvas before writing any, so a bad file can't leave the
+	// vault half renamed.
+	type pending struct {
+		path string
+		doc  *canvasDoc
+	}
+	var todo []pending
+	for _, path := range paths {
+		doc, err := readCanvasDoc(path)
+		if err != nil && *vault != "" {
+			slog.Warn("rename: skipped unreadable canvas", "err", err)
+			continue
+		}
+		if err != nil {
+			fatalf("rename: %v", err)
+		}
+		changes := 0
+		for i, n := range doc.Nodes {
+			if n.str("type") != "file" {
+				continue
+			}
+			old := n.str("file")
+			if renamed, ok := applyRenames(rules, old); ok && renamed != old {
+				fmt.Printf("%s: %s: %s -> %s\n", path, n.str("id"), old, renamed)
+				doc.Nodes[i].set("file", renamed)
+				changes++
+			}
+		}
+		if changes > 0 {
+			todo = append(todo, pending{path, doc})
+		}
+	}
+	if *dryRun {
+		return
+	}
+	for _, p := range todo {
+		if *backup {
+			orig, err := os.ReadFile(p.path)
+			if err == nil {
+				err = os.WriteFile(p.path+".bak", orig, 0o644)
+			}
+			if err != nil {
+				fatalf("rename: backup %s: %v", p.path, err)
+			}
+		}
+		if err := p.doc.write(p.path); err != nil {
+			fatalf("rename: write %s: %v", p.path, err)
+		}
+	}
+	fmt.Printf("%d canvases updated\n", len(todo))
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// With a vault, validate also reports file nodes whose file is gone and
+// suggests the vault files whose names are closest, so moved or misspelt
+// notes can be repaired with -fix.
+
+// listVaultFiles returns every file in the vault as a vault-relative slash
+// path, skipping dot directories such as .obsidian and .trash.
+func listVaultFiles(vault string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(vault, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != vault && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(vault, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// suggestFiles ranks the files whose base name is within a few edits of the
+// missing file's, ignoring case. Only files with the same extension are
+// considered; ties go to the path closest to the vault root.
+func suggestFiles(missing string, files []string, limit int) []string {
+	missing = slashPath(missing)
+	ext := strings.ToLower(path.Ext(missing))
+	stem := strings.ToLower(strings.TrimSuffix(path.Base(missing), path.Ext(missing)))
+	maxDist := max(2, len([]rune(stem))/3)
+
+	type candidate struct {
+		file string
+		dist int
+	}
+	var cands []candidate
+	for _, f := range files {
+		if strings.ToLower(path.Ext(f)) != ext {
+			continue
+		}
+		d := levenshtein(stem, strings.ToLower(strings.TrimSuffix(path.Base(f), path.Ext(f))))
+		if d <= maxDist {
+			cands = append(cands, candidate{f, d})
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		a, b := cands[i], cands[j]
+		if a.dist != b.dist {
+			return a.dist < b.dist
+		}
+		if da, db := strings.Count(a.file, "/"), strings.Count(b.file, "/"); da != db {
+			return da < db
+		}
+		return a.file < b.file
+	})
+	var out []string
+	for i := 0; i < len(cands) && i < limit; i++ {
+		out = append(out, cands[i].file)
+	}
+	return out
+}
+
+// levenshtein is the edit distance between a and b in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// validateVaultFiles reports file nodes whose file does not exist in the
+// vault, with up to limit suggestions each.
+func validateVaultFiles(c Canvas, vault string, files []string, limit int) []issue {
+	var issues []issue
+	for i, n := range c.Nodes {
+		if n.Type != "file" || n.File == "" {
+			continue
+		}
+		if _, err := os.Stat(vaultFile(vault, n.File)); err == nil {
+			continue
+		}
+		issues = append(issues, issue{
+			Severity:    "error",
+			Code:        "broken-file",
+			Message:     fmt.Sprintf("file %q is not in the vault", n.File),
+			Path:        fmt.Sprintf("nodes[%d]", i),
+			NodeID:      n.ID,
+			Suggestions: suggestFiles(n.File, files, limit),
+		})
+	}
+	return issues
+}
+
+// bestRepair picks the fix for a broken file node: the first suggestion,
+// provided it is strictly better than the runner-up.
+func bestRepair(missing string, suggestions []string) (string, bool) {
+	if len(suggestions) == 0 {
+		return "", false
+	}
+	if len(suggestions) > 1 {
+		stem := func(p string) string {
+			p = slashPath(p)
+			return strings.ToLower(strings.TrimSuffix(path.Base(p), path.Ext(p)))
+		}
+		want := stem(missing)
+		if levenshtein(want, stem(suggestions[0])) == levenshtein(want, stem(suggestions[1])) &&
+			strings.Count(suggestions[0], "/") == strings.Count(suggestions[1], "/") {
+			return "", false
+		}
+	}
+	return suggestions[0], true
+}
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	vault := fs.String("vault", "", "also check that file nodes exist in this vault and suggest replacements")
+	suggestions := fs.Int("suggestions", 3, "suggestions to list per broken file node")
+	fix := fs.Bool("fix", false, "rewrite each canvas with the best suggestion for its broken file nodes (needs -vault)")
+	asJSON := fs.Bool("json", false, "print issues as JSON lines")
+	dag := fs.Bool("dag", false, "the canvas must be acyclic: report a small set of edges whose removal breaks every cycle")
+	report := fs.String("report", "text", reportFlagUsage)
+	fs.Parse(args)
+	if *asJSON {
+		*report = "json"
+	}
+	checkReportFormat("validate", *report)
+	if *fix && *vault == "" {
+		fatalf("validate: -fix needs -vault")
+	}
+	paths, err := expandInputs(fs.Args())
+	if err != nil {
+		fatalf("validate: %v", err)
+	}
+	if len(paths) == 0 {
+		fatalf("validate: no canvases given")
+	}
+	var files []string
+	if *vault != "" {
+		if files, err = listVaultFiles(*vault); err != nil {
+			fatalf("validate: %v", err)
+		}
+	}
+
+	failed := false
+	enc := json.NewEncoder(os.Stdout)
+	var reported []reportedCanvas
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			fatalf("validate: %v", err)
+		}
+		var checks []reportCheck
+		c, err := parseCanvas(data)
+		if err != nil {
+			checks = []reportCheck{{name: "parse", issues: []issue{{Severity: "error", Code: "invalid-json", Message: err.Error()}}}}
+		} else {
+			checks = []reportCheck{{name: "structure", issues: validateCanvas(c)}}
+			if *vault != "" {
+				checks = append(checks, reportCheck{name: "vault-files", issues: validateVaultFiles(c, *vault, files, max(*suggestions, 2))})
+			}
+			if *dag {
+				checks = append(checks, reportCheck{name: "dag", issues: validateDAG(c)})
+			}
+		}
+
+		fixes := map[string]string{} // node id -> new file
+		if *fix {
+			files := map[string]string{}
+			for _, n := range c.Nodes {
+				files[n.ID] = n.File
+			}
+			for _, ck := range checks {
+				for _, is := range ck.issues {
+					if is.Code != "broken-file" {
+						continue
+					}
+					if to, ok := bestRepair(files[is.NodeID], is.Suggestions); ok {
+						fixes[is.NodeID] = to
+					}
+				}
+			}
+		}
+		rc := reportedCanvas{path: p, data: data}
+		for _, ck := range checks {
+			kept := reportCheck{name: ck.name}
+			for _, is := range ck.issues {
+				if len(is.Suggestions) > *suggestions {
+					is.Suggestions = is.Suggestions[:*suggestions]
+				}
+				to := ""
+				if is.Code == "broken-file" {
+					to = fixes[is.NodeID]
+				}
+				fixed := to != ""
+				if !fixed && is.Severity == "error" {
+					failed = true
+				}
+				switch *report {
+				case "sarif", "junit":
+					if !fixed {
+						kept.issues = append(kept.issues, is)
+					}
+					continue
+				case "json":
+					enc.Encode(struct {
+						Canvas string `json:"canvas"`
+						issue
+						Fixed string `json:"fixed,omitempty"`
+					}{p, is, to})
+					continue
+				}
+				loc := is.Path
+				if loc == "" {
+					loc = "-"
+				}
+				fmt.Printf("%s: %s: %s %s: %s\n", p, loc, is.Severity, is.Code, is.Message)
+				switch {
+				case fixed:
+					fmt.Printf("\tfixed: %s\n", to)
+				case len(is.Suggestions) > 0:
+					fmt.Printf("\tdid you mean: %s\n", strings.Join(is.Suggestions, ", "))
+				}
+			}
+			rc.checks = append(rc.checks, kept)
+		}
+		reported = append(reported, rc)
+
+		if len(fixes) > 0 {
+			doc, err := readCanvasDoc(p)
+			if err != nil {
+				fatalf("validate: %v", err)
+			}
+			for i, n := range doc.Nodes {
+				if to, ok := fixes[n.str("id")]; ok && n.str("type") == "file" {
+					doc.Nodes[i].set("file", to)
+				}
+			}
+			if err := doc.write(p); err != nil {
+				fatalf("validate: write %s: %v", p, err)
+			}
+		}
+	}
+	if err := writeReport(os.Stdout, "validate", *report, reported); err != nil {
+		fatalf("validate: %v", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
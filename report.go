@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validate and check can write their findings as a SARIF 2.1.0 log with
+// -report sarif, for GitHub code scanning and other tools that annotate
+// pull requests. Each result points at the node or edge it is about: by
+// its JSON path as a logical location, and by the lines and columns the
+// element spans in the .canvas file. With -report junit they write JUnit
+// XML instead, for the test report views of Jenkins and GitLab: a test
+// suite per canvas and a test case per check run on it.
+
+// reportedCanvas is one checked canvas and what was found in it.
+type reportedCanvas struct {
+	path   string
+	data   []byte // the file as read, for locating elements
+	checks []reportCheck
+}
+
+// reportCheck is one check run on a canvas (a rule of check, or a part of
+// validate) and its findings.
+type reportCheck struct {
+	name    string
+	skipped bool // the rule is turned off
+	issues  []issue
+}
+
+func (rc reportedCanvas) issues() []issue {
+	var all []issue
+	for _, ck := range rc.checks {
+		all = append(all, ck.issues...)
+	}
+	return all
+}
+
+var jsonPathPattern = regexp.MustCompile(`^(nodes|edges)(?:\[(\d+)\])?$`)
+
+// jsonSpan finds the byte range of the element a path such as "edges[3]"
+// names in a canvas file.
+func jsonSpan(data []byte, path string) (start, end int64, ok bool) {
+	m := jsonPathPattern.FindStringSubmatch(path)
+	if m == nil {
+		return 0, 0, false
+	}
+	bom := int64(0)
+	if bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}) {
+		bom = 3
+	}
+	body := data[bom:]
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, 0, false
+	}
+	// valueStart skips the whitespace and separators before the next value.
+	valueStart := func() int64 {
+		off := dec.InputOffset()
+		for off < int64(len(body)) && bytes.IndexByte([]byte(" \t\r\n,:"), body[off]) >= 0 {
+			off++
+		}
+		return off
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		if key != m[1] {
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				return 0, 0, false
+			}
+			continue
+		}
+		if m[2] == "" {
+			start = valueStart()
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				return 0, 0, false
+			}
+			return bom + start, bom + dec.InputOffset(), true
+		}
+		want, _ := strconv.Atoi(m[2])
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return 0, 0, false
+		}
+		for i := 0; dec.More(); i++ {
+			start = valueStart()
+			var elem json.RawMessage
+			if dec.Decode(&elem) != nil {
+				return 0, 0, false
+			}
+			if i == want {
+				return bom + start, bom + dec.InputOffset(), true
+			}
+		}
+		return 0, 0, false
+	}
+	return 0, 0, false
+}
+
+// charCol converts a byte offset to a 1-based line and a column counted in
+// characters, as SARIF expects by default.
+func charCol(data []byte, offset int64) (int, int) {
+	line, _ := lineCol(data, offset)
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	return line, utf8.RuneCount(data[lineStart:offset]) + 1
+}
+
+// sarifURI names a canvas the way code scanning matches it to the
+// repository: a slash-separated path relative to where the tool ran.
+func sarifURI(path string) string {
+	if filepath.IsAbs(path) {
+		return "file://" + filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+func writeSARIF(w io.Writer, command string, canvases []reportedCanvas) error {
+	type obj = map[string]any
+	codes := map[string]bool{}
+	results := []any{}
+	for _, rc := range canvases {
+		for _, is := range rc.issues() {
+			codes[is.Code] = true
+			level := "error"
+			if is.Severity == "warning" {
+				level = "warning"
+			}
+			physical := obj{"artifactLocation": obj{"uri": sarifURI(rc.path)}}
+			if start, end, ok := jsonSpan(rc.data, is.Path); ok {
+				sl, sc := charCol(rc.data, start)
+				el, ec := charCol(rc.data, end)
+				physical["region"] = obj{"startLine": sl, "startColumn": sc, "endLine": el, "endColumn": ec}
+			}
+			loc := obj{"physicalLocation": physical}
+			if is.Path != "" {
+				logical := obj{"fullyQualifiedName": is.Path, "kind": "object"}
+				if is.NodeID != "" {
+					logical["name"] = is.NodeID
+				}
+				loc["logicalLocations"] = []any{logical}
+			}
+			result := obj{
+				"ruleId":    is.Code,
+				"level":     level,
+				"message":   obj{"text": is.Message},
+				"locations": []any{loc},
+			}
+			if len(is.Suggestions) > 0 {
+				result["properties"] = obj{"suggestions": is.Suggestions}
+			}
+			results = append(results, result)
+		}
+	}
+	rules := []any{}
+	for _, id := range sortedKeys(codes) {
+		rule := obj{"id": id}
+		if r, ok := checkRules[id]; ok {
+			rule["shortDescription"] = obj{"text": r.usage}
+		}
+		rules = append(rules, rule)
+	}
+	log := obj{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{obj{
+			"tool": obj{"driver": obj{
+				"name":    "canvas_tool " + command,
+				"version": toolVersion(),
+				"rules":   rules,
+			}},
+			"results": results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false) // messages draw edges as a -> b
+	return enc.Encode(log)
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Skipped   *struct{}     `xml:"skipped"`
+	Failure   *junitFailure `xml:"failure"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+// writeJUnit writes the findings as JUnit XML. A check fails when it
+// found an error; warnings are kept as the test case's output.
+func writeJUnit(w io.Writer, command string, canvases []reportedCanvas) error {
+	type testsuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Name     string       `xml:"name,attr"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Skipped  int          `xml:"skipped,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+	all := testsuites{Name: "canvas_tool " + command}
+	for _, rc := range canvases {
+		suite := junitSuite{Name: rc.path, Cases: []junitCase{}}
+		for _, ck := range rc.checks {
+			tc := junitCase{Name: ck.name, Classname: rc.path}
+			var errs, warnings []string
+			for _, is := range ck.issues {
+				loc := is.Path
+				if loc == "" {
+					loc = "-"
+				}
+				line := fmt.Sprintf("%s: %s %s: %s", loc, is.Severity, is.Code, is.Message)
+				if is.Severity == "error" {
+					if tc.Failure == nil {
+						tc.Failure = &junitFailure{Message: is.Message, Type: is.Code}
+					}
+					errs = append(errs, line)
+				} else {
+					warnings = append(warnings, line)
+				}
+			}
+			switch {
+			case ck.skipped:
+				tc.Skipped = &struct{}{}
+				suite.Skipped++
+			case tc.Failure != nil:
+				if len(errs) > 1 {
+					tc.Failure.Message = fmt.Sprintf("%d problems, the first: %s", len(errs), tc.Failure.Message)
+				}
+				tc.Failure.Text = strings.Join(errs, "\n")
+				suite.Failures++
+			}
+			tc.SystemOut = strings.Join(warnings, "\n")
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+		}
+		all.Tests += suite.Tests
+		all.Failures += suite.Failures
+		all.Skipped += suite.Skipped
+		all.Suites = append(all.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(all); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeReport writes a sarif or junit report of the canvases; the text and
+// json reports are printed as the findings come.
+func writeReport(w io.Writer, command, report string, canvases []reportedCanvas) error {
+	switch report {
+	case "sarif":
+		return writeSARIF(w, command, canvases)
+	case "junit":
+		return writeJUnit(w, command, canvases)
+	}
+	return nil
+}
+
+// checkReportFormat rejects unknown -report values.
+func checkReportFormat(command, report string) {
+	switch report {
+	case "text", "json", "sarif", "junit":
+	default:
+		fatalf("%s: unknown -report %q (want text, json, sarif or junit)", command, report)
+	}
+}
+
+// reportFlagUsage is the usage of the -report flag validate and check share.
+const reportFlagUsage = "how to report findings: text, json (JSON lines, like -json), sarif (a SARIF 2.1.0 log for code scanning) or junit (JUnit XML for CI test reports)"
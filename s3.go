@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Uploads to s3://bucket/key destinations are a single PUT Object signed
+// with AWS Signature Version 4. Credentials and region come from the usual
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+// AWS_REGION variables; AWS_ENDPOINT_URL points at S3-compatible stores
+// (path-style addressing).
+
+func isS3URL(s string) bool { return strings.HasPrefix(s, "s3://") }
+
+func putS3(dest string, body []byte) error {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return err
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return fmt.Errorf("%s: want s3://bucket/key", dest)
+	}
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return fmt.Errorf("%s: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set", dest)
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	var endpoint *url.URL
+	if ep := os.Getenv("AWS_ENDPOINT_URL"); ep != "" {
+		if endpoint, err = url.Parse(ep); err != nil {
+			return fmt.Errorf("AWS_ENDPOINT_URL: %v", err)
+		}
+		endpoint.Path = "/" + bucket + "/" + key
+	} else {
+		endpoint = &url.URL{Scheme: "https", Host: bucket + ".s3." + region + ".amazonaws.com", Path: "/" + key}
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	signS3(req, body, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), region, time.Now().UTC())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("PUT %s: %s: %s", dest, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func signS3(req *http.Request, body []byte, accessKey, secretKey, token, region string, now time.Time) {
+	const service = "s3"
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if token != "" {
+		signed = append(signed, "x-amz-security-token")
+	}
+	var canonHeaders strings.Builder
+	for _, h := range signed {
+		canonHeaders.WriteString(h + ":" + strings.TrimSpace(req.Header.Get(h)) + "\n")
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, strings.Join(signed, ";"), sig))
+	req.Header.Del("Host") // net/http sends req.Host
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// scriptStep runs a Starlark script (see starlark.go) over the graph. The
+// script's top level runs first; then node(n), if it defines one, is
+// called for every node with a dict of its fields, the ones plugins see:
+// id, type, name, text, file, url, label and attrs. After that edge(e), if
+// defined, is called for every edge with from, to, label and attrs.
+//
+// A hook may change the dict in place and return None, or return a dict
+// of its own: the fields that dict names are set, and the rest are kept.
+// Returning False drops the node with its edges, or drops the edge. A node
+// may change its id, and its edges follow it. Attribute values may be
+// strings, numbers or bools; setting one to None removes it. print writes
+// to stderr.
+type scriptStep struct{ prog *starProgram }
+
+var (
+	scriptNodeFields = []string{"id", "type", "name", "text", "file", "url", "label"}
+	scriptEdgeFields = []string{"from", "to", "label"}
+)
+
+func loadScript(path string) (scriptStep, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return scriptStep{}, err
+	}
+	prog, err := parseStarlark(path, string(src))
+	if err != nil {
+		return scriptStep{}, err
+	}
+	return scriptStep{prog}, nil
+}
+
+func (s scriptStep) apply(ctx context.Context, g *graph) (*graph, error) {
+	th := &starThread{ctx: ctx, print: os.Stderr}
+	globals, err := th.run(s.prog)
+	if err != nil {
+		return nil, fmt.Errorf("script: %v", err)
+	}
+	hook := func(name string) (*starFunction, error) {
+		v, ok := globals[name]
+		if !ok {
+			return nil, nil
+		}
+		fn, ok := v.(*starFunction)
+		if !ok {
+			return nil, fmt.Errorf("script %s: %s is a %s, not a function", s.prog.file, name, starType(v))
+		}
+		return fn, nil
+	}
+	nodeFn, err := hook("node")
+	if err != nil {
+		return nil, err
+	}
+	edgeFn, err := hook("edge")
+	if err != nil {
+		return nil, err
+	}
+	if nodeFn == nil && edgeFn == nil {
+		return nil, fmt.Errorf("script %s defines neither node(n) nor edge(e)", s.prog.file)
+	}
+	if nodeFn != nil {
+		if err := s.nodes(th, nodeFn, g); err != nil {
+			return nil, err
+		}
+	}
+	if edgeFn != nil {
+		if err := s.edges(th, edgeFn, g); err != nil {
+			return nil, err
+		}
+	}
+	g.index()
+	return g, nil
+}
+
+// nodes calls fn for every node of g and applies what it returns.
+func (s scriptStep) nodes(th *starThread, fn *starFunction, g *graph) error {
+	renamed := map[string]string{} // old ID -> new, for every node kept
+	taken := map[string]bool{}
+	var nodes []graphNode
+	var attrs []*starDict
+	for _, n := range g.Nodes {
+		d := newStarDict()
+		for i, v := range []string{n.ID, n.Type, n.Name, n.Node.Text, n.Node.File, n.Node.URL, n.Node.Label} {
+			d.set(scriptNodeFields[i], v)
+		}
+		d.set("attrs", scriptAttrs(n.Attrs, g.attrs))
+		fields, a, keep, err := scriptCall(th, fn, d, scriptNodeFields)
+		if err != nil {
+			return fmt.Errorf("script: node(%s): %v", n.ID, err)
+		}
+		if !keep {
+			continue
+		}
+		for k, v := range fields {
+			switch k {
+			case "id":
+				n.ID = v
+			case "type":
+				n.Type = v
+			case "name":
+				n.Name = v
+			case "text":
+				n.Node.Text = v
+			case "file":
+				n.Node.File = v
+			case "url":
+				n.Node.URL = v
+			case "label":
+				n.Node.Label = v
+			}
+		}
+		if n.ID == "" {
+			return fmt.Errorf("script: node(%s) returned an empty id", n.Node.ID)
+		}
+		if taken[n.ID] {
+			return fmt.Errorf("script: node(%s): two nodes have id %q", n.Node.ID, n.ID)
+		}
+		taken[n.ID] = true
+		renamed[n.Node.ID] = n.ID
+		n.Node.ID, n.Node.Type = n.ID, n.Type
+		if a != nil {
+			n.Attrs = nil
+		}
+		nodes = append(nodes, n)
+		attrs = append(attrs, a)
+	}
+	g.Nodes = nodes
+	for i, a := range attrs {
+		if err := scriptSetAttrs(a, func(k, v string) { g.setAttr(i, k, v) }); err != nil {
+			return fmt.Errorf("script: node(%s): %v", nodes[i].ID, err)
+		}
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		from, ok1 := renamed[e.From]
+		to, ok2 := renamed[e.To]
+		if ok1 && ok2 {
+			e.From, e.To = from, to
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+	return nil
+}
+
+// edges calls fn for every edge of g and applies what it returns.
+func (s scriptStep) edges(th *starThread, fn *starFunction, g *graph) error {
+	ids := map[string]bool{}
+	for _, n := range g.Nodes {
+		ids[n.ID] = true
+	}
+	var edges []graphEdge
+	var attrs []*starDict
+	for _, e := range g.Edges {
+		d := newStarDict()
+		d.set("from", e.From)
+		d.set("to", e.To)
+		d.set("label", e.Label)
+		d.set("attrs", scriptAttrs(e.Attrs, g.edgeAttrs))
+		name := e.From + " -> " + e.To
+		fields, a, keep, err := scriptCall(th, fn, d, scriptEdgeFields)
+		if err != nil {
+			return fmt.Errorf("script: edge(%s): %v", name, err)
+		}
+		if !keep {
+			continue
+		}
+		for k, v := range fields {
+			switch k {
+			case "from":
+				e.From = v
+			case "to":
+				e.To = v
+			case "label":
+				e.Label = v
+			}
+		}
+		for _, id := range []string{e.From, e.To} {
+			if !ids[id] {
+				return fmt.Errorf("script: edge(%s): no node has id %q", name, id)
+			}
+		}
+		if a != nil {
+			e.Attrs = nil
+		}
+		edges = append(edges, e)
+		attrs = append(attrs, a)
+	}
+	g.Edges = edges
+	for i, a := range attrs {
+		if err := scriptSetAttrs(a, func(k, v string) { g.setEdgeAttr(i, k, v) }); err != nil {
+			return fmt.Errorf("script: edge(%s -> %s): %v", edges[i].From, edges[i].To, err)
+		}
+	}
+	return nil
+}
+
+// scriptAttrs returns attrs as a dict, keys in column order.
+func scriptAttrs(attrs map[string]string, columns []string) *starDict {
+	d := newStarDict()
+	for _, k := range columns {
+		if v, ok := attrs[k]; ok {
+			d.set(k, v)
+		}
+	}
+	return d
+}
+
+// scriptCall calls a hook with d and reads back the fields it sets, which
+// must be among names, and its attrs if it names them. keep is false if
+// the hook returned False.
+func scriptCall(th *starThread, fn *starFunction, d *starDict, names []string) (fields map[string]string, attrs *starDict, keep bool, err error) {
+	v, err := th.call(fn, []any{d}, nil)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	switch v := v.(type) {
+	case nil:
+	case bool:
+		if v {
+			return nil, nil, false, fmt.Errorf("returned True; return None or a dict to keep it, False to drop it")
+		}
+		return nil, nil, false, nil
+	case *starDict:
+		d = v
+	default:
+		return nil, nil, false, fmt.Errorf("returned a %s; want None, False or a dict", starType(v))
+	}
+	fields = map[string]string{}
+	for i, k := range d.keys {
+		key, _ := k.(string)
+		switch v := d.vals[i]; {
+		case key == "attrs":
+			if v == nil {
+				attrs = newStarDict()
+				continue
+			}
+			var ok bool
+			if attrs, ok = v.(*starDict); !ok {
+				return nil, nil, false, fmt.Errorf("attrs is a %s, not a dict", starType(v))
+			}
+		case slices.Contains(names, key):
+			switch v := v.(type) {
+			case nil:
+				fields[key] = ""
+			case string:
+				fields[key] = v
+			default:
+				return nil, nil, false, fmt.Errorf("%s is a %s, not a string", key, starType(v))
+			}
+		default:
+			return nil, nil, false, fmt.Errorf("unknown field %s (want %s or attrs)", starRepr(k), strings.Join(names, ", "))
+		}
+	}
+	return fields, attrs, true, nil
+}
+
+// scriptSetAttrs sets the attributes in a through set, skipping None.
+func scriptSetAttrs(a *starDict, set func(k, v string)) error {
+	if a == nil {
+		return nil
+	}
+	for i, k := range a.keys {
+		key, ok := k.(string)
+		if !ok {
+			return fmt.Errorf("attribute name %s is not a string", starRepr(k))
+		}
+		switch v := a.vals[i].(type) {
+		case nil:
+		case string, int, float64, bool:
+			set(key, starStr(v))
+		default:
+			return fmt.Errorf("attrs[%q] is a %s; want a string, number or bool", key, starType(v))
+		}
+	}
+	return nil
+}
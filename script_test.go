@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const scriptCanvas = `{
+	"nodes": [
+		{"id": "a", "type": "text", "text": "Alpha", "x": 0, "y": 0, "width": 250, "height": 60},
+		{"id": "b", "type": "text", "text": "Beta", "x": 300, "y": 0, "width": 250, "height": 60},
+		{"id": "tmp", "type": "text", "text": "scratch", "x": 600, "y": 0, "width": 250, "height": 60}
+	],
+	"edges": [
+		{"id": "e1", "fromNode": "a", "toNode": "b", "label": "uses"},
+		{"id": "e2", "fromNode": "b", "toNode": "tmp", "label": "notes"},
+		{"id": "e3", "fromNode": "b", "toNode": "a"}
+	]
+}`
+
+// runScript runs src as a script step over scriptCanvas.
+func runScript(t *testing.T, src string) (*graph, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.star")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := parseCanvas([]byte(scriptCanvas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, err := parseStep("script:"+path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return step.apply(context.Background(), buildGraph(c, false))
+}
+
+func TestScriptStep(t *testing.T) {
+	g, err := runScript(t, `
+prefix = "n-"
+
+def node(n):
+    if n["text"] == "scratch":
+        return False
+    n["id"] = prefix + n["id"]
+    n["name"] = n["name"].upper()
+    n["attrs"]["length"] = len(n["text"])
+    n["attrs"]["first"] = n["id"] == "n-a"
+
+def edge(e):
+    if not e["label"]:
+        return {"label": "back", "attrs": {"weight": 0.5}}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes [][2]string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, [2]string{n.ID, n.Name})
+	}
+	if want := [][2]string{{"n-a", "ALPHA"}, {"n-b", "BETA"}}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("nodes = %v, want %v", nodes, want)
+	}
+	if want := []string{"length", "first"}; !reflect.DeepEqual(g.attrs, want) {
+		t.Errorf("attrs = %v, want %v", g.attrs, want)
+	}
+	if want := map[string]string{"length": "5", "first": "True"}; !reflect.DeepEqual(g.Nodes[0].Attrs, want) {
+		t.Errorf("attrs of n-a = %v, want %v", g.Nodes[0].Attrs, want)
+	}
+	var edges [][3]string
+	for _, e := range g.Edges {
+		edges = append(edges, [3]string{e.From, e.To, e.Label})
+	}
+	if want := [][3]string{{"n-a", "n-b", "uses"}, {"n-b", "n-a", "back"}}; !reflect.DeepEqual(edges, want) {
+		t.Errorf("edges = %v, want %v", edges, want)
+	}
+	if got := g.Edges[1].Attrs["weight"]; got != "0.5" {
+		t.Errorf("weight = %q, want 0.5", got)
+	}
+	if _, ok := g.node("n-a"); !ok {
+		t.Error("graph not reindexed after renaming")
+	}
+}
+
+func TestScriptStepErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, want string
+	}{
+		{"no hooks", "x = 1", "defines neither node(n) nor edge(e)"},
+		{"hook not a function", "node = 1", "node is a int, not a function"},
+		{"syntax error", "def node(n)\n    pass", "rules.star:1: unexpected end of line"},
+		{"runtime error", "def node(n):\n    return n['nope']", `node(a): `},
+		{"returns True", "def node(n):\n    return True", "node(a): returned True"},
+		{"returns a string", "def edge(e):\n    return 'x'", "edge(a -> b): returned a string"},
+		{"unknown field", "def node(n):\n    return {'colour': 'red'}", `unknown field "colour"`},
+		{"field not a string", "def node(n):\n    n['name'] = 1", "name is a int, not a string"},
+		{"duplicate id", "def node(n):\n    n['id'] = 'same'", `two nodes have id "same"`},
+		{"empty id", "def node(n):\n    return {'id': ''}", "node(a) returned an empty id"},
+		{"edge to nowhere", "def edge(e):\n    e['to'] = 'zzz'", `no node has id "zzz"`},
+		{"list attribute", "def node(n):\n    n['attrs']['x'] = []", `attrs["x"] is a list`},
+		{"top-level error", "fail('stop')", "rules.star:1: fail: stop"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := runScript(t, tc.src)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestScriptStepFiles checks that a script's contents are part of the
+// cache key.
+func TestScriptStepFiles(t *testing.T) {
+	if got, want := stepFiles("script:rules.star"), []string{"rules.star"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stepFiles = %v, want %v", got, want)
+	}
+}
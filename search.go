@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type searchHit struct {
+	Canvas     string   `json:"canvas"`
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	Name       string   `json:"name"`
+	Neighbours []string `json:"neighbours"` // display names
+}
+
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	var inputs stringsFlag
+	fs.Var(&inputs, "in", "canvas `path or glob` to search (repeatable; positional args work too)")
+	text := fs.String("text", "", "case-insensitive substring to look for in node text, file and url")
+	pattern := fs.String("regexp", "", "regular expression to look for instead of -text")
+	nodeType := fs.String("type", "", "only nodes of this type")
+	depth := fs.Int("depth", 1, "list neighbours up to this many hops away")
+	asJSON := fs.Bool("json", false, "print hits as JSON lines")
+	fs.Parse(args)
+
+	var match func(string) bool
+	switch {
+	case *pattern != "":
+		re, err := regexp.Compile(*pattern)
+		if err != nil {
+			fatalf("search: -regexp: %v", err)
+		}
+		match = re.MatchString
+	case *text != "":
+		needle := strings.ToLower(*text)
+		match = func(s string) bool { return strings.Contains(strings.ToLower(s), needle) }
+	default:
+		fatalf("search: need -text or -regexp")
+	}
+	paths, err := expandInputs(append(inputs, fs.Args()...))
+	if err != nil {
+		fatalf("search: %v", err)
+	}
+	if len(paths) == 0 {
+		fatalf("search: no canvases given (-in)")
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	found := false
+	for _, path := range paths {
+		c, err := loadCanvas(path)
+		if err != nil {
+			slog.Warn("search: " + err.Error())
+			continue
+		}
+		g := buildGraph(c, true)
+		for _, n := range g.Nodes {
+			if *nodeType != "" && n.Type != *nodeType {
+				continue
+			}
+			if !match(n.Node.Text) && !match(n.Node.File) && !match(n.Node.URL) && !match(n.Node.Label) {
+				continue
+			}
+			found = true
+			hit := searchHit{Canvas: path, ID: n.ID, Type: n.Type, Name: n.Name, Neighbours: []string{}}
+			for _, id := range g.neighbors(n.ID, *depth, "both") {
+				if name := g.name(id); name != "" {
+					hit.Neighbours = append(hit.Neighbours, name)
+				}
+			}
+			if *asJSON {
+				enc.Encode(hit)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", hit.Canvas, hit.ID, hit.Name, strings.Join(hit.Neighbours, ", "))
+		}
+	}
+	if !found {
+		w.Flush()
+		os.Exit(1) // like grep
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serve: load one or more canvases and answer queries over HTTP.
+
+type servedCanvas struct {
+	path string
+	g    *graph
+
+	size    int64 // of the file when it was loaded
+	modTime time.Time
+}
+
+type server struct {
+	store   *graphStore
+	auth    bool // requests need a bearer token
+	metrics *serveMetrics
+	limits  parseLimits // for canvases sent by clients
+	form    string      // -normalize
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "listen address")
+	logOpts := registerLogFlags(fs, "text")
+	s := &server{metrics: newServeMetrics()}
+	registerLimitFlags(fs, &s.limits, serveLimits)
+	keepPath := fs.Bool("keep-path", false, "for file nodes, keep full path instead of base name")
+	fs.StringVar(&s.form, "normalize", "nfc", "Unicode `form` node names and edge labels are served in: "+strings.Join(normForms, ", "))
+	tokenPath := fs.String("tokens", "", "`file` of \"name secret\" lines; requests must send one of the secrets as a bearer token")
+	rate := fs.Float64("rate", 0, "requests a second allowed per client (token name, or remote address for requests without a valid token) on average; 0 for no limit")
+	burst := fs.Int("burst", 10, "requests a client may make at once under -rate")
+	reload := fs.Duration("reload", 2*time.Second, "check the canvases for changes this often, reloading changed ones and picking up new glob matches (0 to load once)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: canvas_tool serve [flags] [file.canvas|glob ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fatalf("serve: %v", err)
+	}
+	if err := checkNormForm(s.form); err != nil {
+		fatalf("serve: -normalize: %v", err)
+	}
+
+	gd := &guard{metrics: s.metrics}
+	if *tokenPath != "" {
+		var err error
+		if gd.tokens, err = readTokens(*tokenPath); err != nil {
+			fatalf("serve: -tokens: %v", err)
+		}
+		s.auth = true
+	} else if !isLoopback(*addr) {
+		slog.Warn("serving beyond localhost without -tokens; anyone who can reach " + *addr + " can query it")
+	}
+	if *rate < 0 || *burst < 1 {
+		fatalf("serve: -rate must be at least 0 and -burst at least 1")
+	}
+	if *rate > 0 {
+		gd.limiter = &rateLimiter{rate: *rate, burst: float64(*burst), clients: map[string]*tokenBucket{}}
+	}
+
+	s.store = newGraphStore(fs.Args(), *keepPath, s.form, s.limits)
+	s.store.onReload = func(result string) { s.metrics.reloads.inc(result) }
+	if err := s.store.refresh(); err != nil {
+		fatalf("serve: %v", err)
+	}
+	if *reload > 0 {
+		go s.store.watch(context.Background(), *reload)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", s.handleConvert)
+	mux.HandleFunc("/graphql", s.handleGraphQL)
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc(grpcService, s.handleGRPC)
+	mux.HandleFunc("/metrics", s.metrics.reg.handle)
+
+	// HTTP/1.1 for the HTTP endpoints, cleartext HTTP/2 for gRPC clients
+	srv := &http.Server{Addr: *addr, Handler: logRequests(gd.wrap(mux)), Protocols: new(http.Protocols)}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	slog.Info("serving", "canvases", len(s.store.snapshot()), "addr", *addr, "convert", "/convert", "graphql", "/graphql", "openapi", "/openapi.json", "metrics", "/metrics", "grpc", strings.TrimSuffix(grpcService, "/"))
+	if err := srv.ListenAndServe(); err != nil {
+		fatalf("serve: %v", err)
+	}
+}
+
+// statusRecorder remembers the status a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// logRequests logs every request at debug level.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		slog.Debug("request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
+
+// expandInputs expands glob patterns, keeping plain paths as given.
+func expandInputs(args []string) ([]string, error) {
+	var paths []string
+	for _, a := range args {
+		if !strings.ContainsAny(a, "*?[") {
+			paths = append(paths, a)
+			continue
+		}
+		matches, err := filepath.Glob(a)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", a)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+func (s *server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "variables: "+err.Error())
+				return
+			}
+		}
+	case http.MethodPost:
+		body := io.Reader(r.Body)
+		if s.limits.maxBytes > 0 {
+			body = http.MaxBytesReader(w, r.Body, s.limits.maxBytes)
+		}
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			var tooBig *http.MaxBytesError
+			if errors.As(err, &tooBig) {
+				writeGraphQLError(w, http.StatusRequestEntityTooLarge, "request body is over the "+strconv.FormatInt(s.limits.maxBytes, 10)+" byte limit")
+				return
+			}
+			writeGraphQLError(w, http.StatusBadRequest, "request body: "+err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeGraphQLError(w, http.StatusMethodNotAllowed, "use GET or POST")
+		return
+	}
+
+	resp, err := executeGraphQL(req.Query, req.OperationName, req.Variables, gqlQuery{s})
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(gqlResponse{Errors: []gqlError{{Message: msg}}})
+}
+
+// GraphQL schema:
+//
+//	type Query {
+//	  canvases: [String!]!
+//	  node(id: ID!, canvas: String): Node
+//	  nodes(filter: {canvas, type, name}, first: Int): [Node!]!
+//	  edges(canvas: String, label: String, first: Int): [Edge!]!
+//	  neighbors(id: ID!, canvas: String, depth: Int = 1, direction: OUT|IN|BOTH = BOTH): [Node!]!
+//	}
+//	type Node { id canvas type name text file url outgoing: [Edge!]! incoming: [Edge!]! neighbors(depth, direction): [Node!]! }
+//	type Edge { canvas label from: Node to: Node }
+//
+// nodes' name filter is a case-insensitive substring match. POST bodies
+// are held to -max-bytes, and executeGraphQL bounds how deep and how large
+// a query may get.
+
+type gqlQuery struct{ s *server }
+
+type gqlNode struct {
+	c *servedCanvas
+	n graphNode
+}
+
+type gqlEdge struct {
+	c *servedCanvas
+	e graphEdge
+}
+
+func (gqlQuery) typename() string { return "Query" }
+
+func (q gqlQuery) field(name string, args map[string]any) (any, error) {
+	first := gqlArgInt(args, "first", -1)
+	switch name {
+	case "canvases":
+		var paths []string
+		for _, c := range q.s.store.snapshot() {
+			paths = append(paths, c.path)
+		}
+		return paths, nil
+	case "node":
+		id := gqlArgString(args, "id")
+		for _, c := range q.s.lookup(gqlArgString(args, "canvas")) {
+			if n, ok := c.g.node(id); ok {
+				return gqlNode{c, n}, nil
+			}
+		}
+		return nil, nil
+	case "nodes":
+		filter := gqlArgObject(args, "filter")
+		typ := gqlArgString(filter, "type")
+		match := strings.ToLower(gqlArgString(filter, "name"))
+		var out []gqlResolver
+		for _, c := range q.s.lookup(gqlArgString(filter, "canvas")) {
+			for _, n := range c.g.Nodes {
+				if (typ == "" || n.Type == typ) && strings.Contains(strings.ToLower(n.Name), match) {
+					out = append(out, gqlNode{c, n})
+				}
+			}
+		}
+		return limit(out, first), nil
+	case "edges":
+		label, hasLabel := args["label"].(string)
+		var out []gqlResolver
+		for _, c := range q.s.lookup(gqlArgString(args, "canvas")) {
+			for _, e := range c.g.Edges {
+				if !hasLabel || e.Label == label {
+					out = append(out, gqlEdge{c, e})
+				}
+			}
+		}
+		return limit(out, first), nil
+	case "neighbors":
+		id := gqlArgString(args, "id")
+		for _, c := range q.s.lookup(gqlArgString(args, "canvas")) {
+			if _, ok := c.g.node(id); ok {
+				return gqlNeighbors(c, id, args), nil
+			}
+		}
+		return []gqlResolver{}, nil
+	}
+	return nil, fmt.Errorf("unknown field %q on Query", name)
+}
+
+// lookup returns the canvases matching path, or all of them if path is "".
+func (s *server) lookup(path string) []*servedCanvas {
+	if path == "" {
+		return s.store.snapshot()
+	}
+	if c, ok := s.store.get(path); ok {
+		return []*servedCanvas{c}
+	}
+	return nil
+}
+
+func limit(xs []gqlResolver, n int) []gqlResolver {
+	if xs == nil {
+		return []gqlResolver{}
+	}
+	if n >= 0 && n < len(xs) {
+		return xs[:n]
+	}
+	return xs
+}
+
+func gqlNeighbors(c *servedCanvas, id string, args map[string]any) []gqlResolver {
+	dir := strings.ToLower(gqlArgString(args, "direction"))
+	if dir == "" {
+		dir = "both"
+	}
+	out := []gqlResolver{}
+	for _, nid := range c.g.neighbors(id, gqlArgInt(args, "depth", 1), dir) {
+		if n, ok := c.g.node(nid); ok {
+			out = append(out, gqlNode{c, n})
+		}
+	}
+	return out
+}
+
+func (gqlNode) typename() string { return "Node" }
+
+func (n gqlNode) field(name string, args map[string]any) (any, error) {
+	orNull := func(s string) any {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	switch name {
+	case "id":
+		return n.n.ID, nil
+	case "canvas":
+		return n.c.path, nil
+	case "type":
+		return n.n.Type, nil
+	case "name":
+		return n.n.Name, nil
+	case "text":
+		return orNull(n.n.Node.Text), nil
+	case "file":
+		return orNull(n.n.Node.File), nil
+	case "url":
+		return orNull(n.n.Node.URL), nil
+	case "outgoing", "incoming":
+		idx := n.c.g.out[n.n.ID]
+		if name == "incoming" {
+			idx = n.c.g.in[n.n.ID]
+		}
+		out := []gqlResolver{}
+		for _, i := range idx {
+			out = append(out, gqlEdge{n.c, n.c.g.Edges[i]})
+		}
+		return out, nil
+	case "neighbors":
+		return gqlNeighbors(n.c, n.n.ID, args), nil
+	}
+	return nil, fmt.Errorf("unknown field %q on Node", name)
+}
+
+func (gqlEdge) typename() string { return "Edge" }
+
+func (e gqlEdge) field(name string, _ map[string]any) (any, error) {
+	endpoint := func(id string) any {
+		if n, ok := e.c.g.node(id); ok {
+			return gqlNode{e.c, n}
+		}
+		return nil
+	}
+	switch name {
+	case "canvas":
+		return e.c.path, nil
+	case "label":
+		return e.e.Label, nil
+	case "from":
+		return endpoint(e.e.From), nil
+	case "to":
+		return endpoint(e.e.To), nil
+	}
+	return nil, fmt.Errorf("unknown field %q on Edge", name)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// split breaks a canvas up by its top-level groups: each group and the
+// nodes inside it become a canvas of their own, and an index canvas links
+// to the parts. Nodes are copied verbatim, so colours and other fields the
+// exporter ignores are kept.
+
+// node decodes the fields of a canvas node this tool understands.
+func (o jsonObject) node() Node {
+	var n Node
+	data, _ := o.MarshalJSON()
+	json.Unmarshal(data, &n)
+	return n
+}
+
+// splitPart is one output canvas.
+type splitPart struct {
+	group Node
+	file  string // output path
+	doc   *canvasDoc
+}
+
+// fileSlug turns a group label into a file name: letters, digits, - and _
+// are kept and runs of anything else become a single -.
+func fileSlug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.TrimSpace(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// canvasSplit is a canvas divided among its top-level groups.
+type canvasSplit struct {
+	parts []*splitPart
+	owner map[string]int // node ID -> part index, -1 when ungrouped
+	rest  *canvasDoc     // ungrouped nodes and the edges between them
+	cross []jsonObject   // edges whose ends lie in different parts
+}
+
+// splitCanvas assigns every node to the first top-level group containing
+// it. Edges within a part go with it.
+func splitCanvas(doc *canvasDoc) canvasSplit {
+	nodes := make([]Node, len(doc.Nodes))
+	for i, o := range doc.Nodes {
+		nodes[i] = o.node()
+	}
+	s := canvasSplit{owner: map[string]int{}, rest: &canvasDoc{root: doc.root}}
+	for i, n := range nodes {
+		if n.Type != "group" {
+			continue
+		}
+		nested := false
+		for j, g := range nodes {
+			// Of two identical rectangles the first is the outer one.
+			if j != i && g.Type == "group" && n.within(g) && !(g.within(n) && j > i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			s.parts = append(s.parts, &splitPart{group: n, doc: &canvasDoc{root: doc.root}})
+		}
+	}
+
+	for i, n := range nodes {
+		s.owner[n.ID] = -1
+		for p, part := range s.parts {
+			if n.ID == part.group.ID || n.within(part.group) {
+				s.owner[n.ID] = p
+				break
+			}
+		}
+		if p := s.owner[n.ID]; p >= 0 {
+			s.parts[p].doc.Nodes = append(s.parts[p].doc.Nodes, doc.Nodes[i])
+		} else {
+			s.rest.Nodes = append(s.rest.Nodes, doc.Nodes[i])
+		}
+	}
+
+	for _, e := range doc.Edges {
+		from, to := s.owner[e.str("fromNode")], s.owner[e.str("toNode")]
+		switch {
+		case from != to:
+			s.cross = append(s.cross, e)
+		case from >= 0:
+			s.parts[from].doc.Edges = append(s.parts[from].doc.Edges, e)
+		default:
+			s.rest.Edges = append(s.rest.Edges, e)
+		}
+	}
+	return s
+}
+
+func runSplit(args []string) {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	outdir := fs.String("outdir", "", "directory for the part canvases and the index (default: next to the input)")
+	vault := fs.String("vault", "", "vault root the index's file nodes are relative to (default: -outdir)")
+	noIndex := fs.Bool("no-index", false, "don't write the index canvas")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatalf("split: want one canvas")
+	}
+	in := fs.Arg(0)
+	doc, err := readCanvasDoc(in)
+	if err != nil {
+		fatalf("split: %v", err)
+	}
+	if *outdir == "" {
+		*outdir = filepath.Dir(in)
+	}
+	if *vault == "" {
+		*vault = *outdir
+	}
+	if err := os.MkdirAll(*outdir, 0o755); err != nil {
+		fatalf("split: %v", err)
+	}
+
+	split := splitCanvas(doc)
+	parts := split.parts
+	if len(parts) == 0 {
+		fatalf("split: %s has no groups", in)
+	}
+	base := strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))
+	used := map[string]bool{strings.ToLower(base + "-index"): true}
+	for i, p := range parts {
+		name := fileSlug(p.group.Label)
+		if name == "" {
+			name = fmt.Sprintf("group-%d", i+1)
+		}
+		stem := base + "-" + name
+		for n := 2; used[strings.ToLower(stem)]; n++ {
+			stem = fmt.Sprintf("%s-%s-%d", base, name, n)
+		}
+		used[strings.ToLower(stem)] = true
+		p.file = filepath.Join(*outdir, stem+".canvas")
+		if err := p.doc.write(p.file); err != nil {
+			fatalf("split: %v", err)
+		}
+		fmt.Printf("%s: %d nodes, %d edges\n", p.file, len(p.doc.Nodes), len(p.doc.Edges))
+	}
+	if *noIndex {
+		return
+	}
+
+	// The index keeps the ungrouped nodes where they were and puts a file
+	// node for each part in its group's place, with one edge per pair of
+	// parts labelled with the number of edges they shared.
+	index := split.rest
+	partIDs := make([]string, len(parts))
+	for i, p := range parts {
+		rel, err := filepath.Rel(*vault, p.file)
+		if err != nil {
+			fatalf("split: %v", err)
+		}
+		n := jsonObject{}
+		partIDs[i] = canvasID("split", p.group.ID)
+		n.set("id", partIDs[i])
+		n.set("type", "file")
+		n.set("file", filepath.ToSlash(rel))
+		n.set("x", p.group.X)
+		n.set("y", p.group.Y)
+		n.set("width", p.group.Width)
+		n.set("height", p.group.Height)
+		index.Nodes = append(index.Nodes, n)
+	}
+	var pairs [][2]int
+	counts := map[[2]int]int{}
+	for _, e := range split.cross {
+		fromID, toID := e.str("fromNode"), e.str("toNode")
+		from, to := split.owner[fromID], split.owner[toID]
+		if from >= 0 && to >= 0 {
+			key := [2]int{from, to}
+			if counts[key] == 0 {
+				pairs = append(pairs, key)
+			}
+			counts[key]++
+			continue
+		}
+		// An edge touching an ungrouped node keeps that node as an end.
+		edge := append(jsonObject(nil), e...)
+		if from >= 0 {
+			edge.set("fromNode", partIDs[from])
+		}
+		if to >= 0 {
+			edge.set("toNode", partIDs[to])
+		}
+		index.Edges = append(index.Edges, edge)
+	}
+	for _, key := range pairs {
+		edge := jsonObject{}
+		edge.set("id", canvasID("split-edge", partIDs[key[0]], partIDs[key[1]]))
+		edge.set("fromNode", partIDs[key[0]])
+		edge.set("toNode", partIDs[key[1]])
+		if counts[key] > 1 {
+			edge.set("label", fmt.Sprintf("%d edges", counts[key]))
+		}
+		index.Edges = append(index.Edges, edge)
+	}
+	indexPath := filepath.Join(*outdir, base+"-index.canvas")
+	if err := index.write(indexPath); err != nil {
+		fatalf("split: %v", err)
+	}
+	fmt.Printf("%s: %d parts\n", indexPath, len(parts))
+}
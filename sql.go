@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sqlDialect captures the few places postgres, mysql and sqlite disagree.
+type sqlDialect struct {
+	quoteIdent   func(string) string
+	quoteString  func(string) string
+	keyType      string // type for primary-key text columns
+	begin        string
+	create       string
+	createSuffix string
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"postgres": {
+		quoteIdent:  func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` },
+		quoteString: func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" },
+		keyType:     "TEXT",
+		begin:       "BEGIN;",
+		create:      "CREATE TABLE IF NOT EXISTS",
+	},
+	"mysql": {
+		quoteIdent: func(s string) string { return "`" + strings.ReplaceAll(s, "`", "``") + "`" },
+		quoteString: func(s string) string {
+			s = strings.ReplaceAll(s, `\`, `\\`)
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		},
+		keyType:      "VARCHAR(255)",
+		begin:        "START TRANSACTION;",
+		create:       "CREATE TABLE IF NOT EXISTS",
+		createSuffix: " DEFAULT CHARSET=utf8mb4",
+	},
+	"sqlite": {
+		quoteIdent:  func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` },
+		quoteString: func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" },
+		keyType:     "TEXT",
+		begin:       "BEGIN TRANSACTION;",
+		create:      "CREATE TABLE IF NOT EXISTS",
+	},
+	// duckdb output targets a database file that is rebuilt on every export
+	"duckdb": {
+		quoteIdent:  func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` },
+		quoteString: func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" },
+		keyType:     "TEXT",
+		begin:       "BEGIN TRANSACTION;",
+		create:      "CREATE OR REPLACE TABLE",
+	},
+}
+
+// writeSQL emits CREATE TABLE statements for nodes and edges followed by
+// batched INSERTs, wrapped in a single transaction.
+func writeSQL(out io.Writer, g *graph, opts exportOptions) error {
+	dialect := opts.opt("sql", "dialect", "postgres")
+	d, ok := sqlDialects[dialect]
+	if !ok {
+		return fmt.Errorf("unknown SQL dialect %q (want postgres, mysql, sqlite or duckdb)", dialect)
+	}
+	batch, err := opts.optInt("sql", "batch", 500)
+	if err != nil {
+		return err
+	}
+	if batch <= 0 {
+		return errors.New("-opt sql.batch must be positive")
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, d.begin)
+	for _, t := range []table{nodeTable(g), edgeTable(g)} {
+		writeSQLTable(w, d, t, batch)
+	}
+	fmt.Fprintln(w, "COMMIT;")
+	return w.Flush()
+}
+
+func writeSQLTable(w *bufio.Writer, d sqlDialect, t table, batch int) {
+	cols := make([]string, len(t.columns))
+	defs := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		cols[i] = d.quoteIdent(c.name)
+		typ := "TEXT"
+		if c.nodeID {
+			typ = d.keyType
+		}
+		switch {
+		case c.primary:
+			typ += " PRIMARY KEY"
+		case c.required:
+			typ += " NOT NULL"
+		}
+		defs[i] = "  " + cols[i] + " " + typ
+	}
+	fmt.Fprintf(w, "\n%s %s (\n%s\n)%s;\n", d.create, d.quoteIdent(t.name), strings.Join(defs, ",\n"), d.createSuffix)
+
+	for start := 0; start < len(t.rows); start += batch {
+		fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES\n", d.quoteIdent(t.name), strings.Join(cols, ", "))
+		chunk := t.rows[start:min(start+batch, len(t.rows))]
+		for i, row := range chunk {
+			vals := make([]string, len(row))
+			for j, v := range row {
+				if v == nil {
+					vals[j] = "NULL"
+				} else {
+					vals[j] = d.quoteString(*v)
+				}
+			}
+			sep := ","
+			if i == len(chunk)-1 {
+				sep = ";"
+			}
+			fmt.Fprintf(w, "  (%s)%s\n", strings.Join(vals, ", "), sep)
+		}
+	}
+}
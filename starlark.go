@@ -0,0 +1,3420 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A small interpreter for the part of Starlark (the Python dialect Bazel
+// configures builds in, https://github.com/bazelbuild/starlark) that graph
+// scripts need: def, lambda, if/elif/else, for, list and dict
+// comprehensions, the usual operators, None/bool/int/float/string/list/
+// tuple/dict values with their common methods, and the builtins listed in
+// starBuiltins. As in Starlark there is no while loop, recursion is an
+// error and strings index by byte; load, sets, bytes, *args and **kwargs
+// are not supported.
+
+// Lexing.
+
+type starToken struct {
+	kind byte // 'n' name, 'i' int, 'f' float, 's' string, 'p' punctuation, '\n' end of line, '>' indent, '<' dedent, 0 end
+	val  string
+	line int
+}
+
+var starPuncts = []string{
+	"//=", "**",
+	"==", "!=", "<=", ">=", "+=", "-=", "*=", "/=", "%=", "//",
+	"+", "-", "*", "/", "%", "<", ">", "=", "(", ")", "[", "]", "{", "}", ",", ":", ".", ";",
+}
+
+func starLex(src string) ([]starToken, error) {
+	var toks []starToken
+	indents := []int{0}
+	line, depth := 1, 0 // depth of open brackets, inside which lines continue
+	lineStart := true
+	for i := 0; i < len(src); {
+		if lineStart {
+			j, col := i, 0
+			for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+				if src[j] == '\t' {
+					return nil, fmt.Errorf("line %d: tab in indentation", line)
+				}
+				j, col = j+1, col+1
+			}
+			if j == len(src) || src[j] == '\n' || src[j] == '\r' || src[j] == '#' {
+				for j < len(src) && src[j] != '\n' {
+					j++ // a blank or comment line
+				}
+				if j < len(src) {
+					j, line = j+1, line+1
+				}
+				i = j
+				continue
+			}
+			i, lineStart = j, false
+			if top := indents[len(indents)-1]; col > top {
+				indents = append(indents, col)
+				toks = append(toks, starToken{kind: '>', line: line})
+			}
+			for col < indents[len(indents)-1] {
+				indents = indents[:len(indents)-1]
+				toks = append(toks, starToken{kind: '<', line: line})
+			}
+			if col != indents[len(indents)-1] {
+				return nil, fmt.Errorf("line %d: unindent does not match an outer indentation level", line)
+			}
+			continue
+		}
+		c := src[i]
+		switch {
+		case c == '\n':
+			if depth == 0 {
+				toks = append(toks, starToken{kind: '\n', line: line})
+				lineStart = true
+			}
+			i, line = i+1, line+1
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && strings.HasPrefix(src[i+1:], "\n"):
+			i, line = i+2, line+1
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '\'' || (c == 'r' || c == 'R') && i+1 < len(src) && (src[i+1] == '"' || src[i+1] == '\''):
+			raw := c == 'r' || c == 'R'
+			if raw {
+				i++
+			}
+			s, n, err := starString(src[i:], raw, &line)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, starToken{kind: 's', val: s, line: line})
+			i += n
+		case isASCIILetter(c) || c == '_':
+			j := i
+			for j < len(src) && (isASCIILetter(src[j]) || isASCIIDigit(src[j]) || src[j] == '_') {
+				j++
+			}
+			toks = append(toks, starToken{kind: 'n', val: src[i:j], line: line})
+			i = j
+		case isASCIIDigit(c) || c == '.' && i+1 < len(src) && isASCIIDigit(src[i+1]):
+			j, kind := i, byte('i')
+			if strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X") || strings.HasPrefix(src[i:], "0o") || strings.HasPrefix(src[i:], "0O") {
+				j += 2
+			}
+			for j < len(src) && (isASCIIDigit(src[j]) || isASCIILetter(src[j]) || src[j] == '.' || src[j] == '_' ||
+				(src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E') && kind == 'f') {
+				if src[j] == '.' || (src[j] == 'e' || src[j] == 'E') && !strings.HasPrefix(src[i:], "0x") && !strings.HasPrefix(src[i:], "0X") {
+					kind = 'f'
+				}
+				j++
+			}
+			toks = append(toks, starToken{kind: kind, val: src[i:j], line: line})
+			i = j
+		default:
+			p := ""
+			for _, q := range starPuncts {
+				if strings.HasPrefix(src[i:], q) {
+					p = q
+					break
+				}
+			}
+			switch p {
+			case "":
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, fmt.Errorf("line %d: unexpected %q", line, r)
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				if depth > 0 {
+					depth--
+				}
+			}
+			toks = append(toks, starToken{kind: 'p', val: p, line: line})
+			i += len(p)
+		}
+	}
+	if !lineStart {
+		toks = append(toks, starToken{kind: '\n', line: line})
+	}
+	for len(indents) > 1 {
+		indents = indents[:len(indents)-1]
+		toks = append(toks, starToken{kind: '<', line: line})
+	}
+	return append(toks, starToken{line: line}), nil
+}
+
+// starString reads the string literal at the start of src, returning its
+// value and length; line advances past line breaks inside it.
+func starString(src string, raw bool, line *int) (string, int, error) {
+	q := src[:1]
+	if strings.HasPrefix(src, q+q+q) {
+		q = q + q + q
+	}
+	start := *line
+	var b strings.Builder
+	for j := len(q); j < len(src); j++ {
+		c := src[j]
+		switch {
+		case strings.HasPrefix(src[j:], q):
+			return b.String(), j + len(q), nil
+		case c == '\n':
+			if len(q) == 1 {
+				return "", 0, fmt.Errorf("line %d: unterminated string", start)
+			}
+			*line++
+			b.WriteByte(c)
+		case c == '\\' && j+1 < len(src):
+			j++
+			e := src[j]
+			if e == '\n' {
+				*line++
+			}
+			if raw {
+				b.WriteByte(c)
+				b.WriteByte(e)
+				continue
+			}
+			switch e {
+			case '\n':
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			case '\\', '\'', '"':
+				b.WriteByte(e)
+			case 'x', 'u', 'U':
+				digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+				if j+digits >= len(src) {
+					return "", 0, fmt.Errorf("line %d: bad escape \\%c", *line, e)
+				}
+				r, err := strconv.ParseUint(src[j+1:j+1+digits], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return "", 0, fmt.Errorf("line %d: bad escape \\%s", *line, src[j:j+1+digits])
+				}
+				b.WriteRune(rune(r))
+				j += digits
+			default:
+				return "", 0, fmt.Errorf("line %d: bad escape \\%c", *line, e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("line %d: unterminated string", start)
+}
+
+// Syntax.
+
+type starStmt interface{ pos() int }
+
+type starExpr interface{ pos() int }
+
+type (
+	starExprStmt struct {
+		line int
+		x    starExpr
+	}
+	starAssign struct {
+		line   int
+		op     string // "" for =, "+" for +=, and so on
+		target starExpr
+		value  starExpr
+	}
+	starIf struct {
+		line       int
+		cond       starExpr
+		then, els_ []starStmt
+	}
+	starFor struct {
+		line   int
+		target starExpr
+		iter   starExpr
+		body   []starStmt
+	}
+	starDef struct {
+		line int
+		fn   *starFuncLit
+	}
+	starReturn struct {
+		line int
+		x    starExpr // nil: None
+	}
+	starBranch struct {
+		line int
+		kw   string // break, continue or pass
+	}
+)
+
+type (
+	starIdent struct {
+		line int
+		name string
+	}
+	starLit struct {
+		line int
+		v    any
+	}
+	starListExpr struct {
+		line  int
+		elems []starExpr
+	}
+	starTupleExpr struct {
+		line  int
+		elems []starExpr
+	}
+	starDictExpr struct {
+		line       int
+		keys, vals []starExpr
+	}
+	// starComp is a list or dict comprehension.
+	starComp struct {
+		line     int
+		dict     bool
+		key, val starExpr // key only for dicts
+		clauses  []starClause
+	}
+	starIndex struct {
+		line int
+		x, i starExpr
+	}
+	starSlice struct {
+		line          int
+		x, lo, hi, st starExpr // nil when left out
+	}
+	starDot struct {
+		line int
+		x    starExpr
+		name string
+	}
+	starCall struct {
+		line   int
+		fn     starExpr
+		args   []starExpr
+		kwargs []starKwargExpr
+	}
+	starUnary struct {
+		line int
+		op   string
+		x    starExpr
+	}
+	starBinary struct {
+		line int
+		op   string
+		x, y starExpr
+	}
+	starCond struct {
+		line            int
+		cond, then, els starExpr
+	}
+	starFuncLit struct {
+		line     int
+		name     string
+		params   []string
+		defaults []starExpr // for the last len(defaults) params
+		body     []starStmt
+	}
+)
+
+// starClause is a "for target in iter" or "if cond" of a comprehension.
+type starClause struct {
+	target, iter starExpr // for
+	cond         starExpr // if
+}
+
+type starKwargExpr struct {
+	name string
+	x    starExpr
+}
+
+func (s *starExprStmt) pos() int  { return s.line }
+func (s *starAssign) pos() int    { return s.line }
+func (s *starIf) pos() int        { return s.line }
+func (s *starFor) pos() int       { return s.line }
+func (s *starDef) pos() int       { return s.line }
+func (s *starReturn) pos() int    { return s.line }
+func (s *starBranch) pos() int    { return s.line }
+func (x *starIdent) pos() int     { return x.line }
+func (x *starLit) pos() int       { return x.line }
+func (x *starListExpr) pos() int  { return x.line }
+func (x *starTupleExpr) pos() int { return x.line }
+func (x *starDictExpr) pos() int  { return x.line }
+func (x *starComp) pos() int      { return x.line }
+func (x *starIndex) pos() int     { return x.line }
+func (x *starSlice) pos() int     { return x.line }
+func (x *starDot) pos() int       { return x.line }
+func (x *starCall) pos() int      { return x.line }
+func (x *starUnary) pos() int     { return x.line }
+func (x *starBinary) pos() int    { return x.line }
+func (x *starCond) pos() int      { return x.line }
+func (x *starFuncLit) pos() int   { return x.line }
+
+// starProgram is a parsed script.
+type starProgram struct {
+	file string
+	body []starStmt
+}
+
+type starParser struct {
+	toks  []starToken
+	i     int
+	funcs int // function bodies being parsed
+	loops int // loops being parsed in the innermost function
+}
+
+// Names that can't be assigned or used as identifiers.
+var starKeywords = map[string]bool{
+	"and": true, "break": true, "continue": true, "def": true, "elif": true, "else": true,
+	"for": true, "if": true, "in": true, "lambda": true, "load": true, "not": true,
+	"or": true, "pass": true, "return": true,
+	"True": true, "False": true, "None": true,
+	// reserved by Starlark for Python keywords it leaves out
+	"as": true, "assert": true, "class": true, "del": true, "except": true, "finally": true,
+	"from": true, "global": true, "import": true, "is": true, "nonlocal": true, "raise": true,
+	"try": true, "while": true, "with": true, "yield": true,
+}
+
+// parseStarlark parses the script src; file names it in error messages.
+func parseStarlark(file, src string) (*starProgram, error) {
+	toks, err := starLex(strings.TrimPrefix(src, "\ufeff"))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", file, strings.TrimPrefix(err.Error(), "line "))
+	}
+	p := &starParser{toks: toks}
+	var body []starStmt
+	for p.peek().kind != 0 {
+		stmts, err := p.stmt()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%v", file, strings.TrimPrefix(err.Error(), "line "))
+		}
+		body = append(body, stmts...)
+	}
+	return &starProgram{file: file, body: body}, nil
+}
+
+func (p *starParser) peek() starToken { return p.toks[p.i] }
+
+func (p *starParser) next() starToken {
+	t := p.toks[p.i]
+	if t.kind != 0 {
+		p.i++
+	}
+	return t
+}
+
+func (p *starParser) is(kind byte, val string) bool {
+	t := p.peek()
+	return t.kind == kind && t.val == val
+}
+
+func (p *starParser) isPunct(v string) bool { return p.is('p', v) }
+
+func (p *starParser) isKeyword(v string) bool { return p.is('n', v) }
+
+func (p *starParser) unexpected() error {
+	t := p.peek()
+	switch t.kind {
+	case 0:
+		return fmt.Errorf("line %d: unexpected end of file", t.line)
+	case '\n':
+		return fmt.Errorf("line %d: unexpected end of line", t.line)
+	case '>', '<':
+		return fmt.Errorf("line %d: unexpected indentation", t.line)
+	case 's':
+		return fmt.Errorf("line %d: unexpected string %q", t.line, t.val)
+	}
+	return fmt.Errorf("line %d: unexpected %q", t.line, t.val)
+}
+
+func (p *starParser) expect(kind byte, val string) error {
+	if !p.is(kind, val) {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+func (p *starParser) name() (string, error) {
+	t := p.peek()
+	if t.kind != 'n' || starKeywords[t.val] {
+		return "", p.unexpected()
+	}
+	p.next()
+	return t.val, nil
+}
+
+func (p *starParser) stmt() ([]starStmt, error) {
+	t := p.peek()
+	if t.kind == 'n' {
+		switch t.val {
+		case "def":
+			s, err := p.def()
+			return []starStmt{s}, err
+		case "if":
+			s, err := p.ifStmt()
+			return []starStmt{s}, err
+		case "for":
+			s, err := p.forStmt()
+			return []starStmt{s}, err
+		case "while":
+			return nil, fmt.Errorf("line %d: while loops are not supported; loop over a list or range() with for", t.line)
+		case "load":
+			return nil, fmt.Errorf("line %d: load is not supported", t.line)
+		}
+	}
+	return p.simpleStmts()
+}
+
+// simpleStmts reads small statements separated by ; up to the end of the line.
+func (p *starParser) simpleStmts() ([]starStmt, error) {
+	var out []starStmt
+	for {
+		s, err := p.smallStmt()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+		if !p.isPunct(";") {
+			break
+		}
+		p.next()
+		if p.peek().kind == '\n' {
+			break
+		}
+	}
+	if err := p.expect('\n', ""); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *starParser) smallStmt() (starStmt, error) {
+	t := p.peek()
+	if t.kind == 'n' {
+		switch t.val {
+		case "return":
+			p.next()
+			if p.funcs == 0 {
+				return nil, fmt.Errorf("line %d: return outside a function", t.line)
+			}
+			s := &starReturn{line: t.line}
+			if k := p.peek().kind; k != '\n' && !p.isPunct(";") {
+				var err error
+				if s.x, err = p.exprList(); err != nil {
+					return nil, err
+				}
+			}
+			return s, nil
+		case "break", "continue":
+			p.next()
+			if p.loops == 0 {
+				return nil, fmt.Errorf("line %d: %s outside a loop", t.line, t.val)
+			}
+			return &starBranch{line: t.line, kw: t.val}, nil
+		case "pass":
+			p.next()
+			return &starBranch{line: t.line, kw: t.val}, nil
+		}
+	}
+	x, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	op := ""
+	switch t := p.peek(); {
+	case t.kind == 'p' && t.val == "=":
+	case t.kind == 'p' && len(t.val) >= 2 && strings.HasSuffix(t.val, "=") && t.val != "==" && t.val != "!=" && t.val != "<=" && t.val != ">=":
+		op = strings.TrimSuffix(t.val, "=")
+	default:
+		return &starExprStmt{line: x.pos(), x: x}, nil
+	}
+	p.next()
+	if err := starCheckTarget(x, op != ""); err != nil {
+		return nil, err
+	}
+	v, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	return &starAssign{line: x.pos(), op: op, target: x, value: v}, nil
+}
+
+// starCheckTarget reports whether x can be assigned to: a name, an index
+// or, for plain assignment, a tuple or list of those.
+func starCheckTarget(x starExpr, augmented bool) error {
+	switch x := x.(type) {
+	case *starIdent, *starIndex:
+		return nil
+	case *starTupleExpr, *starListExpr:
+		if augmented {
+			break
+		}
+		elems := starElems(x)
+		for _, e := range elems {
+			if err := starCheckTarget(e, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("line %d: can't assign to this expression", x.pos())
+}
+
+func starElems(x starExpr) []starExpr {
+	if t, ok := x.(*starTupleExpr); ok {
+		return t.elems
+	}
+	return x.(*starListExpr).elems
+}
+
+// suite reads the body after a colon: statements on the same line, or an
+// indented block.
+func (p *starParser) suite() ([]starStmt, error) {
+	if err := p.expect('p', ":"); err != nil {
+		return nil, err
+	}
+	if p.peek().kind != '\n' {
+		return p.simpleStmts()
+	}
+	p.next()
+	if p.peek().kind != '>' {
+		return nil, fmt.Errorf("line %d: want an indented block", p.peek().line)
+	}
+	p.next()
+	var body []starStmt
+	for p.peek().kind != '<' && p.peek().kind != 0 {
+		stmts, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, stmts...)
+	}
+	p.next()
+	return body, nil
+}
+
+func (p *starParser) def() (starStmt, error) {
+	line := p.next().line
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect('p', "("); err != nil {
+		return nil, err
+	}
+	fn, err := p.params(")")
+	if err != nil {
+		return nil, err
+	}
+	fn.line, fn.name = line, name
+	p.next()
+	loops := p.loops
+	p.funcs, p.loops = p.funcs+1, 0
+	fn.body, err = p.suite()
+	p.funcs, p.loops = p.funcs-1, loops
+	if err != nil {
+		return nil, err
+	}
+	return &starDef{line: line, fn: fn}, nil
+}
+
+// params reads parameter names, with defaults, up to but not including end.
+func (p *starParser) params(end string) (*starFuncLit, error) {
+	fn := &starFuncLit{}
+	for !p.isPunct(end) {
+		if p.isPunct("*") || p.isPunct("**") {
+			return nil, fmt.Errorf("line %d: *args and **kwargs are not supported", p.peek().line)
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(fn.params, name) {
+			return nil, fmt.Errorf("line %d: duplicate parameter %s", p.peek().line, name)
+		}
+		fn.params = append(fn.params, name)
+		if p.isPunct("=") {
+			p.next()
+			d, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			fn.defaults = append(fn.defaults, d)
+		} else if len(fn.defaults) > 0 {
+			return nil, fmt.Errorf("line %d: parameter %s without a default follows one with a default", p.peek().line, name)
+		}
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if !p.isPunct(end) {
+		return nil, p.unexpected()
+	}
+	return fn, nil
+}
+
+func (p *starParser) ifStmt() (starStmt, error) {
+	line := p.next().line // if or elif
+	cond, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	s := &starIf{line: line, cond: cond}
+	if s.then, err = p.suite(); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isKeyword("elif"):
+		els, err := p.ifStmt()
+		if err != nil {
+			return nil, err
+		}
+		s.els_ = []starStmt{els}
+	case p.isKeyword("else"):
+		p.next()
+		if s.els_, err = p.suite(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (p *starParser) forStmt() (starStmt, error) {
+	line := p.next().line
+	target, err := p.targets()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	p.loops++
+	body, err := p.suite()
+	p.loops--
+	if err != nil {
+		return nil, err
+	}
+	return &starFor{line: line, target: target, iter: iter, body: body}, nil
+}
+
+// targets reads the loop variables of a for, and the "in" after them.
+func (p *starParser) targets() (starExpr, error) {
+	line := p.peek().line
+	var elems []starExpr
+	for {
+		x, err := p.postfix()
+		if err != nil {
+			return nil, err
+		}
+		if err := starCheckTarget(x, false); err != nil {
+			return nil, err
+		}
+		elems = append(elems, x)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+		if p.isKeyword("in") {
+			break
+		}
+	}
+	if err := p.expect('n', "in"); err != nil {
+		return nil, err
+	}
+	if len(elems) == 1 {
+		return elems[0], nil
+	}
+	return &starTupleExpr{line: line, elems: elems}, nil
+}
+
+// exprList reads expressions separated by commas, a tuple if there is a
+// comma.
+func (p *starParser) exprList() (starExpr, error) {
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isPunct(",") {
+		return x, nil
+	}
+	t := &starTupleExpr{line: x.pos(), elems: []starExpr{x}}
+	for p.isPunct(",") {
+		p.next()
+		if k := p.peek(); k.kind == '\n' || k.kind == 0 || p.isPunct("=") || p.isPunct(")") || p.isPunct(":") || k.kind == 'p' && strings.HasSuffix(k.val, "=") && len(k.val) > 1 && k.val != "==" && k.val != "!=" && k.val != "<=" && k.val != ">=" {
+			break
+		}
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		t.elems = append(t.elems, x)
+	}
+	return t, nil
+}
+
+func (p *starParser) expr() (starExpr, error) {
+	if p.isKeyword("lambda") {
+		line := p.next().line
+		fn, err := p.params(":")
+		if err != nil {
+			return nil, err
+		}
+		p.next()
+		body, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		fn.line, fn.name = line, "lambda"
+		fn.body = []starStmt{&starReturn{line: line, x: body}}
+		return fn, nil
+	}
+	x, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword("if") {
+		return x, nil
+	}
+	p.next()
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect('n', "else"); err != nil {
+		return nil, err
+	}
+	els, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &starCond{line: x.pos(), cond: cond, then: x, els: els}, nil
+}
+
+// Binary operators by precedence, loosest first; unary not sits between
+// "and" and the comparisons.
+var starPrec = []map[string]bool{
+	{"or": true},
+	{"and": true},
+	{"==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true, "in": true, "not in": true},
+	{"+": true, "-": true},
+	{"*": true, "/": true, "//": true, "%": true},
+}
+
+// binaryOp returns the operator at the current token, if any.
+func (p *starParser) binaryOp() string {
+	t := p.peek()
+	switch {
+	case t.kind == 'p':
+		return t.val
+	case t.kind == 'n' && (t.val == "or" || t.val == "and" || t.val == "in"):
+		return t.val
+	case t.kind == 'n' && t.val == "not" && p.toks[p.i+1].kind == 'n' && p.toks[p.i+1].val == "in":
+		return "not in"
+	}
+	return ""
+}
+
+func (p *starParser) binary(level int) (starExpr, error) {
+	if level == len(starPrec) {
+		return p.unary()
+	}
+	if level == 2 && p.isKeyword("not") {
+		line := p.next().line
+		x, err := p.binary(2)
+		if err != nil {
+			return nil, err
+		}
+		return &starUnary{line: line, op: "not", x: x}, nil
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.binaryOp()
+		if !starPrec[level][op] {
+			return x, nil
+		}
+		p.next()
+		if op == "not in" {
+			p.next()
+		}
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &starBinary{line: x.pos(), op: op, x: x, y: y}
+		if level == 2 && starPrec[2][p.binaryOp()] {
+			return nil, fmt.Errorf("line %d: comparisons can't be chained; combine them with and", p.peek().line)
+		}
+	}
+}
+
+func (p *starParser) unary() (starExpr, error) {
+	if p.isPunct("-") || p.isPunct("+") {
+		t := p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &starUnary{line: t.line, op: t.val, x: x}, nil
+	}
+	if p.isPunct("**") {
+		return nil, fmt.Errorf("line %d: ** is not supported", p.peek().line)
+	}
+	return p.postfix()
+}
+
+func (p *starParser) postfix() (starExpr, error) {
+	x, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == 'p' && t.val == ".":
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			x = &starDot{line: t.line, x: x, name: name}
+		case t.kind == 'p' && t.val == "(":
+			p.next()
+			if x, err = p.call(x, t.line); err != nil {
+				return nil, err
+			}
+		case t.kind == 'p' && t.val == "[":
+			p.next()
+			if x, err = p.index(x, t.line); err != nil {
+				return nil, err
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *starParser) call(fn starExpr, line int) (starExpr, error) {
+	c := &starCall{line: line, fn: fn}
+	for !p.isPunct(")") {
+		if p.isPunct("*") || p.isPunct("**") {
+			return nil, fmt.Errorf("line %d: *args and **kwargs are not supported", p.peek().line)
+		}
+		if t := p.peek(); t.kind == 'n' && p.toks[p.i+1].kind == 'p' && p.toks[p.i+1].val == "=" {
+			p.i += 2
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			for _, kw := range c.kwargs {
+				if kw.name == t.val {
+					return nil, fmt.Errorf("line %d: keyword argument %s repeated", t.line, t.val)
+				}
+			}
+			c.kwargs = append(c.kwargs, starKwargExpr{name: t.val, x: x})
+		} else {
+			if len(c.kwargs) > 0 {
+				return nil, fmt.Errorf("line %d: positional argument after a keyword argument", t.line)
+			}
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, x)
+		}
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return c, p.expect('p', ")")
+}
+
+func (p *starParser) index(x starExpr, line int) (starExpr, error) {
+	var parts [3]starExpr
+	n := 0
+	for {
+		if !p.isPunct(":") && !p.isPunct("]") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			parts[n] = e
+		}
+		if !p.isPunct(":") || n == 2 {
+			break
+		}
+		p.next()
+		n++
+	}
+	if err := p.expect('p', "]"); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		if parts[0] == nil {
+			return nil, fmt.Errorf("line %d: want an index", line)
+		}
+		return &starIndex{line: line, x: x, i: parts[0]}, nil
+	}
+	return &starSlice{line: line, x: x, lo: parts[0], hi: parts[1], st: parts[2]}, nil
+}
+
+func (p *starParser) operand() (starExpr, error) {
+	t := p.peek()
+	switch t.kind {
+	case 'i':
+		p.next()
+		n, err := strconv.ParseInt(strings.ReplaceAll(t.val, "_", ""), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad number %s", t.line, t.val)
+		}
+		return &starLit{line: t.line, v: int(n)}, nil
+	case 'f':
+		p.next()
+		f, err := strconv.ParseFloat(strings.ReplaceAll(t.val, "_", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad number %s", t.line, t.val)
+		}
+		return &starLit{line: t.line, v: f}, nil
+	case 's':
+		var b strings.Builder
+		for p.peek().kind == 's' { // adjacent literals join
+			b.WriteString(p.next().val)
+		}
+		return &starLit{line: t.line, v: b.String()}, nil
+	case 'n':
+		switch t.val {
+		case "True", "False":
+			p.next()
+			return &starLit{line: t.line, v: t.val == "True"}, nil
+		case "None":
+			p.next()
+			return &starLit{line: t.line}, nil
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return &starIdent{line: t.line, name: name}, nil
+	case 'p':
+		switch t.val {
+		case "(":
+			p.next()
+			if p.isPunct(")") {
+				p.next()
+				return &starTupleExpr{line: t.line}, nil
+			}
+			x, err := p.exprList()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect('p', ")")
+		case "[":
+			p.next()
+			return p.list(t.line)
+		case "{":
+			p.next()
+			return p.dict(t.line)
+		}
+	}
+	return nil, p.unexpected()
+}
+
+func (p *starParser) list(line int) (starExpr, error) {
+	l := &starListExpr{line: line}
+	for !p.isPunct("]") {
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if len(l.elems) == 0 && p.isKeyword("for") {
+			c := &starComp{line: line, val: x}
+			if c.clauses, err = p.clauses("]"); err != nil {
+				return nil, err
+			}
+			return c, p.expect('p', "]")
+		}
+		l.elems = append(l.elems, x)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return l, p.expect('p', "]")
+}
+
+func (p *starParser) dict(line int) (starExpr, error) {
+	d := &starDictExpr{line: line}
+	for !p.isPunct("}") {
+		k, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect('p', ":"); err != nil {
+			return nil, err
+		}
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if len(d.keys) == 0 && p.isKeyword("for") {
+			c := &starComp{line: line, dict: true, key: k, val: v}
+			if c.clauses, err = p.clauses("}"); err != nil {
+				return nil, err
+			}
+			return c, p.expect('p', "}")
+		}
+		d.keys, d.vals = append(d.keys, k), append(d.vals, v)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return d, p.expect('p', "}")
+}
+
+// clauses reads the for and if clauses of a comprehension up to end.
+func (p *starParser) clauses(end string) ([]starClause, error) {
+	var out []starClause
+	for !p.isPunct(end) {
+		switch {
+		case p.isKeyword("for"):
+			p.next()
+			target, err := p.targets()
+			if err != nil {
+				return nil, err
+			}
+			iter, err := p.binary(0)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, starClause{target: target, iter: iter})
+		case p.isKeyword("if"):
+			p.next()
+			cond, err := p.binary(0)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, starClause{cond: cond})
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	return out, nil
+}
+
+// Values: nil (None), bool, int, float64, string, *starList, starTuple,
+// *starDict, starRange, *starFunction and *starBuiltin.
+
+type starList struct{ elems []any }
+
+type starTuple []any
+
+// starDict keeps its keys in insertion order.
+type starDict struct {
+	keys, vals []any
+	index      map[string]int // starHashKey(key) -> position
+}
+
+type starRange struct{ start, stop, step int }
+
+type starFunction struct {
+	lit      *starFuncLit
+	defaults []any
+	closure  *starFrame
+}
+
+type starBuiltin struct {
+	name string
+	fn   func(th *starThread, args []any, kwargs []starKwarg) (any, error)
+}
+
+type starKwarg struct {
+	name string
+	v    any
+}
+
+func newStarDict() *starDict { return &starDict{index: map[string]int{}} }
+
+func (d *starDict) get(k any) (any, bool, error) {
+	h, err := starHashKey(k)
+	if err != nil {
+		return nil, false, err
+	}
+	i, ok := d.index[h]
+	if !ok {
+		return nil, false, nil
+	}
+	return d.vals[i], true, nil
+}
+
+func (d *starDict) set(k, v any) error {
+	h, err := starHashKey(k)
+	if err != nil {
+		return err
+	}
+	if i, ok := d.index[h]; ok {
+		d.vals[i] = v
+		return nil
+	}
+	d.index[h] = len(d.keys)
+	d.keys, d.vals = append(d.keys, k), append(d.vals, v)
+	return nil
+}
+
+func (d *starDict) delete(k any) (any, bool, error) {
+	h, err := starHashKey(k)
+	if err != nil {
+		return nil, false, err
+	}
+	i, ok := d.index[h]
+	if !ok {
+		return nil, false, nil
+	}
+	v := d.vals[i]
+	d.keys, d.vals = slices.Delete(d.keys, i, i+1), slices.Delete(d.vals, i, i+1)
+	delete(d.index, h)
+	for j := i; j < len(d.keys); j++ {
+		h, _ := starHashKey(d.keys[j])
+		d.index[h] = j
+	}
+	return v, true, nil
+}
+
+// starHashKey encodes a dict key so equal keys encode alike.
+func starHashKey(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "N", nil
+	case bool:
+		if v {
+			return "T", nil
+		}
+		return "F", nil
+	case int:
+		return "i" + strconv.Itoa(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<62 {
+			return "i" + strconv.Itoa(int(v)), nil
+		}
+		return "f" + strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		return "s" + strconv.Itoa(len(v)) + ":" + v, nil
+	case starTuple:
+		var b strings.Builder
+		b.WriteString("t" + strconv.Itoa(len(v)) + "(")
+		for _, e := range v {
+			h, err := starHashKey(e)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(strconv.Itoa(len(h)) + ":" + h)
+		}
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("unhashable type: %s", starType(v))
+}
+
+func starType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "NoneType"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case *starList:
+		return "list"
+	case starTuple:
+		return "tuple"
+	case *starDict:
+		return "dict"
+	case starRange:
+		return "range"
+	case *starFunction:
+		return "function"
+	case *starBuiltin:
+		return "builtin_function_or_method"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func starTruth(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case *starList:
+		return len(v.elems) > 0
+	case starTuple:
+		return len(v) > 0
+	case *starDict:
+		return len(v.keys) > 0
+	case starRange:
+		return v.len() > 0
+	}
+	return true
+}
+
+// starStr is str(v): strings as they are, anything else as starRepr.
+func starStr(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return starRepr(v)
+}
+
+func starRepr(v any) string {
+	var b strings.Builder
+	starWriteRepr(&b, v, 0)
+	return b.String()
+}
+
+func starWriteRepr(b *strings.Builder, v any, depth int) {
+	if depth > 64 {
+		b.WriteString("...") // a list containing itself
+		return
+	}
+	seq := func(open, close string, elems []any) {
+		b.WriteString(open)
+		for i, e := range elems {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			starWriteRepr(b, e, depth+1)
+		}
+		if len(elems) == 1 && open == "(" {
+			b.WriteString(",")
+		}
+		b.WriteString(close)
+	}
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("None")
+	case bool:
+		if v {
+			b.WriteString("True")
+		} else {
+			b.WriteString("False")
+		}
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case float64:
+		b.WriteString(starFormatFloat(v))
+	case string:
+		b.WriteString(strconv.Quote(v))
+	case *starList:
+		seq("[", "]", v.elems)
+	case starTuple:
+		seq("(", ")", v)
+	case *starDict:
+		b.WriteString("{")
+		for i, k := range v.keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			starWriteRepr(b, k, depth+1)
+			b.WriteString(": ")
+			starWriteRepr(b, v.vals[i], depth+1)
+		}
+		b.WriteString("}")
+	case starRange:
+		if v.step == 1 {
+			fmt.Fprintf(b, "range(%d, %d)", v.start, v.stop)
+		} else {
+			fmt.Fprintf(b, "range(%d, %d, %d)", v.start, v.stop, v.step)
+		}
+	case *starFunction:
+		fmt.Fprintf(b, "<function %s>", v.lit.name)
+	case *starBuiltin:
+		fmt.Fprintf(b, "<built-in function %s>", v.name)
+	default:
+		fmt.Fprint(b, v)
+	}
+}
+
+func starFormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func (r starRange) len() int {
+	switch {
+	case r.step > 0 && r.start < r.stop:
+		return (r.stop - r.start + r.step - 1) / r.step
+	case r.step < 0 && r.start > r.stop:
+		return (r.start - r.stop - r.step - 1) / -r.step
+	}
+	return 0
+}
+
+func starEqual(x, y any) bool {
+	switch x := x.(type) {
+	case int:
+		switch y := y.(type) {
+		case int:
+			return x == y
+		case float64:
+			return float64(x) == y
+		}
+	case float64:
+		switch y := y.(type) {
+		case int:
+			return x == float64(y)
+		case float64:
+			return x == y
+		}
+	case *starList:
+		if y, ok := y.(*starList); ok {
+			return slices.EqualFunc(x.elems, y.elems, starEqual)
+		}
+	case starTuple:
+		if y, ok := y.(starTuple); ok {
+			return slices.EqualFunc(x, y, starEqual)
+		}
+	case *starDict:
+		y, ok := y.(*starDict)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false
+		}
+		for i, k := range x.keys {
+			if v, ok, _ := y.get(k); !ok || !starEqual(x.vals[i], v) {
+				return false
+			}
+		}
+		return true
+	case starRange:
+		if y, ok := y.(starRange); ok {
+			return slices.EqualFunc(x.items(), y.items(), starEqual)
+		}
+	case *starFunction, *starBuiltin:
+		return x == y
+	case nil, bool, string:
+		return x == y
+	}
+	return false
+}
+
+func (r starRange) items() []any {
+	out := make([]any, r.len())
+	for i := range out {
+		out[i] = r.start + i*r.step
+	}
+	return out
+}
+
+// starCompare orders numbers, strings, and lists or tuples of those.
+func starCompare(x, y any) (int, error) {
+	switch x := x.(type) {
+	case int:
+		switch y := y.(type) {
+		case int:
+			return cmpInt(x, y), nil
+		case float64:
+			return cmpFloat(float64(x), y), nil
+		}
+	case float64:
+		switch y := y.(type) {
+		case int:
+			return cmpFloat(x, float64(y)), nil
+		case float64:
+			return cmpFloat(x, y), nil
+		}
+	case string:
+		if y, ok := y.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case *starList:
+		if y, ok := y.(*starList); ok {
+			return starCompareSeq(x.elems, y.elems)
+		}
+	case starTuple:
+		if y, ok := y.(starTuple); ok {
+			return starCompareSeq(x, y)
+		}
+	}
+	return 0, fmt.Errorf("can't compare %s with %s", starType(x), starType(y))
+}
+
+func cmpInt(x, y int) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func cmpFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func starCompareSeq(x, y []any) (int, error) {
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if starEqual(x[i], y[i]) {
+			continue
+		}
+		return starCompare(x[i], y[i])
+	}
+	return cmpInt(len(x), len(y)), nil
+}
+
+// starIter returns a function yielding the elements of an iterable: a
+// list or tuple as it was when the loop began, the keys of a dict, or a
+// range.
+func starIter(v any) (func() (any, bool), error) {
+	var elems []any
+	switch v := v.(type) {
+	case *starList:
+		elems = slices.Clone(v.elems)
+	case starTuple:
+		elems = v
+	case *starDict:
+		elems = slices.Clone(v.keys)
+	case starRange:
+		i, n := 0, v.len()
+		return func() (any, bool) {
+			if i == n {
+				return nil, false
+			}
+			i++
+			return v.start + (i-1)*v.step, true
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s is not iterable", starType(v))
+	}
+	i := 0
+	return func() (any, bool) {
+		if i == len(elems) {
+			return nil, false
+		}
+		i++
+		return elems[i-1], true
+	}, nil
+}
+
+func starElemsOf(v any) ([]any, error) {
+	next, err := starIter(v)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for e, ok := next(); ok; e, ok = next() {
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func starLen(v any) (int, error) {
+	switch v := v.(type) {
+	case string:
+		return len(v), nil
+	case *starList:
+		return len(v.elems), nil
+	case starTuple:
+		return len(v), nil
+	case *starDict:
+		return len(v.keys), nil
+	case starRange:
+		return v.len(), nil
+	}
+	return 0, fmt.Errorf("%s has no len()", starType(v))
+}
+
+// Execution.
+
+// starThread runs a program. Every statement and call checks ctx now and
+// then, so -timeout stops a runaway script.
+type starThread struct {
+	ctx   context.Context
+	file  string
+	print io.Writer
+	steps int
+	stack []*starFuncLit
+}
+
+// starFrame holds the variables of the module, a function call or a
+// comprehension; names not found are looked up in parent and then among
+// the builtins.
+type starFrame struct {
+	vars   map[string]any
+	parent *starFrame
+}
+
+func (f *starFrame) lookup(name string) (any, bool) {
+	for ; f != nil; f = f.parent {
+		if v, ok := f.vars[name]; ok {
+			return v, true
+		}
+	}
+	v, ok := starBuiltins[name]
+	return v, ok
+}
+
+// starError is an error at a line of the script.
+type starError struct {
+	file string
+	line int
+	msg  string
+}
+
+func (e *starError) Error() string { return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg) }
+
+// at places err, if it isn't already, at line.
+func (th *starThread) at(line int, err error) error {
+	var se *starError
+	if err == nil || errors.As(err, &se) || errors.Is(err, context.Cause(th.ctx)) && th.ctx.Err() != nil {
+		return err
+	}
+	return &starError{file: th.file, line: line, msg: err.Error()}
+}
+
+func (th *starThread) tick() error {
+	th.steps++
+	if th.steps%1024 == 0 && th.ctx.Err() != nil {
+		return context.Cause(th.ctx)
+	}
+	return nil
+}
+
+// run executes prog, returning its global variables.
+func (th *starThread) run(prog *starProgram) (map[string]any, error) {
+	th.file = prog.file
+	globals := &starFrame{vars: map[string]any{}}
+	flow, _, err := th.exec(globals, prog.body)
+	if err != nil {
+		return nil, err
+	}
+	if flow != starNext {
+		return nil, errors.New("break, continue or return outside a function") // caught by the parser
+	}
+	return globals.vars, nil
+}
+
+type starFlow int
+
+const (
+	starNext starFlow = iota
+	starBreakFlow
+	starContinueFlow
+	starReturnFlow
+)
+
+func (th *starThread) exec(fr *starFrame, body []starStmt) (starFlow, any, error) {
+	for _, s := range body {
+		if err := th.tick(); err != nil {
+			return 0, nil, err
+		}
+		switch s := s.(type) {
+		case *starExprStmt:
+			if _, err := th.eval(fr, s.x); err != nil {
+				return 0, nil, err
+			}
+		case *starAssign:
+			if err := th.assign(fr, s); err != nil {
+				return 0, nil, th.at(s.line, err)
+			}
+		case *starIf:
+			cond, err := th.eval(fr, s.cond)
+			if err != nil {
+				return 0, nil, err
+			}
+			branch := s.els_
+			if starTruth(cond) {
+				branch = s.then
+			}
+			if flow, v, err := th.exec(fr, branch); err != nil || flow != starNext {
+				return flow, v, err
+			}
+		case *starFor:
+			iter, err := th.eval(fr, s.iter)
+			if err != nil {
+				return 0, nil, err
+			}
+			next, err := starIter(iter)
+			if err != nil {
+				return 0, nil, th.at(s.line, err)
+			}
+		loop:
+			for e, ok := next(); ok; e, ok = next() {
+				if err := th.bind(fr, s.target, e); err != nil {
+					return 0, nil, th.at(s.line, err)
+				}
+				flow, v, err := th.exec(fr, s.body)
+				switch {
+				case err != nil:
+					return 0, nil, err
+				case flow == starBreakFlow:
+					break loop
+				case flow == starReturnFlow:
+					return flow, v, nil
+				}
+				if err := th.tick(); err != nil {
+					return 0, nil, err
+				}
+			}
+		case *starDef:
+			fn, err := th.function(fr, s.fn)
+			if err != nil {
+				return 0, nil, err
+			}
+			fr.vars[s.fn.name] = fn
+		case *starReturn:
+			var v any
+			if s.x != nil {
+				var err error
+				if v, err = th.eval(fr, s.x); err != nil {
+					return 0, nil, err
+				}
+			}
+			return starReturnFlow, v, nil
+		case *starBranch:
+			switch s.kw {
+			case "break":
+				return starBreakFlow, nil, nil
+			case "continue":
+				return starContinueFlow, nil, nil
+			}
+		}
+	}
+	return starNext, nil, nil
+}
+
+func (th *starThread) function(fr *starFrame, lit *starFuncLit) (*starFunction, error) {
+	fn := &starFunction{lit: lit, closure: fr}
+	for _, d := range lit.defaults {
+		v, err := th.eval(fr, d)
+		if err != nil {
+			return nil, err
+		}
+		fn.defaults = append(fn.defaults, v)
+	}
+	return fn, nil
+}
+
+func (th *starThread) assign(fr *starFrame, s *starAssign) error {
+	if s.op == "" {
+		v, err := th.eval(fr, s.value)
+		if err != nil {
+			return err
+		}
+		return th.bind(fr, s.target, v)
+	}
+	// x op= y evaluates the container and index of x once.
+	var get func() (any, error)
+	var set func(any) error
+	switch t := s.target.(type) {
+	case *starIdent:
+		get = func() (any, error) {
+			if v, ok := fr.lookup(t.name); ok {
+				return v, nil
+			}
+			return nil, fmt.Errorf("undefined name %s", t.name)
+		}
+		set = func(v any) error { fr.vars[t.name] = v; return nil }
+	case *starIndex:
+		x, err := th.eval(fr, t.x)
+		if err != nil {
+			return err
+		}
+		i, err := th.eval(fr, t.i)
+		if err != nil {
+			return err
+		}
+		get = func() (any, error) { return starGetIndex(x, i) }
+		set = func(v any) error { return starSetIndex(x, i, v) }
+	}
+	old, err := get()
+	if err != nil {
+		return err
+	}
+	y, err := th.eval(fr, s.value)
+	if err != nil {
+		return err
+	}
+	if l, ok := old.(*starList); ok && s.op == "+" {
+		if m, ok := y.(*starList); ok { // extends the list in place
+			l.elems = append(l.elems, m.elems...)
+			return set(l)
+		}
+	}
+	v, err := starBinaryOp(s.op, old, y)
+	if err != nil {
+		return err
+	}
+	return set(v)
+}
+
+// bind assigns v to the target of an assignment or for loop.
+func (th *starThread) bind(fr *starFrame, target starExpr, v any) error {
+	switch t := target.(type) {
+	case *starIdent:
+		fr.vars[t.name] = v
+		return nil
+	case *starIndex:
+		x, err := th.eval(fr, t.x)
+		if err != nil {
+			return err
+		}
+		i, err := th.eval(fr, t.i)
+		if err != nil {
+			return err
+		}
+		return starSetIndex(x, i, v)
+	case *starTupleExpr, *starListExpr:
+		targets := starElems(t)
+		vals, err := starElemsOf(v)
+		if err != nil {
+			return fmt.Errorf("can't unpack %s", starType(v))
+		}
+		if len(vals) != len(targets) {
+			return fmt.Errorf("can't unpack %d values into %d variables", len(vals), len(targets))
+		}
+		for i, e := range targets {
+			if err := th.bind(fr, e, vals[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.New("can't assign to this expression")
+}
+
+func (th *starThread) eval(fr *starFrame, x starExpr) (any, error) {
+	switch x := x.(type) {
+	case *starLit:
+		return x.v, nil
+	case *starIdent:
+		if v, ok := fr.lookup(x.name); ok {
+			return v, nil
+		}
+		return nil, th.at(x.line, fmt.Errorf("undefined name %s", x.name))
+	case *starListExpr:
+		elems, err := th.evalAll(fr, x.elems)
+		return &starList{elems: elems}, err
+	case *starTupleExpr:
+		elems, err := th.evalAll(fr, x.elems)
+		return starTuple(elems), err
+	case *starDictExpr:
+		d := newStarDict()
+		for i, kx := range x.keys {
+			k, err := th.eval(fr, kx)
+			if err != nil {
+				return nil, err
+			}
+			v, err := th.eval(fr, x.vals[i])
+			if err != nil {
+				return nil, err
+			}
+			if _, dup, _ := d.get(k); dup {
+				return nil, th.at(x.line, fmt.Errorf("duplicate key %s", starRepr(k)))
+			}
+			if err := d.set(k, v); err != nil {
+				return nil, th.at(x.line, err)
+			}
+		}
+		return d, nil
+	case *starComp:
+		return th.comprehension(fr, x)
+	case *starIndex:
+		v, err := th.eval(fr, x.x)
+		if err != nil {
+			return nil, err
+		}
+		i, err := th.eval(fr, x.i)
+		if err != nil {
+			return nil, err
+		}
+		v, err = starGetIndex(v, i)
+		return v, th.at(x.line, err)
+	case *starSlice:
+		v, err := th.eval(fr, x.x)
+		if err != nil {
+			return nil, err
+		}
+		var bounds [3]any
+		for i, b := range []starExpr{x.lo, x.hi, x.st} {
+			if b != nil {
+				if bounds[i], err = th.eval(fr, b); err != nil {
+					return nil, err
+				}
+			}
+		}
+		v, err = starSliceOf(v, bounds[0], bounds[1], bounds[2])
+		return v, th.at(x.line, err)
+	case *starDot:
+		v, err := th.eval(fr, x.x)
+		if err != nil {
+			return nil, err
+		}
+		m, err := starAttr(v, x.name)
+		return m, th.at(x.line, err)
+	case *starCall:
+		fn, err := th.eval(fr, x.fn)
+		if err != nil {
+			return nil, err
+		}
+		args, err := th.evalAll(fr, x.args)
+		if err != nil {
+			return nil, err
+		}
+		var kwargs []starKwarg
+		for _, kw := range x.kwargs {
+			v, err := th.eval(fr, kw.x)
+			if err != nil {
+				return nil, err
+			}
+			kwargs = append(kwargs, starKwarg{kw.name, v})
+		}
+		v, err := th.call(fn, args, kwargs)
+		return v, th.at(x.line, err)
+	case *starUnary:
+		v, err := th.eval(fr, x.x)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "not":
+			return !starTruth(v), nil
+		case "-":
+			switch v := v.(type) {
+			case int:
+				return -v, nil
+			case float64:
+				return -v, nil
+			}
+		case "+":
+			switch v.(type) {
+			case int, float64:
+				return v, nil
+			}
+		}
+		return nil, th.at(x.line, fmt.Errorf("bad operand type for unary %s: %s", x.op, starType(v)))
+	case *starBinary:
+		l, err := th.eval(fr, x.x)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "and":
+			if !starTruth(l) {
+				return l, nil
+			}
+			return th.eval(fr, x.y)
+		case "or":
+			if starTruth(l) {
+				return l, nil
+			}
+			return th.eval(fr, x.y)
+		}
+		r, err := th.eval(fr, x.y)
+		if err != nil {
+			return nil, err
+		}
+		v, err := starBinaryOp(x.op, l, r)
+		return v, th.at(x.line, err)
+	case *starCond:
+		cond, err := th.eval(fr, x.cond)
+		if err != nil {
+			return nil, err
+		}
+		if starTruth(cond) {
+			return th.eval(fr, x.then)
+		}
+		return th.eval(fr, x.els)
+	case *starFuncLit:
+		return th.function(fr, x)
+	}
+	return nil, fmt.Errorf("unknown expression %T", x)
+}
+
+func (th *starThread) evalAll(fr *starFrame, xs []starExpr) ([]any, error) {
+	out := make([]any, 0, len(xs))
+	for _, x := range xs {
+		v, err := th.eval(fr, x)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// comprehension evaluates x in a frame of its own, so its loop variables
+// don't leak.
+func (th *starThread) comprehension(fr *starFrame, x *starComp) (any, error) {
+	inner := &starFrame{vars: map[string]any{}, parent: fr}
+	list, dict := &starList{}, newStarDict()
+	var loop func(clauses []starClause) error
+	loop = func(clauses []starClause) error {
+		if len(clauses) == 0 {
+			if err := th.tick(); err != nil {
+				return err
+			}
+			v, err := th.eval(inner, x.val)
+			if err != nil {
+				return err
+			}
+			if !x.dict {
+				list.elems = append(list.elems, v)
+				return nil
+			}
+			k, err := th.eval(inner, x.key)
+			if err != nil {
+				return err
+			}
+			return th.at(x.line, dict.set(k, v))
+		}
+		c := clauses[0]
+		if c.cond != nil {
+			cond, err := th.eval(inner, c.cond)
+			if err != nil || !starTruth(cond) {
+				return err
+			}
+			return loop(clauses[1:])
+		}
+		iter, err := th.eval(inner, c.iter)
+		if err != nil {
+			return err
+		}
+		next, err := starIter(iter)
+		if err != nil {
+			return th.at(x.line, err)
+		}
+		for e, ok := next(); ok; e, ok = next() {
+			if err := th.bind(inner, c.target, e); err != nil {
+				return th.at(x.line, err)
+			}
+			if err := loop(clauses[1:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := loop(x.clauses); err != nil {
+		return nil, err
+	}
+	if x.dict {
+		return dict, nil
+	}
+	return list, nil
+}
+
+// call calls a function or builtin.
+func (th *starThread) call(fn any, args []any, kwargs []starKwarg) (any, error) {
+	if err := th.tick(); err != nil {
+		return nil, err
+	}
+	switch fn := fn.(type) {
+	case *starBuiltin:
+		return fn.fn(th, args, kwargs)
+	case *starFunction:
+		lit := fn.lit
+		if slices.Contains(th.stack, lit) {
+			return nil, fmt.Errorf("function %s called recursively", lit.name)
+		}
+		if len(args) > len(lit.params) {
+			return nil, fmt.Errorf("%s() takes %d arguments, got %d", lit.name, len(lit.params), len(args))
+		}
+		locals := make(map[string]any, len(lit.params))
+		for i, a := range args {
+			locals[lit.params[i]] = a
+		}
+		for _, kw := range kwargs {
+			i := slices.Index(lit.params, kw.name)
+			switch {
+			case i < 0:
+				return nil, fmt.Errorf("%s() has no parameter %s", lit.name, kw.name)
+			case i < len(args):
+				return nil, fmt.Errorf("%s() got two values for %s", lit.name, kw.name)
+			}
+			locals[kw.name] = kw.v
+		}
+		for i, name := range lit.params {
+			if _, ok := locals[name]; ok {
+				continue
+			}
+			if d := i - (len(lit.params) - len(fn.defaults)); d >= 0 {
+				locals[name] = fn.defaults[d]
+				continue
+			}
+			return nil, fmt.Errorf("%s() missing argument %s", lit.name, name)
+		}
+		th.stack = append(th.stack, lit)
+		_, v, err := th.exec(&starFrame{vars: locals, parent: fn.closure}, lit.body)
+		th.stack = th.stack[:len(th.stack)-1]
+		return v, err
+	}
+	return nil, fmt.Errorf("%s is not callable", starType(fn))
+}
+
+func starIndexOf(i any, n int) (int, error) {
+	k, ok := i.(int)
+	if !ok {
+		return 0, fmt.Errorf("index must be an int, not %s", starType(i))
+	}
+	if k < 0 {
+		k += n
+	}
+	if k < 0 || k >= n {
+		return 0, fmt.Errorf("index %s out of range for length %d", starRepr(i), n)
+	}
+	return k, nil
+}
+
+func starGetIndex(x, i any) (any, error) {
+	switch x := x.(type) {
+	case *starList:
+		k, err := starIndexOf(i, len(x.elems))
+		if err != nil {
+			return nil, err
+		}
+		return x.elems[k], nil
+	case starTuple:
+		k, err := starIndexOf(i, len(x))
+		if err != nil {
+			return nil, err
+		}
+		return x[k], nil
+	case string:
+		k, err := starIndexOf(i, len(x))
+		if err != nil {
+			return nil, err
+		}
+		return x[k : k+1], nil
+	case starRange:
+		k, err := starIndexOf(i, x.len())
+		if err != nil {
+			return nil, err
+		}
+		return x.start + k*x.step, nil
+	case *starDict:
+		v, ok, err := x.get(i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("key %s not in dict", starRepr(i))
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s can't be indexed", starType(x))
+}
+
+func starSetIndex(x, i, v any) error {
+	switch x := x.(type) {
+	case *starList:
+		k, err := starIndexOf(i, len(x.elems))
+		if err != nil {
+			return err
+		}
+		x.elems[k] = v
+		return nil
+	case *starDict:
+		return x.set(i, v)
+	}
+	return fmt.Errorf("%s doesn't support item assignment", starType(x))
+}
+
+// starSliceOf is x[lo:hi:step] for a list, tuple or string.
+func starSliceOf(x, lo, hi, step any) (any, error) {
+	n, err := starLen(x)
+	if _, isDict := x.(*starDict); err != nil || isDict {
+		return nil, fmt.Errorf("%s can't be sliced", starType(x))
+	}
+	st := 1
+	if step != nil {
+		var ok bool
+		if st, ok = step.(int); !ok || st == 0 {
+			return nil, errors.New("slice step must be a non-zero int")
+		}
+	}
+	bound := func(b any, def int) (int, error) {
+		if b == nil {
+			return def, nil
+		}
+		k, ok := b.(int)
+		if !ok {
+			return 0, fmt.Errorf("slice index must be an int, not %s", starType(b))
+		}
+		if k < 0 {
+			k += n
+		}
+		if st > 0 {
+			return min(max(k, 0), n), nil
+		}
+		return min(max(k, -1), n-1), nil
+	}
+	start, stop := 0, n
+	if st < 0 {
+		start, stop = n-1, -1
+	}
+	if start, err = bound(lo, start); err != nil {
+		return nil, err
+	}
+	if stop, err = bound(hi, stop); err != nil {
+		return nil, err
+	}
+	var idx []int
+	for k := start; st > 0 && k < stop || st < 0 && k > stop; k += st {
+		idx = append(idx, k)
+	}
+	switch x := x.(type) {
+	case string:
+		var b strings.Builder
+		for _, k := range idx {
+			b.WriteByte(x[k])
+		}
+		return b.String(), nil
+	case *starList:
+		out := &starList{elems: []any{}}
+		for _, k := range idx {
+			out.elems = append(out.elems, x.elems[k])
+		}
+		return out, nil
+	case starTuple:
+		out := starTuple{}
+		for _, k := range idx {
+			out = append(out, x[k])
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%s can't be sliced", starType(x))
+}
+
+func starBinaryOp(op string, x, y any) (any, error) {
+	switch op {
+	case "==":
+		return starEqual(x, y), nil
+	case "!=":
+		return !starEqual(x, y), nil
+	case "<", ">", "<=", ">=":
+		c, err := starCompare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "<":
+			return c < 0, nil
+		case ">":
+			return c > 0, nil
+		case "<=":
+			return c <= 0, nil
+		}
+		return c >= 0, nil
+	case "in", "not in":
+		in, err := starContains(y, x)
+		return in == (op == "in"), err
+	}
+	if xi, ok := x.(int); ok {
+		if yi, ok := y.(int); ok {
+			switch op {
+			case "+":
+				return xi + yi, nil
+			case "-":
+				return xi - yi, nil
+			case "*":
+				return xi * yi, nil
+			case "/":
+				if yi == 0 {
+					return nil, errors.New("division by zero")
+				}
+				return float64(xi) / float64(yi), nil
+			case "//", "%":
+				if yi == 0 {
+					return nil, errors.New("division by zero")
+				}
+				q, r := xi/yi, xi%yi
+				if r != 0 && (r < 0) != (yi < 0) { // floor, as Python does
+					q, r = q-1, r+yi
+				}
+				if op == "//" {
+					return q, nil
+				}
+				return r, nil
+			}
+		}
+	}
+	xf, xnum := starFloat(x)
+	yf, ynum := starFloat(y)
+	if xnum && ynum {
+		switch op {
+		case "+":
+			return xf + yf, nil
+		case "-":
+			return xf - yf, nil
+		case "*":
+			return xf * yf, nil
+		case "/", "//", "%":
+			if yf == 0 {
+				return nil, errors.New("division by zero")
+			}
+			switch op {
+			case "/":
+				return xf / yf, nil
+			case "//":
+				return math.Floor(xf / yf), nil
+			}
+			r := math.Mod(xf, yf)
+			if r != 0 && (r < 0) != (yf < 0) {
+				r += yf
+			}
+			return r, nil
+		}
+	}
+	switch x := x.(type) {
+	case string:
+		switch y := y.(type) {
+		case string:
+			if op == "+" {
+				return x + y, nil
+			}
+		case int:
+			if op == "*" {
+				return strings.Repeat(x, max(y, 0)), nil
+			}
+		}
+		if op == "%" {
+			return starPercent(x, y)
+		}
+	case *starList:
+		switch y := y.(type) {
+		case *starList:
+			if op == "+" {
+				return &starList{elems: slices.Concat(x.elems, y.elems)}, nil
+			}
+		case int:
+			if op == "*" {
+				return &starList{elems: slices.Repeat(slices.Clip(x.elems), max(y, 0))}, nil
+			}
+		}
+	case starTuple:
+		switch y := y.(type) {
+		case starTuple:
+			if op == "+" {
+				return starTuple(slices.Concat(x, y)), nil
+			}
+		case int:
+			if op == "*" {
+				return starTuple(slices.Repeat(slices.Clip([]any(x)), max(y, 0))), nil
+			}
+		}
+	case int:
+		switch y.(type) {
+		case string, *starList, starTuple:
+			if op == "*" {
+				return starBinaryOp(op, y, x)
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported operand types for %s: %s and %s", op, starType(x), starType(y))
+}
+
+func starFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// starContains is "x in container".
+func starContains(container, x any) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := x.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' needs a string, not %s", starType(x))
+		}
+		return strings.Contains(c, s), nil
+	case *starList:
+		return slices.ContainsFunc(c.elems, func(e any) bool { return starEqual(e, x) }), nil
+	case starTuple:
+		return slices.ContainsFunc(c, func(e any) bool { return starEqual(e, x) }), nil
+	case *starDict:
+		_, ok, err := c.get(x)
+		return ok, err
+	case starRange:
+		k, ok := x.(int)
+		if !ok {
+			return false, nil
+		}
+		n := c.len()
+		return n > 0 && (k-c.start)%c.step == 0 && (k-c.start)/c.step >= 0 && (k-c.start)/c.step < n, nil
+	}
+	return false, fmt.Errorf("'in' needs a string, list, tuple, dict or range, not %s", starType(container))
+}
+
+// starPercent is format % args with %s, %r, %d and %%.
+func starPercent(format string, args any) (any, error) {
+	vals := []any{args}
+	if t, ok := args.(starTuple); ok {
+		vals = t
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			return nil, errors.New("incomplete format")
+		}
+		if format[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if n == len(vals) {
+			return nil, errors.New("not enough arguments for format string")
+		}
+		v := vals[n]
+		n++
+		switch format[i] {
+		case 's':
+			b.WriteString(starStr(v))
+		case 'r':
+			b.WriteString(starRepr(v))
+		case 'd':
+			switch v := v.(type) {
+			case int:
+				b.WriteString(strconv.Itoa(v))
+			case float64:
+				b.WriteString(strconv.Itoa(int(v)))
+			default:
+				return nil, fmt.Errorf("%%d format needs a number, not %s", starType(v))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported format character %q", format[i])
+		}
+	}
+	if n < len(vals) {
+		return nil, errors.New("not all arguments converted during string formatting")
+	}
+	return b.String(), nil
+}
+
+// Builtins and methods.
+
+// starNoValue stands for an optional argument that was not passed.
+type starNoValue struct{}
+
+// starArgs binds the arguments of a builtin to names, the first required
+// of them mandatory; optional ones not passed are starNoValue{}.
+func starArgs(fn string, args []any, kwargs []starKwarg, required int, names ...string) ([]any, error) {
+	if len(args) > len(names) {
+		return nil, fmt.Errorf("%s() takes at most %d arguments, got %d", fn, len(names), len(args))
+	}
+	out := make([]any, len(names))
+	for i := range out {
+		out[i] = starNoValue{}
+	}
+	copy(out, args)
+	for _, kw := range kwargs {
+		i := slices.Index(names, kw.name)
+		if i < 0 {
+			return nil, fmt.Errorf("%s() has no parameter %s", fn, kw.name)
+		}
+		if i < len(args) {
+			return nil, fmt.Errorf("%s() got two values for %s", fn, kw.name)
+		}
+		out[i] = kw.v
+	}
+	for i := range required {
+		if out[i] == (starNoValue{}) {
+			return nil, fmt.Errorf("%s() missing argument %s", fn, names[i])
+		}
+	}
+	return out, nil
+}
+
+func given(v any) bool { return v != starNoValue{} }
+
+func starStringArg(fn string, v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s() wants a string, not %s", fn, starType(v))
+	}
+	return s, nil
+}
+
+func starIntArg(fn string, v any) (int, error) {
+	n, ok := v.(int)
+	if !ok {
+		return 0, fmt.Errorf("%s() wants an int, not %s", fn, starType(v))
+	}
+	return n, nil
+}
+
+var starBuiltins map[string]any
+
+func init() {
+	b := func(name string, fn func(th *starThread, args []any, kwargs []starKwarg) (any, error)) {
+		starBuiltins[name] = &starBuiltin{name: name, fn: fn}
+	}
+	starBuiltins = map[string]any{}
+	b("len", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("len", args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		return starLen(a[0])
+	})
+	b("str", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("str", args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		return starStr(a[0]), nil
+	})
+	b("repr", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("repr", args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		return starRepr(a[0]), nil
+	})
+	b("bool", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("bool", args, kwargs, 0, "x")
+		if err != nil {
+			return nil, err
+		}
+		return given(a[0]) && starTruth(a[0]), nil
+	})
+	b("int", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("int", args, kwargs, 1, "x", "base")
+		if err != nil {
+			return nil, err
+		}
+		switch x := a[0].(type) {
+		case bool:
+			if x {
+				return 1, nil
+			}
+			return 0, nil
+		case int:
+			return x, nil
+		case float64:
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return nil, fmt.Errorf("int() can't convert %s", starFormatFloat(x))
+			}
+			return int(x), nil
+		case string:
+			base := 10
+			if given(a[1]) {
+				if base, err = starIntArg("int", a[1]); err != nil {
+					return nil, err
+				}
+			}
+			n, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(x), "_", ""), base, 64)
+			if err != nil {
+				return nil, fmt.Errorf("int() can't parse %s", starRepr(x))
+			}
+			return int(n), nil
+		}
+		return nil, fmt.Errorf("int() can't convert %s", starType(a[0]))
+	})
+	b("float", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("float", args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		switch x := a[0].(type) {
+		case bool:
+			if x {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case int:
+			return float64(x), nil
+		case float64:
+			return x, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, fmt.Errorf("float() can't parse %s", starRepr(x))
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("float() can't convert %s", starType(a[0]))
+	})
+	b("list", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("list", args, kwargs, 0, "x")
+		if err != nil || !given(a[0]) {
+			return &starList{elems: []any{}}, err
+		}
+		elems, err := starElemsOf(a[0])
+		return &starList{elems: append([]any{}, elems...)}, err
+	})
+	b("tuple", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("tuple", args, kwargs, 0, "x")
+		if err != nil || !given(a[0]) {
+			return starTuple{}, err
+		}
+		elems, err := starElemsOf(a[0])
+		return starTuple(elems), err
+	})
+	b("dict", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		if len(args) > 1 {
+			return nil, fmt.Errorf("dict() takes at most 1 positional argument, got %d", len(args))
+		}
+		d := newStarDict()
+		if len(args) == 1 {
+			if err := starUpdate(d, args[0]); err != nil {
+				return nil, err
+			}
+		}
+		for _, kw := range kwargs {
+			d.set(kw.name, kw.v)
+		}
+		return d, nil
+	})
+	b("range", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("range", args, kwargs, 1, "start", "stop", "step")
+		if err != nil {
+			return nil, err
+		}
+		r := starRange{step: 1}
+		ints := make([]int, 0, 3)
+		for _, v := range a {
+			if given(v) {
+				n, err := starIntArg("range", v)
+				if err != nil {
+					return nil, err
+				}
+				ints = append(ints, n)
+			}
+		}
+		switch len(ints) {
+		case 1:
+			r.stop = ints[0]
+		case 2:
+			r.start, r.stop = ints[0], ints[1]
+		case 3:
+			r.start, r.stop, r.step = ints[0], ints[1], ints[2]
+		}
+		if r.step == 0 {
+			return nil, errors.New("range() step must not be zero")
+		}
+		return r, nil
+	})
+	b("enumerate", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("enumerate", args, kwargs, 1, "x", "start")
+		if err != nil {
+			return nil, err
+		}
+		start := 0
+		if given(a[1]) {
+			if start, err = starIntArg("enumerate", a[1]); err != nil {
+				return nil, err
+			}
+		}
+		elems, err := starElemsOf(a[0])
+		if err != nil {
+			return nil, err
+		}
+		out := &starList{elems: []any{}}
+		for i, e := range elems {
+			out.elems = append(out.elems, starTuple{start + i, e})
+		}
+		return out, nil
+	})
+	b("zip", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		if len(kwargs) > 0 {
+			return nil, errors.New("zip() takes no keyword arguments")
+		}
+		var cols [][]any
+		n := -1
+		for _, a := range args {
+			elems, err := starElemsOf(a)
+			if err != nil {
+				return nil, err
+			}
+			cols = append(cols, elems)
+			if n < 0 || len(elems) < n {
+				n = len(elems)
+			}
+		}
+		out := &starList{elems: []any{}}
+		for i := 0; i < n; i++ {
+			row := make(starTuple, len(cols))
+			for j, c := range cols {
+				row[j] = c[i]
+			}
+			out.elems = append(out.elems, row)
+		}
+		return out, nil
+	})
+	b("sorted", func(th *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("sorted", args, kwargs, 1, "x", "key", "reverse")
+		if err != nil {
+			return nil, err
+		}
+		elems, err := starElemsOf(a[0])
+		if err != nil {
+			return nil, err
+		}
+		keys := slices.Clone(elems)
+		if given(a[1]) && a[1] != nil {
+			for i, e := range elems {
+				if keys[i], err = th.call(a[1], []any{e}, nil); err != nil {
+					return nil, err
+				}
+			}
+		}
+		order := make([]int, len(elems))
+		for i := range order {
+			order[i] = i
+		}
+		reverse := given(a[2]) && starTruth(a[2])
+		sort.SliceStable(order, func(i, j int) bool {
+			c, cerr := starCompare(keys[order[i]], keys[order[j]])
+			if cerr != nil && err == nil {
+				err = cerr
+			}
+			if reverse {
+				return c > 0
+			}
+			return c < 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		out := &starList{elems: make([]any, len(elems))}
+		for i, k := range order {
+			out.elems[i] = elems[k]
+		}
+		return out, nil
+	})
+	b("reversed", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("reversed", args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		elems, err := starElemsOf(a[0])
+		if err != nil {
+			return nil, err
+		}
+		slices.Reverse(elems)
+		return &starList{elems: append([]any{}, elems...)}, nil
+	})
+	minMax := func(name string, want int) {
+		b(name, func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+			if len(kwargs) > 0 {
+				return nil, fmt.Errorf("%s() takes no keyword arguments", name)
+			}
+			elems := args
+			if len(args) == 1 {
+				var err error
+				if elems, err = starElemsOf(args[0]); err != nil {
+					return nil, err
+				}
+			}
+			if len(elems) == 0 {
+				return nil, fmt.Errorf("%s() of an empty sequence", name)
+			}
+			best := elems[0]
+			for _, e := range elems[1:] {
+				c, err := starCompare(e, best)
+				if err != nil {
+					return nil, err
+				}
+				if c == want {
+					best = e
+				}
+			}
+			return best, nil
+		})
+	}
+	minMax("min", -1)
+	minMax("max", 1)
+	b("abs", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("abs", args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		switch x := a[0].(type) {
+		case int:
+			return max(x, -x), nil
+		case float64:
+			return math.Abs(x), nil
+		}
+		return nil, fmt.Errorf("abs() wants a number, not %s", starType(a[0]))
+	})
+	anyAll := func(name string, all bool) {
+		b(name, func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs(name, args, kwargs, 1, "x")
+			if err != nil {
+				return nil, err
+			}
+			elems, err := starElemsOf(a[0])
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range elems {
+				if starTruth(e) != all {
+					return !all, nil
+				}
+			}
+			return all, nil
+		})
+	}
+	anyAll("any", false)
+	anyAll("all", true)
+	b("type", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("type", args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		return starType(a[0]), nil
+	})
+	b("hasattr", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("hasattr", args, kwargs, 2, "x", "name")
+		if err != nil {
+			return nil, err
+		}
+		name, err := starStringArg("hasattr", a[1])
+		if err != nil {
+			return nil, err
+		}
+		_, err = starAttr(a[0], name)
+		return err == nil, nil
+	})
+	b("getattr", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("getattr", args, kwargs, 2, "x", "name", "default")
+		if err != nil {
+			return nil, err
+		}
+		name, err := starStringArg("getattr", a[1])
+		if err != nil {
+			return nil, err
+		}
+		v, err := starAttr(a[0], name)
+		if err != nil && given(a[2]) {
+			return a[2], nil
+		}
+		return v, err
+	})
+	b("print", func(th *starThread, args []any, kwargs []starKwarg) (any, error) {
+		sep := " "
+		for _, kw := range kwargs {
+			if kw.name != "sep" {
+				return nil, fmt.Errorf("print() has no parameter %s", kw.name)
+			}
+			var err error
+			if sep, err = starStringArg("print", kw.v); err != nil {
+				return nil, err
+			}
+		}
+		parts := make([]string, len(args))
+		for i, a := range args {
+			parts[i] = starStr(a)
+		}
+		if th.print != nil {
+			fmt.Fprintln(th.print, strings.Join(parts, sep))
+		}
+		return nil, nil
+	})
+	b("fail", func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		parts := make([]string, len(args))
+		for i, a := range args {
+			parts[i] = starStr(a)
+		}
+		return nil, errors.New("fail: " + strings.Join(parts, " "))
+	})
+}
+
+// starUpdate adds the entries of a dict, or of a list of pairs, to d.
+func starUpdate(d *starDict, from any) error {
+	if src, ok := from.(*starDict); ok {
+		for i, k := range src.keys {
+			d.set(k, src.vals[i])
+		}
+		return nil
+	}
+	pairs, err := starElemsOf(from)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		kv, err := starElemsOf(p)
+		if err != nil || len(kv) != 2 {
+			return fmt.Errorf("dict update wants key, value pairs, not %s", starRepr(p))
+		}
+		if err := d.set(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type starMethod func(recv any, args []any, kwargs []starKwarg) (any, error)
+
+var starMethods = map[string]map[string]starMethod{
+	"string": {
+		"lower":      starStringFunc(strings.ToLower),
+		"upper":      starStringFunc(strings.ToUpper),
+		"title":      starStringFunc(starTitle),
+		"capitalize": starStringFunc(starCapitalize),
+		"strip":      starStripMethod(strings.Trim, strings.TrimSpace),
+		"lstrip":     starStripMethod(strings.TrimLeft, func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) }),
+		"rstrip":     starStripMethod(strings.TrimRight, func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }),
+		"startswith": starAffixMethod("startswith", strings.HasPrefix),
+		"endswith":   starAffixMethod("endswith", strings.HasSuffix),
+		"removeprefix": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starStrings("removeprefix", args, kwargs, 1, "prefix")
+			if err != nil {
+				return nil, err
+			}
+			return strings.TrimPrefix(recv.(string), a[0]), nil
+		},
+		"removesuffix": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starStrings("removesuffix", args, kwargs, 1, "suffix")
+			if err != nil {
+				return nil, err
+			}
+			return strings.TrimSuffix(recv.(string), a[0]), nil
+		},
+		"replace": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("replace", args, kwargs, 2, "old", "new", "count")
+			if err != nil {
+				return nil, err
+			}
+			old, err := starStringArg("replace", a[0])
+			if err != nil {
+				return nil, err
+			}
+			repl, err := starStringArg("replace", a[1])
+			if err != nil {
+				return nil, err
+			}
+			n := -1
+			if given(a[2]) {
+				if n, err = starIntArg("replace", a[2]); err != nil {
+					return nil, err
+				}
+			}
+			return strings.Replace(recv.(string), old, repl, n), nil
+		},
+		"split": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("split", args, kwargs, 0, "sep", "maxsplit")
+			if err != nil {
+				return nil, err
+			}
+			n := -1
+			if given(a[1]) {
+				if n, err = starIntArg("split", a[1]); err != nil {
+					return nil, err
+				}
+				if n >= 0 {
+					n++
+				}
+			}
+			var parts []string
+			if !given(a[0]) || a[0] == nil {
+				parts = strings.Fields(recv.(string))
+				if n > 0 && len(parts) > n {
+					parts = starSplitSpace(recv.(string), n)
+				}
+			} else {
+				sep, err := starStringArg("split", a[0])
+				if err != nil {
+					return nil, err
+				}
+				if sep == "" {
+					return nil, errors.New("split() separator is empty")
+				}
+				parts = strings.SplitN(recv.(string), sep, n)
+			}
+			return starStringList(parts), nil
+		},
+		"splitlines": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			if _, err := starArgs("splitlines", args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			s := strings.TrimSuffix(strings.ReplaceAll(recv.(string), "\r\n", "\n"), "\n")
+			if s == "" {
+				return &starList{elems: []any{}}, nil
+			}
+			return starStringList(strings.Split(s, "\n")), nil
+		},
+		"join": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("join", args, kwargs, 1, "x")
+			if err != nil {
+				return nil, err
+			}
+			elems, err := starElemsOf(a[0])
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, len(elems))
+			for i, e := range elems {
+				if parts[i], err = starStringArg("join", e); err != nil {
+					return nil, err
+				}
+			}
+			return strings.Join(parts, recv.(string)), nil
+		},
+		"find":  starFindMethod("find", strings.Index, false),
+		"rfind": starFindMethod("rfind", strings.LastIndex, false),
+		"index": starFindMethod("index", strings.Index, true),
+		"count": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starStrings("count", args, kwargs, 1, "sub")
+			if err != nil {
+				return nil, err
+			}
+			return strings.Count(recv.(string), a[0]), nil
+		},
+		"isdigit": starStringTest(func(r rune) bool { return r >= '0' && r <= '9' }),
+		"isalpha": starStringTest(unicode.IsLetter),
+		"isalnum": starStringTest(func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }),
+		"isspace": starStringTest(unicode.IsSpace),
+		"isupper": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			s := recv.(string)
+			return strings.ToUpper(s) == s && strings.ToLower(s) != s, nil
+		},
+		"islower": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			s := recv.(string)
+			return strings.ToLower(s) == s && strings.ToUpper(s) != s, nil
+		},
+		"format": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			return starFormat(recv.(string), args, kwargs)
+		},
+	},
+	"list": {
+		"append": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("append", args, kwargs, 1, "x")
+			if err != nil {
+				return nil, err
+			}
+			l := recv.(*starList)
+			l.elems = append(l.elems, a[0])
+			return nil, nil
+		},
+		"extend": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("extend", args, kwargs, 1, "x")
+			if err != nil {
+				return nil, err
+			}
+			elems, err := starElemsOf(a[0])
+			if err != nil {
+				return nil, err
+			}
+			l := recv.(*starList)
+			l.elems = append(l.elems, elems...)
+			return nil, nil
+		},
+		"insert": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("insert", args, kwargs, 2, "index", "x")
+			if err != nil {
+				return nil, err
+			}
+			i, err := starIntArg("insert", a[0])
+			if err != nil {
+				return nil, err
+			}
+			l := recv.(*starList)
+			if i < 0 {
+				i += len(l.elems)
+			}
+			l.elems = slices.Insert(l.elems, min(max(i, 0), len(l.elems)), a[1])
+			return nil, nil
+		},
+		"pop": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("pop", args, kwargs, 0, "index")
+			if err != nil {
+				return nil, err
+			}
+			l := recv.(*starList)
+			i := any(-1)
+			if given(a[0]) {
+				i = a[0]
+			}
+			k, err := starIndexOf(i, len(l.elems))
+			if err != nil {
+				return nil, fmt.Errorf("pop: %v", err)
+			}
+			v := l.elems[k]
+			l.elems = slices.Delete(l.elems, k, k+1)
+			return v, nil
+		},
+		"remove": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("remove", args, kwargs, 1, "x")
+			if err != nil {
+				return nil, err
+			}
+			l := recv.(*starList)
+			i := slices.IndexFunc(l.elems, func(e any) bool { return starEqual(e, a[0]) })
+			if i < 0 {
+				return nil, fmt.Errorf("remove: %s not in list", starRepr(a[0]))
+			}
+			l.elems = slices.Delete(l.elems, i, i+1)
+			return nil, nil
+		},
+		"index": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("index", args, kwargs, 1, "x")
+			if err != nil {
+				return nil, err
+			}
+			i := slices.IndexFunc(recv.(*starList).elems, func(e any) bool { return starEqual(e, a[0]) })
+			if i < 0 {
+				return nil, fmt.Errorf("index: %s not in list", starRepr(a[0]))
+			}
+			return i, nil
+		},
+		"clear": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			if _, err := starArgs("clear", args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			recv.(*starList).elems = nil
+			return nil, nil
+		},
+	},
+	"dict": {
+		"get": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("get", args, kwargs, 1, "key", "default")
+			if err != nil {
+				return nil, err
+			}
+			v, ok, err := recv.(*starDict).get(a[0])
+			if err != nil || ok {
+				return v, err
+			}
+			if given(a[1]) {
+				return a[1], nil
+			}
+			return nil, nil
+		},
+		"pop": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("pop", args, kwargs, 1, "key", "default")
+			if err != nil {
+				return nil, err
+			}
+			v, ok, err := recv.(*starDict).delete(a[0])
+			switch {
+			case err != nil || ok:
+				return v, err
+			case given(a[1]):
+				return a[1], nil
+			}
+			return nil, fmt.Errorf("pop: key %s not in dict", starRepr(a[0]))
+		},
+		"setdefault": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			a, err := starArgs("setdefault", args, kwargs, 1, "key", "default")
+			if err != nil {
+				return nil, err
+			}
+			d := recv.(*starDict)
+			v, ok, err := d.get(a[0])
+			if err != nil || ok {
+				return v, err
+			}
+			v = a[1]
+			if !given(v) {
+				v = nil
+			}
+			return v, d.set(a[0], v)
+		},
+		"update": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("update() takes at most 1 positional argument, got %d", len(args))
+			}
+			d := recv.(*starDict)
+			if len(args) == 1 {
+				if err := starUpdate(d, args[0]); err != nil {
+					return nil, err
+				}
+			}
+			for _, kw := range kwargs {
+				d.set(kw.name, kw.v)
+			}
+			return nil, nil
+		},
+		"keys": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			if _, err := starArgs("keys", args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			return &starList{elems: slices.Clone(recv.(*starDict).keys)}, nil
+		},
+		"values": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			if _, err := starArgs("values", args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			return &starList{elems: slices.Clone(recv.(*starDict).vals)}, nil
+		},
+		"items": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			if _, err := starArgs("items", args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			d := recv.(*starDict)
+			out := &starList{elems: make([]any, len(d.keys))}
+			for i, k := range d.keys {
+				out.elems[i] = starTuple{k, d.vals[i]}
+			}
+			return out, nil
+		},
+		"clear": func(recv any, args []any, kwargs []starKwarg) (any, error) {
+			if _, err := starArgs("clear", args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			*recv.(*starDict) = *newStarDict()
+			return nil, nil
+		},
+	},
+}
+
+// starAttr returns the method name of v bound to it.
+func starAttr(v any, name string) (any, error) {
+	m, ok := starMethods[starType(v)][name]
+	if !ok {
+		return nil, fmt.Errorf("%s has no .%s field or method", starType(v), name)
+	}
+	return &starBuiltin{name: starType(v) + "." + name, fn: func(_ *starThread, args []any, kwargs []starKwarg) (any, error) {
+		return m(v, args, kwargs)
+	}}, nil
+}
+
+func starStrings(fn string, args []any, kwargs []starKwarg, required int, names ...string) ([]string, error) {
+	a, err := starArgs(fn, args, kwargs, required, names...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(a))
+	for i, v := range a {
+		if !given(v) {
+			continue
+		}
+		if out[i], err = starStringArg(fn, v); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func starStringList(parts []string) *starList {
+	l := &starList{elems: make([]any, len(parts))}
+	for i, p := range parts {
+		l.elems[i] = p
+	}
+	return l
+}
+
+func starStringFunc(f func(string) string) starMethod {
+	return func(recv any, args []any, kwargs []starKwarg) (any, error) {
+		if len(args)+len(kwargs) > 0 {
+			return nil, errors.New("takes no arguments")
+		}
+		return f(recv.(string)), nil
+	}
+}
+
+func starStringTest(f func(rune) bool) starMethod {
+	return func(recv any, args []any, kwargs []starKwarg) (any, error) {
+		s := recv.(string)
+		for _, r := range s {
+			if !f(r) {
+				return false, nil
+			}
+		}
+		return s != "", nil
+	}
+}
+
+func starStripMethod(trim func(string, string) string, space func(string) string) starMethod {
+	return func(recv any, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs("strip", args, kwargs, 0, "chars")
+		if err != nil {
+			return nil, err
+		}
+		if !given(a[0]) || a[0] == nil {
+			return space(recv.(string)), nil
+		}
+		chars, err := starStringArg("strip", a[0])
+		if err != nil {
+			return nil, err
+		}
+		return trim(recv.(string), chars), nil
+	}
+}
+
+// starAffixMethod is startswith or endswith, taking a string or a tuple of
+// strings.
+func starAffixMethod(name string, has func(string, string) bool) starMethod {
+	return func(recv any, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starArgs(name, args, kwargs, 1, "x")
+		if err != nil {
+			return nil, err
+		}
+		affixes := []any{a[0]}
+		if t, ok := a[0].(starTuple); ok {
+			affixes = t
+		}
+		for _, x := range affixes {
+			s, err := starStringArg(name, x)
+			if err != nil {
+				return nil, err
+			}
+			if has(recv.(string), s) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func starFindMethod(name string, find func(string, string) int, mustFind bool) starMethod {
+	return func(recv any, args []any, kwargs []starKwarg) (any, error) {
+		a, err := starStrings(name, args, kwargs, 1, "sub")
+		if err != nil {
+			return nil, err
+		}
+		i := find(recv.(string), a[0])
+		if i < 0 && mustFind {
+			return nil, fmt.Errorf("index: substring %q not found", a[0])
+		}
+		return i, nil
+	}
+}
+
+func starTitle(s string) string {
+	var b strings.Builder
+	prev := ' '
+	for _, r := range s {
+		if unicode.IsLetter(prev) {
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+func starCapitalize(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + strings.ToLower(s[n:])
+}
+
+// starSplitSpace splits s at runs of white space into at most n fields,
+// the last keeping the rest of s.
+func starSplitSpace(s string, n int) []string {
+	var out []string
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	for len(out) < n-1 && s != "" {
+		i := strings.IndexFunc(s, unicode.IsSpace)
+		if i < 0 {
+			break
+		}
+		out = append(out, s[:i])
+		s = strings.TrimLeftFunc(s[i:], unicode.IsSpace)
+	}
+	if s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// starFormat is str.format with {}, {0} and {name} fields; format specs
+// and conversions are not supported.
+func starFormat(format string, args []any, kwargs []starKwarg) (any, error) {
+	var b strings.Builder
+	auto := 0
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '{' && strings.HasPrefix(format[i+1:], "{"), c == '}' && strings.HasPrefix(format[i+1:], "}"):
+			b.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, errors.New("format: unmatched {")
+			}
+			field := format[i+1 : i+end]
+			i += end
+			if strings.ContainsAny(field, ":!") {
+				return nil, fmt.Errorf("format: {%s}: format specs and conversions are not supported", field)
+			}
+			var v any
+			switch n, err := strconv.Atoi(field); {
+			case field == "":
+				if auto >= len(args) {
+					return nil, errors.New("format: not enough arguments")
+				}
+				v, auto = args[auto], auto+1
+			case err == nil:
+				if n < 0 || n >= len(args) {
+					return nil, fmt.Errorf("format: no argument %d", n)
+				}
+				v = args[n]
+			default:
+				k := slices.IndexFunc(kwargs, func(kw starKwarg) bool { return kw.name == field })
+				if k < 0 {
+					return nil, fmt.Errorf("format: no argument %s", field)
+				}
+				v = kwargs[k].v
+			}
+			b.WriteString(starStr(v))
+		case c == '}':
+			return nil, errors.New("format: single } in format string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
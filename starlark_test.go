@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runStarlark(ctx context.Context, src string) (map[string]any, error) {
+	prog, err := parseStarlark("test.star", src)
+	if err != nil {
+		return nil, err
+	}
+	th := &starThread{ctx: ctx}
+	return th.run(prog)
+}
+
+func TestStarlark(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, want string // want is repr(result)
+	}{
+		{"arithmetic", "result = 1 + 2 * 3 - 8 // 3", "5"},
+		{"floor division and modulo", "result = (-7 // 2, -7 % 2, 7 % -2, 7 / 2)", "(-4, 1, -1, 3.5)"},
+		{"floats", "result = (1.5 * 2, 1e3, float('2.5'), int(3.9))", "(3.0, 1000.0, 2.5, 3)"},
+		{"hex and underscores", "result = 0x1f + 1_000", "1031"},
+		{"strings", `result = "a" + 'b' * 3 + """c"""`, `"abbbc"`},
+		{"escapes", `result = "t\tq\"\u00e9\x41" + r"\n"`, `"t\tq\"éA\\n"`},
+		{"adjacent literals", `result = "a" "b"`, `"ab"`},
+		{"string methods", `result = [" Ab ".strip(), "ab".upper(), "a-b-c".split("-", 1), "-".join(["x", "y"]), "abc".startswith(("x", "a")), "hello world".title(), "x.md".removesuffix(".md"), "a b  c".split()]`,
+			`["Ab", "AB", ["a", "b-c"], "x-y", True, "Hello World", "x", ["a", "b", "c"]]`},
+		{"format", `result = ["{} {}".format(1, "a"), "{1}{0}".format("a", "b"), "{x}!".format(x=2), "%s=%d%%" % ("k", 3)]`, `["1 a", "ba", "2!", "k=3%"]`},
+		{"indexing and slicing", "s = 'abcdef'\nl = [1, 2, 3, 4]\nresult = (s[1], s[-1], s[1:3], s[::-1], l[1:], l[:-1], l[::2])", `("b", "f", "bc", "fedcba", [2, 3, 4], [1, 2, 3], [1, 3])`},
+		{"comparisons", "result = (1 < 2, 'a' >= 'b', [1, 2] < [1, 3], 1 == 1.0, None == None, 2 in [1, 2], 'x' not in 'abc', 'k' in {'k': 1})", "(True, False, True, True, True, True, True, True)"},
+		{"logic", "result = (0 or 'x', 1 and 2, not [], None or None, 1 if False else 2)", `("x", 2, True, None, 2)`},
+		{"lists", "l = [3]\nl.append(1)\nl.extend([2])\nl.insert(0, 9)\nx = l.pop()\nl += [7]\nresult = (l, x, l.index(1), sorted(l), sorted(l, reverse=True), len(l))", "([9, 3, 1, 7], 2, 2, [1, 3, 7, 9], [9, 7, 3, 1], 4)"},
+		{"dicts", "d = {'b': 1, 'a': 2}\nd['c'] = 3\nd['b'] += 10\nx = d.pop('a')\nresult = (d, x, d.get('z'), d.get('z', 0), list(d.keys()), d.items(), d.setdefault('e', 5), d)",
+			`({"b": 11, "c": 3, "e": 5}, 2, None, 0, ["b", "c"], [("b", 11), ("c", 3)], 5, {"b": 11, "c": 3, "e": 5})`},
+		{"tuple keys", "d = {(1, 'a'): 'x'}\nresult = d[(1, 'a')]", `"x"`},
+		{"numeric keys", "d = {1: 'x'}\nresult = d[1.0]", `"x"`},
+		{"if elif else", "def f(x):\n    if x < 0:\n        return 'neg'\n    elif x == 0:\n        return 'zero'\n    else:\n        return 'pos'\nresult = [f(-1), f(0), f(1)]", `["neg", "zero", "pos"]`},
+		{"for break continue", "out = []\nfor i in range(10):\n    if i % 2:\n        continue\n    if i > 6:\n        break\n    out.append(i)\nresult = out", "[0, 2, 4, 6]"},
+		{"for unpacking", "out = []\nfor k, v in {'a': 1, 'b': 2}.items():\n    out.append(k * v)\nresult = out", `["a", "bb"]`},
+		{"for over a list it appends to", "l = [1, 2]\nfor x in l:\n    l.append(x)\nresult = l", "[1, 2, 1, 2]"},
+		{"defaults and keywords", "def f(a, b=2, c=3):\n    return a + b * c\nresult = (f(1), f(1, 0), f(1, c=10), f(a=2, b=1))", "(7, 1, 21, 5)"},
+		{"closures", "def outer():\n    n = 5\n    def inner(x):\n        return x + n\n    return inner\nresult = outer()(1)", "6"},
+		{"lambda", "result = sorted(['bb', 'a', 'ccc'], key=lambda s: -len(s))", `["ccc", "bb", "a"]`},
+		{"comprehensions", "result = ([x * x for x in range(5) if x % 2 == 0], {k: v for k, v in [('a', 1), ('b', 2)]}, [(i, j) for i in range(2) for j in 'ab'.split('x')])",
+			`([0, 4, 16], {"a": 1, "b": 2}, [(0, "ab"), (1, "ab")])`},
+		{"comprehension variables stay inside", "x = 'outer'\nl = [x for x in range(3)]\nresult = x", `"outer"`},
+		{"builtins", "result = (len('abc'), str(1), repr('a'), bool(''), list(range(3)), min(3, 1, 2), max([1, 5]), abs(-2), any([0, 1]), all([]), type({}), list(enumerate('ab'.split('x'))), zip([1, 2], 'ab'.split('x')))",
+			`(3, "1", "\"a\"", False, [0, 1, 2], 1, 5, 2, True, True, "dict", [(0, "ab")], [(1, "ab")])`},
+		{"multiple assignment", "a, b = 1, 2\na, b = b, a\nresult = (a, b)", "(2, 1)"},
+		{"statements on one line", "x = 1; y = 2\nif x: z = 3\nresult = x + y + z", "6"},
+		{"line continuation", "result = (1 +\n    2) + \\\n    3", "6"},
+		{"comments and blank lines", "# header\n\nx = 1  # trailing\n\n    # indented comment\nresult = x", "1"},
+		{"nested lists repr", "result = [[1, (2,)], {'a': [None, True]}, 1.0]", `[[1, (2,)], {"a": [None, True]}, 1.0]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			globals, err := runStarlark(context.Background(), tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := starRepr(globals["result"]); got != tc.want {
+				t.Errorf("result = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStarlarkErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, want string
+	}{
+		{"undefined name", "x = 1\ny = z", "test.star:2: undefined name z"},
+		{"syntax", "x = (1", "test.star:1: unexpected end of line"},
+		{"bad indentation", "if True:\n    x = 1\n  y = 2", "test.star:3: unindent does not match"},
+		{"missing block", "if True:\nx = 1", "test.star:2: want an indented block"},
+		{"tab", "if True:\n\tx = 1", "test.star:2: tab in indentation"},
+		{"while", "while True:\n    pass", "test.star:1: while loops are not supported"},
+		{"return outside a function", "return 1", "test.star:1: return outside a function"},
+		{"break outside a loop", "def f():\n    break", "test.star:2: break outside a loop"},
+		{"chained comparison", "x = 1 < 2 < 3", "comparisons can't be chained"},
+		{"keyword as a name", "and = 1", "test.star:1: unexpected \"and\""},
+		{"recursion", "def f(n):\n    return f(n - 1)\nf(1)", "test.star:2: function f called recursively"},
+		{"type error", "x = 1 + 'a'", "test.star:1: unsupported operand types for +: int and string"},
+		{"division by zero", "x = 1 // 0", "division by zero"},
+		{"index out of range", "x = [1][1]", "index 1 out of range for length 1"},
+		{"missing key", "x = {}['k']", `key "k" not in dict`},
+		{"unhashable key", "x = {[]: 1}", "unhashable type: list"},
+		{"duplicate key", "x = {'a': 1, 'a': 2}", `duplicate key "a"`},
+		{"unknown method", "x = 'a'.nope()", "string has no .nope field or method"},
+		{"wrong arguments", "def f(a):\n    pass\nf(1, 2)", "f() takes 1 arguments, got 2"},
+		{"missing argument", "def f(a, b):\n    pass\nf(1)", "f() missing argument b"},
+		{"unpacking", "a, b = [1]", "can't unpack 1 values into 2 variables"},
+		{"not iterable", "for x in 1:\n    pass", "int is not iterable"},
+		{"fail", "fail('bad', 1)", "test.star:1: fail: bad 1"},
+		{"error inside a call", "def f():\n    return {}['x']\ny = f()", "test.star:2: key"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := runStarlark(context.Background(), tc.src)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestStarlarkTimeout checks that a script looping for too long stops when
+// its context is done.
+func TestStarlarkTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := runStarlark(ctx, "n = 0\nfor i in range(1000000000000):\n    n += i")
+	if err != context.DeadlineExceeded {
+		t.Errorf("error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
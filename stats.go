@@ -0,0 +1,41 @@
+package main
+
+// graphStats summarises the shape of a graph.
+type graphStats struct {
+	Nodes      int            `json:"nodes"`
+	Edges      int            `json:"edges"`
+	NodeTypes  map[string]int `json:"node_types"`
+	Labels     int            `json:"labels"`     // distinct edge labels
+	Unlabelled int            `json:"unlabelled"` // edges without a label
+	Orphans    int            `json:"orphans"`    // non-group nodes with no edges
+	Dangling   int            `json:"dangling"`   // edges with a missing endpoint
+	SelfLoops  int            `json:"self_loops"`
+}
+
+func computeStats(g *graph) graphStats {
+	st := graphStats{Nodes: len(g.Nodes), Edges: len(g.Edges), NodeTypes: map[string]int{}}
+	labels := map[string]bool{}
+	for _, e := range g.Edges {
+		if e.Label == "" {
+			st.Unlabelled++
+		} else {
+			labels[e.Label] = true
+		}
+		_, okFrom := g.node(e.From)
+		_, okTo := g.node(e.To)
+		if !okFrom || !okTo {
+			st.Dangling++
+		}
+		if e.From == e.To {
+			st.SelfLoops++
+		}
+	}
+	st.Labels = len(labels)
+	for _, n := range g.Nodes {
+		st.NodeTypes[n.Type]++
+		if n.Type != "group" && len(g.out[n.ID]) == 0 && len(g.in[n.ID]) == 0 {
+			st.Orphans++
+		}
+	}
+	return st
+}
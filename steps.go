@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// -step builds a pipeline of graph transformations run in the order given,
+// each "name" or "name:argument":
+//
+//	filter:LIST          keep the nodes a node list entry (or @file) matches
+//	filter:!LIST         drop them instead, as -exclude-nodes does
+//	dedupe[:count]       collapse parallel edges, as -parallel merge-labels (or count)
+//	contract-groups      replace each top-level group's members by the group
+//	reverse              turn every edge around
+//	map-labels:FILE      rename edge labels from a "label -> new label" file
+//	plugin:NAME          run the transform plugin NAME, as -transform does
+//	script:FILE          run the Starlark script FILE's node and edge hooks (script.go)
+//
+// The flags with the same effect run the same steps.
+//
+// The steps live here rather than in a transform package: the tree has no
+// module path to import one from, so, like streamEdges, they stay in main.
+
+// graphStep is one transformation of the graph; it may change g in place
+// or return a new graph.
+type graphStep interface {
+	apply(ctx context.Context, g *graph) (*graph, error)
+}
+
+type filterStep struct {
+	m    *nodeMatcher
+	drop bool
+}
+
+func (s filterStep) apply(_ context.Context, g *graph) (*graph, error) {
+	if s.drop {
+		excludeNodes(g, s.m)
+		return g, nil
+	}
+	keep := map[string]bool{}
+	nodes := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if s.m.match(n) {
+			keep[n.ID] = true
+			nodes = append(nodes, n)
+		}
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if keep[e.From] && keep[e.To] {
+			edges = append(edges, e)
+		}
+	}
+	g.Nodes, g.Edges = nodes, edges
+	g.index()
+	return g, nil
+}
+
+type dedupeStep struct{ policy string }
+
+func (s dedupeStep) apply(_ context.Context, g *graph) (*graph, error) {
+	return g, applyParallelPolicy(g, s.policy)
+}
+
+type contractGroupsStep struct{}
+
+// apply folds every node inside a top-level group into the group: edges
+// are rewired to it, edges within one group are dropped and the members
+// removed. Edges between groups may end up parallel; dedupe merges them.
+func (contractGroupsStep) apply(_ context.Context, g *graph) (*graph, error) {
+	owner := map[string]string{}
+	for i, n := range g.Nodes {
+		if top := g.outermostGroup(i); top >= 0 {
+			owner[n.ID] = g.Nodes[top].ID
+		}
+	}
+	group := func(id string) string {
+		if o, ok := owner[id]; ok {
+			return o
+		}
+		return id
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		from, to := group(e.From), group(e.To)
+		if from == to && (from != e.From || to != e.To) {
+			continue // inside one group
+		}
+		e.From, e.To = from, to
+		edges = append(edges, e)
+	}
+	g.Edges = edges
+	nodes := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if _, ok := owner[n.ID]; !ok {
+			nodes = append(nodes, n)
+		}
+	}
+	g.Nodes = nodes
+	g.index()
+	return g, nil
+}
+
+// outermostGroup returns the index of the largest group node containing
+// g.Nodes[i], or -1.
+func (g *graph) outermostGroup(i int) int {
+	best := -1
+	for j, grp := range g.Nodes {
+		if j == i || grp.Type != "group" || !g.Nodes[i].Node.within(grp.Node) {
+			continue
+		}
+		if best < 0 || grp.Node.Width*grp.Node.Height > g.Nodes[best].Node.Width*g.Nodes[best].Node.Height {
+			best = j
+		}
+	}
+	return best
+}
+
+type reverseStep struct{}
+
+func (reverseStep) apply(_ context.Context, g *graph) (*graph, error) {
+	for i, e := range g.Edges {
+		g.Edges[i].From, g.Edges[i].To = e.To, e.From
+	}
+	g.index()
+	return g, nil
+}
+
+type mapLabelsStep struct{ dict map[string]string } // lower-cased label -> new label
+
+func (s mapLabelsStep) apply(_ context.Context, g *graph) (*graph, error) {
+	for i, e := range g.Edges {
+		if to, ok := s.dict[strings.ToLower(strings.TrimSpace(e.Label))]; ok {
+			g.Edges[i].Label = to
+		}
+	}
+	return g, nil
+}
+
+type pluginStep struct {
+	name, path string
+	settings   map[string]string
+}
+
+func (s pluginStep) apply(ctx context.Context, g *graph) (*graph, error) {
+	ng, err := runTransformPlugin(ctx, s.path, g, s.settings)
+	if err != nil {
+		return nil, fmt.Errorf("transform %s: %v", s.name, err)
+	}
+	return ng, nil
+}
+
+// parseStep parses one -step value.
+func parseStep(def, pluginDir string, settings map[string]string) (graphStep, error) {
+	name, arg, hasArg := strings.Cut(def, ":")
+	needArg := func() error {
+		if !hasArg || arg == "" {
+			return fmt.Errorf("%s needs an argument (%s:...)", name, name)
+		}
+		return nil
+	}
+	noArg := func() error {
+		if hasArg {
+			return fmt.Errorf("%s takes no argument", name)
+		}
+		return nil
+	}
+	switch name {
+	case "filter":
+		if err := needArg(); err != nil {
+			return nil, err
+		}
+		list, drop := strings.CutPrefix(arg, "!")
+		m, err := nodeMatcherFrom([]string{list})
+		if err != nil {
+			return nil, err
+		}
+		return filterStep{m: m, drop: drop}, nil
+	case "dedupe":
+		switch arg {
+		case "", "merge-labels":
+			return dedupeStep{"merge-labels"}, nil
+		case "count":
+			return dedupeStep{"count"}, nil
+		}
+		return nil, fmt.Errorf("dedupe: unknown mode %q (want merge-labels or count)", arg)
+	case "contract-groups":
+		return contractGroupsStep{}, noArg()
+	case "reverse":
+		return reverseStep{}, noArg()
+	case "map-labels":
+		if err := needArg(); err != nil {
+			return nil, err
+		}
+		dict, err := readTranslations(arg)
+		if err != nil {
+			return nil, err
+		}
+		return mapLabelsStep{dict}, nil
+	case "plugin":
+		if err := needArg(); err != nil {
+			return nil, err
+		}
+		path, err := findPlugin(pluginDir, "transform", arg)
+		if err != nil {
+			return nil, err
+		}
+		return pluginStep{name: arg, path: path, settings: settings}, nil
+	case "script":
+		if err := needArg(); err != nil {
+			return nil, err
+		}
+		return loadScript(arg)
+	}
+	return nil, fmt.Errorf("unknown step %q (want filter, dedupe, contract-groups, reverse, map-labels, plugin or script)", name)
+}
+
+// stepFiles returns the files a -step value reads: the labels file of
+// map-labels, the script of script and the @file lists of filter.
+func stepFiles(def string) []string {
+	name, arg, _ := strings.Cut(def, ":")
+	switch name {
+	case "map-labels", "script":
+		if arg != "" {
+			return []string{arg}
+		}
+	case "filter":
+		if path, ok := strings.CutPrefix(strings.TrimPrefix(arg, "!"), "@"); ok {
+			return []string{path}
+		}
+	}
+	return nil
+}
+
+// runSteps applies steps to g in order.
+func runSteps(ctx context.Context, g *graph, steps []graphStep) (*graph, error) {
+	for _, s := range steps {
+		var err error
+		if g, err = s.apply(ctx, g); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
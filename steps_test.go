@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stepsCanvas has a group G around A and B, and C outside it.
+const stepsCanvas = `{
+	"nodes": [
+		{"id": "g", "type": "group", "label": "G", "x": 0, "y": 0, "width": 500, "height": 200},
+		{"id": "a", "type": "text", "text": "A", "x": 10, "y": 10, "width": 100, "height": 50},
+		{"id": "b", "type": "text", "text": "B", "x": 200, "y": 10, "width": 100, "height": 50},
+		{"id": "c", "type": "text", "text": "C", "x": 700, "y": 0, "width": 100, "height": 50}
+	],
+	"edges": [
+		{"id": "e1", "fromNode": "a", "toNode": "b", "label": "x"},
+		{"id": "e2", "fromNode": "a", "toNode": "b", "label": "y"},
+		{"id": "e3", "fromNode": "a", "toNode": "c", "label": "uses"},
+		{"id": "e4", "fromNode": "c", "toNode": "b"},
+		{"id": "e5", "fromNode": "b", "toNode": "c", "label": "Part of"}
+	]
+}`
+
+// stepsGraph returns the graph of stepsCanvas.
+func stepsGraph(t *testing.T) *graph {
+	t.Helper()
+	c, err := parseCanvas([]byte(stepsCanvas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buildGraph(c, false)
+}
+
+// describeGraph lists g's node names and its edges as "From-label->To",
+// followed by any edge attributes.
+func describeGraph(g *graph) (nodes, edges []string) {
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.Name)
+	}
+	for _, e := range g.Edges {
+		s := g.name(e.From) + "-" + e.Label + "->" + g.name(e.To)
+		for _, k := range g.edgeAttrs {
+			if v, ok := e.Attrs[k]; ok {
+				s += " " + k + "=" + v
+			}
+		}
+		edges = append(edges, s)
+	}
+	return nodes, edges
+}
+
+// writeTestFile writes content to name in a new temporary directory.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSteps(t *testing.T) {
+	all := []string{"A-x->B", "A-y->B", "A-uses->C", "C-->B", "B-Part of->C"}
+	nodeList := writeTestFile(t, "nodes.txt", "# keep these\na\n/^C$/\n")
+	labels := writeTestFile(t, "labels.txt", "uses -> needs\npart of\tin\n")
+	script := writeTestFile(t, "drop.star", "def node(n):\n    return n['name'] != 'B' and None\n")
+	pluginDir := t.TempDir()
+	if runtime.GOOS != "windows" {
+		// answers with a graph of its own, whatever it is sent
+		plugin := "#!/bin/sh\ncat >/dev/null\necho '" + `{"nodes": [{"id": "a", "type": "text", "name": "A"}, {"id": "z", "type": "text", "name": "Z"}], "edges": [{"from": "a", "to": "z", "label": "new"}]}` + "'\n"
+		if err := os.WriteFile(filepath.Join(pluginDir, "transform-fixed"), []byte(plugin), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		name         string
+		steps        []string
+		nodes, edges []string
+	}{
+		{"none", nil, []string{"G", "A", "B", "C"}, all},
+		{"filter", []string{"filter:/^[AB]$/"}, []string{"A", "B"}, []string{"A-x->B", "A-y->B"}},
+		{"filter by name", []string{"filter:c"}, []string{"C"}, nil},
+		{"filter from a file", []string{"filter:@" + nodeList}, []string{"A", "C"}, []string{"A-uses->C"}},
+		{"filter out", []string{"filter:!C"}, []string{"G", "A", "B"}, []string{"A-x->B", "A-y->B"}},
+		{"dedupe", []string{"dedupe"}, []string{"G", "A", "B", "C"}, []string{"A-x, y->B", "A-uses->C", "C-->B", "B-Part of->C"}},
+		{"dedupe merge-labels", []string{"dedupe:merge-labels"}, []string{"G", "A", "B", "C"}, []string{"A-x, y->B", "A-uses->C", "C-->B", "B-Part of->C"}},
+		{"dedupe count", []string{"dedupe:count"}, []string{"G", "A", "B", "C"}, []string{"A-x, y->B count=2", "A-uses->C count=1", "C-->B count=1", "B-Part of->C count=1"}},
+		{"contract groups", []string{"contract-groups"}, []string{"G", "C"}, []string{"G-uses->C", "C-->G", "G-Part of->C"}},
+		{"reverse", []string{"reverse"}, []string{"G", "A", "B", "C"}, []string{"B-x->A", "B-y->A", "C-uses->A", "B-->C", "C-Part of->B"}},
+		{"map labels", []string{"map-labels:" + labels}, []string{"G", "A", "B", "C"}, []string{"A-x->B", "A-y->B", "A-needs->C", "C-->B", "B-in->C"}},
+		{"script", []string{"script:" + script}, []string{"G", "A", "C"}, []string{"A-uses->C"}},
+		{"plugin", []string{"plugin:fixed"}, []string{"A", "Z"}, []string{"A-new->Z"}},
+		{"in order", []string{"contract-groups", "reverse", "dedupe:count"}, []string{"G", "C"}, []string{"C-uses, Part of->G count=2", "G-->C count=1"}},
+		{"in another order", []string{"reverse", "dedupe:count", "contract-groups"}, []string{"G", "C"}, []string{"C-uses->G count=1", "G-->C count=1", "C-Part of->G count=1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && slices.Contains(tc.steps, "plugin:fixed") {
+				t.Skip("the test plugin is a shell script")
+			}
+			var steps []graphStep
+			for _, def := range tc.steps {
+				s, err := parseStep(def, pluginDir, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				steps = append(steps, s)
+			}
+			g, err := runSteps(context.Background(), stepsGraph(t), steps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, edges := describeGraph(g)
+			if !reflect.DeepEqual(nodes, tc.nodes) {
+				t.Errorf("nodes %q, want %q", nodes, tc.nodes)
+			}
+			if !reflect.DeepEqual(edges, tc.edges) {
+				t.Errorf("edges %q, want %q", edges, tc.edges)
+			}
+		})
+	}
+}
+
+func TestParseStepErrors(t *testing.T) {
+	for _, tc := range []struct {
+		def, want string
+	}{
+		{"sort", `unknown step "sort"`},
+		{"filter", "filter needs an argument (filter:...)"},
+		{"filter:", "filter needs an argument"},
+		{"filter:/(/", "error parsing regexp"},
+		{"dedupe:all", `dedupe: unknown mode "all"`},
+		{"reverse:x", "reverse takes no argument"},
+		{"contract-groups:x", "contract-groups takes no argument"},
+		{"map-labels", "map-labels needs an argument"},
+		{"map-labels:" + filepath.Join(t.TempDir(), "missing"), "no such file"},
+		{"plugin:nope", `no transform plugin "nope"`},
+		{"script", "script needs an argument"},
+	} {
+		_, err := parseStep(tc.def, t.TempDir(), nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parseStep(%q) error %v, want %q", tc.def, err, tc.want)
+		}
+	}
+}
+
+func TestStepFiles(t *testing.T) {
+	for _, tc := range []struct {
+		def  string
+		want []string
+	}{
+		{"map-labels:labels.txt", []string{"labels.txt"}},
+		{"script:rules.star", []string{"rules.star"}},
+		{"filter:@keep.txt", []string{"keep.txt"}},
+		{"filter:!@drop.txt", []string{"drop.txt"}},
+		{"filter:name", nil},
+		{"reverse", nil},
+	} {
+		if got := stepFiles(tc.def); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("stepFiles(%q) = %q, want %q", tc.def, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// graphStore holds the canvases serve answers from, keyed by path. Queries
+// take a snapshot and read it without locking: a reload builds a new graph
+// and swaps it in, so a query in flight keeps the version it started with.
+// With a reload interval the store re-expands its patterns and re-stats
+// every file, reloading the ones whose size or modification time changed,
+// adding new matches and dropping files that are gone. A canvas that no
+// longer parses keeps its last good version. Canvases load as the CLI
+// loads them: within the server's parse limits, with names and labels in
+// the -normalize form.
+
+type graphStore struct {
+	patterns []string
+	keepPath bool
+	form     string              // Unicode normal form of names and labels
+	limits   parseLimits         // as for canvases sent by clients
+	onReload func(result string) // for metrics; may be nil
+
+	loaded bool                 // the first refresh succeeded
+	failed map[string]time.Time // modification time of versions that didn't parse
+
+	mu       sync.RWMutex
+	canvases map[string]*servedCanvas
+	order    []string // paths in the order they were first loaded
+}
+
+func newGraphStore(patterns []string, keepPath bool, form string, limits parseLimits) *graphStore {
+	return &graphStore{patterns: patterns, keepPath: keepPath, form: form, limits: limits, canvases: map[string]*servedCanvas{}, failed: map[string]time.Time{}}
+}
+
+// snapshot returns the current canvases in load order.
+func (st *graphStore) snapshot() []*servedCanvas {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	out := make([]*servedCanvas, 0, len(st.order))
+	for _, path := range st.order {
+		out = append(out, st.canvases[path])
+	}
+	return out
+}
+
+// get returns the canvas loaded from path.
+func (st *graphStore) get(path string) (*servedCanvas, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	c, ok := st.canvases[path]
+	return c, ok
+}
+
+// load reads a canvas and records when the file was last changed.
+func (st *graphStore) load(path string) (*servedCanvas, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if st.limits.maxBytes > 0 && fi.Size() > st.limits.maxBytes {
+		return nil, limitErrorf("%s: canvas is %d bytes, over the %d byte limit", path, fi.Size(), st.limits.maxBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := parseCanvasLimits(context.Background(), data, st.limits)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	g := buildGraph(c, st.keepPath)
+	normalizeGraph(g, st.form)
+	return &servedCanvas{path: path, g: g, size: fi.Size(), modTime: fi.ModTime()}, nil
+}
+
+// refresh brings the store up to date with the files on disk. On the first
+// call every canvas must load; later, failures are logged and skipped.
+func (st *graphStore) refresh() error {
+	paths, err := expandInputs(st.patterns)
+	initial := !st.loaded
+	if err != nil && initial {
+		return err
+	}
+	var changed []*servedCanvas
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil && !initial {
+			continue
+		}
+		if old, ok := st.get(path); ok && fi.Size() == old.size && fi.ModTime().Equal(old.modTime) {
+			continue
+		}
+		if t, ok := st.failed[path]; ok && fi.ModTime().Equal(t) {
+			continue // still the version that failed
+		}
+		c, err := st.load(path)
+		if err != nil {
+			if initial {
+				return err
+			}
+			slog.Warn("reload failed, keeping the last version", "path", path, "err", err)
+			st.failed[path] = fi.ModTime()
+			st.reloaded("error")
+			continue
+		}
+		delete(st.failed, path)
+		changed = append(changed, c)
+	}
+	st.loaded = true
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, c := range changed {
+		if _, ok := st.canvases[c.path]; !ok {
+			st.order = append(st.order, c.path)
+		}
+		st.canvases[c.path] = c
+		if !initial {
+			slog.Info("reloaded", "path", c.path, "nodes", len(c.g.Nodes), "edges", len(c.g.Edges))
+			st.reloaded("ok")
+		}
+	}
+	st.order = slices.DeleteFunc(st.order, func(path string) bool {
+		if _, err := os.Stat(path); err == nil {
+			return false
+		}
+		delete(st.canvases, path)
+		slog.Info("dropped", "path", path)
+		return true
+	})
+	return nil
+}
+
+func (st *graphStore) reloaded(result string) {
+	if st.onReload != nil {
+		st.onReload(result)
+	}
+}
+
+// watch refreshes the store every interval until ctx is done.
+func (st *graphStore) watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := st.refresh(); err != nil {
+				slog.Warn(fmt.Sprintf("reload: %v", err))
+			}
+		}
+	}
+}
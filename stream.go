@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// -stream converts a canvas to CSV while it is read, for canvases too big
+// to hold as a graph: the nodes are kept, since every edge needs its ends,
+// but each edge is written and dropped as soon as it is decoded. Only the
+// options that apply to one edge at a time work with it.
+
+// streamFlags are the top-level flags -stream can be combined with.
+var streamFlags = []string{"stream", "in", "out", "format", "keep-path", "normalize", "opt", "config", "log-format", "log-level", "timeout", "outdir", "name-template"}
+
+// streamEdges reads a canvas from r and calls fn for each edge with the
+// nodes at its ends. Edges that come before "nodes" in the file are held
+// until the nodes have been read; an end naming no node is passed as a zero
+// Node. An error from fn stops the stream and is returned as it is.
+//
+// This is the callback API for embedding the converter where a whole graph
+// doesn't fit in memory. It lives here rather than in a canvas package
+// because the tree has no module path to import one by.
+func streamEdges(ctx context.Context, r io.Reader, fn func(e Edge, from, to Node) error) error {
+	br := bufio.NewReader(ctxReader{ctx, chunkedReader{r}})
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xEF\xBB\xBF" {
+		br.Discard(3)
+	}
+	dec := json.NewDecoder(br)
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("canvas is not a JSON object")
+	}
+	nodes := map[string]Node{}
+	haveNodes := false
+	var pending []Edge
+	var fnErr error // from fn, returned unwrapped
+	emit := func(e Edge) error {
+		fnErr = fn(e, nodes[e.FromNode], nodes[e.ToNode])
+		return fnErr
+	}
+	// each decodes the elements of an array value one by one.
+	each := func(key string, decode func() error) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok == nil {
+			return nil
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("%s: want an array", key)
+		}
+		for dec.More() {
+			if err := decode(); err != nil {
+				if err == fnErr {
+					return err
+				}
+				return fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch key := tok.(string); key {
+		case "nodes":
+			err = each(key, func() error {
+				var n Node
+				if err := dec.Decode(&n); err != nil {
+					return err
+				}
+				if k := n.Kind(); k != KindUnknown {
+					n.Type = k.String()
+				}
+				nodes[n.ID] = n
+				return nil
+			})
+			haveNodes = true
+			for _, e := range pending {
+				if err == nil {
+					err = emit(e)
+				}
+			}
+			pending = nil
+		case "edges":
+			err = each(key, func() error {
+				var e Edge
+				if err := dec.Decode(&e); err != nil {
+					return err
+				}
+				if !haveNodes {
+					pending = append(pending, e)
+					return nil
+				}
+				return emit(e)
+			})
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range pending { // a canvas without "nodes"
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamCSV writes the CSV export of the canvas read from r to each of
+// outs, decoding it once.
+func streamCSV(ctx context.Context, r io.Reader, outs []io.Writer, keepPath bool, form string, opts exportOptions) error {
+	var ws []*csv.Writer
+	for _, out := range outs {
+		w, err := newCSVWriter(out, opts, nil)
+		if err != nil {
+			return err
+		}
+		ws = append(ws, w)
+	}
+	name := func(n Node) string {
+		return normalizeString(singleLine(nodeDisplay(n, keepPath)), form)
+	}
+	err := streamEdges(ctx, r, func(e Edge, from, to Node) error {
+		label := e.Label
+		if label == "" {
+			label = e.Text
+		}
+		row := []string{name(from), normalizeString(singleLine(label), form), name(to)}
+		for _, w := range ws {
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, w := range ws {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamTargets streams one input to its outputs, which must all be CSV.
+// The input is read once and every row goes to all the outputs, so stdin
+// and the clipboard work with several -out.
+func streamTargets(ctx context.Context, inPath string, targets []outputTarget, keepPath bool, form string, opts exportOptions) (err error) {
+	for _, t := range targets {
+		if t.format != "csv" {
+			return fmt.Errorf("-stream writes only -format csv, not %s", t.format)
+		}
+	}
+	in, closeIn, err := openIn(inPath)
+	if err != nil {
+		return fmt.Errorf("open input: %v", err)
+	}
+	defer closeIn()
+	var outs []io.Writer
+	for _, t := range targets {
+		out, closeOut, err := openOut(t.path)
+		if err != nil {
+			return fmt.Errorf("open output: %v", err)
+		}
+		defer func() {
+			if cerr := closeOut(); err == nil && cerr != nil {
+				err = fmt.Errorf("close output: %v", cerr)
+			}
+		}()
+		outs = append(outs, ctxWriter{ctx, out})
+	}
+	if err := streamCSV(ctx, in, outs, keepPath, form, opts); err != nil {
+		return fmt.Errorf("%s: %v", inPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamEdges(t *testing.T) {
+	nodes := `"nodes": [
+		{"id": "a", "type": "text", "text": "A", "x": 0, "y": 0, "width": 10, "height": 10},
+		{"id": "b", "type": "file", "file": "notes/B.md", "x": 20, "y": 0, "width": 10, "height": 10}
+	]`
+	edges := `"edges": [
+		{"id": "e1", "fromNode": "a", "toNode": "b", "label": "uses"},
+		{"id": "e2", "fromNode": "b", "toNode": "zzz"}
+	]`
+	want := [][3]string{{"A", "uses", "notes/B.md"}, {"notes/B.md", "", ""}}
+	for _, tc := range []struct {
+		name, canvas string
+		want         [][3]string
+	}{
+		{"nodes first", "{" + nodes + "," + edges + "}", want},
+		{"edges first", "{" + edges + "," + nodes + "}", want},
+		{"edges first with other keys between", `{"meta": {"x": [1, {"y": 2}]},` + edges + `, "version": "1",` + nodes + "}", want},
+		{"no nodes", "{" + edges + "}", [][3]string{{"", "uses", ""}, {"", "", ""}}},
+		{"null edges", `{"edges": null,` + nodes + "}", nil},
+		{"byte order mark", "\ufeff{" + nodes + "," + edges + "}", want},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got [][3]string
+			err := streamEdges(context.Background(), strings.NewReader(tc.canvas), func(e Edge, from, to Node) error {
+				got = append(got, [3]string{from.Text + from.File, e.Label, to.Text + to.File})
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("edges %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestStreamEdgesCallbackError checks that an error from the callback
+// stops the stream and comes back unwrapped, for edges read before and
+// after the nodes.
+func TestStreamEdgesCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	edges := `"edges": [{"fromNode": "a", "toNode": "a"}, {"fromNode": "a", "toNode": "a"}, {"fromNode": "a", "toNode": "a"`
+	nodes := `"nodes": [{"id": "a", "type": "text", "text": "A"}]`
+	for _, canvas := range []string{
+		"{" + nodes + "," + edges + "}]}",
+		"{" + edges + "}]," + nodes + "}",
+	} {
+		calls := 0
+		err := streamEdges(context.Background(), strings.NewReader(canvas), func(Edge, Node, Node) error {
+			calls++
+			if calls == 2 {
+				return stop
+			}
+			return nil
+		})
+		if err != stop {
+			t.Errorf("%s: error %v, want %v", canvas, err, stop)
+		}
+		if calls != 2 {
+			t.Errorf("%s: %d calls, want 2", canvas, calls)
+		}
+	}
+}
+
+func TestStreamEdgesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		canvas, want string
+	}{
+		{`[]`, "canvas is not a JSON object"},
+		{`{"nodes": {}}`, "nodes: want an array"},
+		{`{"edges": [{"fromNode": 1}]}`, "edges: json: cannot unmarshal number"},
+		{`{"nodes": [`, "unexpected end of JSON input"},
+	} {
+		err := streamEdges(context.Background(), strings.NewReader(tc.canvas), func(Edge, Node, Node) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want %q", tc.canvas, err, tc.want)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := streamEdges(ctx, strings.NewReader(`{"nodes": []}`), func(Edge, Node, Node) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: error %v, want %v", err, context.Canceled)
+	}
+}
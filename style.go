@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A style file (gen -style) colours and sizes the nodes of a generated
+// canvas, so it reads well in Obsidian straight away. Each line is a rule:
+// conditions, all of which must hold, then "->" and settings. Every
+// matching rule applies, in order, so later rules override earlier ones:
+//
+//	# conditions              settings
+//	*                         -> size=250x60
+//	type=file                 -> color=4
+//	degree>=5                 -> color=1 size=400x120
+//	cluster=2                 -> color=#ff8800
+//	name~/^(TODO|FIXME)\b/    -> color=1
+//
+// Conditions test type, name (= exact, ~ /regexp/), degree, degree_in,
+// degree_out, or cluster (the node's Louvain community, numbered from 1
+// as by `cluster`); numbers compare with = != < <= > >=. Settings are
+// color (an Obsidian colour 1-6 or #rrggbb), width, height, and size=WxH.
+
+type styleRule struct {
+	conds  []styleCond
+	color  string
+	width  float64 // 0: unchanged
+	height float64
+}
+
+type styleCond struct {
+	field, op, value string
+	re               *regexp.Regexp
+}
+
+type styleRules []styleRule
+
+var styleOps = []string{"<=", ">=", "!=", "=", "<", ">", "~"}
+
+func readStyle(path string) (styleRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules styleRules
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		r, err := parseStyleRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, sc.Err()
+}
+
+func parseStyleRule(text string) (styleRule, error) {
+	var r styleRule
+	cond, settings, ok := strings.Cut(text, "->")
+	if !ok {
+		return r, fmt.Errorf("want conditions -> settings")
+	}
+	for rest := strings.TrimSpace(cond); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '*' {
+			rest = rest[1:]
+			continue
+		}
+		var c styleCond
+		var err error
+		if c, rest, err = parseStyleCond(rest); err != nil {
+			return r, err
+		}
+		r.conds = append(r.conds, c)
+	}
+	for _, s := range strings.Fields(settings) {
+		key, v, ok := strings.Cut(s, "=")
+		if !ok {
+			return r, fmt.Errorf("bad setting %q (want key=value)", s)
+		}
+		var err error
+		switch key {
+		case "color", "colour":
+			if !validCanvasColor(v) {
+				return r, fmt.Errorf("bad color %q (want 1-6 or #rrggbb)", v)
+			}
+			r.color = v
+		case "width":
+			r.width, err = styleSize(v)
+		case "height":
+			r.height, err = styleSize(v)
+		case "size":
+			w, h, ok := strings.Cut(v, "x")
+			if !ok {
+				return r, fmt.Errorf("bad size %q (want WxH)", v)
+			}
+			if r.width, err = styleSize(w); err == nil {
+				r.height, err = styleSize(h)
+			}
+		default:
+			return r, fmt.Errorf("unknown setting %q (want color, width, height or size)", key)
+		}
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// parseStyleCond reads one condition from the start of text.
+func parseStyleCond(text string) (styleCond, string, error) {
+	var c styleCond
+	i := strings.IndexAny(text, "<>=!~")
+	if i <= 0 {
+		return c, "", fmt.Errorf("bad condition %q", text)
+	}
+	c.field = text[:i]
+	for _, op := range styleOps {
+		if strings.HasPrefix(text[i:], op) {
+			c.op = op
+			break
+		}
+	}
+	rest := text[i+len(c.op):]
+	switch c.field {
+	case "type", "name":
+		if c.op != "=" && c.op != "!=" && !(c.op == "~" && c.field == "name") {
+			return c, "", fmt.Errorf("%s takes = or != (name also ~/regexp/)", c.field)
+		}
+	case "degree", "degree_in", "degree_out", "cluster":
+		if c.op == "~" {
+			return c, "", fmt.Errorf("%s takes a number comparison", c.field)
+		}
+	default:
+		return c, "", fmt.Errorf("unknown field %q (want type, name, degree, degree_in, degree_out or cluster)", c.field)
+	}
+	if c.op == "~" {
+		end := strings.Index(rest[min(1, len(rest)):], "/") + 1
+		if !strings.HasPrefix(rest, "/") || end <= 0 {
+			return c, "", fmt.Errorf("want name~/regexp/")
+		}
+		re, err := regexp.Compile(rest[1:end])
+		if err != nil {
+			return c, "", err
+		}
+		c.re = re
+		return c, rest[end+1:], nil
+	}
+	end := strings.IndexAny(rest, " \t")
+	if end < 0 {
+		end = len(rest)
+	}
+	c.value = rest[:end]
+	if c.field != "type" && c.field != "name" {
+		if _, err := strconv.ParseFloat(c.value, 64); err != nil {
+			return c, "", fmt.Errorf("%s: want a number, got %q", c.field, c.value)
+		}
+	}
+	return c, rest[end:], nil
+}
+
+func styleSize(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return f, nil
+}
+
+// validCanvasColor accepts the preset colours "1" to "6" and #rrggbb.
+func validCanvasColor(s string) bool {
+	if len(s) == 1 {
+		return s >= "1" && s <= "6"
+	}
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	_, err := strconv.ParseUint(s[1:], 16, 32)
+	return err == nil
+}
+
+// apply sets the colour and size of the nodes each rule matches.
+func (rs styleRules) apply(nodes []Node, edges []Edge) {
+	if len(rs) == 0 {
+		return
+	}
+	g := buildGraph(Canvas{Nodes: nodes, Edges: edges}, false)
+	var cluster []int
+	for _, r := range rs {
+		for _, c := range r.conds {
+			if c.field == "cluster" && cluster == nil {
+				cluster = louvain(weightedAdjacency(g))
+			}
+		}
+	}
+	for i := range nodes {
+		n := g.Nodes[i]
+		value := func(field string) string {
+			switch field {
+			case "type":
+				return n.Type
+			case "name":
+				return n.Name
+			case "degree":
+				return strconv.Itoa(len(g.out[n.ID]) + len(g.in[n.ID]))
+			case "degree_in":
+				return strconv.Itoa(len(g.in[n.ID]))
+			case "degree_out":
+				return strconv.Itoa(len(g.out[n.ID]))
+			}
+			return strconv.Itoa(cluster[i] + 1)
+		}
+		for _, r := range rs {
+			if !r.matches(value) {
+				continue
+			}
+			if r.color != "" {
+				nodes[i].Color = r.color
+			}
+			if r.width > 0 {
+				nodes[i].Width = r.width
+			}
+			if r.height > 0 {
+				nodes[i].Height = r.height
+			}
+		}
+	}
+}
+
+func (r styleRule) matches(value func(field string) string) bool {
+	for _, c := range r.conds {
+		v := value(c.field)
+		if c.re != nil {
+			if !c.re.MatchString(v) {
+				return false
+			}
+			continue
+		}
+		if c.field == "type" || c.field == "name" {
+			if (strings.EqualFold(v, c.value)) != (c.op == "=") {
+				return false
+			}
+			continue
+		}
+		a, _ := strconv.ParseFloat(v, 64)
+		b, _ := strconv.ParseFloat(c.value, 64)
+		ok := map[string]bool{"=": a == b, "!=": a != b, "<": a < b, "<=": a <= b, ">": a > b, ">=": a >= b}[c.op]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package main
+
+import "fmt"
+
+// table is a flat, string-typed view of the graph shared by the tabular
+// exporters (SQL, Parquet, Arrow). A nil cell is NULL.
+type table struct {
+	name    string
+	columns []column
+	rows    [][]*string
+}
+
+type column struct {
+	name     string
+	required bool // never NULL
+	nodeID   bool // holds a node ID
+	primary  bool
+}
+
+func nodeTable(g *graph) table {
+	t := table{
+		name: "nodes",
+		columns: []column{
+			{name: "id", required: true, nodeID: true, primary: true},
+			{name: "type"},
+			{name: "name", required: true},
+			{name: "text"},
+			{name: "file"},
+			{name: "url"},
+		},
+	}
+	for _, a := range g.attrs {
+		t.columns = append(t.columns, column{name: a})
+	}
+	for _, n := range g.Nodes {
+		row := []*string{
+			strPtr(n.ID), nullable(n.Type), strPtr(n.Name), nullable(n.Node.Text), nullable(n.Node.File), nullable(n.Node.URL),
+		}
+		for _, a := range g.attrs {
+			row = append(row, nullable(n.Attrs[a]))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+func edgeTable(g *graph) table {
+	t := table{
+		name: "edges",
+		columns: []column{
+			{name: "source", required: true, nodeID: true},
+			{name: "target", required: true, nodeID: true},
+			{name: "label"},
+		},
+	}
+	for _, a := range g.edgeAttrs {
+		t.columns = append(t.columns, column{name: a})
+	}
+	for _, e := range g.Edges {
+		row := []*string{strPtr(e.From), strPtr(e.To), nullable(e.Label)}
+		for _, a := range g.edgeAttrs {
+			row = append(row, nullable(e.Attrs[a]))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// selectTable picks the table written by single-table formats (-opt parquet.table, arrow.table).
+func selectTable(g *graph, name string) (table, error) {
+	switch name {
+	case "edges", "":
+		return edgeTable(g), nil
+	case "nodes":
+		return nodeTable(g), nil
+	}
+	return table{}, fmt.Errorf("unknown table %q (want edges or nodes)", name)
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func strPtr(s string) *string { return &s }
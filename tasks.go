@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tasks pulls the Markdown checkboxes ("- [ ] write the intro") out of text
+// nodes, for task managers that import CSV or JSON. A task's project is the
+// innermost group its node sits in, and its context is the nodes linked to
+// that node, in either direction.
+
+type canvasTask struct {
+	Canvas  string   `json:"canvas"`
+	NodeID  string   `json:"node"`
+	Line    int      `json:"line"` // within the node's text, from 1
+	Task    string   `json:"task"`
+	Done    bool     `json:"done"`
+	Project string   `json:"project,omitempty"`
+	Context []string `json:"context"` // display names of linked nodes
+}
+
+// checkboxPattern matches a Markdown task item; any status but a space
+// ("[x]", "[-]", "[/]") counts as done.
+var checkboxPattern = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[(.)\]\s+(.*\S)`)
+
+func extractTasks(path string, g *graph) []canvasTask {
+	var tasks []canvasTask
+	for i, n := range g.Nodes {
+		if n.Type != "text" || !strings.Contains(n.Node.Text, "[") {
+			continue
+		}
+		var project string
+		if gi := g.innermostGroup(i); gi >= 0 {
+			project = g.Nodes[gi].Name
+		}
+		var context []string
+		for _, id := range g.neighbors(n.ID, 1, "both") {
+			if name := g.name(id); name != "" {
+				context = append(context, name)
+			}
+		}
+		for line, text := range strings.Split(n.Node.Text, "\n") {
+			m := checkboxPattern.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			tasks = append(tasks, canvasTask{
+				Canvas:  path,
+				NodeID:  n.ID,
+				Line:    line + 1,
+				Task:    m[2],
+				Done:    m[1] != " ",
+				Project: project,
+				Context: append([]string{}, context...),
+			})
+		}
+	}
+	return tasks
+}
+
+func runTasks(args []string) {
+	fs := flag.NewFlagSet("tasks", flag.ExitOnError)
+	var inputs stringsFlag
+	fs.Var(&inputs, "in", "canvas `path or glob` to read (repeatable; positional args work too)")
+	format := fs.String("format", "csv", "output format: csv (canvas;node;line;project;done;task;context) or json")
+	outPath := fs.String("out", "-", "output path (or - for stdout)")
+	open := fs.Bool("open", false, "leave out tasks that are checked off")
+	project := fs.String("project", "", "only tasks in this group, by display name")
+	fs.Parse(args)
+
+	paths, err := expandInputs(append(inputs, fs.Args()...))
+	if err != nil {
+		fatalf("tasks: %v", err)
+	}
+	if len(paths) == 0 {
+		fatalf("tasks: no canvases given (-in)")
+	}
+	tasks := []canvasTask{}
+	for _, path := range paths {
+		c, err := loadCanvas(path)
+		if err != nil {
+			slog.Warn("tasks: " + err.Error())
+			continue
+		}
+		for _, t := range extractTasks(path, buildGraph(c, true)) {
+			if (*open && t.Done) || (*project != "" && t.Project != *project) {
+				continue
+			}
+			tasks = append(tasks, t)
+		}
+	}
+
+	out, closeOut, err := openOut(*outPath)
+	if err != nil {
+		fatalf("tasks: %v", err)
+	}
+	switch *format {
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Comma = ';'
+		w.Write([]string{"canvas", "node", "line", "project", "done", "task", "context"})
+		for _, t := range tasks {
+			w.Write([]string{t.Canvas, t.NodeID, strconv.Itoa(t.Line), t.Project, strconv.FormatBool(t.Done), t.Task, strings.Join(t.Context, ", ")})
+		}
+		w.Flush()
+		err = w.Error()
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(tasks)
+	default:
+		err = fmt.Errorf("unknown -format %q (want csv or json)", *format)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fatalf("tasks: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// tidy cleans up the geometry of a canvas: positions and sizes are snapped
+// to a grid and overlapping cards and groups are pushed apart, with every
+// group moving as one block with its contents and growing to fit them.
+
+type tidyOptions struct {
+	grid    float64 // 0 disables snapping
+	gap     float64 // minimum space between siblings
+	padding float64 // space inside a group around its contents
+}
+
+func snap(v, grid float64) float64 {
+	if grid <= 0 {
+		return v
+	}
+	return math.Round(v/grid) * grid
+}
+
+// tidyNodes rewrites the geometry of nodes in place and reports how many
+// nodes moved or changed size.
+func tidyNodes(nodes []Node, opts tidyOptions) int {
+	before := append([]Node(nil), nodes...)
+	for i := range nodes {
+		n := &nodes[i]
+		n.X, n.Y = snap(n.X, opts.grid), snap(n.Y, opts.grid)
+		if opts.grid > 0 {
+			n.Width = math.Max(snap(n.Width, opts.grid), opts.grid)
+			n.Height = math.Max(snap(n.Height, opts.grid), opts.grid)
+		}
+	}
+
+	// A node belongs to the smallest group containing its centre, so a
+	// card hanging half out of a group is pulled in rather than pushed out.
+	parent := make([]int, len(nodes))
+	for i, n := range nodes {
+		parent[i] = -1
+		cx, cy := n.X+n.Width/2, n.Y+n.Height/2
+		for j, g := range nodes {
+			if j == i || g.Type != "group" || cx < g.X || cy < g.Y || cx > g.X+g.Width || cy > g.Y+g.Height {
+				continue
+			}
+			if n.Type == "group" && g.Width*g.Height <= n.Width*n.Height {
+				continue // a group only nests inside a bigger one
+			}
+			if parent[i] < 0 || g.Width*g.Height < nodes[parent[i]].Width*nodes[parent[i]].Height {
+				parent[i] = j
+			}
+		}
+	}
+	children := map[int][]int{}
+	for i, p := range parent {
+		children[p] = append(children[p], i)
+	}
+	var move func(i int, dx, dy float64)
+	move = func(i int, dx, dy float64) {
+		nodes[i].X += dx
+		nodes[i].Y += dy
+		for _, c := range children[i] {
+			move(c, dx, dy)
+		}
+	}
+
+	// Innermost groups first, then the canvas itself (-1).
+	var groups []int
+	for i, n := range nodes {
+		if n.Type == "group" {
+			groups = append(groups, i)
+		}
+	}
+	depth := func(i int) int {
+		d := 0
+		for ; parent[i] >= 0; i = parent[i] {
+			d++
+		}
+		return d
+	}
+	sort.SliceStable(groups, func(a, b int) bool { return depth(groups[a]) > depth(groups[b]) })
+	for _, g := range append(groups, -1) {
+		kids := children[g]
+		separate(nodes, kids, opts.gap, move)
+		if g < 0 || len(kids) == 0 {
+			continue
+		}
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, k := range kids {
+			minX, minY = math.Min(minX, nodes[k].X), math.Min(minY, nodes[k].Y)
+			maxX, maxY = math.Max(maxX, nodes[k].X+nodes[k].Width), math.Max(maxY, nodes[k].Y+nodes[k].Height)
+		}
+		grp := &nodes[g]
+		// Grow only: a group keeps any empty space it was drawn with.
+		left, top := math.Min(grp.X, minX-opts.padding), math.Min(grp.Y, minY-opts.padding)
+		right := math.Max(grp.X+grp.Width, maxX+opts.padding)
+		bottom := math.Max(grp.Y+grp.Height, maxY+opts.padding)
+		grp.X, grp.Y, grp.Width, grp.Height = left, top, right-left, bottom-top
+	}
+
+	changed := 0
+	for i := range nodes {
+		if nodes[i] != before[i] {
+			changed++
+		}
+	}
+	return changed
+}
+
+// separate pushes the siblings apart until none overlap. Siblings are taken
+// top to bottom, left to right, and only ever move right or down, by
+// whichever is shorter, so earlier ones stay put and the loop settles.
+func separate(nodes []Node, sibs []int, gap float64, move func(i int, dx, dy float64)) {
+	order := append([]int(nil), sibs...)
+	sort.SliceStable(order, func(a, b int) bool {
+		na, nb := nodes[order[a]], nodes[order[b]]
+		if na.Y != nb.Y {
+			return na.Y < nb.Y
+		}
+		return na.X < nb.X
+	})
+	for pass := 0; pass < len(order)*len(order)+1; pass++ {
+		moved := false
+		for a, i := range order {
+			for _, j := range order[a+1:] {
+				ni, nj := nodes[i], nodes[j]
+				if nj.X >= ni.X+ni.Width+gap || ni.X >= nj.X+nj.Width+gap ||
+					nj.Y >= ni.Y+ni.Height+gap || ni.Y >= nj.Y+nj.Height+gap {
+					continue
+				}
+				dx := ni.X + ni.Width + gap - nj.X
+				dy := ni.Y + ni.Height + gap - nj.Y
+				if dx < dy {
+					move(j, dx, 0)
+				} else {
+					move(j, 0, dy)
+				}
+				moved = true
+			}
+		}
+		if !moved {
+			return
+		}
+	}
+}
+
+func runTidy(args []string) {
+	fs := flag.NewFlagSet("tidy", flag.ExitOnError)
+	grid := fs.Float64("grid", 20, "snap positions and sizes to multiples of this (0 disables)")
+	gap := fs.Float64("gap", 20, "minimum space kept between overlapping neighbours")
+	padding := fs.Float64("padding", groupPadding, "space kept inside a group around its contents")
+	out := fs.String("out", "", "write the tidied canvas here instead of over the input")
+	dryRun := fs.Bool("dry-run", false, "report how many nodes would change without writing")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatalf("tidy: want one canvas")
+	}
+	in := fs.Arg(0)
+	doc, err := readCanvasDoc(in)
+	if err != nil {
+		fatalf("tidy: %v", err)
+	}
+	if *out == "" {
+		*out = in
+	}
+	nodes := make([]Node, len(doc.Nodes))
+	for i, o := range doc.Nodes {
+		nodes[i] = o.node()
+	}
+	before := append([]Node(nil), nodes...)
+	changed := tidyNodes(nodes, tidyOptions{grid: *grid, gap: *gap, padding: *padding})
+	fmt.Printf("%s: %d of %d nodes changed\n", in, changed, len(nodes))
+	if *dryRun {
+		return
+	}
+	for i, n := range nodes {
+		if n == before[i] {
+			continue
+		}
+		o := &doc.Nodes[i]
+		o.set("x", n.X)
+		o.set("y", n.Y)
+		o.set("width", n.Width)
+		o.set("height", n.Height)
+	}
+	if err := doc.write(*out); err != nil {
+		fatalf("tidy: %v", err)
+	}
+}
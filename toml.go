@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// -format toml writes the graph document (see graphdoc.go) as TOML, a
+// [[nodes]] table per node and an [[edges]] table per edge, so edge lists
+// can live beside TOML configs; `gen toml` reads it back into a canvas.
+// The reader takes TOML 1.0; dates and times are kept as strings.
+
+func writeTOML(w io.Writer, g *graph, _ exportOptions) error {
+	bw := bufio.NewWriter(w)
+	doc := graphDocument(g)
+	// Plain keys must come before the first table header.
+	sep := ""
+	for _, p := range doc {
+		if !tomlIsTable(p.Value) {
+			bw.WriteString(tomlKey(p.Key) + " = " + tomlValue(p.Value, true) + "\n")
+			sep = "\n"
+		}
+	}
+	for _, p := range doc {
+		switch v := p.Value.(type) {
+		case docMap:
+			fmt.Fprintf(bw, "%s[%s]\n", sep, tomlKey(p.Key))
+			tomlFields(bw, v)
+			sep = "\n"
+		case []any:
+			if !tomlIsTable(v) {
+				continue
+			}
+			for _, item := range v {
+				fmt.Fprintf(bw, "%s[[%s]]\n", sep, tomlKey(p.Key))
+				tomlFields(bw, item.(docMap))
+				sep = "\n"
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// tomlIsTable reports whether v is written as a table, or a list of them,
+// rather than as a value.
+func tomlIsTable(v any) bool {
+	switch v := v.(type) {
+	case docMap:
+		return true
+	case []any:
+		if len(v) == 0 {
+			return false
+		}
+		for _, item := range v {
+			if _, ok := item.(docMap); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func tomlFields(w *bufio.Writer, m docMap) {
+	for _, p := range m {
+		w.WriteString(tomlKey(p.Key) + " = " + tomlValue(p.Value, true) + "\n")
+	}
+}
+
+// tomlValue formats v; nested mappings become inline tables. multiline
+// allows """ strings.
+func tomlValue(v any, multiline bool) string {
+	switch v := v.(type) {
+	case string:
+		if multiline && strings.Contains(v, "\n") {
+			return `"""` + "\n" + tomlEscape(v, true) + `"""`
+		}
+		return `"` + tomlEscape(v, false) + `"`
+	case float64:
+		if v == 0 {
+			return "0" // not -0, which reads back as an integer
+		}
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return strings.TrimPrefix(strings.ToLower(strconv.FormatFloat(v, 'f', -1, 64)), "+")
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case docMap:
+		parts := make([]string, len(v))
+		for i, p := range v {
+			parts[i] = tomlKey(p.Key) + " = " + tomlValue(p.Value, false)
+		}
+		if len(parts) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = tomlValue(item, false)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return `""`
+}
+
+// tomlEscape escapes s for a basic string, or a multi-line one, where
+// newlines and tabs stay as they are and only quotes that would close the
+// string are escaped.
+func tomlEscape(s string, multiline bool) string {
+	var b strings.Builder
+	quotes := 0 // unescaped quotes just written
+	for i, r := range s {
+		switch {
+		case r == '"' && (!multiline || quotes == 2 || i == len(s)-1):
+			b.WriteString(`\"`)
+			quotes = 0
+			continue
+		case r == '"':
+			b.WriteRune(r)
+			quotes++
+			continue
+		case r == '\\':
+			b.WriteString(`\\`)
+		case multiline && (r == '\n' || r == '\t'):
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+		quotes = 0
+	}
+	return b.String()
+}
+
+func tomlKey(k string) string {
+	if k == "" || strings.ContainsFunc(k, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}) {
+		return `"` + tomlEscape(k, false) + `"`
+	}
+	return k
+}
+
+// parseTOML reads a TOML document into docMap, []any and scalar values:
+// strings, float64 for numbers, and strings for booleans and dates.
+func parseTOML(data []byte) (any, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	p := &tomlParser{s: strings.ReplaceAll(text, "\r\n", "\n")}
+	root := &tomlTable{vals: map[string]any{}}
+	if err := p.document(root); err != nil {
+		return nil, fmt.Errorf("line %d: %v", strings.Count(p.s[:p.i], "\n")+1, err)
+	}
+	return root.doc(), nil
+}
+
+// tomlTable is a table being parsed, with its keys in order. Values are
+// *tomlTable, *tomlArray, []any or scalars.
+type tomlTable struct {
+	keys    []string
+	vals    map[string]any
+	defined bool // by a [header] or inline, so it can't be reopened
+}
+
+// tomlArray is an array of tables.
+type tomlArray struct {
+	tables []*tomlTable
+}
+
+func (t *tomlTable) set(key string, v any) {
+	if _, ok := t.vals[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+	t.vals[key] = v
+}
+
+// child returns the table under key for a dotted key or [header], making
+// it if need be; for an array of tables, the last one.
+func (t *tomlTable) child(key string) (*tomlTable, error) {
+	switch v := t.vals[key].(type) {
+	case nil:
+		c := &tomlTable{vals: map[string]any{}}
+		t.set(key, c)
+		return c, nil
+	case *tomlTable:
+		return v, nil
+	case *tomlArray:
+		return v.tables[len(v.tables)-1], nil
+	}
+	return nil, fmt.Errorf("key %q is already a value", key)
+}
+
+func (t *tomlTable) doc() docMap {
+	m := docMap{}
+	for _, k := range t.keys {
+		m.add(k, tomlDoc(t.vals[k]))
+	}
+	return m
+}
+
+func tomlDoc(v any) any {
+	switch v := v.(type) {
+	case *tomlTable:
+		return v.doc()
+	case *tomlArray:
+		items := make([]any, len(v.tables))
+		for i, t := range v.tables {
+			items[i] = t.doc()
+		}
+		return items
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = tomlDoc(item)
+		}
+		return items
+	}
+	return v
+}
+
+type tomlParser struct {
+	s string
+	i int
+}
+
+func (p *tomlParser) eof() bool { return p.i >= len(p.s) }
+
+func (p *tomlParser) rest() string { return p.s[p.i:] }
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		switch {
+		case strings.HasPrefix(p.rest(), "#"):
+			for !p.eof() && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case strings.HasPrefix(p.rest(), "\n"):
+			p.i++
+		default:
+			return
+		}
+	}
+}
+
+// endLine expects only a comment before the end of the line.
+func (p *tomlParser) endLine() error {
+	p.skipSpace()
+	if strings.HasPrefix(p.rest(), "#") {
+		for !p.eof() && p.s[p.i] != '\n' {
+			p.i++
+		}
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.s[p.i] != '\n' {
+		return fmt.Errorf("unexpected %q", p.s[p.i])
+	}
+	p.i++
+	return nil
+}
+
+func (p *tomlParser) document(root *tomlTable) error {
+	cur := root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+		if p.s[p.i] == '[' {
+			array := strings.HasPrefix(p.rest(), "[[")
+			p.i++
+			if array {
+				p.i++
+			}
+			p.skipSpace()
+			keys, err := p.key()
+			if err != nil {
+				return err
+			}
+			p.skipSpace()
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			if !strings.HasPrefix(p.rest(), closing) {
+				return fmt.Errorf("want %s", closing)
+			}
+			p.i += len(closing)
+			if cur, err = root.open(keys, array); err != nil {
+				return err
+			}
+		} else if err := p.keyValue(cur); err != nil {
+			return err
+		}
+		if err := p.endLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// open returns the table a [header] or [[header]] names.
+func (t *tomlTable) open(keys []string, array bool) (*tomlTable, error) {
+	for _, k := range keys[:len(keys)-1] {
+		var err error
+		if t, err = t.child(k); err != nil {
+			return nil, err
+		}
+	}
+	last := keys[len(keys)-1]
+	if array {
+		arr, ok := t.vals[last].(*tomlArray)
+		if !ok && t.vals[last] != nil {
+			return nil, fmt.Errorf("key %q is not an array of tables", last)
+		}
+		if arr == nil {
+			arr = &tomlArray{}
+			t.set(last, arr)
+		}
+		c := &tomlTable{vals: map[string]any{}, defined: true}
+		arr.tables = append(arr.tables, c)
+		return c, nil
+	}
+	if _, ok := t.vals[last].(*tomlArray); ok {
+		return nil, fmt.Errorf("table %q is an array of tables", last)
+	}
+	c, err := t.child(last)
+	if err != nil {
+		return nil, err
+	}
+	if c.defined {
+		return nil, fmt.Errorf("table %q defined twice", strings.Join(keys, "."))
+	}
+	c.defined = true
+	return c, nil
+}
+
+func (p *tomlParser) keyValue(t *tomlTable) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if !strings.HasPrefix(p.rest(), "=") {
+		return errors.New("want key = value")
+	}
+	p.i++
+	p.skipSpace()
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys[:len(keys)-1] {
+		if t, err = t.child(k); err != nil {
+			return err
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, dup := t.vals[last]; dup {
+		return fmt.Errorf("duplicate key %q", last)
+	}
+	t.set(last, v)
+	return nil
+}
+
+// key reads a possibly dotted key.
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, errors.New("want a key")
+		}
+		switch c := p.s[p.i]; {
+		case c == '"' || c == '\'':
+			k, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		default:
+			start := p.i
+			for !p.eof() && tomlBare(p.s[p.i]) {
+				p.i++
+			}
+			if p.i == start {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			keys = append(keys, p.s[start:p.i])
+		}
+		p.skipSpace()
+		if !strings.HasPrefix(p.rest(), ".") {
+			return keys, nil
+		}
+		p.i++
+	}
+}
+
+func tomlBare(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) value() (any, error) {
+	if p.eof() {
+		return nil, errors.New("want a value")
+	}
+	switch p.s[p.i] {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+	start := p.i
+	for !p.eof() && !strings.ContainsRune(",]}#\n", rune(p.s[p.i])) {
+		p.i++
+	}
+	tok := strings.TrimRight(p.s[start:p.i], " \t")
+	p.i = start + len(tok)
+	switch tok {
+	case "true", "false":
+		return tok, nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	}
+	num := strings.ReplaceAll(tok, "_", "")
+	if n, err := strconv.ParseInt(num, 0, 64); err == nil { // 0x, 0o and 0b too
+		return float64(n), nil
+	}
+	if f, err := strconv.ParseFloat(num, 64); err == nil {
+		return f, nil
+	}
+	if tok != "" && tok[0] >= '0' && tok[0] <= '9' && strings.ContainsAny(tok, "-:") {
+		return tok, nil // a date or time
+	}
+	return nil, fmt.Errorf("bad value %q", tok)
+}
+
+func (p *tomlParser) array() ([]any, error) {
+	p.i++ // [
+	items := []any{}
+	for {
+		p.skipBlank()
+		if strings.HasPrefix(p.rest(), "]") {
+			p.i++
+			return items, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		p.skipBlank()
+		switch {
+		case strings.HasPrefix(p.rest(), ","):
+			p.i++
+		case strings.HasPrefix(p.rest(), "]"):
+		default:
+			return nil, errors.New("want , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (*tomlTable, error) {
+	p.i++ // {
+	t := &tomlTable{vals: map[string]any{}, defined: true}
+	p.skipSpace()
+	if strings.HasPrefix(p.rest(), "}") {
+		p.i++
+		return t, nil
+	}
+	for {
+		if err := p.keyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch {
+		case strings.HasPrefix(p.rest(), ","):
+			p.i++
+		case strings.HasPrefix(p.rest(), "}"):
+			p.i++
+			return t, nil
+		default:
+			return nil, errors.New("want , or } in inline table")
+		}
+	}
+}
+
+// str reads a basic, literal or multi-line string.
+func (p *tomlParser) str() (string, error) {
+	q := p.s[p.i : p.i+1]
+	if strings.HasPrefix(p.rest(), q+q+q) {
+		p.i += 3
+		if strings.HasPrefix(p.rest(), "\n") {
+			p.i++ // a newline right after the quotes is trimmed
+		}
+		end := strings.Index(p.rest(), q+q+q)
+		for end >= 0 && q == `"` && tomlEscaped(p.rest()[:end]) {
+			next := strings.Index(p.rest()[end+1:], q+q+q)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end += 1 + next
+		}
+		if end < 0 {
+			return "", errors.New("unterminated multi-line string")
+		}
+		// Up to two more quotes just before the closing ones belong to the
+		// string.
+		for n := 0; n < 2 && strings.HasPrefix(p.rest()[end+3:], q); n++ {
+			end++
+		}
+		body := p.rest()[:end]
+		p.i += end + 3
+		if q == "'" {
+			return body, nil
+		}
+		return tomlUnescape(body, true)
+	}
+	p.i++
+	for j := p.i; j < len(p.s) && p.s[j] != '\n'; j++ {
+		switch {
+		case q == `"` && p.s[j] == '\\':
+			j++
+		case p.s[j] == q[0]:
+			body := p.s[p.i:j]
+			p.i = j + 1
+			if q == "'" {
+				return body, nil
+			}
+			return tomlUnescape(body, false)
+		}
+	}
+	return "", errors.New("unterminated string")
+}
+
+// tomlEscaped reports whether s ends in an odd number of backslashes.
+func tomlEscaped(s string) bool {
+	return (len(s)-len(strings.TrimRight(s, `\`)))%2 == 1
+}
+
+// tomlUnescape decodes a basic string; in multi-line ones a backslash at
+// the end of a line trims the line break and the whitespace after it.
+func tomlUnescape(s string, multiline bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("bad escape")
+		}
+		switch c := s[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte('\x1b')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			digits := 4
+			if c == 'U' {
+				digits = 8
+			}
+			if i+digits >= len(s) {
+				return "", fmt.Errorf("bad escape \\%c", c)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", fmt.Errorf("bad escape \\%s", s[i:i+1+digits])
+			}
+			b.WriteRune(rune(r))
+			i += digits
+		default:
+			rest := strings.TrimLeft(s[i:], " \t")
+			if !multiline || !strings.HasPrefix(rest, "\n") {
+				return "", fmt.Errorf("bad escape \\%c", c)
+			}
+			i = len(s) - len(strings.TrimLeft(rest, " \t\n")) - 1
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want docMap
+	}{
+		{"empty", "", docMap{}},
+		{"comments only", "# nothing\n\n", docMap{}},
+		{"basic string", `a = "x \"y\" \t \u00e9 \U0001F600 \\"` + "\n", docMap{{"a", "x \"y\" \t é 😀 \\"}}},
+		{"literal string", `a = 'C:\path "quoted"'` + "\n", docMap{{"a", `C:\path "quoted"`}}},
+		{"hash in string", "a = \"x # y\" # note\n", docMap{{"a", "x # y"}}},
+		{"multi-line basic", "a = \"\"\"\none\ntwo \\\n    three\"\"\"\n", docMap{{"a", "one\ntwo three"}}},
+		{"multi-line literal", "a = '''\none\\n\n'''\n", docMap{{"a", "one\\n\n"}}},
+		{"quotes before closing", `a = """x"""""` + "\n", docMap{{"a", `x""`}}},
+		{"escaped quotes in multi-line", `a = """x\"""y"""` + "\n", docMap{{"a", `x"""y`}}},
+		{"numbers", "a = 1\nb = -2.5\nc = 1_000\nd = 0x1F\ne = 1e3\n", docMap{{"a", 1.0}, {"b", -2.5}, {"c", 1000.0}, {"d", 31.0}, {"e", 1000.0}}},
+		{"infinity", "a = inf\nb = -inf\n", docMap{{"a", math.Inf(1)}, {"b", math.Inf(-1)}}},
+		{"bools and dates", "a = true\nb = 2026-01-01\nc = 2026-01-01T10:00:00Z\nd = 07:32:00\n", docMap{{"a", "true"}, {"b", "2026-01-01"}, {"c", "2026-01-01T10:00:00Z"}, {"d", "07:32:00"}}},
+		{"quoted key", "\"a.b\" = 1\n'c d' = 2\n", docMap{{"a.b", 1.0}, {"c d", 2.0}}},
+		{"dotted keys", "a.b = 1\na.c = 2\n", docMap{{"a", docMap{{"b", 1.0}, {"c", 2.0}}}}},
+		{"tables", "top = 1\n[a]\nx = 1\n[a.b]\ny = 2\n[c]\n", docMap{{"top", 1.0}, {"a", docMap{{"x", 1.0}, {"b", docMap{{"y", 2.0}}}}}, {"c", docMap{}}}},
+		{"super-table after sub-table", "[a.b]\ny = 2\n[a]\nx = 1\n", docMap{{"a", docMap{{"b", docMap{{"y", 2.0}}}, {"x", 1.0}}}}},
+		{"array of tables", "[[n]]\nid = \"a\"\n[[n]]\nid = \"b\"\n[n.meta]\nk = 1\n", docMap{{"n", []any{docMap{{"id", "a"}}, docMap{{"id", "b"}, {"meta", docMap{{"k", 1.0}}}}}}}},
+		{"arrays", "a = [1, \"x\", [true]]\nb = [\n  1, # one\n  2,\n]\nc = []\n", docMap{{"a", []any{1.0, "x", []any{"true"}}}, {"b", []any{1.0, 2.0}}, {"c", []any{}}}},
+		{"inline tables", "a = {x = 1, y.z = \"w\"}\nb = {}\nc = [{id = 1}, {id = 2}]\n", docMap{{"a", docMap{{"x", 1.0}, {"y", docMap{{"z", "w"}}}}}, {"b", docMap{}}, {"c", []any{docMap{{"id", 1.0}}, docMap{{"id", 2.0}}}}}},
+		{"bom and crlf", "\ufeffa = 1\r\nb = 2\r\n", docMap{{"a", 1.0}, {"b", 2.0}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseTOML([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("parseTOML(%q): %v", tc.in, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseTOML(%q) = %#v, want %#v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"a = 1\na = 2\n", `line 2: duplicate key "a"`},
+		{"[a]\n[a]\n", `line 2: table "a" defined twice`},
+		{"[a.b]\n[a.b]\n", `line 2: table "a.b" defined twice`},
+		{"a = 1\n[a]\n", `line 2: key "a" is already a value`},
+		{"a = 1\na.b = 2\n", `line 2: key "a" is already a value`},
+		{"a = {x = 1}\n[a]\n", `line 2: table "a" defined twice`},
+		{"[[a]]\n[a]\n", `line 2: table "a" is an array of tables`},
+		{"[a]\n[[a]]\n", `line 2: key "a" is not an array of tables`},
+		{"[a\n", "line 1: want ]"},
+		{"[[a]\n", "line 1: want ]]"},
+		{"a\n", "line 1: want key = value"},
+		{"a =\n", `line 1: bad value ""`},
+		{"a = yes\n", `line 1: bad value "yes"`},
+		{"a = 1 2\n", `line 1: bad value "1 2"`},
+		{"a = \"x\" y\n", `line 1: unexpected 'y'`},
+		{"a = [\"x\" \"y\"]\n", "line 1: want , or ] in array"},
+		{"a = {x = \"1\" y = 2}\n", "line 1: want , or } in inline table"},
+		{"a = \"x\n", "line 1: unterminated string"},
+		{"a = \"\"\"x\n", "line 1: unterminated multi-line string"},
+		{`a = "\q"` + "\n", `line 1: bad escape \q`},
+		{`a = "\u12"` + "\n", `line 1: bad escape \u`},
+		{`a = "\uD800"` + "\n", `line 1: bad escape \uD800`},
+		{"\na = 1\nb = \"\\x\"\n", `line 3: bad escape \x`},
+	} {
+		_, err := parseTOML([]byte(tc.in))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parseTOML(%q) error = %v, want %q", tc.in, err, tc.want)
+		}
+	}
+}
+
+// TestTOMLValue checks that strings the writer quotes read back unchanged,
+// on one line and as multi-line strings.
+func TestTOMLValue(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"text",
+		`quote " and backslash \`,
+		"tab\tand bell\a",
+		"é and 😀",
+		"one\ntwo",
+		"ends with a quote\"",
+		`three """ quotes`,
+		"trailing newline\n",
+		"trailing backslash\\",
+	} {
+		for _, multiline := range []bool{false, true} {
+			v := tomlValue(s, multiline)
+			got, err := parseTOML([]byte("k = " + v + "\n"))
+			if err != nil {
+				t.Errorf("tomlValue(%q, %v) = %s, which doesn't parse: %v", s, multiline, v, err)
+				continue
+			}
+			if want := (docMap{{"k", s}}); !reflect.DeepEqual(got, want) {
+				t.Errorf("tomlValue(%q, %v) = %s, which reads back as %#v", s, multiline, v, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// Edge label translation, for canvases written in several languages. Each
+// label's language is guessed from its script and, for Latin script, from
+// accented letters and common short words; label_lang records it as an
+// ISO 639-1 code ("und" when there is nothing to go on). A -translate
+// dictionary holds "label -> English" lines like an alias file, matched
+// case-insensitively against whole labels:
+//
+//	hängt ab von -> depends on
+//	utilise -> uses
+//
+// Labels the dictionary misses and that don't look English can go to a
+// -translate-cmd: it reads "lang<TAB>label" lines on stdin, each distinct
+// label once, and writes one translation per line (an empty line keeps the
+// label). A translated edge keeps its old label in label_original.
+
+// labelLanguages are the Latin-script languages told apart by their marks
+// and short words, in tie-break order.
+var labelLanguages = []struct {
+	code  string
+	marks string
+	words []string
+}{
+	{"en", "", []string{"the", "of", "and", "to", "is", "in", "for", "on", "with", "by", "from", "uses", "calls", "depends", "has", "needs"}},
+	{"de", "äöüß", []string{"der", "die", "das", "und", "von", "mit", "ist", "nicht", "zu", "auf", "für", "hat", "ab"}},
+	{"fr", "éèêàçœù", []string{"le", "la", "les", "des", "et", "est", "une", "pour", "avec", "dans", "du", "au"}},
+	{"es", "ñáíóú¿¡", []string{"el", "los", "las", "del", "y", "es", "una", "para", "con", "por", "usa"}},
+	{"pt", "ãõâç", []string{"o", "os", "do", "da", "dos", "e", "um", "uma", "para", "com", "não", "usa"}},
+	{"it", "ìò", []string{"il", "gli", "della", "di", "che", "è", "per", "con", "usa", "dal"}},
+	{"nl", "", []string{"het", "een", "van", "en", "met", "voor", "niet", "naar", "gebruikt"}},
+	{"pl", "ąęłńśźż", []string{"i", "w", "z", "na", "się", "nie", "jest", "do", "od"}},
+}
+
+// labelScripts name the language of labels in other scripts.
+var labelScripts = []struct {
+	code  string
+	table *unicode.RangeTable
+}{
+	{"ja", unicode.Hiragana}, {"ja", unicode.Katakana}, {"zh", unicode.Han},
+	{"ko", unicode.Hangul}, {"ru", unicode.Cyrillic}, {"el", unicode.Greek},
+	{"ar", unicode.Arabic}, {"he", unicode.Hebrew}, {"hi", unicode.Devanagari},
+	{"th", unicode.Thai},
+}
+
+// detectLanguage guesses the language of a short label.
+func detectLanguage(s string) string {
+	counts := map[string]int{}
+	letters, latin := 0, 0
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, sc := range labelScripts {
+			if unicode.Is(sc.table, r) {
+				counts[sc.code]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "und"
+	}
+	if latin*2 < letters {
+		if counts["ja"] > 0 {
+			return "ja" // kanji are Han too
+		}
+		best := "und"
+		for _, sc := range labelScripts {
+			if counts[sc.code] > counts[best] {
+				best = sc.code
+			}
+		}
+		if best == "ru" && strings.ContainsAny(strings.ToLower(s), "іїєґ") {
+			return "uk"
+		}
+		return best
+	}
+
+	lower := strings.ToLower(s)
+	words := strings.FieldsFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	best, bestScore := "", 0
+	for _, l := range labelLanguages {
+		score := 0
+		for _, r := range l.marks {
+			score += 2 * strings.Count(lower, string(r))
+		}
+		if l.code == "nl" {
+			score += strings.Count(lower, "ij") // a digraph rather than a mark
+		}
+		for _, w := range words {
+			for _, lw := range l.words {
+				if w == lw {
+					score++
+				}
+			}
+		}
+		if score > bestScore {
+			best, bestScore = l.code, score
+		}
+	}
+	if best != "" {
+		return best
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII && unicode.IsLetter(r) {
+			return "und"
+		}
+	}
+	return "en" // plain ASCII words with nothing else to go on
+}
+
+// readTranslations parses a dictionary file into a map from lower-cased
+// label to its translation.
+func readTranslations(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dict := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		from, to, ok := strings.Cut(text, "\t")
+		if !ok {
+			from, to, ok = strings.Cut(text, " -> ")
+		}
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("%s:%d: want \"label -> translation\"", path, line)
+		}
+		dict[strings.ToLower(from)] = to
+	}
+	return dict, sc.Err()
+}
+
+// detectLabelLanguages records each labelled edge's language in label_lang.
+func detectLabelLanguages(g *graph) {
+	for i, e := range g.Edges {
+		if e.Label != "" {
+			g.setEdgeAttr(i, "label_lang", detectLanguage(e.Label))
+		}
+	}
+}
+
+// translateLabels replaces labels from dict, then sends the remaining
+// non-English ones through command, if set. It returns how many edges were
+// relabelled.
+func translateLabels(ctx context.Context, g *graph, dict map[string]string, command string) (int, error) {
+	translated := 0
+	relabel := func(i int, to string) {
+		if to == "" || to == g.Edges[i].Label {
+			return
+		}
+		g.setEdgeAttr(i, "label_original", g.Edges[i].Label)
+		g.Edges[i].Label = to
+		translated++
+	}
+	var pending []int
+	for i, e := range g.Edges {
+		if e.Label == "" {
+			continue
+		}
+		if to, ok := dict[strings.ToLower(strings.TrimSpace(e.Label))]; ok {
+			relabel(i, to)
+		} else if detectLanguage(e.Label) != "en" {
+			pending = append(pending, i)
+		}
+	}
+	if command == "" || len(pending) == 0 {
+		return translated, nil
+	}
+
+	var labels []string
+	index := map[string]int{}
+	var in bytes.Buffer
+	for _, i := range pending {
+		l := g.Edges[i].Label
+		if _, ok := index[l]; !ok {
+			index[l] = len(labels)
+			labels = append(labels, l)
+			fmt.Fprintf(&in, "%s\t%s\n", detectLanguage(l), l)
+		}
+	}
+	args := strings.Fields(command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = &in
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return translated, fmt.Errorf("%s: %v", args[0], err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != len(labels) {
+		return translated, fmt.Errorf("%s: sent %d labels, got %d lines back", args[0], len(labels), len(lines))
+	}
+	for _, i := range pending {
+		relabel(i, strings.TrimSpace(strings.TrimSuffix(lines[index[g.Edges[i].Label]], "\r")))
+	}
+	return translated, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import "fmt"
+
+// issue is one problem found in a canvas.
+type issue struct {
+	Severity    string   `json:"severity"` // "error" or "warning"
+	Code        string   `json:"code"`     // stable identifier, e.g. "dangling-edge"
+	Message     string   `json:"message"`
+	Path        string   `json:"path"` // location in the canvas JSON, e.g. "edges[3]"
+	NodeID      string   `json:"node,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"` // candidate fixes, best first
+}
+
+// validateCanvas checks structural integrity: IDs, node types and edge
+// endpoints.
+func validateCanvas(c Canvas) []issue {
+	var issues []issue
+	add := func(sev, code, path, nodeID, format string, args ...any) {
+		issues = append(issues, issue{Severity: sev, Code: code, Message: fmt.Sprintf(format, args...), Path: path, NodeID: nodeID})
+	}
+
+	seen := make(map[string]bool, len(c.Nodes))
+	for i, n := range c.Nodes {
+		path := fmt.Sprintf("nodes[%d]", i)
+		switch {
+		case n.ID == "":
+			add("error", "missing-id", path, "", "node has no id")
+		case seen[n.ID]:
+			add("error", "duplicate-id", path, n.ID, "duplicate node id %q", n.ID)
+		}
+		seen[n.ID] = true
+
+		switch c := n.Content().(type) {
+		case nil:
+			add("warning", "unknown-type", path, n.ID, "unknown node type %q", n.Type)
+		case FileNode:
+			if c.File == "" {
+				add("error", "missing-file", path, n.ID, "file node has no file")
+			}
+		case LinkNode:
+			if c.URL == "" {
+				add("error", "missing-url", path, n.ID, "link node has no url")
+			}
+		}
+	}
+	for i, e := range c.Edges {
+		path := fmt.Sprintf("edges[%d]", i)
+		if !seen[e.FromNode] {
+			add("error", "dangling-edge", path, e.FromNode, "edge starts at unknown node %q", e.FromNode)
+		}
+		if !seen[e.ToNode] {
+			add("error", "dangling-edge", path, e.ToNode, "edge ends at unknown node %q", e.ToNode)
+		}
+	}
+	return issues
+}
+
+func hasErrors(issues []issue) bool {
+	for _, is := range issues {
+		if is.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
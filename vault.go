@@ -0,0 +1,290 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Canvas file nodes hold vault-relative paths; with -vault set they are
+// resolved against the vault so the notes' frontmatter can be joined in.
+
+// frontmatter is the parsed YAML header of a note. Only the subset notes
+// actually use is understood: "key: scalar", inline lists "key: [a, b]" and
+// block lists of "- item" lines. Each value is kept as a list of strings.
+type frontmatter map[string][]string
+
+// readNote returns the frontmatter of the note at path (nil if it has
+// none) and the body that follows it.
+func readNote(path string) (frontmatter, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	fm, body := parseFrontmatter(string(data))
+	return fm, body, nil
+}
+
+func parseFrontmatter(note string) (frontmatter, string) {
+	note = strings.TrimPrefix(note, "\uFEFF")
+	first, rest, _ := strings.Cut(note, "\n")
+	if strings.TrimSpace(first) != "---" {
+		return nil, note
+	}
+	fm := frontmatter{}
+	key := ""
+	for rest != "" {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		line = strings.TrimRight(line, " \t\r")
+		if line == "---" || line == "..." {
+			return fm, rest
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && key != "" {
+			fm[key] = append(fm[key], yamlScalar(item))
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || line[0] == ' ' || line[0] == '\t' {
+			continue // nested maps are not supported
+		}
+		key = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		switch {
+		case v == "":
+			fm[key] = nil
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			var items []string
+			for _, item := range strings.Split(v[1:len(v)-1], ",") {
+				if item = yamlScalar(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			fm[key] = items
+		default:
+			fm[key] = []string{yamlScalar(v)}
+		}
+	}
+	return nil, note // unterminated: not frontmatter
+}
+
+// yamlScalar strips quotes and trailing comments from a plain scalar.
+func yamlScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+// vaultFile resolves a file node's path inside the vault.
+func vaultFile(vault, file string) string {
+	if isAbsFile(file) {
+		return filepath.FromSlash(slashPath(file))
+	}
+	return filepath.Join(vault, filepath.FromSlash(slashPath(file)))
+}
+
+// joinFrontmatter copies the selected frontmatter keys of every markdown
+// file node onto the node as attributes; list values are joined with ",".
+// Notes that are missing from the vault are skipped.
+func joinFrontmatter(g *graph, vault string, keys []string) error {
+	for _, k := range keys {
+		if !slices.Contains(g.attrs, k) {
+			g.attrs = append(g.attrs, k)
+		}
+	}
+	for i, n := range g.Nodes {
+		if n.Type != "file" || !strings.EqualFold(path.Ext(slashPath(n.Node.File)), ".md") {
+			continue
+		}
+		fm, _, err := readNote(vaultFile(vault, n.Node.File))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if vals, ok := fm[k]; ok {
+				g.setAttr(i, k, strings.Join(vals, ","))
+			}
+		}
+	}
+	return nil
+}
+
+// noteTags returns the tags of a note: the frontmatter "tags" (or "tag")
+// list plus inline #tags in the body outside code, without the leading #.
+func noteTags(fm frontmatter, body string) []string {
+	var tags []string
+	add := func(t string) {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "#")
+		if t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	for _, key := range []string{"tags", "tag"} {
+		for _, v := range fm[key] {
+			for _, t := range strings.Fields(strings.ReplaceAll(v, ",", " ")) {
+				add(t)
+			}
+		}
+	}
+	fenced := false
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		inCode := false
+		for i, r := range line {
+			switch {
+			case r == '`':
+				inCode = !inCode
+			case r == '#' && !inCode && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+				if t := inlineTag(line[i+1:]); t != "" {
+					add(t)
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// inlineTag returns the tag at the start of s. Obsidian tags are letters,
+// digits, _, - and / for nesting, and must not be purely numeric.
+func inlineTag(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '/')
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	tag := s[:end]
+	if strings.IndexFunc(tag, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+		return ""
+	}
+	return tag
+}
+
+// addTagEdges links every pair of markdown file nodes whose notes share a
+// tag, one edge per pair and tag labelled "#tag", in canvas node order.
+func addTagEdges(g *graph, vault string) error {
+	var order []string
+	members := map[string][]string{}
+	for _, n := range g.Nodes {
+		if n.Type != "file" || !strings.EqualFold(path.Ext(slashPath(n.Node.File)), ".md") {
+			continue
+		}
+		fm, body, err := readNote(vaultFile(vault, n.Node.File))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, t := range noteTags(fm, body) {
+			if _, ok := members[t]; !ok {
+				order = append(order, t)
+			}
+			members[t] = append(members[t], n.ID)
+		}
+	}
+	for _, t := range order {
+		ids := members[t]
+		for i := range ids {
+			for _, other := range ids[i+1:] {
+				g.Edges = append(g.Edges, graphEdge{From: ids[i], To: other, Label: "#" + t})
+			}
+		}
+	}
+	g.index()
+	return nil
+}
+
+// obsidianURI returns an obsidian://open link to file in the named vault.
+func obsidianURI(vaultName, file string) string {
+	return "obsidian://open?vault=" + uriComponent(vaultName) + "&file=" + uriComponent(file)
+}
+
+// uriComponent escapes a URI query component with spaces as %20, the way
+// Obsidian writes its own links.
+func uriComponent(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// addObsidianURIs sets obsidian_uri on file nodes and, when the canvas lies
+// inside the vault, canvas_uri on every node (Obsidian has no per-node
+// anchors, so it opens the canvas).
+func addObsidianURIs(g *graph, vault, vaultName, canvasPath string) {
+	if vaultName == "" {
+		abs, _ := filepath.Abs(vault)
+		vaultName = filepath.Base(abs)
+	}
+	canvasURI := ""
+	if canvasPath != "" && canvasPath != "-" && canvasPath != clipboardPath {
+		absVault, err1 := filepath.Abs(vault)
+		absCanvas, err2 := filepath.Abs(canvasPath)
+		if rel, err := filepath.Rel(absVault, absCanvas); err1 == nil && err2 == nil && err == nil && !strings.HasPrefix(rel, "..") {
+			canvasURI = obsidianURI(vaultName, filepath.ToSlash(rel))
+		}
+	}
+	for i, n := range g.Nodes {
+		if n.Type == "file" && n.Node.File != "" {
+			g.setAttr(i, "obsidian_uri", obsidianURI(vaultName, slashPath(n.Node.File)))
+		}
+	}
+	if canvasURI != "" {
+		for i := range g.Nodes {
+			g.setAttr(i, "canvas_uri", canvasURI)
+		}
+	}
+}
+
+// filterRecent keeps the edges with at least one endpoint note modified at
+// or after cutoff, and the nodes those edges touch plus any recent notes
+// left without edges. Only file nodes have a modification time; a missing
+// note counts as stale.
+func filterRecent(g *graph, vault string, cutoff time.Time) {
+	recent := map[string]bool{}
+	for _, n := range g.Nodes {
+		if n.Type != "file" || n.Node.File == "" {
+			continue
+		}
+		if fi, err := os.Stat(vaultFile(vault, n.Node.File)); err == nil && !fi.ModTime().Before(cutoff) {
+			recent[n.ID] = true
+		}
+	}
+	keep := map[string]bool{}
+	var edges []graphEdge
+	for _, e := range g.Edges {
+		if recent[e.From] || recent[e.To] {
+			edges = append(edges, e)
+			keep[e.From], keep[e.To] = true, true
+		}
+	}
+	var nodes []graphNode
+	for _, n := range g.Nodes {
+		if keep[n.ID] || recent[n.ID] {
+			nodes = append(nodes, n)
+		}
+	}
+	g.Nodes, g.Edges = nodes, edges
+	g.index()
+}
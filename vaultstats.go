@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// findCanvases returns the vault-relative paths (with / separators) of all
+// .canvas files under vault, skipping dot directories such as .obsidian
+// and .trash.
+func findCanvases(vault string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(vault, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != vault && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".canvas") {
+			rel, err := filepath.Rel(vault, p)
+			if err != nil {
+				return err
+			}
+			found = append(found, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(found)
+	return found, err
+}
+
+type noteUsage struct {
+	Note     string   `json:"note"`
+	Canvases []string `json:"canvases"`
+	Nodes    int      `json:"nodes"`  // file nodes pointing at the note
+	Degree   int      `json:"degree"` // edges touching those nodes
+}
+
+type vaultStats struct {
+	Canvases  int            `json:"canvases"`
+	Failed    []string       `json:"failed,omitempty"` // canvases that could not be parsed
+	Nodes     int            `json:"nodes"`
+	Edges     int            `json:"edges"`
+	NodeTypes map[string]int `json:"node_types"`
+	Notes     []noteUsage    `json:"notes"` // most linked first
+	PerCanvas []canvasCount  `json:"per_canvas"`
+}
+
+type canvasCount struct {
+	Canvas string `json:"canvas"`
+	Nodes  int    `json:"nodes"`
+	Edges  int    `json:"edges"`
+}
+
+// scanVaultCanvases loads every canvas in the vault, reporting the ones
+// that fail to parse through bad rather than stopping.
+func scanVaultCanvases(vault string, bad func(path string, err error)) (map[string]*graph, []string, error) {
+	paths, err := findCanvases(vault)
+	if err != nil {
+		return nil, nil, err
+	}
+	graphs := map[string]*graph{}
+	var ok []string
+	for _, p := range paths {
+		c, err := loadCanvas(filepath.Join(vault, filepath.FromSlash(p)))
+		if err != nil {
+			bad(p, err)
+			continue
+		}
+		graphs[p] = buildGraph(c, true)
+		ok = append(ok, p)
+	}
+	return graphs, ok, nil
+}
+
+func computeVaultStats(vault string) (vaultStats, error) {
+	st := vaultStats{NodeTypes: map[string]int{}}
+	graphs, paths, err := scanVaultCanvases(vault, func(p string, _ error) { st.Failed = append(st.Failed, p) })
+	if err != nil {
+		return st, err
+	}
+	usage := map[string]*noteUsage{}
+	for _, p := range paths {
+		g := graphs[p]
+		st.Canvases++
+		st.Nodes += len(g.Nodes)
+		st.Edges += len(g.Edges)
+		st.PerCanvas = append(st.PerCanvas, canvasCount{Canvas: p, Nodes: len(g.Nodes), Edges: len(g.Edges)})
+		for _, n := range g.Nodes {
+			st.NodeTypes[n.Type]++
+			if n.Type != "file" || n.Node.File == "" {
+				continue
+			}
+			note := slashPath(n.Node.File)
+			u := usage[note]
+			if u == nil {
+				u = &noteUsage{Note: note}
+				usage[note] = u
+			}
+			if len(u.Canvases) == 0 || u.Canvases[len(u.Canvases)-1] != p {
+				u.Canvases = append(u.Canvases, p)
+			}
+			u.Nodes++
+			u.Degree += len(g.out[n.ID]) + len(g.in[n.ID])
+		}
+	}
+	for _, u := range usage {
+		st.Notes = append(st.Notes, *u)
+	}
+	sort.Slice(st.Notes, func(i, j int) bool {
+		a, b := st.Notes[i], st.Notes[j]
+		if a.Degree != b.Degree {
+			return a.Degree > b.Degree
+		}
+		if len(a.Canvases) != len(b.Canvases) {
+			return len(a.Canvases) > len(b.Canvases)
+		}
+		return a.Note < b.Note
+	})
+	return st, nil
+}
+
+func runVaultStats(args []string) {
+	fset := flag.NewFlagSet("vault-stats", flag.ExitOnError)
+	vault := fset.String("vault", ".", "vault directory to scan for .canvas files")
+	top := fset.Int("top", 10, "how many of the most linked notes to list (0 for all)")
+	asJSON := fset.Bool("json", false, "print the full report as JSON")
+	fset.Parse(args)
+	if fset.NArg() > 0 {
+		*vault = fset.Arg(0)
+	}
+	st, err := computeVaultStats(*vault)
+	if err != nil {
+		fatalf("vault-stats: %v", err)
+	}
+	for _, p := range st.Failed {
+		slog.Warn("vault-stats: skipped unreadable canvas", "path", p)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(st); err != nil {
+			fatalf("vault-stats: %v", err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "canvases\t%d\n", st.Canvases)
+	fmt.Fprintf(w, "nodes\t%d\n", st.Nodes)
+	fmt.Fprintf(w, "edges\t%d\n", st.Edges)
+	for _, t := range sortedKeys(st.NodeTypes) {
+		fmt.Fprintf(w, "  %s\t%d\n", t, st.NodeTypes[t])
+	}
+	fmt.Fprintf(w, "notes referenced\t%d\n", len(st.Notes))
+	notes := st.Notes
+	if *top > 0 && len(notes) > *top {
+		notes = notes[:*top]
+	}
+	if len(notes) > 0 {
+		fmt.Fprintln(w, "\nmost linked notes\tdegree\tcanvases")
+		for _, u := range notes {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", u.Note, u.Degree, strings.Join(u.Canvases, ", "))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Release builds stamp the version, commit and date with
+//
+//	go build -ldflags "-X main.version=v1.4.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Otherwise they come from the Go build info: the module version for go
+// install, and the VCS revision and commit time for a build in a checkout.
+var version, commit, buildDate string
+
+type buildDetails struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	Modified  bool     `json:"modified,omitempty"` // built from a checkout with local changes
+	BuildDate string   `json:"build_date,omitempty"`
+	Go        string   `json:"go"`
+	Platform  string   `json:"platform"`
+	Formats   []string `json:"formats"`
+	Plugins   []string `json:"plugins,omitempty"` // export plugins installed
+}
+
+func readBuildDetails() buildDetails {
+	d := buildDetails{
+		Version: version, Commit: commit, BuildDate: buildDate,
+		Go: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH,
+		Formats: exporterNames(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v := info.Main.Version; d.Version == "" && v != "" && v != "(devel)" {
+			d.Version = v
+		}
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && d.Commit == "":
+				d.Commit = s.Value
+			case s.Key == "vcs.time" && d.BuildDate == "":
+				d.BuildDate = s.Value
+			case s.Key == "vcs.modified":
+				d.Modified = s.Value == "true"
+			}
+		}
+	}
+	if d.Version == "" {
+		d.Version = "devel"
+	}
+	return d
+}
+
+// toolVersion is the version recorded in provenance and cache keys: the
+// release version, or the commit of an unreleased build.
+func toolVersion() string {
+	d := readBuildDetails()
+	if d.Version == "devel" && d.Commit != "" {
+		return d.Commit
+	}
+	return d.Version
+}
+
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the build details as JSON")
+	pluginDir := fs.String("plugins", defaultPluginDir(), "directory to list export plugins from")
+	fs.Parse(args)
+
+	d := readBuildDetails()
+	if found, err := listPlugins(*pluginDir); err == nil {
+		d.Plugins = found["export"]
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(d)
+		return
+	}
+	fmt.Printf("canvas_tool %s\n", d.Version)
+	if d.Commit != "" {
+		modified := ""
+		if d.Modified {
+			modified = " (modified)"
+		}
+		fmt.Printf("commit:   %s%s\n", d.Commit, modified)
+	}
+	if d.BuildDate != "" {
+		fmt.Printf("built:    %s\n", d.BuildDate)
+	}
+	fmt.Printf("go:       %s %s\n", d.Go, d.Platform)
+	fmt.Printf("formats:  %s\n", strings.Join(d.Formats, ", "))
+	if len(d.Plugins) > 0 {
+		fmt.Printf("plugins:  %s\n", strings.Join(d.Plugins, ", "))
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The daemon can tell other systems when a conversion finishes. Webhooks
+// are listed next to the jobs in its config:
+//
+//	{"jobs": [...], "webhooks": [{
+//	  "url": "https://ci.example.com/hooks/graph",
+//	  "on": ["success", "failure"],
+//	  "jobs": ["kb"],
+//	  "headers": {"Authorization": "Bearer ..."},
+//	  "secret": "..."
+//	}]}
+//
+// on defaults to both events and jobs to every job. Each conversion POSTs
+// a JSON webhookEvent; with a secret the body's HMAC-SHA256 is sent as
+// "X-Canvas-Tool-Signature: sha256=<hex>". Deliveries that fail with a
+// network error or a 5xx status are retried twice; a webhook that still
+// fails is logged and doesn't fail the job.
+
+type webhook struct {
+	URL     string            `json:"url"`
+	On      []string          `json:"on"`
+	Jobs    []string          `json:"jobs"`
+	Headers map[string]string `json:"headers"`
+	Secret  string            `json:"secret"`
+}
+
+// webhookEvent is the body of a webhook delivery. Stats describe the input
+// canvas as read, before the job's flags change the graph.
+type webhookEvent struct {
+	Event      string      `json:"event"` // success or failure
+	Job        string      `json:"job"`
+	Canvas     string      `json:"canvas"`
+	Output     string      `json:"output"`
+	Format     string      `json:"format"`
+	Time       string      `json:"time"`
+	DurationMS int64       `json:"duration_ms"`
+	Error      string      `json:"error,omitempty"`
+	Stats      *graphStats `json:"stats,omitempty"`
+}
+
+var webhookEvents = []string{"success", "failure"}
+
+const webhookTimeout = 10 * time.Second
+
+func (h *webhook) check() error {
+	if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+		return fmt.Errorf("webhook url %q: want http:// or https://", h.URL)
+	}
+	if len(h.On) == 0 {
+		h.On = webhookEvents
+	}
+	for _, on := range h.On {
+		if !slices.Contains(webhookEvents, on) {
+			return fmt.Errorf("webhook %s: unknown event %q (want success or failure)", h.URL, on)
+		}
+	}
+	return nil
+}
+
+func (h *webhook) wants(ev *webhookEvent) bool {
+	return slices.Contains(h.On, ev.Event) && (len(h.Jobs) == 0 || slices.Contains(h.Jobs, ev.Job))
+}
+
+// deliver posts ev to h, retrying transient failures.
+func (h *webhook) deliver(ctx context.Context, ev *webhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var last error
+	for attempt := range 3 {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		retry, err := h.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		last = err
+		if !retry {
+			break
+		}
+	}
+	return last
+}
+
+// post makes one delivery, reporting whether a failure is worth retrying.
+func (h *webhook) post(ctx context.Context, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "canvas_tool/"+toolVersion())
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Canvas-Tool-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode >= 500, fmt.Errorf("POST %s: %s: %s", h.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return false, nil
+}
+
+// notify sends ev to every webhook that wants it.
+func notify(hooks []webhook, ev *webhookEvent) {
+	for i := range hooks {
+		h := &hooks[i]
+		if !h.wants(ev) {
+			continue
+		}
+		if err := h.deliver(context.Background(), ev); err != nil {
+			slog.Warn("webhook failed", "job", ev.Job, "url", h.URL, "err", err)
+		}
+	}
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// -format yaml writes the graph document (see graphdoc.go) as YAML meant
+// for editing by hand; `gen yaml` reads it back into a canvas. The reader
+// handles the block-style subset the writer produces and people tend to
+// type: mappings, lists, plain and quoted scalars, | and > blocks,
+// comments, and one-line flow collections of scalars. Anchors, aliases
+// and tags are not supported.
+
+func writeYAML(w io.Writer, g *graph, _ exportOptions) error {
+	bw := bufio.NewWriter(w)
+	yamlMap(bw, graphDocument(g), 0, false)
+	return bw.Flush()
+}
+
+// yamlMap writes m with its keys at indent. inItem means the first key
+// follows a "- " already written.
+func yamlMap(w *bufio.Writer, m docMap, indent int, inItem bool) {
+	pad := strings.Repeat(" ", indent)
+	for i, p := range m {
+		if i > 0 || !inItem {
+			w.WriteString(pad)
+		}
+		w.WriteString(yamlQuote(p.Key) + ":")
+		yamlValue(w, p.Value, indent)
+	}
+}
+
+// yamlValue writes v after "key:", nesting blocks below indent.
+func yamlValue(w *bufio.Writer, v any, indent int) {
+	pad := strings.Repeat(" ", indent+2)
+	switch v := v.(type) {
+	case docMap:
+		if len(v) == 0 {
+			w.WriteString(" {}\n")
+			return
+		}
+		w.WriteString("\n")
+		yamlMap(w, v, indent+2, false)
+	case []any:
+		if len(v) == 0 {
+			w.WriteString(" []\n")
+			return
+		}
+		w.WriteString("\n")
+		for _, item := range v {
+			w.WriteString(pad + "- ")
+			if m, ok := item.(docMap); ok && len(m) > 0 {
+				yamlMap(w, m, indent+4, true)
+				continue
+			}
+			w.WriteString(strings.TrimPrefix(yamlInline(item), " ") + "\n")
+		}
+	case string:
+		if header, ok := yamlBlockHeader(v); ok {
+			w.WriteString(" " + header + "\n")
+			for _, line := range strings.Split(strings.TrimSuffix(v, "\n"), "\n") {
+				if line != "" {
+					w.WriteString(pad + line)
+				}
+				w.WriteString("\n")
+			}
+			return
+		}
+		w.WriteString(yamlInline(v) + "\n")
+	default:
+		w.WriteString(yamlInline(v) + "\n")
+	}
+}
+
+// yamlInline formats a scalar, or an empty collection, for the rest of a
+// line, with its leading space.
+func yamlInline(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return " " + yamlQuote(v)
+	case float64:
+		return " " + strconv.FormatFloat(v, 'f', -1, 64)
+	case docMap:
+		return " {}"
+	case []any:
+		return " []"
+	}
+	return " " + yamlQuote(fmt.Sprint(v))
+}
+
+// yamlBlockHeader reports whether s is better written as a literal block,
+// and with which header: "|-" without a final newline, "|" with one.
+func yamlBlockHeader(s string) (string, bool) {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") || strings.HasSuffix(s, "\n\n") {
+		return "", false
+	}
+	if strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") {
+		return "", false // would need an indentation indicator
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" && strings.TrimSpace(line) == "" || strings.ContainsFunc(line, yamlUnsafeRune) {
+			return "", false
+		}
+	}
+	if strings.HasSuffix(s, "\n") {
+		return "|", true
+	}
+	return "|-", true
+}
+
+func yamlUnsafeRune(r rune) bool {
+	return r != '\t' && !unicode.IsPrint(r)
+}
+
+// yamlQuote quotes s unless it reads back as the same string when plain.
+func yamlQuote(s string) string {
+	if yamlPlain(s) {
+		return s
+	}
+	return strconv.Quote(s) // Go escapes are a subset of YAML's
+}
+
+// yamlResolved matches plain scalars YAML 1.1 parsers (PyYAML, for one)
+// resolve to something other than a string: timestamps and sexagesimal
+// numbers such as 1:30.
+var yamlResolved = regexp.MustCompile(`^(?:\d{4}-\d\d?-\d\d?(?:(?:[Tt]|[ \t]+)\d\d?:\d\d:\d\d(?:\.\d*)?(?:[ \t]*Z|[-+]\d\d?(?::\d\d)?)?)?|[-+]?[0-9][0-9_]*(?::[0-5]?[0-9])+(?:\.[0-9_]*)?)$`)
+
+func yamlPlain(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") || strings.ContainsFunc(s, func(r rune) bool { return r == '\t' || !unicode.IsPrint(r) }) {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~", ".inf", "-.inf", "+.inf", ".nan":
+		return false
+	}
+	if yamlResolved.MatchString(s) {
+		return false
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// parseYAML reads a YAML document into docMap, []any, string and nil
+// values. Scalars stay strings; the caller converts numbers.
+func parseYAML(data []byte) (any, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	// A final newline ends the last line rather than starting an empty one,
+	// which a |+ block would otherwise keep.
+	p := &yamlParser{lines: strings.Split(strings.TrimSuffix(text, "\n"), "\n")}
+	for i, line := range p.lines {
+		if strings.HasPrefix(line, "\t") && strings.TrimSpace(line) != "" {
+			return nil, fmt.Errorf("line %d: tab in indentation", i+1)
+		}
+	}
+	if _, ok := p.peek(); !ok {
+		return docMap{}, nil
+	}
+	v, err := p.node()
+	if err != nil {
+		return nil, err
+	}
+	if li, ok := p.peek(); ok {
+		return nil, fmt.Errorf("line %d: unexpected indentation", li.num)
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int // next line to read
+}
+
+type yamlLine struct {
+	num    int // 1-based
+	indent int
+	text   string // without indentation and comment
+}
+
+// peek returns the next line with content, skipping blank lines, comments
+// and document markers.
+func (p *yamlParser) peek() (yamlLine, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos]
+		text := strings.TrimLeft(raw, " ")
+		if text == "---" || text == "..." || strings.HasPrefix(text, "--- ") {
+			continue
+		}
+		if text = yamlStripComment(text); text == "" {
+			continue
+		}
+		return yamlLine{num: p.pos + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text}, true
+	}
+	return yamlLine{}, false
+}
+
+// node reads the block starting at the next line.
+func (p *yamlParser) node() (any, error) {
+	li, _ := p.peek()
+	if yamlIsItem(li.text) {
+		return p.list(li.indent)
+	}
+	if _, _, ok := yamlSplitKey(li.text); ok {
+		return p.mapping(li.indent)
+	}
+	p.pos++
+	return p.value(li.text, li.indent-1, li.num)
+}
+
+func yamlIsItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) list(indent int) ([]any, error) {
+	items := []any{}
+	for {
+		li, ok := p.peek()
+		if !ok || li.indent != indent || !yamlIsItem(li.text) {
+			return items, nil
+		}
+		rest := strings.TrimLeft(li.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			var v any
+			if next, ok := p.peek(); ok && next.indent > indent {
+				var err error
+				if v, err = p.node(); err != nil {
+					return nil, err
+				}
+			}
+			items = append(items, v)
+			continue
+		}
+		if _, _, isKey := yamlSplitKey(rest); isKey || yamlIsItem(rest) {
+			// Blank out the dash: the rest is a block at its own column.
+			raw := p.lines[p.pos]
+			p.lines[p.pos] = raw[:indent] + " " + raw[indent+1:]
+			v, err := p.node()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		p.pos++
+		v, err := p.value(rest, indent, li.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+}
+
+func (p *yamlParser) mapping(indent int) (docMap, error) {
+	m := docMap{}
+	seen := map[string]bool{}
+	for {
+		li, ok := p.peek()
+		if !ok || li.indent < indent || li.indent == indent && yamlIsItem(li.text) {
+			return m, nil
+		}
+		if li.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", li.num)
+		}
+		key, rest, ok := yamlSplitKey(li.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value", li.num)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", li.num, key)
+		}
+		seen[key] = true
+		p.pos++
+		var v any
+		var err error
+		if rest == "" {
+			next, ok := p.peek()
+			switch {
+			case ok && next.indent > indent:
+				v, err = p.node()
+			case ok && next.indent == indent && yamlIsItem(next.text):
+				v, err = p.list(indent)
+			}
+		} else {
+			v, err = p.value(rest, indent, li.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.add(key, v)
+	}
+}
+
+// value reads the scalar, flow collection or block scalar that starts
+// with text on line num, inside a block at indent.
+func (p *yamlParser) value(text string, indent, num int) (any, error) {
+	switch {
+	case text[0] == '|' || text[0] == '>':
+		return p.block(text, indent, num)
+	case text[0] == '[' || text[0] == '{':
+		return yamlFlow(text, num)
+	case text[0] == '&' || text[0] == '*' || text[0] == '!':
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported", num)
+	case text[0] == '"' || text[0] == '\'':
+		text = p.foldQuoted(text)
+	}
+	return yamlScalarValue(text, num)
+}
+
+// block reads a literal (|) or folded (>) block scalar from the lines
+// after the header.
+func (p *yamlParser) block(header string, indent, num int) (string, error) {
+	folded := header[0] == '>'
+	chomp, width := byte(0), 0
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			width = int(c - '0')
+		default:
+			return "", fmt.Errorf("line %d: bad block scalar header %q", num, header)
+		}
+	}
+	blockIndent := -1
+	if width > 0 {
+		blockIndent = indent + width
+	}
+	var lines []string
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos]
+		n := len(raw) - len(strings.TrimLeft(raw, " "))
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if blockIndent < 0 {
+			blockIndent = max(n, indent+1)
+		}
+		if n < blockIndent || n <= indent {
+			break
+		}
+		lines = append(lines, raw[blockIndent:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var s string
+	if folded {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0 || line != "" && lines[i-1] == "":
+			case line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " "):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		s = b.String()
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+	switch {
+	case len(lines) == 0:
+	case chomp == '+':
+		s += strings.Repeat("\n", trailing+1)
+	case chomp == 0:
+		s += "\n"
+	}
+	return s, nil
+}
+
+// yamlFlow reads a one-line flow list or mapping of scalars.
+func yamlFlow(text string, num int) (any, error) {
+	open, close := text[0], byte(']')
+	if open == '{' {
+		close = '}'
+	}
+	if text[len(text)-1] != close {
+		return nil, fmt.Errorf("line %d: unterminated %c (flow collections must fit on one line)", num, open)
+	}
+	inner := strings.TrimSpace(text[1 : len(text)-1])
+	var parts []string
+	if inner != "" {
+		var quote byte
+		start := 0
+		for i := 0; i < len(inner); i++ {
+			c := inner[i]
+			switch {
+			case quote == '"' && c == '\\':
+				i++
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '[' || c == '{':
+				return nil, fmt.Errorf("line %d: nested flow collections are not supported", num)
+			case c == ',':
+				parts = append(parts, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+		parts = append(parts, strings.TrimSpace(inner[start:]))
+	}
+	if open == '[' {
+		items := []any{}
+		for _, part := range parts {
+			v, err := yamlScalarValue(part, num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	}
+	m := docMap{}
+	for _, part := range parts {
+		key, rest, ok := yamlSplitKey(part)
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value in {}", num)
+		}
+		var v any
+		if rest != "" {
+			var err error
+			if v, err = yamlScalarValue(rest, num); err != nil {
+				return nil, err
+			}
+		}
+		m.add(key, v)
+	}
+	return m, nil
+}
+
+// yamlScalarValue reads a plain or quoted scalar; ~ and null are nil.
+func yamlScalarValue(text string, num int) (any, error) {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	}
+	if text[0] != '"' && text[0] != '\'' {
+		return text, nil
+	}
+	s, n, err := yamlQuoted(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", num, err)
+	}
+	if n != len(text) {
+		return nil, fmt.Errorf("line %d: unexpected text after quoted string", num)
+	}
+	return s, nil
+}
+
+// foldQuoted joins the lines of a quoted scalar that continues past its
+// first line: a line break becomes a space, blank lines become newlines,
+// and a backslash at the end of a double-quoted line joins without space.
+func (p *yamlParser) foldQuoted(text string) string {
+	for {
+		if _, _, err := yamlQuoted(text); err != errYAMLUnterminated {
+			return text
+		}
+		blank := 0
+		for p.pos < len(p.lines) && strings.TrimSpace(p.lines[p.pos]) == "" {
+			blank++
+			p.pos++
+		}
+		if p.pos == len(p.lines) {
+			return text
+		}
+		next := strings.Trim(p.lines[p.pos], " \t")
+		p.pos++
+		trimmed := strings.TrimRight(text, " \t")
+		switch {
+		case text[0] == '"' && (len(trimmed)-len(strings.TrimRight(trimmed, `\`)))%2 == 1:
+			text = trimmed[:len(trimmed)-1] + next
+		case blank > 0:
+			text = trimmed + strings.Repeat("\n", blank) + next
+		default:
+			text = trimmed + " " + next
+		}
+	}
+}
+
+var errYAMLUnterminated = errors.New("unterminated quoted string")
+
+// yamlQuoted unquotes the quoted string at the start of text, returning
+// its length in text.
+func yamlQuoted(text string) (string, int, error) {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] != q:
+		case q == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case q == '\'':
+			return strings.ReplaceAll(text[1:i], "''", "'"), i + 1, nil
+		default:
+			s, err := yamlUnescape(text[1:i])
+			if err != nil {
+				return "", 0, fmt.Errorf("%v in %s", err, text[:i+1])
+			}
+			return s, i + 1, nil
+		}
+	}
+	return "", 0, errYAMLUnterminated
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': `"`, '/': "/", '\\': `\`,
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// yamlUnescape decodes the escapes of a double-quoted scalar.
+func yamlUnescape(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", errors.New("bad escape")
+		}
+		if e, ok := yamlEscapes[s[i]]; ok {
+			b.WriteString(e)
+			continue
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+		if digits == 0 || i+digits >= len(s) {
+			return "", fmt.Errorf("bad escape \\%c", s[i])
+		}
+		r, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("bad escape \\%s", s[i:i+1+digits])
+		}
+		b.WriteRune(rune(r))
+		i += digits
+	}
+	return b.String(), nil
+}
+
+// yamlSplitKey splits "key: rest"; the key may be quoted.
+func yamlSplitKey(text string) (key, rest string, ok bool) {
+	switch {
+	case text[0] == '"' || text[0] == '\'':
+		k, n, err := yamlQuoted(text)
+		if err != nil {
+			return "", "", false
+		}
+		key, text = k, strings.TrimLeft(text[n:], " ")
+	case yamlIsItem(text) || text[0] == '[' || text[0] == '{':
+		return "", "", false
+	default:
+		i := 0
+		for i < len(text) && !(text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ')) {
+			i++
+		}
+		if i == 0 || i == len(text) {
+			return "", "", false
+		}
+		key, text = strings.TrimSpace(text[:i]), text[i:]
+	}
+	if !strings.HasPrefix(text, ":") || len(text) > 1 && text[1] != ' ' {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(text[1:]), true
+}
+
+// yamlStripComment drops a trailing # comment, which starts a line or
+// follows whitespace outside quotes.
+func yamlStripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\', quote == '\'' && c == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:", text[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return strings.TrimRight(text, " \t")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want any
+	}{
+		{"empty", "", docMap{}},
+		{"comments only", "# nothing\n\n---\n", docMap{}},
+		{"mapping", "a: 1\nb: two words\n", docMap{{"a", "1"}, {"b", "two words"}}},
+		{"nested", "a:\n  b:\n    c: x\n  d: y\n", docMap{{"a", docMap{{"b", docMap{{"c", "x"}}}, {"d", "y"}}}}},
+		{"list of scalars", "- a\n- b\n", []any{"a", "b"}},
+		{"list under key", "k:\n  - a\n  - b\n", docMap{{"k", []any{"a", "b"}}}},
+		{"list at key indent", "k:\n- a\n- b\nn: 1\n", docMap{{"k", []any{"a", "b"}}, {"n", "1"}}},
+		{"list of mappings", "- id: a\n  type: text\n- id: b\n", []any{docMap{{"id", "a"}, {"type", "text"}}, docMap{{"id", "b"}}}},
+		{"nested list item", "- - a\n  - b\n", []any{[]any{"a", "b"}}},
+		{"empty item", "-\n- a\n", []any{nil, "a"}},
+		{"nulls", "a:\nb: ~\nc: null\nd: NULL\n", docMap{{"a", nil}, {"b", nil}, {"c", nil}, {"d", nil}}},
+		{"double quoted", `a: "x: \"y\" \t \u00e9 \x41"` + "\n", docMap{{"a", "x: \"y\" \t é A"}}},
+		{"single quoted", "a: 'it''s # not a comment'\n", docMap{{"a", "it's # not a comment"}}},
+		{"quoted key", "\"a: b\": 1\n'y': 2\n", docMap{{"a: b", "1"}, {"y", "2"}}},
+		{"quoted keeps type", "a: \"2026-01-01\"\nb: 'yes'\n", docMap{{"a", "2026-01-01"}, {"b", "yes"}}},
+		{"comment", "a: x # note\nb: x#y\n", docMap{{"a", "x"}, {"b", "x#y"}}},
+		{"colon in value", "url: https://example.com/a?b=c\n", docMap{{"url", "https://example.com/a?b=c"}}},
+		{"literal block", "a: |\n  one\n  two\nb: x\n", docMap{{"a", "one\ntwo\n"}, {"b", "x"}}},
+		{"literal strip", "a: |-\n  one\n\n  two\n", docMap{{"a", "one\n\ntwo"}}},
+		{"literal keep", "a: |+\n  one\n\n", docMap{{"a", "one\n\n"}}},
+		{"literal keep at the end", "a: |+\n  one\n", docMap{{"a", "one\n"}}},
+		{"literal keep without a final newline", "a: |+\n  one", docMap{{"a", "one\n"}}},
+		{"folded block", "a: >\n  one\n  two\n\n  three\n", docMap{{"a", "one two\nthree\n"}}},
+		{"block indentation indicator", "a: |2\n    indented\n  less\n", docMap{{"a", "  indented\nless\n"}}},
+		{"multi-line double quoted", "a: \"one\n  two\n\n  three\\\n  four\"\n", docMap{{"a", "one two\nthreefour"}}},
+		{"flow list", "a: [x, 'y, z', \"w\"]\nb: []\n", docMap{{"a", []any{"x", "y, z", "w"}}, {"b", []any{}}}},
+		{"flow mapping", "a: {x: 1, y: }\n", docMap{{"a", docMap{{"x", "1"}, {"y", nil}}}}},
+		{"bom and crlf", "\ufeffa: 1\r\nb: 2\r\n", docMap{{"a", "1"}, {"b", "2"}}},
+		{"document marker", "---\na: 1\n...\n", docMap{{"a", "1"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("parseYAML(%q): %v", tc.in, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseYAML(%q) = %#v, want %#v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"a: 1\n\tb: 2\n", "line 2: tab in indentation"},
+		{"a: 1\na: 2\n", `line 2: duplicate key "a"`},
+		{"a: 1\n    b: 2\n", "line 2: unexpected indentation"},
+		{"a:\n  b: 1\n c: 2\n", "line 3: unexpected indentation"},
+		{"a: 1\njust text\n", "line 2: want key: value"},
+		{"a: &x 1\n", "line 1: anchors, aliases and tags are not supported"},
+		{"a: *x\n", "line 1: anchors, aliases and tags are not supported"},
+		{"a: !!str 1\n", "line 1: anchors, aliases and tags are not supported"},
+		{"a: [x, y\n", "line 1: unterminated ["},
+		{"a: [x, [y]]\n", "line 1: nested flow collections are not supported"},
+		{"a: {x}\n", "line 1: want key: value in {}"},
+		{`a: "\q"` + "\n", `line 1: bad escape \q`},
+		{`a: "\u12"` + "\n", `line 1: bad escape`},
+		{"a: 'x' y\n", "line 1: unexpected text after quoted string"},
+	} {
+		_, err := parseYAML([]byte(tc.in))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parseYAML(%q) error = %v, want %q", tc.in, err, tc.want)
+		}
+	}
+}
+
+// TestYAMLQuote checks that every scalar reads back as the same string,
+// in particular the ones YAML parsers would turn into something else if
+// they were left plain.
+func TestYAMLQuote(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		plain bool
+	}{
+		{"text", true},
+		{"two words", true},
+		{"https://example.com/a?b=c", true},
+		{"C#", true},
+		{"file.md", true},
+		{"2026-01", true},
+		{"12:75", true}, // not base 60: the digits after a colon stop at 59
+		{"", false},
+		{" padded", false},
+		{"trailing ", false},
+		{"key: value", false},
+		{"ends with:", false},
+		{"a #comment", false},
+		{"- item", false},
+		{"#tag", false},
+		{"'quoted'", false},
+		{`"quoted"`, false},
+		{"*alias", false},
+		{"&anchor", false},
+		{"!tag", false},
+		{"|", false},
+		{">", false},
+		{"[list]", false},
+		{"{map}", false},
+		{"@handle", false},
+		{"%directive", false},
+		{"tab\there", false},
+		{"line\nbreak", false},
+		{"bell\a", false},
+		{"true", false},
+		{"False", false},
+		{"null", false},
+		{"~", false},
+		{"y", false},
+		{"Y", false},
+		{"n", false},
+		{"N", false},
+		{"yes", false},
+		{"No", false},
+		{"on", false},
+		{"OFF", false},
+		{"12", false},
+		{"-3", false},
+		{"0x1F", false},
+		{"0o17", false},
+		{"1_000", false},
+		{"3.14", false},
+		{"1e3", false},
+		{".inf", false},
+		{"-.Inf", false},
+		{".NaN", false},
+		{"2026-01-01", false},
+		{"2026-1-1", false},
+		{"2026-01-01T10:00:00Z", false},
+		{"2026-01-01t10:00:00.5+02:00", false},
+		{"2026-01-01 10:00:00", false},
+		{"2026-01-01  10:00:00 Z", false},
+		{"1:30", false},
+		{"-1:30:00", false},
+		{"190:20:30.15", false},
+	} {
+		got := yamlQuote(tc.in)
+		if plain := got == tc.in; plain != tc.plain {
+			t.Errorf("yamlQuote(%q) = %s, want it plain: %v", tc.in, got, tc.plain)
+		}
+		doc, err := parseYAML([]byte("k: " + got + "\n"))
+		if err != nil {
+			t.Errorf("yamlQuote(%q) = %s, which doesn't parse: %v", tc.in, got, err)
+			continue
+		}
+		if want := (docMap{{"k", tc.in}}); !reflect.DeepEqual(doc, want) {
+			t.Errorf("yamlQuote(%q) = %s, which reads back as %#v", tc.in, got, doc)
+		}
+	}
+}